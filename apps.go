@@ -22,10 +22,10 @@ const (
 // NamePattern is a regex pattern that app names must conform to.
 var NamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{2,30}$`)
 
-// appNameFromRepo generates a name from a Repo
+// AppNameFromRepo generates a name from a Repo
 //
 //	remind101/r101-api => r101-api
-func appNameFromRepo(repo string) string {
+func AppNameFromRepo(repo string) string {
 	p := strings.Split(repo, "/")
 	return p[len(p)-1]
 }
@@ -91,6 +91,10 @@ type App struct {
 
 	// Maintenance defines whether the app is in maintenance mode or not.
 	Maintenance bool
+
+	// AutoRollback defines whether deployments that fail should be
+	// automatically rolled back. When nil, the Empire default is used.
+	AutoRollback *bool
 }
 
 // IsValid returns an error if the app isn't valid.
@@ -155,6 +159,10 @@ func (s *appsService) Destroy(ctx context.Context, db *gorm.DB, app *App) error
 		return err
 	}
 
+	if err := canariesDestroyForApp(db, app.ID); err != nil {
+		return err
+	}
+
 	return s.Scheduler.Remove(ctx, app.ID)
 }
 
@@ -169,7 +177,7 @@ func (s *appsService) Restart(ctx context.Context, db *gorm.DB, opts RestartOpts
 func (s *appsService) Scale(ctx context.Context, db *gorm.DB, opts ScaleOpts) ([]*Process, error) {
 	app := opts.App
 
-	release, err := releasesFind(db, ReleasesQuery{App: app})
+	release, err := releasesCurrent(db, app)
 	if err != nil {
 		return nil, err
 	}
@@ -188,6 +196,12 @@ func (s *appsService) Scale(ctx context.Context, db *gorm.DB, opts ScaleOpts) ([
 			return nil, &ValidationError{Err: fmt.Errorf("no %s process type in release", t)}
 		}
 
+		// For autoscaled processes, the quantity is the baseline that
+		// the autoscaler won't scale below.
+		if a := p.Autoscaling; a != nil && q > a.Max {
+			return nil, &ValidationError{Err: fmt.Errorf("%s is autoscaled to at most %d instances", t, a.Max)}
+		}
+
 		eventUpdate := event.Updates[i]
 		eventUpdate.PreviousQuantity = p.Quantity
 		eventUpdate.PreviousConstraints = p.Constraints()
@@ -207,12 +221,12 @@ func (s *appsService) Scale(ctx context.Context, db *gorm.DB, opts ScaleOpts) ([
 		return nil, err
 	}
 
-	err = s.releases.Release(ctx, release, nil)
+	err = s.releases.Release(ctx, db, release, nil)
 	if err != nil {
 		return ps, err
 	}
 
-	return ps, s.PublishEvent(event)
+	return ps, s.publishEvent(db, event)
 }
 
 // appsEnsureRepo will set the repo if it's not set.
@@ -229,7 +243,7 @@ func appsEnsureRepo(db *gorm.DB, app *App, repo string) error {
 // appsFindOrCreateByRepo first attempts to find an app by repo, falling back to
 // creating a new app.
 func appsFindOrCreateByRepo(db *gorm.DB, repo string) (*App, error) {
-	n := appNameFromRepo(repo)
+	n := AppNameFromRepo(repo)
 	a, err := appsFind(db, AppsQuery{Name: &n})
 	if err != nil && err != gorm.RecordNotFound {
 		return a, err
@@ -0,0 +1,207 @@
+package empire
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/internal/uuid"
+	"github.com/remind101/empire/pkg/headerutil"
+	"github.com/remind101/empire/pkg/timex"
+)
+
+// AuditEvent is a record of an Event that was published, which is kept in the
+// audit log.
+type AuditEvent struct {
+	// A unique uuid that identifies this event.
+	ID string
+
+	// The app that this event relates to, if any. Since apps can be
+	// destroyed, the name of the app is recorded as well.
+	AppID   *string
+	AppName *string
+
+	// The name of the user that triggered the event, if any.
+	UserName *string
+
+	// The name of the event (e.g. "deploy").
+	Event string
+
+	// The human readable message for the event.
+	Message string
+
+	// The JSON representation of the event.
+	Data string `sql:"type:json"`
+
+	// The time that the event happened.
+	CreatedAt *time.Time
+}
+
+// AuditEventsQuery is a scope implementation for common things to filter the
+// audit log by.
+type AuditEventsQuery struct {
+	// If provided, finds the event with the given id.
+	ID *string
+
+	// If provided, finds events for the app with the given name.
+	App *string
+
+	// If provided, finds events that were triggered by the given user.
+	User *string
+
+	// If provided, finds events with the given name.
+	Event *string
+
+	// If provided, finds events that happened at or after this time.
+	Since *time.Time
+
+	// If provided, finds events that happened before this time.
+	Until *time.Time
+
+	// If provided, uses the limit and sorting parameters specified in the
+	// range.
+	Range headerutil.Range
+}
+
+// scope implements the scope interface.
+func (q AuditEventsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.App != nil {
+		scope = append(scope, fieldEquals("app_name", *q.App))
+	}
+
+	if q.User != nil {
+		scope = append(scope, fieldEquals("user_name", *q.User))
+	}
+
+	if q.Event != nil {
+		scope = append(scope, fieldEquals("event", *q.Event))
+	}
+
+	if q.Since != nil {
+		scope = append(scope, scopeFunc(func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at >= ?", *q.Since)
+		}))
+	}
+
+	if q.Until != nil {
+		scope = append(scope, scopeFunc(func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at < ?", *q.Until)
+		}))
+	}
+
+	scope = append(scope, inRange(q.Range.WithDefaults(q.DefaultRange())))
+
+	return scope.scope(db)
+}
+
+// DefaultRange returns the default headerutil.Range used if values aren't
+// provided.
+func (q AuditEventsQuery) DefaultRange() headerutil.Range {
+	sort, order, max := "created_at", "desc", 100
+	return headerutil.Range{
+		Sort:  &sort,
+		Order: &order,
+		Max:   &max,
+	}
+}
+
+// auditEventsFind returns the first matching audit event.
+func auditEventsFind(db *gorm.DB, scope scope) (*AuditEvent, error) {
+	var event AuditEvent
+	return &event, first(db, scope, &event)
+}
+
+// auditEvents returns all audit events matching the scope.
+func auditEvents(db *gorm.DB, scope scope) ([]*AuditEvent, error) {
+	var events []*AuditEvent
+	return events, find(db, scope, &events)
+}
+
+// auditEventsCreate inserts the event into the audit log. Events that are
+// delivered from the outbox keep the id of the outbox event, so an event that's
+// delivered more than once is only recorded once.
+//
+// When an event is recorded, its id is sent as a notification on the
+// auditEventsChannel, so that it can be streamed to subscribers on every Empire
+// instance.
+func auditEventsCreate(db *gorm.DB, e *AuditEvent) error {
+	return db.Exec(`WITH inserted AS (
+  INSERT INTO audit_events (id, app_id, app_name, user_name, event, message, data, created_at)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  ON CONFLICT (id) DO NOTHING
+  RETURNING id
+)
+SELECT pg_notify(?, id::text) FROM inserted`, e.ID, e.AppID, e.AppName, e.UserName, e.Event, e.Message, e.Data, e.CreatedAt, auditEventsChannel).Error
+}
+
+// AuditLog is an EventStream implementation that records events in the
+// database, so that they can be queried later with Empire.AuditEvents.
+//
+// The user and app that an event relates to are taken from the User and App
+// fields of the event, if present.
+type AuditLog struct {
+	db *gorm.DB
+}
+
+// NewAuditLog returns a new AuditLog that records events in db.
+func NewAuditLog(db *DB) *AuditLog {
+	return &AuditLog{db: db.DB}
+}
+
+// PublishEvent implements the EventStream interface.
+func (l *AuditLog) PublishEvent(event Event) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var fields struct {
+		User string
+		App  string
+	}
+	// Not all events are JSON objects with these fields.
+	json.Unmarshal(raw, &fields)
+
+	e := &AuditEvent{
+		Event:   event.Event(),
+		Message: event.String(),
+		Data:    string(raw),
+	}
+
+	if o, ok := event.(outboxEventer); ok {
+		e.ID = o.outboxEvent().ID
+		e.CreatedAt = o.outboxEvent().CreatedAt
+	} else {
+		t := timex.Now()
+		e.ID = uuid.New()
+		e.CreatedAt = &t
+	}
+
+	if fields.User != "" {
+		e.UserName = &fields.User
+	}
+
+	if fields.App != "" {
+		e.AppName = &fields.App
+	}
+
+	if event, ok := event.(AppEvent); ok {
+		if app := event.GetApp(); app != nil {
+			e.AppID = &app.ID
+			e.AppName = &app.Name
+		}
+	}
+
+	return auditEventsCreate(l.db, e)
+}
+
+// AuditEvents returns the events in the audit log matching the query.
+func (e *Empire) AuditEvents(q AuditEventsQuery) ([]*AuditEvent, error) {
+	return auditEvents(e.db, q)
+}
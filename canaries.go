@@ -0,0 +1,174 @@
+package empire
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// Canary represents a release that's being gradually rolled out, alongside the
+// current stable release of an app. While a canary is in progress, a
+// percentage of traffic to ALB processes is sent to the canary release, and the
+// rest is sent to the stable release.
+type Canary struct {
+	// A unique uuid that identifies this canary.
+	ID string
+
+	// The app that this canary belongs to.
+	AppID string
+	App   *App
+
+	// The version of the release that's being rolled out.
+	Version int
+
+	// The version of the release that the rest of the traffic is sent to.
+	StableVersion int
+
+	// The percentage of traffic (1-99) that's sent to the canary release.
+	Weight int
+
+	// If provided, the canary will be automatically promoted at this time.
+	PromoteAt *time.Time
+
+	// The time that this canary was created.
+	CreatedAt *time.Time
+}
+
+// BeforeCreate sets created_at before inserting.
+func (c *Canary) BeforeCreate() error {
+	t := timex.Now()
+	c.CreatedAt = &t
+	return nil
+}
+
+// canariesService provides methods for promoting and aborting canaries.
+type canariesService struct {
+	*Empire
+}
+
+// Promote ends the canary, and submits the canary release, which sends all
+// traffic to the new release.
+func (s *canariesService) Promote(ctx context.Context, db *gorm.DB, c *Canary) (*Release, error) {
+	if err := canariesDestroy(db, c); err != nil {
+		return nil, err
+	}
+
+	r, err := releasesFind(db, ReleasesQuery{App: c.App, Version: &c.Version})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, s.releases.Release(ctx, db, r, nil)
+}
+
+// Abort ends the canary, marks the canary release as failed, and creates a new
+// release from the stable release, which sends all traffic back to the stable
+// release.
+func (s *canariesService) Abort(ctx context.Context, db *gorm.DB, c *Canary, user *User, message string) (*Release, error) {
+	if err := canariesDestroy(db, c); err != nil {
+		return nil, err
+	}
+
+	r, err := releasesFind(db, ReleasesQuery{App: c.App, Version: &c.Version})
+	if err != nil {
+		return nil, err
+	}
+
+	r.Failed = true
+	if err := releasesUpdate(db, r); err != nil {
+		return nil, err
+	}
+
+	return s.releases.Rollback(ctx, db, RollbackOpts{
+		User:    user,
+		App:     c.App,
+		Version: c.StableVersion,
+		Message: message,
+	})
+}
+
+// CanariesQuery is a scope implementation for common things to filter canaries
+// by.
+type CanariesQuery struct {
+	// If provided, finds the canary for the given app.
+	App *App
+
+	// If provided, finds canaries that should be promoted before this time.
+	PromoteBefore *time.Time
+}
+
+// scope implements the scope interface.
+func (q CanariesQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.App != nil {
+		scope = append(scope, forApp(q.App))
+	}
+
+	if q.PromoteBefore != nil {
+		scope = append(scope, scopeFunc(func(db *gorm.DB) *gorm.DB {
+			return db.Where("promote_at <= ?", *q.PromoteBefore)
+		}))
+	}
+
+	return scope.scope(db)
+}
+
+// These associations are always available on a Canary.
+var canariesPreload = preload("App")
+
+// canariesFind returns the first matching canary.
+func canariesFind(db *gorm.DB, scope scope) (*Canary, error) {
+	var canary Canary
+	scope = composedScope{canariesPreload, scope}
+	return &canary, first(db, scope, &canary)
+}
+
+// canaries returns all canaries matching the scope.
+func canaries(db *gorm.DB, scope scope) ([]*Canary, error) {
+	var canaries []*Canary
+	scope = composedScope{canariesPreload, scope}
+	return canaries, find(db, scope, &canaries)
+}
+
+// canaryForApp returns the canary that's in progress for the app, or nil if
+// there isn't one.
+func canaryForApp(db *gorm.DB, app *App) (*Canary, error) {
+	c, err := canariesFind(db, CanariesQuery{App: app})
+	if err != nil {
+		if err == gorm.RecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// canariesCreate inserts a new canary, replacing any existing canary for the
+// app.
+func canariesCreate(db *gorm.DB, c *Canary) (*Canary, error) {
+	if err := canariesDestroyForApp(db, c.AppID); err != nil {
+		return c, err
+	}
+	return c, db.Create(c).Error
+}
+
+// canariesDestroyForApp removes any canary for the app.
+func canariesDestroyForApp(db *gorm.DB, appID string) error {
+	return db.Where("app_id = ?", appID).Delete(Canary{}).Error
+}
+
+// canariesDestroy removes the canary. If the canary was already removed (e.g.
+// it was promoted by another process), gorm.RecordNotFound is returned.
+func canariesDestroy(db *gorm.DB, c *Canary) error {
+	result := db.Delete(c)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return gorm.RecordNotFound
+	}
+	return nil
+}
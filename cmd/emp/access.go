@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+var cmdAccess = &Command{
+	Run:      runAccess,
+	Usage:    "access",
+	Alias:    "access:list",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  0,
+	Short:    "list app collaborators" + extra,
+	Long: `
+Lists the collaborators on an app, which are the users that can make changes
+to it.
+
+Examples:
+
+    $ emp access -a acme-inc
+    ejholmes
+    mwildehahn
+`,
+}
+
+func runAccess(cmd *Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	collaborators, err := client.CollaboratorList(appname, nil)
+	must(err)
+
+	for _, c := range collaborators {
+		listRec(w, c.User.Email)
+	}
+}
+
+var cmdAccessAdd = &Command{
+	Run:      runAccessAdd,
+	Usage:    "access-add <user>",
+	Alias:    "access:add",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "give a user access to an app" + extra,
+	Long: `
+Adds a user as a collaborator on an app, which gives them access to make
+changes to it.
+
+Examples:
+
+    $ emp access-add -a acme-inc mwildehahn
+    Added mwildehahn to acme-inc.
+`,
+}
+
+func runAccessAdd(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	user := args[0]
+	_, err := client.CollaboratorCreate(appname, user, nil)
+	must(err)
+	log.Printf("Added %s to %s.", user, appname)
+}
+
+var cmdAccessRemove = &Command{
+	Run:      runAccessRemove,
+	Usage:    "access-remove <user>",
+	Alias:    "access:remove",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "remove a user's access to an app" + extra,
+	Long: `
+Removes a collaborator from an app.
+
+Examples:
+
+    $ emp access-remove -a acme-inc mwildehahn
+    Removed mwildehahn from acme-inc.
+`,
+}
+
+func runAccessRemove(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	user := args[0]
+	must(client.CollaboratorDelete(appname, user))
+	log.Printf("Removed %s from %s.", user, appname)
+}
@@ -53,6 +53,6 @@ func runAPI(cmd *Command, args []string) {
 		body = os.Stdin
 	}
 	if err := client.APIReq(os.Stdout, method, args[1], body, nil); err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 }
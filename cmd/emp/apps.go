@@ -63,7 +63,7 @@ func runApps(cmd *Command, names []string) {
 		for _ = range names {
 			select {
 			case err := <-errch:
-				printFatal(err.Error())
+				printFatal("%s", err)
 			case app := <-appch:
 				if app != nil {
 					apps = append(apps, fromApp(*app))
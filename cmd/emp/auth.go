@@ -52,7 +52,7 @@ func runAuthorize(cmd *Command, args []string) {
 	var twoFactorCode string
 	fmt.Printf("Enter two-factor auth code: ")
 	if _, err := fmt.Scanln(&twoFactorCode); err != nil {
-		printFatal("reading two-factor auth code: " + err.Error())
+		printFatal("reading two-factor auth code: %s", err)
 	}
 
 	client.AdditionalHeaders.Set("Heroku-Two-Factor-Code", twoFactorCode)
@@ -79,17 +79,17 @@ func runCreds(cmd *Command, args []string) {
 
 	nrc, err = hkclient.LoadNetRc()
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 
 	u, err := url.Parse(apiURL)
 	if err != nil {
-		printFatal("could not parse API url: " + err.Error())
+		printFatal("could not parse API url: %s", err)
 	}
 
 	user, pass, err := nrc.GetCreds(u)
 	if err != nil {
-		printFatal("could not get credentials: " + err.Error())
+		printFatal("could not get credentials: %s", err)
 	}
 
 	fmt.Println(user, pass)
@@ -113,7 +113,7 @@ type OAuthWebFlow struct {
 	srv *http.Server
 	port int
 	email string // The email associated with the GitHub token
-	githubToken string // A GitHub bearer token for API access
+	githubToken string // A GitHub bearer token for API access, or an OpenID Connect ID token
 	empireToken string // A signed JTW containing details about the Empire login
 	empireAddress string // The server address for the empire token
 	startUrl string
@@ -143,7 +143,7 @@ func (wf*OAuthWebFlow) handleOAuthToken(w http.ResponseWriter, r *http.Request)
 
 func (wf*OAuthWebFlow) handleOAuthError(w http.ResponseWriter, r *http.Request) {
 	defer wf.wg.Done() // let main know we are done cleaning up
-	fmt.Fprintf(w, r.FormValue("err"))
+	fmt.Fprint(w, r.FormValue("err"))
 }
 
 func (wf*OAuthWebFlow) waitForGithubToken() {
@@ -164,7 +164,7 @@ func (wf*OAuthWebFlow) fetchEmpireToken() {
 	// the original username/password authentication endpoint
 	wf.empireAddress, wf.empireToken, err = attemptLogin("$token$", wf.githubToken, "")
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 }
 
@@ -182,6 +182,12 @@ func (wf*OAuthWebFlow) extractEmail() {
 
 	if emailEntry != nil {
 		wf.email = emailEntry.(string)
+	} else if strings.Count(wf.githubToken, ".") == 2 {
+		// With OpenID Connect, the web flow returns an ID token (a JWT)
+		// instead of a GitHub token, and the user's name comes from it.
+		if name, ok := user["Name"].(string); ok {
+			wf.email = name
+		}
 	} else {
 		ctx := context.Background()
 		ts := oauth2.StaticTokenSource(
@@ -191,7 +197,7 @@ func (wf*OAuthWebFlow) extractEmail() {
 		client := github.NewClient(tc)
 		user, _, err := client.Users.Get("")
 		if err != nil {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 		wf.email = *user.Email
 	}
@@ -221,7 +227,7 @@ func newWebFlow() *OAuthWebFlow {
 		// Bind to a free local port
 		listener, err := net.Listen("tcp", ":0")
 		if err != nil {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 
 		wf.port = listener.Addr().(*net.TCPAddr).Port
@@ -286,7 +292,7 @@ func openBrowser(url string) {
 		err = fmt.Errorf("unsupported platform")
 	}
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 }
 
@@ -299,7 +305,7 @@ func parseUnsignedToken(tokenString string) *jwt.Token {
 
 	// We can't validate the signature as the client, so ignore ONLY that specific error
 	if err != nil && err.(*jwt.ValidationError).Errors != jwt.ValidationErrorSignatureInvalid {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 
 	return token
@@ -360,7 +366,7 @@ func runLogin(cmd *Command, args []string) {
 	_, err := fmt.Scanln(&email)
 	switch {
 	case err != nil && err.Error() != "unexpected newline":
-		printFatal(err.Error())
+		printFatal("%s", err)
 	case email == "" && oldEmail == "":
 		printFatal("email is required.")
 	case email == "":
@@ -374,7 +380,7 @@ func runLogin(cmd *Command, args []string) {
 	case err.Error() == "unexpected newline":
 		printFatal("password is required.")
 	default:
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 
 	address, token, err := attemptLogin(email, password, "")
@@ -384,7 +390,7 @@ func runLogin(cmd *Command, args []string) {
 			var twoFactorCode string
 			fmt.Printf("Enter two-factor auth code: ")
 			if _, err := fmt.Scanln(&twoFactorCode); err != nil {
-				printFatal("reading two-factor auth code: " + err.Error())
+				printFatal("reading two-factor auth code: %s", err)
 			}
 			address, token, err = attemptLogin(email, password, twoFactorCode)
 			must(err)
@@ -401,12 +407,12 @@ func runLogin(cmd *Command, args []string) {
 func persistCredentials(address string, email string, token string) {
 	nrc, err := hkclient.LoadNetRc()
 	if err != nil {
-		printFatal("loading netrc: " + err.Error())
+		printFatal("loading netrc: %s", err)
 	}
 
 	err = nrc.SaveCreds(address, email, token)
 	if err != nil {
-		printFatal("saving new token: " + err.Error())
+		printFatal("saving new token: %s", err)
 	}
 }
 
@@ -469,17 +475,17 @@ func runLogout(cmd *Command, args []string) {
 
 	u, err := url.Parse(client.URL)
 	if err != nil {
-		printFatal("couldn't parse client URL: " + err.Error())
+		printFatal("couldn't parse client URL: %s", err)
 	}
 
 	nrc, err = hkclient.LoadNetRc()
 	if err != nil {
-		printError(err.Error())
+		printError("%s", err)
 	}
 
 	err = removeCreds(strings.Split(u.Host, ":")[0])
 	if err != nil {
-		printFatal("saving new netrc: " + err.Error())
+		printFatal("saving new netrc: %s", err)
 	}
 	fmt.Println("Logged out.")
 }
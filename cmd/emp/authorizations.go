@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+var cmdAuthorizations = &Command{
+	Run:      runAuthorizations,
+	Usage:    "authorizations",
+	Alias:    "authorizations:list",
+	Category: "emp",
+	NumArgs:  0,
+	Short:    "list service accounts" + extra,
+	Long: `
+Lists the service accounts, along with who created them, their scopes, when
+their token expires, and when their token was last used.
+
+Examples:
+
+    $ emp authorizations
+    ci      ejholmes  deploy,app:acme-*  Apr  2 15:04  Jan  2 15:04
+    github  ejholmes  read               Mar  1 09:00  never
+`,
+}
+
+func runAuthorizations(cmd *Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	cmd.AssertNumArgsCorrect(args)
+	authorizations, err := client.OAuthAuthorizationList(nil)
+	must(err)
+
+	for _, a := range authorizations {
+		expires := "never"
+		if a.AccessToken != nil && a.AccessToken.ExpiresIn != nil {
+			expires = prettyTime{time.Now().Add(time.Duration(*a.AccessToken.ExpiresIn) * time.Second)}.String()
+		}
+		lastUsed := "never"
+		if a.LastUsedAt != nil {
+			lastUsed = prettyTime{*a.LastUsedAt}.String()
+		}
+		listRec(w, a.Description, a.CreatedBy, strings.Join(a.Scope, ","), expires, lastUsed)
+	}
+}
+
+var (
+	authorizationScopes    string
+	authorizationExpiresIn string
+)
+
+var cmdAuthorizationsCreate = &Command{
+	Run:      runAuthorizationsCreate,
+	Usage:    "authorizations-create <name> [-s <scopes>] [--expires-in <duration>]",
+	Alias:    "authorizations:create",
+	Category: "emp",
+	NumArgs:  1,
+	Short:    "create a service account" + extra,
+	Long: `
+Creates a service account, and prints its API token. The token is only shown
+once, so store it somewhere safe.
+
+Scopes are comma separated, and limit what the service account can do:
+
+    global        allow any action
+    read          allow actions that only read apps
+    deploy        allow deploying apps
+    app:<pattern> limit the other scopes to apps matching a pattern
+
+Options:
+
+    -s, --scopes      comma separated list of scopes (default: read)
+    --expires-in      duration after which the token expires (e.g. 720h).
+                      Defaults to the maximum allowed by the server.
+
+Examples:
+
+    $ emp authorizations-create ci -s deploy,app:acme-*
+    Created service account ci.
+    emp_0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+`,
+}
+
+func init() {
+	cmdAuthorizationsCreate.Flag.StringVarP(&authorizationScopes, "scopes", "s", "read", "comma separated list of scopes")
+	cmdAuthorizationsCreate.Flag.StringVar(&authorizationExpiresIn, "expires-in", "", "duration after which the token expires")
+}
+
+func runAuthorizationsCreate(cmd *Command, args []string) {
+	cmd.AssertNumArgsCorrect(args)
+	name := args[0]
+
+	opts := &heroku.OAuthAuthorizationCreateOpts{Description: &name}
+	if authorizationExpiresIn != "" {
+		d, err := time.ParseDuration(authorizationExpiresIn)
+		if err != nil {
+			printFatal("invalid expiration: %s", err)
+		}
+		expiresIn := int(d.Seconds())
+		opts.ExpiresIn = &expiresIn
+	}
+
+	a, err := client.OAuthAuthorizationCreate(strings.Split(authorizationScopes, ","), opts)
+	must(err)
+	log.Printf("Created service account %s.", name)
+	fmt.Println(a.AccessToken.Token)
+}
+
+var cmdAuthorizationsRevoke = &Command{
+	Run:      runAuthorizationsRevoke,
+	Usage:    "authorizations-revoke <name>",
+	Alias:    "authorizations:revoke",
+	Category: "emp",
+	NumArgs:  1,
+	Short:    "remove a service account" + extra,
+	Long: `
+Removes a service account, which revokes its API token.
+
+Examples:
+
+    $ emp authorizations-revoke ci
+    Revoked service account ci.
+`,
+}
+
+func runAuthorizationsRevoke(cmd *Command, args []string) {
+	cmd.AssertNumArgsCorrect(args)
+	name := args[0]
+	must(client.OAuthAuthorizationDelete(name))
+	log.Printf("Revoked service account %s.", name)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+var cmdAutoRollbackEnable = &Command{
+	Run:      maybeMessage(runAutoRollbackEnable),
+	Usage:    "autorollback-enable",
+	NeedsApp: true,
+	Category: "app",
+	Short:    "enable automatic rollbacks" + extra,
+	Long: `
+Enables automatic rollbacks on an app. When enabled, deployments that fail to
+stabilize are automatically rolled back to the previous release.
+Example:
+    $ emp autorollback-enable -a <myapp>
+    Enabled automatic rollbacks on myapp.
+`,
+}
+
+func runAutoRollbackEnable(cmd *Command, args []string) {
+	message := getMessage()
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	enabled := true
+	app, err := client.AppUpdate(mustApp(), &heroku.AppUpdateOpts{AutoRollback: &enabled}, message)
+	must(err)
+	log.Printf("Enabled automatic rollbacks on %s.", app.Name)
+}
+
+var cmdAutoRollbackDisable = &Command{
+	Run:      maybeMessage(runAutoRollbackDisable),
+	Usage:    "autorollback-disable",
+	NeedsApp: true,
+	Category: "app",
+	Short:    "disable automatic rollbacks" + extra,
+	Long: `
+Disables automatic rollbacks on an app.
+Example:
+    $ emp autorollback-disable -a <myapp>
+    Disabled automatic rollbacks on myapp.
+`,
+}
+
+func runAutoRollbackDisable(cmd *Command, args []string) {
+	message := getMessage()
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	enabled := false
+	app, err := client.AppUpdate(mustApp(), &heroku.AppUpdateOpts{AutoRollback: &enabled}, message)
+	must(err)
+	log.Printf("Disabled automatic rollbacks on %s.", app.Name)
+}
+
+type fmtAutoRollback struct {
+	enabled *bool
+}
+
+func (f fmtAutoRollback) String() string {
+	if f.enabled == nil {
+		return "default"
+	}
+	if *f.enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+var cmdCanary = &Command{
+	Run:      runCanary,
+	Usage:    "canary",
+	NeedsApp: true,
+	Category: "deploy",
+	Short:    "show the canary that's in progress for an app" + extra,
+	Long: `
+Canary shows the release that's being rolled out as a canary, and the release
+that's receiving the rest of the traffic.
+
+Example:
+
+    $ emp canary -a <myapp>
+    Canary:      v5 (10% of traffic)
+    Stable:      v4
+    Promote At:  Jan 1 13:25
+`,
+}
+
+func runCanary(cmd *Command, args []string) {
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	canary, err := client.CanaryInfo(mustApp())
+	must(err)
+	fmt.Printf("Canary:      v%d (%d%% of traffic)\n", canary.Version, canary.Weight)
+	fmt.Printf("Stable:      v%d\n", canary.StableVersion)
+	if canary.PromoteAt != nil {
+		fmt.Printf("Promote At:  %s\n", prettyTime{canary.PromoteAt.In(time.Local)})
+	}
+}
+
+var cmdCanaryPromote = &Command{
+	Run:      maybeMessage(runCanaryPromote),
+	Usage:    "canary-promote",
+	NeedsApp: true,
+	Category: "deploy",
+	Short:    "send all traffic to the canary release" + extra,
+	Long: `
+Promotes the canary that's in progress for an app, sending all traffic to the
+canary release.
+
+Example:
+
+    $ emp canary-promote -a <myapp>
+    Promoted v5 of myapp.
+`,
+}
+
+func runCanaryPromote(cmd *Command, args []string) {
+	message := getMessage()
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	appname := mustApp()
+	r, err := client.CanaryPromote(appname, message)
+	must(err)
+	log.Printf("Promoted v%d of %s.", r.Version, appname)
+}
+
+var cmdCanaryAbort = &Command{
+	Run:      maybeMessage(runCanaryAbort),
+	Usage:    "canary-abort",
+	NeedsApp: true,
+	Category: "deploy",
+	Short:    "abort the canary and roll back to the stable release" + extra,
+	Long: `
+Aborts the canary that's in progress for an app. The canary release is marked
+as failed, and a new release is created from the stable release.
+
+Example:
+
+    $ emp canary-abort -a <myapp>
+    Aborted canary, myapp is now on v6.
+`,
+}
+
+func runCanaryAbort(cmd *Command, args []string) {
+	message := getMessage()
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	appname := mustApp()
+	r, err := client.CanaryAbort(appname, message)
+	must(err)
+	log.Printf("Aborted canary, %s is now on v%d.", appname, r.Version)
+}
@@ -4,17 +4,24 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/term"
 	"github.com/remind101/empire/pkg/heroku"
 )
 
-var stream bool
+var (
+	stream   bool
+	canary   string
+	bakeTime string
+	detach   bool
+)
 
 var cmdDeploy = &Command{
 	Run:             maybeMessage(runDeploy),
-	Usage:           "deploy [<registry>]<image>:[<tag>] [-s]",
+	Usage:           "deploy [<registry>]<image>:[<tag>] [-s] [--canary <percent>] [--bake <duration>] [--detach]",
 	OptionalApp:     true,
 	OptionalMessage: true,
 	Category:        "deploy",
@@ -28,6 +35,17 @@ Options:
     command will wait until the scheduler has finished deploying the new
     release.
 
+    --canary roll out the release as a canary, which receives the given
+    percentage of traffic to processes exposed through an ALB. The rest of the
+    traffic is sent to the current release until the canary is promoted with
+    canary-promote, or aborted with canary-abort.
+
+    --bake automatically promote the canary after the given duration (e.g. 30m).
+
+    --detach queue the deployment and return immediately, instead of waiting
+    for it to finish. The progress of the deployment can be followed with
+    deployments-watch.
+
 Examples:
 
     $ emp deploy remind101/acme-inc:latest
@@ -43,16 +61,29 @@ Examples:
     Status: Created new release v1 for acme-inc
     $ emp releases
     v1    Jan 1 12:55  Deploy remind101/acme-inc:latest
+    $ emp deploy remind101/acme-inc:master --canary 10% --bake 30m
+    ...
+    Status: Created new release v2 for acme-inc
+    Status: Rolling out v2 as a canary with 10% of traffic
+    $ emp deploy remind101/acme-inc:master --detach
+    Queued deployment 01234567-89ab-cdef-0123-456789abcdef to acme-inc.
+    Run 'emp deployments-watch 01234567-89ab-cdef-0123-456789abcdef -a acme-inc' to follow it.
 `,
 }
 
 func init() {
 	cmdDeploy.Flag.BoolVarP(&stream, "stream", "s", false, "boolean to enable the status stream")
+	cmdDeploy.Flag.StringVar(&canary, "canary", "", "percentage of traffic to send to the new release")
+	cmdDeploy.Flag.StringVar(&bakeTime, "bake", "", "duration after which the canary is automatically promoted")
+	cmdDeploy.Flag.BoolVar(&detach, "detach", false, "queue the deployment and return immediately")
 }
 
 type PostDeployForm struct {
 	Image  string `json:"image"`
 	Stream bool   `json:"stream"`
+	Canary int    `json:"canary,omitempty"`
+	Bake   string `json:"bake,omitempty"`
+	Detach bool   `json:"detach,omitempty"`
 }
 
 func runDeploy(cmd *Command, args []string) {
@@ -64,7 +95,14 @@ func runDeploy(cmd *Command, args []string) {
 
 	image := args[0]
 	message := getMessage()
-	form := &PostDeployForm{Image: image, Stream: stream}
+	form := &PostDeployForm{Image: image, Stream: stream, Bake: bakeTime}
+	if canary != "" {
+		weight, err := strconv.Atoi(strings.TrimSuffix(canary, "%"))
+		if err != nil {
+			printFatal("Invalid canary percentage: %s", canary)
+		}
+		form.Canary = weight
+	}
 
 	var endpoint string
 	appName, _ := app()
@@ -75,6 +113,16 @@ func runDeploy(cmd *Command, args []string) {
 	}
 
 	rh := heroku.RequestHeaders{CommitMessage: message}
+
+	if detach {
+		form.Detach = true
+		var d heroku.Deployment
+		must(client.PostWithHeaders(&d, endpoint, form, rh.Headers()))
+		fmt.Printf("Queued deployment %s to %s.\n", d.Id, d.App.Name)
+		fmt.Printf("Run 'emp deployments-watch %s -a %s' to follow it.\n", d.Id, d.App.Name)
+		return
+	}
+
 	go func() {
 		retry := func() {
 			runDeploy(cmd, args)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// The interval at which deployments-watch polls for updates.
+const deploymentPollInterval = 2 * time.Second
+
+var cmdDeploymentsWatch = &Command{
+	Run:      runDeploymentsWatch,
+	Usage:    "deployments-watch <id>",
+	Alias:    "deployments:watch",
+	NeedsApp: true,
+	Category: "deploy",
+	NumArgs:  1,
+	Short:    "follow the progress of a detached deployment" + extra,
+	Long: `
+Follows the progress of a deployment that was started with "emp deploy --detach",
+printing its status messages until it finishes. Exits with a non-zero status if
+the deployment fails.
+
+Example:
+
+    $ emp deployments-watch 01234567-89ab-cdef-0123-456789abcdef -a acme-inc
+    Pulling repository remind101/acme-inc
+    Status: Image is up to date for remind101/acme-inc:latest
+    Status: Created new release v2 for acme-inc
+    Status: Finished processing events for release v2 of acme-inc
+    Deployment succeeded (v2).
+`,
+}
+
+func runDeploymentsWatch(cmd *Command, args []string) {
+	cmd.AssertNumArgsCorrect(args)
+	appname := mustApp()
+	id := args[0]
+
+	var printed int
+	for {
+		d, err := client.DeploymentInfo(appname, id)
+		must(err)
+
+		for _, m := range d.Messages[printed:] {
+			fmt.Println(m.Message)
+		}
+		printed = len(d.Messages)
+
+		switch d.State {
+		case "succeeded":
+			if d.Version != nil {
+				fmt.Printf("Deployment succeeded (v%d).\n", *d.Version)
+			} else {
+				fmt.Println("Deployment succeeded.")
+			}
+			return
+		case "failed":
+			fmt.Fprintf(os.Stderr, "Deployment failed: %s\n", d.Error)
+			os.Exit(1)
+		case "cancelled":
+			fmt.Fprintf(os.Stderr, "Deployment was %s\n", d.Error)
+			os.Exit(1)
+		}
+
+		time.Sleep(deploymentPollInterval)
+	}
+}
+
+var cmdDeployCancel = &Command{
+	Run:      maybeMessage(runDeployCancel),
+	Usage:    "deploy-cancel [<id>]",
+	Alias:    "deploy:cancel",
+	NeedsApp: true,
+	Category: "deploy",
+	Short:    "cancel a deployment that's in progress" + extra,
+	Long: `
+Cancels a deployment that's in progress. If no deployment id is given, the most
+recent deployment that's in progress is cancelled. If a release was already
+created for the deployment, it's marked as failed and the app is rolled back to
+the last release that didn't fail. When using the CloudFormation scheduler, any
+stack update that's in progress for the app is cancelled and rolled back.
+
+Example:
+
+    $ emp deploy-cancel -a acme-inc
+    Cancelled deployment of remind101/acme-inc:master to acme-inc.
+`,
+}
+
+func runDeployCancel(cmd *Command, args []string) {
+	message := getMessage()
+	if len(args) > 1 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	appname := mustApp()
+
+	var id string
+	if len(args) == 1 {
+		id = args[0]
+	}
+
+	d, err := client.DeploymentCancel(appname, id, message)
+	must(err)
+	log.Printf("Cancelled deployment of %s to %s.", d.Image, appname)
+}
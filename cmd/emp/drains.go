@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+var cmdDrains = &Command{
+	Run:      runDrains,
+	Usage:    "drains",
+	Alias:    "drains:list",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  0,
+	Short:    "list log drains" + extra,
+	Long: `
+Lists the log drains for an app, which forward the app's logs to a syslog
+server or an HTTPS endpoint.
+
+Examples:
+
+    $ emp drains -a acme-inc
+    01234567-89ab-cdef-0123-456789abcdef  syslog+tls://logs.example.com:6514  d.12345678-9abc-def0-1234-56789abcdef0
+`,
+}
+
+func runDrains(cmd *Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	drains, err := client.LogDrainList(appname, nil)
+	must(err)
+
+	for _, d := range drains {
+		listRec(w, d.Id, d.URL, d.Token)
+	}
+}
+
+var cmdDrainsAdd = &Command{
+	Run:      runDrainsAdd,
+	Usage:    "drains-add <url>",
+	Alias:    "drains:add",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "add a log drain" + extra,
+	Long: `
+Adds a log drain to an app. The URL can be a syslog server over TCP
+(syslog://host:port), a syslog server over TLS (syslog+tls://host:port), or an
+HTTPS endpoint (https://...), which receives batches of syslog messages.
+
+Examples:
+
+    $ emp drains-add -a acme-inc syslog+tls://logs.example.com:6514
+    Added log drain syslog+tls://logs.example.com:6514 to acme-inc.
+`,
+}
+
+func runDrainsAdd(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	url := args[0]
+	_, err := client.LogDrainCreate(appname, url)
+	must(err)
+	log.Printf("Added log drain %s to %s.", url, appname)
+}
+
+var cmdDrainsRemove = &Command{
+	Run:      runDrainsRemove,
+	Usage:    "drains-remove <url-or-id>",
+	Alias:    "drains:remove",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "remove a log drain" + extra,
+	Long: `
+Removes a log drain from an app.
+
+Examples:
+
+    $ emp drains-remove -a acme-inc syslog+tls://logs.example.com:6514
+    Removed log drain syslog+tls://logs.example.com:6514 from acme-inc.
+`,
+}
+
+func runDrainsRemove(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	drain := args[0]
+
+	// The API only accepts ids, so find the id of the drain with the given
+	// url.
+	id := drain
+	drains, err := client.LogDrainList(appname, nil)
+	must(err)
+	for _, d := range drains {
+		if d.URL == drain {
+			id = d.Id
+		}
+	}
+
+	must(client.LogDrainDelete(appname, id))
+	log.Printf("Removed log drain %s from %s.", drain, appname)
+}
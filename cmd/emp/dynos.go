@@ -23,6 +23,7 @@ var cmdDynos = &Command{
 	Short:    "list processes",
 	Long: `
 Lists processes. Shows the name, size, host, state, age, and command.
+Sidecar containers are listed below the process that they run alongside.
 
 Examples:
 
@@ -62,6 +63,17 @@ func listDyno(w io.Writer, d *heroku.Dyno) {
 		prettyDuration{dynoAge(d)},
 		maybeQuote(d.Command),
 	)
+
+	for _, s := range d.Sidecars {
+		listRec(w,
+			d.Name+"/"+s.Name,
+			d.Host.Id,
+			"",
+			s.State,
+			prettyDuration{dynoAge(d)},
+			maybeQuote(s.Command),
+		)
+	}
 }
 
 // quotes s as a json string if it contains any weird chars
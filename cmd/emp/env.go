@@ -99,7 +99,7 @@ func runSet(cmd *Command, args []string) {
 	}
 	_, err := client.ConfigVarUpdate(appname, config, message)
 	must(err)
-	log.Printf("Set env vars and restarted " + appname + ".")
+	log.Printf("Set env vars and restarted %s.", appname)
 }
 
 var cmdUnset = &Command{
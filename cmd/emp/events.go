@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+var (
+	eventsUser   string
+	eventsType   string
+	eventsSince  string
+	eventsUntil  string
+	eventsCount  int
+	eventsFollow bool
+)
+
+var cmdEvents = &Command{
+	Run:         runEvents,
+	Usage:       "events [-f] [-u <user>] [-e <event>] [--since <time>] [--until <time>] [-n <limit>]",
+	OptionalApp: true,
+	Category:    "app",
+	Short:       "list events from the audit log" + extra,
+	Long: `
+Lists events from the audit log, such as deploys, scales and config changes. If
+an app is given, only events for that app are listed.
+
+Times can be given as an RFC3339 timestamp (e.g. 2017-01-02T15:04:05Z), or as a
+duration to go back from now (e.g. 24h).
+
+With --follow, recent events are listed, and then new events are printed as
+they happen, until interrupted.
+
+Options:
+
+    -f, --follow         print new events as they happen
+    -u, --user <user>    only list events triggered by this user
+    -e, --event <event>  only list events of this type (e.g. deploy, set, scale)
+    --since <time>       only list events that happened at or after this time
+    --until <time>       only list events that happened before this time
+    -n <limit>           maximum number of recent events to display
+
+Examples:
+
+    $ emp events -a acme-inc --since 168h -e set
+    Jun 12 18:28  set     ejholmes changed environment variables on acme-inc (RAILS_ENV)
+    Jun 13 18:14  set     bob changed environment variables on acme-inc (DATABASE_URL)
+
+    $ emp events -f -n 1
+    Jun 13 18:14  set     bob changed environment variables on acme-inc (DATABASE_URL)
+    Jun 13 18:20  deploy  ejholmes deployed remind101/acme-inc:master to acme-inc
+`,
+}
+
+func init() {
+	cmdEvents.Flag.StringVarP(&eventsUser, "user", "u", "", "only list events triggered by this user")
+	cmdEvents.Flag.StringVarP(&eventsType, "event", "e", "", "only list events of this type")
+	cmdEvents.Flag.StringVar(&eventsSince, "since", "", "only list events that happened at or after this time")
+	cmdEvents.Flag.StringVar(&eventsUntil, "until", "", "only list events that happened before this time")
+	cmdEvents.Flag.IntVarP(&eventsCount, "number", "n", 50, "max number of recent events to display")
+	cmdEvents.Flag.BoolVarP(&eventsFollow, "follow", "f", false, "print new events as they happen")
+}
+
+func runEvents(cmd *Command, args []string) {
+	cmd.AssertNumArgsCorrect(args)
+	appname, _ := app()
+
+	var opts heroku.EventListOpts
+	if eventsUser != "" {
+		opts.User = &eventsUser
+	}
+	if eventsType != "" {
+		opts.Event = &eventsType
+	}
+	opts.Since = mustParseTime(cmd, eventsSince)
+	opts.Until = mustParseTime(cmd, eventsUntil)
+
+	events, err := client.EventList(appname, &opts, &heroku.ListRange{
+		Field:      "created_at",
+		Max:        eventsCount,
+		Descending: true,
+	})
+	must(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+
+	// Events are returned most recent first.
+	for i := len(events) - 1; i >= 0; i-- {
+		listEvent(w, events[i])
+	}
+	w.Flush()
+
+	if !eventsFollow {
+		return
+	}
+
+	must(client.EventStream(appname, func(e heroku.Event) error {
+		if eventsUser != "" && (e.User == nil || *e.User != eventsUser) {
+			return nil
+		}
+		if eventsType != "" && e.Event != eventsType {
+			return nil
+		}
+
+		listEvent(w, e)
+		return w.Flush()
+	}))
+}
+
+func listEvent(w *tabwriter.Writer, e heroku.Event) {
+	listRec(w,
+		prettyTime{e.CreatedAt},
+		e.Event,
+		e.Message,
+	)
+}
+
+// mustParseTime parses a time given as either an RFC3339 timestamp, or a
+// duration to go back from now.
+func mustParseTime(cmd *Command, v string) *time.Time {
+	if v == "" {
+		return nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid time: %s\n", v)
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+
+	t := time.Now().Add(-d)
+	return &t
+}
@@ -173,7 +173,7 @@ func appFromGitRemote(remote string) (string, error) {
 
 		appName := appNameFromGitURL(out)
 		if appName == "" {
-			return "", fmt.Errorf("could not find app name in " + remote + " git remote")
+			return "", fmt.Errorf("could not find app name in %s git remote", remote)
 		}
 		return appName, nil
 	}
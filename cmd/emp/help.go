@@ -227,7 +227,7 @@ func printStyleGuide() {
 		cmap,
 	})
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 }
 
@@ -20,5 +20,6 @@ func runInfo(cmd *Command, args []string) {
 	fmt.Printf("Name: %s\n", app.Name)
 	fmt.Printf("ID: %s\n", app.Id)
 	fmt.Printf("Maintenance: %s\n", fmtMaintenance(app.Maintenance))
+	fmt.Printf("Auto Rollback: %s\n", fmtAutoRollback{app.AutoRollback})
 	fmt.Printf("Cert: %s\n", app.Cert)
 }
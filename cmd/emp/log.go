@@ -6,11 +6,20 @@ import (
 	"time"
 )
 
-var duration string
+var (
+	duration    string
+	logProcess  string
+	logTask     string
+	logSince    string
+	logUntil    string
+	logFilter   string
+	logRegexp   bool
+	logNoFollow bool
+)
 
 var cmdLog = &Command{
 	Run:      runLog,
-	Usage:    "log [-d]",
+	Usage:    "log [-d <duration>] [-p <process>] [-t <task>] [--since <time>] [--until <time>] [-g <text>] [-E] [--no-follow]",
 	NeedsApp: true,
 	Category: "app",
 	NumArgs:  0,
@@ -18,25 +27,53 @@ var cmdLog = &Command{
 	Long: `
 Log prints the streaming application log.
 
+Times can be given as an RFC3339 timestamp (e.g. 2017-01-02T15:04:05Z), or as a
+duration to go back from now (e.g. 24h). Filtering by process or task is not
+supported by all log backends.
+
 Options:
 
 	-d duration to go back and start reading logs from (ie. 10m will start
 	   streaming from 10 minutes ago)
+	-p, --process <process>  only show logs from this process type
+	-t, --task <task>        only show logs from this task
+	--since <time>           show logs starting from this time
+	--until <time>           stop showing logs at this time
+	-g, --grep <text>        only show lines containing this text
+	-E, --regexp             treat the --grep text as a regular expression
+	--no-follow              show existing logs and exit, instead of
+	                         streaming new logs
 
 Examples:
 
 	$ emp log -a acme-inc
 	2013-10-17T00:17:35.066089+00:00 app[web.1]: Completed 302 Found in 0ms
 	...
+
+	$ emp log -a acme-inc -p web --since 2017-01-02T03:00:00Z --until 2017-01-02T04:00:00Z -g "status=500" --no-follow
 `,
 }
 
 func init() {
 	cmdLog.Flag.StringVarP(&duration, "duration", "d", "", "duration to start streaming logs from")
+	cmdLog.Flag.StringVarP(&logProcess, "process", "p", "", "only show logs from this process type")
+	cmdLog.Flag.StringVarP(&logTask, "task", "t", "", "only show logs from this task")
+	cmdLog.Flag.StringVar(&logSince, "since", "", "show logs starting from this time")
+	cmdLog.Flag.StringVar(&logUntil, "until", "", "stop showing logs at this time")
+	cmdLog.Flag.StringVarP(&logFilter, "grep", "g", "", "only show lines containing this text")
+	cmdLog.Flag.BoolVarP(&logRegexp, "regexp", "E", false, "treat the --grep text as a regular expression")
+	cmdLog.Flag.BoolVar(&logNoFollow, "no-follow", false, "show existing logs and exit")
 }
 
 type PostLogForm struct {
-	Duration int64 `json:"duration"`
+	Duration int64      `json:"duration"`
+	Process  string     `json:"process,omitempty"`
+	TaskID   string     `json:"task_id,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+	Filter   string     `json:"filter,omitempty"`
+	Regexp   bool       `json:"regexp,omitempty"`
+	NoFollow bool       `json:"no_follow,omitempty"`
 }
 
 func runLog(cmd *Command, args []string) {
@@ -53,9 +90,24 @@ func runLog(cmd *Command, args []string) {
 		d = parsed.Nanoseconds()
 	}
 
+	if logNoFollow && duration == "" && logSince == "" {
+		fmt.Println("--since or -d is required with --no-follow")
+		cmd.PrintUsage()
+		os.Exit(1)
+	}
+
 	appName := mustApp()
 	endpoint := fmt.Sprintf("/apps/%s/log-sessions", appName)
-	form := &PostLogForm{Duration: d}
+	form := &PostLogForm{
+		Duration: d,
+		Process:  logProcess,
+		TaskID:   logTask,
+		Since:    mustParseTime(cmd, logSince),
+		Until:    mustParseTime(cmd, logUntil),
+		Filter:   logFilter,
+		Regexp:   logRegexp,
+		NoFollow: logNoFollow,
+	}
 
 	must(client.Post(os.Stdout, endpoint, form))
 }
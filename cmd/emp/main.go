@@ -144,9 +144,26 @@ var commands = []*Command{
 	helpAbout,
 
 	// listed by emp help more
+	cmdAccess,
+	cmdAccessAdd,
+	cmdAccessRemove,
 	cmdAPI,
+	cmdAuthorizations,
+	cmdAuthorizationsCreate,
+	cmdAuthorizationsRevoke,
 	cmdAuthorize,
+	cmdAutoRollbackEnable,
+	cmdAutoRollbackDisable,
+	cmdCanary,
+	cmdCanaryPromote,
+	cmdCanaryAbort,
 	cmdCreds,
+	cmdDeployCancel,
+	cmdDeploymentsWatch,
+	cmdDrains,
+	cmdDrainsAdd,
+	cmdDrainsRemove,
+	cmdEvents,
 	cmdGet,
 	cmdLogin,
 	cmdWebLogin,
@@ -154,6 +171,11 @@ var commands = []*Command{
 	cmdMaintenance,
 	cmdMaintenanceEnable,
 	cmdMaintenanceDisable,
+	cmdNotifications,
+	cmdNotificationsAdd,
+	cmdNotificationsRemove,
+	cmdScheduler,
+	cmdSchedulerMigrate,
 	cmdSSL,
 	cmdSSLCertAdd,
 	cmdSSLCertRollback,
@@ -174,7 +196,7 @@ func initClients() {
 	loadNetrc()
 	suite, err := hkclient.New(nrc, hkAgent)
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 
 	client = suite.Client
@@ -223,7 +245,7 @@ func main() {
 				cmdHelp.Run(cmdHelp, args[:1])
 				return
 			} else if err != nil {
-				printError(err.Error())
+				printError("%s", err)
 				os.Exit(2)
 			}
 			if flagApp != "" {
@@ -239,11 +261,11 @@ func main() {
 					if err != nil {
 						msg = err.Error()
 					}
-					printError(msg)
+					printError("%s", msg)
 					cmd.PrintUsage()
 					os.Exit(2)
 				case err != nil && cmd.NeedsApp:
-					printFatal(err.Error())
+					printFatal("%s", err)
 				}
 			}
 			cmd.Run(cmd, cmd.Flag.Args())
@@ -282,7 +304,7 @@ func app() (string, error) {
 func mustApp() string {
 	name, err := app()
 	if err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 	return name
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+var cmdNotifications = &Command{
+	Run:      runNotifications,
+	Usage:    "notifications",
+	Alias:    "notifications:list",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  0,
+	Short:    "list notifications" + extra,
+	Long: `
+Lists the notifications for an app, which send the app's events to a webhook
+URL or an SNS topic.
+
+Examples:
+
+    $ emp notifications -a acme-inc
+    01234567-89ab-cdef-0123-456789abcdef  webhook  https://example.com/hooks/empire  deploy,rollback
+    12345678-9abc-def0-1234-56789abcdef0  sns      arn:aws:sns:us-east-1:123456789012:acme-inc  *
+`,
+}
+
+func runNotifications(cmd *Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	notifications, err := client.NotificationList(appname)
+	must(err)
+
+	for _, n := range notifications {
+		events := "*"
+		if len(n.Events) > 0 {
+			events = strings.Join(n.Events, ",")
+		}
+		listRec(w, n.Id, n.Kind, n.Target, events)
+	}
+}
+
+var notificationEvents string
+
+var cmdNotificationsAdd = &Command{
+	Run:      runNotificationsAdd,
+	Usage:    "notifications-add [-e <event>,...] <webhook|sns> <target>",
+	Alias:    "notifications:add",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  2,
+	Short:    "add a notification" + extra,
+	Long: `
+Adds a notification to an app, which sends the app's events to a webhook URL or
+an SNS topic. Webhooks receive a POST with the same JSON payload that's
+published to SNS.
+
+Options:
+
+    -e, --events <event>,...  only send these events (e.g. deploy,rollback)
+
+Examples:
+
+    $ emp notifications-add -a acme-inc -e deploy,rollback webhook https://example.com/hooks/empire
+    Added webhook notification to acme-inc.
+    Requests are signed with the secret 2f9a6c0d...
+
+    $ emp notifications-add -a acme-inc sns arn:aws:sns:us-east-1:123456789012:acme-inc
+    Added sns notification to acme-inc.
+`,
+}
+
+func init() {
+	cmdNotificationsAdd.Flag.StringVarP(&notificationEvents, "events", "e", "", "comma separated events to send")
+}
+
+func runNotificationsAdd(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+
+	opts := heroku.NotificationCreateOpts{
+		Kind:   args[0],
+		Target: args[1],
+	}
+	if notificationEvents != "" {
+		opts.Events = strings.Split(notificationEvents, ",")
+	}
+
+	n, err := client.NotificationCreate(appname, opts)
+	must(err)
+	log.Printf("Added %s notification to %s.", opts.Kind, appname)
+	if n.Secret != "" {
+		log.Printf("Requests are signed with the secret %s", n.Secret)
+	}
+}
+
+var cmdNotificationsRemove = &Command{
+	Run:      runNotificationsRemove,
+	Usage:    "notifications-remove <id>",
+	Alias:    "notifications:remove",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "remove a notification" + extra,
+}
+
+func runNotificationsRemove(cmd *Command, args []string) {
+	appname := mustApp()
+	cmd.AssertNumArgsCorrect(args)
+	id := args[0]
+	must(client.NotificationDelete(appname, id))
+	log.Printf("Removed notification %s from %s.", id, appname)
+}
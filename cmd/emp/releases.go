@@ -98,7 +98,7 @@ func listReleases(w io.Writer, versions []string) {
 	for _ = range versions {
 		select {
 		case err := <-errch:
-			printFatal(err.Error())
+			printFatal("%s", err)
 		case rel := <-relch:
 			if rel != nil {
 				rels = append(rels, newRelease(rel))
@@ -141,6 +141,9 @@ func listRelease(w io.Writer, r *Release) {
 	if r.Commit != "" && !strings.Contains(r.Description, r.Commit) {
 		desc += " (" + abbrev(r.Commit, 12) + ")"
 	}
+	if r.Status == "failed" {
+		desc = "[failed] " + desc
+	}
 	listRec(w,
 		fmt.Sprintf("v%d", r.Version),
 		abbrev(r.Who, 10),
@@ -192,6 +195,9 @@ func runReleaseInfo(cmd *Command, args []string) {
 	fmt.Printf("Version:  v%d\n", rel.Version)
 	fmt.Printf("By:       %s\n", rel.User.Email)
 	fmt.Printf("Change:   %s\n", rel.Description)
+	if rel.Status != "" {
+		fmt.Printf("Status:   %s\n", rel.Status)
+	}
 	fmt.Printf("When:     %s\n", rel.CreatedAt.UTC().Format(time.RFC3339))
 	fmt.Printf("Id:       %s\n", rel.Id)
 	if rel.Slug != nil {
@@ -87,7 +87,7 @@ func runRun(cmd *Command, args []string) {
 				Width:  80,
 			}
 		} else {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 	}
 
@@ -141,12 +141,12 @@ func runRun(cmd *Command, args []string) {
 	if proto == "tls" {
 		dial, err = tlsDial("tcp", address, &tls.Config{})
 		if err != nil {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 	} else {
 		dial, err = net.Dial(proto, address)
 		if err != nil {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 	}
 
@@ -155,10 +155,10 @@ func runRun(cmd *Command, args []string) {
 	res, err := clientconn.Do(req)
 	defer res.Body.Close()
 	if err = heroku.CheckResp(res); err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 	if err != nil && err != httputil.ErrPersistEOF {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 	rwc, br := clientconn.Hijack()
 	defer rwc.Close()
@@ -166,7 +166,7 @@ func runRun(cmd *Command, args []string) {
 	if isTerminalIn && isTerminalOut {
 		state, err := term.SetRawTerminal(inFd)
 		if err != nil {
-			printFatal(err.Error())
+			printFatal("%s", err)
 		}
 		defer term.RestoreTerminal(inFd, state)
 	}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/term"
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+var cmdScheduler = &Command{
+	Run:      runScheduler,
+	Usage:    "scheduler",
+	NeedsApp: true,
+	Category: "app",
+	Short:    "show the scheduler backend for an app" + extra,
+	Long: `
+Scheduler shows the scheduler backend that an app is running on.
+
+Example:
+
+    $ emp scheduler -a <myapp>
+    cloudformation
+`,
+}
+
+func runScheduler(cmd *Command, args []string) {
+	if len(args) != 0 {
+		cmd.PrintUsage()
+		os.Exit(2)
+	}
+	scheduler, err := client.SchedulerInfo(mustApp())
+	must(err)
+	fmt.Println(scheduler.Backend)
+}
+
+var cmdSchedulerMigrate = &Command{
+	Run:      maybeMessage(runSchedulerMigrate),
+	Usage:    "scheduler-migrate <backend>",
+	NeedsApp: true,
+	Category: "app",
+	NumArgs:  1,
+	Short:    "migrate an app to a different scheduler backend" + extra,
+	Long: `
+Migrates an app to a different scheduler backend. The latest release is
+submitted to the new backend, and once it's stable, the app is removed from
+the old backend.
+
+Example:
+
+    $ emp scheduler-migrate kubernetes -a <myapp>
+    Status: Submitting myapp to the kubernetes backend
+    Status: Deployment myapp-web became stable
+    Status: Removing myapp from the cloudformation backend
+    Status: Migrated myapp to the kubernetes scheduler
+`,
+}
+
+func runSchedulerMigrate(cmd *Command, args []string) {
+	cmd.AssertNumArgsCorrect(args)
+
+	r, w := io.Pipe()
+
+	appName := mustApp()
+	message := getMessage()
+	form := &heroku.SchedulerMigrateOpts{Backend: args[0]}
+	endpoint := fmt.Sprintf("/apps/%s/scheduler/migrations", appName)
+
+	rh := heroku.RequestHeaders{CommitMessage: message}
+	go func() {
+		retry := func() {
+			runSchedulerMigrate(cmd, args)
+		}
+		cleanup := func() {
+			must(w.Close())
+		}
+		defer retryMessageRequired(retry, cleanup)
+		must(client.PostWithHeaders(w, endpoint, form, rh.Headers()))
+	}()
+
+	outFd, isTerminalOut := term.GetFdInfo(os.Stdout)
+	must(jsonmessage.DisplayJSONMessagesStream(r, os.Stdout, outFd, isTerminalOut, nil))
+}
@@ -43,7 +43,7 @@ func loadNetrc() {
 				nrc = &hkclient.NetRc{}
 				return
 			}
-			printFatal("loading netrc: " + err.Error())
+			printFatal("loading netrc: %s", err)
 		}
 	}
 }
@@ -61,7 +61,7 @@ func getCreds(u string) (user, pass string) {
 
 	user, pass, err = nrc.GetCreds(apiURL)
 	if err != nil {
-		printError(err.Error())
+		printError("%s", err)
 	}
 
 	return user, pass
@@ -111,13 +111,13 @@ func must(err error) {
 		if herror, ok := err.(heroku.Error); ok {
 			switch herror.Id {
 			case "two_factor":
-				printError(err.Error() + " Authorize with `emp authorize`.")
+				printError("%s Authorize with `emp authorize`.", err)
 				os.Exit(79)
 			case "unauthorized":
-				printFatal(err.Error() + " Log in with `emp login`.")
+				printFatal("%s Log in with `emp login`.", err)
 			}
 		}
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 }
 
@@ -135,12 +135,12 @@ func printWarning(message string, args ...interface{}) {
 
 func mustConfirm(warning, desired string) {
 	if isTerminalIn {
-		printWarning(warning)
+		printWarning("%s", warning)
 		fmt.Printf("> ")
 	}
 	var confirm string
 	if _, err := fmt.Scanln(&confirm); err != nil {
-		printFatal(err.Error())
+		printFatal("%s", err)
 	}
 
 	if confirm != desired {
@@ -12,6 +12,7 @@ import (
 	"github.com/urfave/cli"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/saml"
+	"github.com/remind101/empire/server/auth/oidc"
 	"github.com/remind101/empire/stats"
 	"github.com/remind101/pkg/logger"
 	"github.com/remind101/pkg/reporter"
@@ -37,6 +38,8 @@ type Context struct {
 	awsConfigProvider client.ConfigProvider
 
 	samlServiceProvider *saml.ServiceProvider
+
+	oidcVerifier *oidc.Verifier
 }
 
 // newContext builds a new base Context object.
@@ -147,6 +150,28 @@ func (c *Context) SAMLServiceProvider() (*saml.ServiceProvider, error) {
 	return c.samlServiceProvider, nil
 }
 
+// OIDCVerifier returns the verifier for ID tokens from the OpenID Connect
+// issuer, or nil if no issuer is configured. The issuer's configuration is
+// only discovered once, and the verifier is shared by the web flow and the
+// authentication backend.
+func (c *Context) OIDCVerifier() (*oidc.Verifier, error) {
+	if c.oidcVerifier == nil {
+		issuer := c.String(FlagOIDCIssuer)
+		if issuer == "" {
+			// No OpenID Connect
+			return nil, nil
+		}
+
+		p, err := oidc.Discover(issuer)
+		if err != nil {
+			return nil, err
+		}
+		c.oidcVerifier = oidc.NewVerifier(p, c.String(FlagOIDCClient))
+	}
+
+	return c.oidcVerifier, nil
+}
+
 // uriContentOrValue uses the following algorithm:
 //
 // 1. If the input is a URI, it will use uriContent to fetch the content from
@@ -21,8 +21,10 @@ import (
 	"github.com/inconshreveable/log15"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/events/app"
+	"github.com/remind101/empire/events/notifications"
 	"github.com/remind101/empire/events/sns"
 	"github.com/remind101/empire/events/stdout"
+	"github.com/remind101/empire/events/webhook"
 	"github.com/remind101/empire/logs"
 	"github.com/remind101/empire/pkg/dockerauth"
 	"github.com/remind101/empire/pkg/dockerutil"
@@ -31,6 +33,7 @@ import (
 	"github.com/remind101/empire/registry"
 	"github.com/remind101/empire/scheduler/cloudformation"
 	"github.com/remind101/empire/scheduler/docker"
+	"github.com/remind101/empire/scheduler/router"
 	"github.com/remind101/empire/stats"
 	"github.com/remind101/empire/twelvefactor"
 	"github.com/remind101/pkg/reporter"
@@ -73,7 +76,7 @@ func newEmpire(db *empire.DB, c *Context) (*empire.Empire, error) {
 		return nil, err
 	}
 
-	streams, err := newEventStreams(c)
+	streams, err := newEventStreams(db, c)
 	if err != nil {
 		return nil, err
 	}
@@ -90,11 +93,16 @@ func newEmpire(db *empire.DB, c *Context) (*empire.Empire, error) {
 
 	e := empire.New(db)
 	e.Scheduler = scheduler
-	e.EventStream = empire.AsyncEvents(streams)
+	// Events for an app are also sent to the notifications that were added
+	// to the app.
+	e.EventStream = append(streams, empire.NamedEventStream{Name: "notifications", EventStream: notifications.NewEventStream(e, c)})
 	e.ImageRegistry = reg
 	e.Environment = c.String(FlagEnvironment)
 	e.RunRecorder = runRecorder
 	e.MessagesRequired = c.Bool(FlagMessagesRequired)
+	e.AutoRollback = c.Bool(FlagAutoRollback)
+	e.NotificationSNSTopics = c.StringSlice(FlagNotificationsSNSTopics)
+	e.ServiceAccountExpiration = c.Duration(FlagServiceAccountsExpiration)
 
 	switch c.String(FlagAllowedCommands) {
 	case "procfile":
@@ -112,20 +120,59 @@ func newEmpire(db *empire.DB, c *Context) (*empire.Empire, error) {
 // Scheduler ============================
 
 func newScheduler(db *empire.DB, c *Context) (empire.Scheduler, error) {
+	name := c.String(FlagScheduler)
+	s, err := newSchedulerBackend(name, db, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// If no additional backends are configured, there's no need to route
+	// apps between backends.
+	names := c.StringSlice(FlagSchedulerBackends)
+	if len(names) == 0 {
+		return s, nil
+	}
+
+	backends := map[string]twelvefactor.Scheduler{
+		name: s,
+	}
+	for _, n := range names {
+		if _, ok := backends[n]; ok {
+			continue
+		}
+		b, err := newSchedulerBackend(n, db, c)
+		if err != nil {
+			return nil, err
+		}
+		backends[n] = b
+	}
+
+	r := router.NewScheduler(db.DB.DB(), name, backends)
+	log.Println(fmt.Sprintf("Routing apps between the following scheduler backends: %v", r.Backends()))
+	return r, nil
+}
+
+func newSchedulerBackend(name string, db *empire.DB, c *Context) (twelvefactor.Scheduler, error) {
 	var (
-		s   empire.Scheduler
+		s   twelvefactor.Scheduler
 		err error
 	)
 
-	switch c.String(FlagScheduler) {
+	switch name {
 	case "cloudformation":
 		s, err = newCloudFormationScheduler(db, c)
+	case "kubernetes":
+		// The Kubernetes scheduler supports attached runs natively.
+		return newKubernetesScheduler(c)
+	case "docker":
+		// The Docker scheduler supports attached runs natively.
+		return newDockerScheduler(c)
 	default:
-		return nil, fmt.Errorf("unknown scheduler: %s", c.String(FlagScheduler))
+		return nil, fmt.Errorf("unknown scheduler: %s", name)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize %s scheduler: %v", c.String(FlagScheduler), err)
+		return nil, fmt.Errorf("failed to initialize %s scheduler: %v", name, err)
 	}
 
 	// If ECS tasks support being attached to with a TTY + stdin, let the
@@ -144,6 +191,18 @@ func newScheduler(db *empire.DB, c *Context) (empire.Scheduler, error) {
 	return a, nil
 }
 
+func newDockerScheduler(c *Context) (twelvefactor.Scheduler, error) {
+	d, err := newDockerClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker scheduler: %v", err)
+	}
+
+	log.Println("Using Docker backend with the following configuration:")
+	log.Println(fmt.Sprintf("  Host: %v", c.String(FlagDockerHost)))
+
+	return docker.NewScheduler(d), nil
+}
+
 func newCloudFormationScheduler(db *empire.DB, c *Context) (twelvefactor.Scheduler, error) {
 	logDriver := c.String(FlagECSLogDriver)
 	logOpts := c.StringSlice(FlagECSLogOpts)
@@ -190,6 +249,7 @@ func newCloudFormationScheduler(db *empire.DB, c *Context) (twelvefactor.Schedul
 	}
 	s.Bucket = c.String(FlagS3TemplateBucket)
 	s.Tags = tags
+	s.StabilizationTimeout = c.Duration(FlagCloudFormationStabilizationTimeout)
 	s.NewDockerClient = func(ec2Instance *ec2.Instance) (cloudformation.DockerClient, error) {
 		certPath := c.String(FlagECSDockerCert)
 		host := ec2Instance.PrivateIpAddress
@@ -215,6 +275,7 @@ func newCloudFormationScheduler(db *empire.DB, c *Context) (twelvefactor.Schedul
 	log.Println(fmt.Sprintf("  InternalSubnetIDs: %v", t.InternalSubnetIDs))
 	log.Println(fmt.Sprintf("  ExternalSubnetIDs: %v", t.ExternalSubnetIDs))
 	log.Println(fmt.Sprintf("  ZoneID: %v", zoneID))
+	log.Println(fmt.Sprintf("  StabilizationTimeout: %v", s.StabilizationTimeout))
 	log.Println(fmt.Sprintf("  LogConfiguration: %v", t.LogConfiguration))
 
 	if v := c.String(FlagECSPlacementConstraintsDefault); v != "" {
@@ -315,6 +376,10 @@ func newLogsStreamer(c *Context) (empire.LogsStreamer, error) {
 	switch c.String(FlagLogsStreamer) {
 	case "kinesis":
 		return newKinesisLogsStreamer(c)
+	case "cloudwatch":
+		return newCloudWatchLogsStreamer(c)
+	case "docker":
+		return newDockerLogsStreamer(c)
 	default:
 		log.Println("Streaming logs are disabled")
 		return nil, nil
@@ -326,26 +391,72 @@ func newKinesisLogsStreamer(c *Context) (empire.LogsStreamer, error) {
 	return logs.NewKinesisLogsStreamer(), nil
 }
 
+func newCloudWatchLogsStreamer(c *Context) (empire.LogsStreamer, error) {
+	group := c.String(FlagLogsCloudWatchGroup)
+	if group == "" {
+		// Default to the log group that the awslogs log driver sends
+		// logs to.
+		if l := newLogConfiguration(c.String(FlagECSLogDriver), c.StringSlice(FlagECSLogOpts)); l != nil && *l.LogDriver == "awslogs" {
+			group = aws.StringValue(l.Options["awslogs-group"])
+		}
+	}
+	if group == "" {
+		return nil, fmt.Errorf("%s is required when using the cloudwatch logs streamer", FlagLogsCloudWatchGroup)
+	}
+
+	s, err := logs.NewCloudWatchLogsStreamer(group, c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", FlagLogsCloudWatchGroup, err)
+	}
+
+	log.Println("Using CloudWatch Logs backend for log streaming with the following configuration:")
+	log.Println(fmt.Sprintf("  Group: %v", group))
+
+	return s, nil
+}
+
+func newDockerLogsStreamer(c *Context) (empire.LogsStreamer, error) {
+	d, err := newDockerClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker logs streamer: %v", err)
+	}
+
+	log.Println("Using Docker backend for log streaming with the following configuration:")
+	log.Println(fmt.Sprintf("  Host: %v", c.String(FlagDockerHost)))
+
+	return logs.NewDockerLogsStreamer(d.Client), nil
+}
+
 // Events ==============================
 
-func newEventStreams(c *Context) (empire.MultiEventStream, error) {
-	var streams empire.MultiEventStream
-	switch c.String(FlagEventsBackend) {
+func newEventStreams(db *empire.DB, c *Context) (empire.MultiEventStream, error) {
+	// Events are always recorded in the audit log. Each stream is named,
+	// so that delivery of events from the outbox is tracked separately for
+	// each of them.
+	streams := empire.MultiEventStream{
+		empire.NamedEventStream{Name: "audit", EventStream: empire.NewAuditLog(db)},
+	}
+	switch backend := c.String(FlagEventsBackend); backend {
 	case "sns":
 		e, err := newSNSEventStream(c)
 		if err != nil {
 			return streams, err
 		}
-		streams = append(streams, e)
+		streams = append(streams, empire.NamedEventStream{Name: backend, EventStream: e})
 	case "stdout":
 		e, err := newStdoutEventStream(c)
 		if err != nil {
 			return streams, err
 		}
-		streams = append(streams, e)
+		streams = append(streams, empire.NamedEventStream{Name: backend, EventStream: e})
+	case "webhook":
+		e, err := newWebhookEventStream(c)
+		if err != nil {
+			return streams, err
+		}
+		streams = append(streams, empire.NamedEventStream{Name: backend, EventStream: e})
 	default:
-		e := empire.NullEventStream
-		streams = append(streams, e)
+		streams = append(streams, empire.NamedEventStream{Name: "null", EventStream: empire.NullEventStream})
 	}
 
 	if c.String(FlagLogsStreamer) == "kinesis" {
@@ -353,7 +464,7 @@ func newEventStreams(c *Context) (empire.MultiEventStream, error) {
 		if err != nil {
 			return streams, err
 		}
-		streams = append(streams, e)
+		streams = append(streams, empire.NamedEventStream{Name: "kinesis", EventStream: e})
 	}
 	return streams, nil
 }
@@ -380,6 +491,34 @@ func newStdoutEventStream(c *Context) (empire.EventStream, error) {
 	return e, nil
 }
 
+func newWebhookEventStream(c *Context) (empire.EventStream, error) {
+	var endpoints []webhook.Endpoint
+	for _, u := range c.StringSlice(FlagWebhookURLs) {
+		endpoint, err := webhook.ParseEndpoint(u)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("the webhook events backend requires at least one url in --%s", FlagWebhookURLs)
+	}
+
+	e := webhook.NewEventStream(endpoints)
+	e.Client.Timeout = c.Duration(FlagWebhookTimeout)
+	if secret := c.String(FlagWebhookSecret); secret != "" {
+		e.Secret = []byte(secret)
+	}
+
+	log.Println("Using Webhook events backend with the following configuration:")
+	for _, endpoint := range endpoints {
+		log.Println(fmt.Sprintf("  URL: %s", endpoint.URL))
+	}
+
+	return e, nil
+}
+
 // RunRecorder =========================
 
 func newRunRecorder(c *Context) (empire.RunRecorder, error) {
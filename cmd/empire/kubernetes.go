@@ -0,0 +1,42 @@
+//go:build kubernetes
+// +build kubernetes
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/remind101/empire/scheduler/kubernetes"
+	"github.com/remind101/empire/twelvefactor"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func newKubernetesScheduler(c *Context) (twelvefactor.Scheduler, error) {
+	var (
+		kubeConfig *rest.Config
+		err        error
+	)
+
+	if path := c.String(FlagKubernetesConfig); path != "" {
+		kubeConfig, err = clientcmd.BuildConfigFromFlags("", path)
+	} else {
+		kubeConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kubernetes scheduler: %v", err)
+	}
+
+	s, err := kubernetes.NewScheduler(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kubernetes scheduler: %v", err)
+	}
+	s.Namespace = c.String(FlagKubernetesNamespace)
+
+	log.Println("Using Kubernetes backend with the following configuration:")
+	log.Println(fmt.Sprintf("  Host: %v", kubeConfig.Host))
+	log.Println(fmt.Sprintf("  Namespace: %v", s.Namespace))
+
+	return s, nil
+}
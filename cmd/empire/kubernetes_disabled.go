@@ -0,0 +1,18 @@
+//go:build !kubernetes
+// +build !kubernetes
+
+package main
+
+import (
+	"errors"
+
+	"github.com/remind101/empire/twelvefactor"
+)
+
+// errKubernetesDisabled is returned when the kubernetes scheduler is used, but
+// Empire was built without the `kubernetes` build tag.
+var errKubernetesDisabled = errors.New("empire was built without the kubernetes scheduler. Build it with `go build -tags kubernetes ./cmd/empire` to enable it")
+
+func newKubernetesScheduler(c *Context) (twelvefactor.Scheduler, error) {
+	return nil, errKubernetesDisabled
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/urfave/cli"
 	"github.com/remind101/empire"
+	"github.com/remind101/empire/events/webhook"
+	"github.com/remind101/empire/scheduler/cloudformation"
+	"github.com/remind101/empire/server/auth/oidc"
 	"github.com/remind101/empire/server/github"
 )
 
@@ -22,8 +25,13 @@ const (
 	FlagRunLogsBackend = "runlogs.backend"
 	FlagLogLevel       = "log.level"
 
+	FlagSchedulerBackends = "scheduler.backends"
+
 	FlagMessagesRequired = "messages.required"
 	FlagAllowedCommands  = "commands.allowed"
+	FlagAutoRollback     = "deployments.autorollback"
+
+	FlagServiceAccountsExpiration = "serviceaccounts.expiration"
 
 	FlagStats = "stats"
 
@@ -31,9 +39,26 @@ const (
 	FlagServerSessionExpiration = "server.session.expiration"
 	FlagServerRealIp            = "server.realip"
 
-	FlagSAMLMetadata       = "saml.metadata"
-	FlagSAMLKey            = "saml.key"
-	FlagSAMLCert           = "saml.cert"
+	FlagServerAuthPolicyFile     = "server.auth.policy.file"
+	FlagServerAuthPolicyDatabase = "server.auth.policy.database"
+	FlagServerAuthCollaborators  = "server.auth.collaborators"
+	FlagServerAuthAdmins         = "server.auth.admins"
+
+	FlagSAMLMetadata        = "saml.metadata"
+	FlagSAMLKey             = "saml.key"
+	FlagSAMLCert            = "saml.cert"
+	FlagSAMLGroupsAttribute = "saml.groups.attribute"
+	FlagSAMLGroups          = "saml.groups"
+
+	FlagOIDCIssuer            = "oidc.issuer"
+	FlagOIDCClient            = "oidc.client.id"
+	FlagOIDCClientSecret      = "oidc.client.secret"
+	FlagOIDCClientRedirectURL = "oidc.client.redirect.url"
+	FlagOIDCScopes            = "oidc.scopes"
+	FlagOIDCUsernameClaim     = "oidc.claims.username"
+	FlagOIDCGroupsClaim       = "oidc.claims.groups"
+	FlagOIDCGroups            = "oidc.groups"
+
 	FlagGithubClient       = "github.client.id"
 	FlagGithubClientSecret = "github.client.secret"
 	FlagGithubClientRedirectURL = "github.client.redirect.url"
@@ -80,16 +105,28 @@ const (
 
 	FlagRoute53InternalZoneID = "route53.zoneid.internal"
 
-	FlagCloudFormationStackNameTemplate = "cloudformation.stack-name-template"
+	FlagCloudFormationStackNameTemplate    = "cloudformation.stack-name-template"
+	FlagCloudFormationStabilizationTimeout = "cloudformation.stabilization-timeout"
+
+	FlagKubernetesConfig    = "kubernetes.config"
+	FlagKubernetesNamespace = "kubernetes.namespace"
 
 	FlagSNSTopic           = "sns.topic"
 	FlagCloudWatchLogGroup = "cloudwatch.loggroup"
 
+	FlagNotificationsSNSTopics = "notifications.sns.topics"
+
+	FlagWebhookURLs    = "webhook.urls"
+	FlagWebhookSecret  = "webhook.secret"
+	FlagWebhookTimeout = "webhook.timeout"
+
 	FlagSecret       = "secret"
 	FlagReporter     = "reporter"
 	FlagRunner       = "runner"
 	FlagLogsStreamer = "logs.streamer"
 
+	FlagLogsCloudWatchGroup = "logs.cloudwatch.group"
+
 	FlagEnvironment = "environment"
 
 	// Expiremental flags.
@@ -122,13 +159,19 @@ var Commands = []cli.Command{
 			cli.StringFlag{
 				Name:   FlagScheduler,
 				Value:  "cloudformation",
-				Usage:  "The scheduling backend to use. Current options are `cloudformation`.",
+				Usage:  "The scheduling backend to use. Current options are `cloudformation`, `kubernetes` and `docker`.",
 				EnvVar: "EMPIRE_SCHEDULER",
 			},
+			cli.StringSliceFlag{
+				Name:   FlagSchedulerBackends,
+				Value:  &cli.StringSlice{},
+				Usage:  "Additional scheduling backends that apps can be migrated to with `emp scheduler-migrate`. Apps that haven't been migrated use the backend from `--" + FlagScheduler + "`.",
+				EnvVar: "EMPIRE_SCHEDULER_BACKENDS",
+			},
 			cli.StringFlag{
 				Name:   FlagServerAuth,
 				Value:  "",
-				Usage:  "The authentication backend to use to authenticate requests to the API. Can be `fake`, `github`, or `saml`.",
+				Usage:  "The authentication backend to use to authenticate requests to the API. Can be `fake`, `github`, `saml`, or `oidc`.",
 				EnvVar: "EMPIRE_SERVER_AUTH",
 			},
 			cli.DurationFlag{
@@ -137,6 +180,28 @@ var Commands = []cli.Command{
 				Usage:  "The maximum amount of time that sessions and access tokens exist for. For security, it's a good idea to set this to a low value, like `24h`. Refer to the ParseDuration docs for details on acceptable values (https://golang.org/pkg/time/#ParseDuration). By default, sessions do not expire.",
 				EnvVar: "EMPIRE_SERVER_SESSION_EXPIRATION",
 			},
+			cli.StringFlag{
+				Name:   FlagServerAuthPolicyFile,
+				Value:  "",
+				Usage:  "Path to a JSON authorization policy, which controls what actions users can perform. When provided, actions that aren't allowed by a policy are denied.",
+				EnvVar: "EMPIRE_SERVER_AUTH_POLICY_FILE",
+			},
+			cli.BoolFlag{
+				Name:   FlagServerAuthPolicyDatabase,
+				Usage:  "When true, authorization policies managed through the /admin/policies API are evaluated after the policy file. When enabled, actions that aren't allowed by a policy are denied. Requires a policy file with a statement for admin:policies, since only the policy file can allow admin actions.",
+				EnvVar: "EMPIRE_SERVER_AUTH_POLICY_DATABASE",
+			},
+			cli.BoolFlag{
+				Name:   FlagServerAuthCollaborators,
+				Usage:  "When true, only the collaborators on an app, and admins, can make changes to the app. Collaborators are managed with `emp access-add` and `emp access-remove`.",
+				EnvVar: "EMPIRE_SERVER_AUTH_COLLABORATORS",
+			},
+			cli.StringSliceFlag{
+				Name:   FlagServerAuthAdmins,
+				Value:  &cli.StringSlice{},
+				Usage:  "Principals that can make changes to any app when `--" + FlagServerAuthCollaborators + "` is enabled, like `user:ejholmes`, `team:1234` or `group:sre`.",
+				EnvVar: "EMPIRE_SERVER_AUTH_ADMINS",
+			},
 			cli.StringSliceFlag{
 				Name:   FlagServerRealIp,
 				Value:  &cli.StringSlice{},
@@ -161,6 +226,66 @@ var Commands = []cli.Command{
 				Usage:  "The location of the public key for this service provider. (e.g. file:///etc/empire/saml.cert)",
 				EnvVar: "EMPIRE_SAML_CERT",
 			},
+			cli.StringFlag{
+				Name:   FlagSAMLGroupsAttribute,
+				Value:  "",
+				Usage:  "The name (or friendly name) of the SAML assertion attribute that contains the groups the user belongs to. (e.g. memberOf)",
+				EnvVar: "EMPIRE_SAML_GROUPS_ATTRIBUTE",
+			},
+			cli.StringSliceFlag{
+				Name:   FlagSAMLGroups,
+				Value:  &cli.StringSlice{},
+				Usage:  "If provided, users have to be a member of at least one of these groups to log in. Requires --saml.groups.attribute.",
+				EnvVar: "EMPIRE_SAML_GROUPS",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCIssuer,
+				Value:  "",
+				Usage:  "The issuer of the OpenID Connect provider, when using the `oidc` authentication backend. (e.g. https://acme.okta.com, https://keycloak.acme.com/realms/acme)",
+				EnvVar: "EMPIRE_OIDC_ISSUER",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCClient,
+				Value:  "",
+				Usage:  "The client id for the OpenID Connect application",
+				EnvVar: "EMPIRE_OIDC_CLIENT_ID",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCClientSecret,
+				Value:  "",
+				Usage:  "The client secret for the OpenID Connect application",
+				EnvVar: "EMPIRE_OIDC_CLIENT_SECRET",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCClientRedirectURL,
+				Value:  "",
+				Usage:  "The base redirect URL for the OpenID Connect application. Defaults to the Empire URL.",
+				EnvVar: "EMPIRE_OIDC_CLIENT_REDIRECT_URL",
+			},
+			cli.StringSliceFlag{
+				Name:   FlagOIDCScopes,
+				Value:  &cli.StringSlice{},
+				Usage:  "Scopes to request in addition to `openid`. Defaults to `email,profile`. Add `groups` if your provider requires it to include the groups claim.",
+				EnvVar: "EMPIRE_OIDC_SCOPES",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCUsernameClaim,
+				Value:  oidc.DefaultUsernameClaim,
+				Usage:  "The claim in the ID token that's used as the user's name.",
+				EnvVar: "EMPIRE_OIDC_CLAIMS_USERNAME",
+			},
+			cli.StringFlag{
+				Name:   FlagOIDCGroupsClaim,
+				Value:  oidc.DefaultGroupsClaim,
+				Usage:  "The claim in the ID token that contains the groups the user belongs to.",
+				EnvVar: "EMPIRE_OIDC_CLAIMS_GROUPS",
+			},
+			cli.StringSliceFlag{
+				Name:   FlagOIDCGroups,
+				Value:  &cli.StringSlice{},
+				Usage:  "If provided, users have to be a member of at least one of these groups to log in.",
+				EnvVar: "EMPIRE_OIDC_GROUPS",
+			},
 			cli.StringFlag{
 				Name:   FlagGithubClient,
 				Value:  "",
@@ -426,16 +551,40 @@ var EmpireFlags = []cli.Flag{
 		Usage:  "If provided, this should be a Go text/template that will be used to generate a CloudFormation stack name for an application. If not provided, and the `--" + FlagEnvironment + "` flag is provided, that will be used as a prefix to the stack name.",
 		EnvVar: "EMPIRE_CLOUDFORMATION_STACK_NAME_TEMPLATE",
 	},
+	cli.DurationFlag{
+		Name:   FlagCloudFormationStabilizationTimeout,
+		Value:  cloudformation.DefaultStabilizationTimeout,
+		Usage:  "The maximum amount of time to wait for ECS services to stabilize after a deployment. Deployments that don't stabilize in time are considered failed, and are rolled back when automatic rollbacks are enabled.",
+		EnvVar: "EMPIRE_CLOUDFORMATION_STABILIZATION_TIMEOUT",
+	},
+	cli.StringFlag{
+		Name:   FlagKubernetesConfig,
+		Value:  "",
+		Usage:  "When using the Kubernetes scheduler, a path to a kubeconfig file to use. If not provided, the in cluster configuration will be used.",
+		EnvVar: "EMPIRE_KUBERNETES_CONFIG",
+	},
+	cli.StringFlag{
+		Name:   FlagKubernetesNamespace,
+		Value:  "default",
+		Usage:  "When using the Kubernetes scheduler, the namespace to create resources in.",
+		EnvVar: "EMPIRE_KUBERNETES_NAMESPACE",
+	},
 	cli.StringFlag{
 		Name:   FlagLogsStreamer,
 		Value:  "",
-		Usage:  "The location of the logs to stream",
+		Usage:  "The location of the logs to stream. Currently supports `kinesis`, `cloudwatch` and `docker`",
 		EnvVar: "EMPIRE_LOGS_STREAMER",
 	},
+	cli.StringFlag{
+		Name:   FlagLogsCloudWatchGroup,
+		Value:  "",
+		Usage:  "When using the cloudwatch logs streamer, a text/template for the name of an app's log group (e.g. /empire/{{.Name}}). Defaults to the awslogs-group log option.",
+		EnvVar: "EMPIRE_LOGS_CLOUDWATCH_GROUP",
+	},
 	cli.StringFlag{
 		Name:   FlagEventsBackend,
 		Value:  "",
-		Usage:  "The backend implementation to use to send event notifactions",
+		Usage:  "The backend implementation to use to send event notifactions. Currently supports `sns`, `stdout` and `webhook`",
 		EnvVar: "EMPIRE_EVENTS_BACKEND",
 	},
 	cli.StringFlag{
@@ -450,6 +599,30 @@ var EmpireFlags = []cli.Flag{
 		Usage:  "When using the SNS events backend, this is the SNS topic that gets published to",
 		EnvVar: "EMPIRE_SNS_TOPIC",
 	},
+	cli.StringSliceFlag{
+		Name:   FlagNotificationsSNSTopics,
+		Value:  &cli.StringSlice{},
+		Usage:  "The comma separated SNS topic ARN prefixes that app notifications can publish to (e.g. arn:aws:sns:us-east-1:123456789012:). If empty, notifications can't be sent to SNS topics.",
+		EnvVar: "EMPIRE_NOTIFICATIONS_SNS_TOPICS",
+	},
+	cli.StringSliceFlag{
+		Name:   FlagWebhookURLs,
+		Value:  &cli.StringSlice{},
+		Usage:  "When using the webhook events backend, the comma separated URLs that events are posted to. The events that are posted to a URL can be limited by adding them to the fragment, separated by `+` (e.g. https://example.com/hooks/empire#deploy+rollback)",
+		EnvVar: "EMPIRE_WEBHOOK_URLS",
+	},
+	cli.StringFlag{
+		Name:   FlagWebhookSecret,
+		Value:  "",
+		Usage:  "When using the webhook events backend, a secret used to sign requests with an HMAC-SHA256 signature in the X-Empire-Signature header",
+		EnvVar: "EMPIRE_WEBHOOK_SECRET",
+	},
+	cli.DurationFlag{
+		Name:   FlagWebhookTimeout,
+		Value:  webhook.DefaultTimeout,
+		Usage:  "When using the webhook events backend, the timeout for a single request to a webhook",
+		EnvVar: "EMPIRE_WEBHOOK_TIMEOUT",
+	},
 	cli.StringFlag{
 		Name:   FlagEnvironment,
 		Value:  "",
@@ -473,6 +646,17 @@ var EmpireFlags = []cli.Flag{
 		Usage:  "Specifies what commands are allowed when using `emp run`. Can be `any`, or `procfile`.",
 		EnvVar: "EMPIRE_ALLOWED_COMMANDS",
 	},
+	cli.BoolFlag{
+		Name:   FlagAutoRollback,
+		Usage:  "If true, deployments that fail to stabilize will be automatically rolled back to the previous release. Can be overridden per app with `emp autorollback-enable` and `emp autorollback-disable`.",
+		EnvVar: "EMPIRE_DEPLOYMENTS_AUTO_ROLLBACK",
+	},
+	cli.DurationFlag{
+		Name:   FlagServiceAccountsExpiration,
+		Value:  empire.DefaultServiceAccountExpiration,
+		Usage:  "The maximum lifetime of service account tokens. Service accounts created without an expiration expire after this long. If 0, service accounts can be created without an expiration.",
+		EnvVar: "EMPIRE_SERVICE_ACCOUNTS_EXPIRATION",
+	},
 	cli.BoolFlag{
 		Name:   FlagXShowAttached,
 		Usage:  "If true, attached runs will be shown in `emp ps` output.",
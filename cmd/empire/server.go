@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"hash/crc32"
 	"log"
 	"net/http"
 	"strings"
@@ -12,14 +13,18 @@ import (
 	"github.com/remind101/conveyor/client/conveyor"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/realip"
+	"github.com/remind101/empire/logs/drains"
+	pglock "github.com/remind101/empire/pkg/pg/lock"
 	"github.com/remind101/empire/server"
 	"github.com/remind101/empire/server/auth"
 	githubauth "github.com/remind101/empire/server/auth/github"
+	oidcauth "github.com/remind101/empire/server/auth/oidc"
 	"github.com/remind101/empire/server/cloudformation"
 	"github.com/remind101/empire/server/github"
 	"github.com/remind101/empire/server/heroku"
 	"github.com/remind101/empire/server/middleware"
 	"github.com/remind101/empire/stats"
+	"github.com/remind101/pkg/reporter"
 	"golang.org/x/oauth2"
 )
 
@@ -66,11 +71,96 @@ func runServer(c *cli.Context) {
 		go p.Start()
 	}
 
+	log.Printf("Starting canary promoter")
+	go promoteCanaries(ctx, e)
+
+	log.Printf("Starting event delivery")
+	go deliverEvents(ctx, e)
+
+	log.Printf("Starting orphaned deployment checks")
+	go failOrphanedDeployments(ctx, e)
+
+	if c.String(FlagLogsStreamer) != "" {
+		log.Printf("Starting log drain forwarder")
+		go forwardLogDrains(ctx, db, e)
+	}
+
 	s := newServer(ctx, e)
 	log.Printf("Starting on port %s", port)
 	log.Fatal(http.ListenAndServe(":"+port, s))
 }
 
+// canaryPromoteInterval is how often canaries are checked for promotion.
+const canaryPromoteInterval = time.Minute
+
+// promoteCanaries periodically promotes canaries that have passed their bake
+// time.
+func promoteCanaries(ctx *Context, e *empire.Empire) {
+	for range time.Tick(canaryPromoteInterval) {
+		if err := e.PromoteCanaries(ctx); err != nil {
+			reporter.Report(ctx, err)
+		}
+	}
+}
+
+// eventDeliveryInterval is how often the outbox is checked for events to
+// deliver.
+const eventDeliveryInterval = 5 * time.Second
+
+// deliverEvents periodically delivers events from the outbox to the configured
+// event streams.
+func deliverEvents(ctx *Context, e *empire.Empire) {
+	for range time.Tick(eventDeliveryInterval) {
+		if err := e.DeliverEvents(ctx); err != nil {
+			reporter.Report(ctx, err)
+		}
+	}
+}
+
+// orphanedDeploymentsInterval is how often deployments are checked for ones
+// that were orphaned by an Empire instance that stopped.
+const orphanedDeploymentsInterval = time.Minute
+
+// failOrphanedDeployments marks orphaned deployments as failed when Empire
+// starts, and periodically after that.
+func failOrphanedDeployments(ctx *Context, e *empire.Empire) {
+	for {
+		n, err := e.FailOrphanedDeployments(ctx)
+		if err != nil {
+			reporter.Report(ctx, err)
+		} else if n > 0 {
+			log.Printf("Marked %d orphaned deployments as failed", n)
+		}
+		time.Sleep(orphanedDeploymentsInterval)
+	}
+}
+
+// logDrainsLockKey is the key for the advisory lock that's held by the Empire
+// instance that forwards logs to log drains.
+var logDrainsLockKey = crc32.ChecksumIEEE([]byte("log_drains"))
+
+// forwardLogDrains forwards logs to log drains. Only one instance of Empire
+// forwards logs at a time, so this blocks until it obtains an advisory lock.
+func forwardLogDrains(ctx *Context, db *empire.DB, e *empire.Empire) {
+	l, err := pglock.NewAdvisoryLock(db.DB.DB(), logDrainsLockKey)
+	if err != nil {
+		reporter.Report(ctx, err)
+		return
+	}
+	l.Context = "log drains"
+
+	if err := l.Lock(); err != nil {
+		reporter.Report(ctx, err)
+		return
+	}
+	defer l.Unlock()
+
+	log.Printf("Obtained log drain lock, forwarding logs to log drains")
+	if err := drains.NewForwarder(e).Run(ctx); err != nil {
+		reporter.Report(ctx, err)
+	}
+}
+
 func newServer(c *Context, e *empire.Empire) http.Handler {
 	var opts server.Options
 	opts.GitHub.Webhooks.Secret = c.String(FlagGithubWebhooksSecret)
@@ -82,10 +172,30 @@ func newServer(c *Context, e *empire.Empire) http.Handler {
 	opts.GitHub.OAuth.RedirectURL = c.String(FlagGithubClientRedirectURL)
 	opts.GitHub.OAuth.Scopes = []string{"read:org", "user:email"}
 
+	v, err := c.OIDCVerifier()
+	if err != nil {
+		panic(err)
+	}
+
+	if v != nil {
+		opts.OIDC.Verifier = v
+		opts.OIDC.ClientID = c.String(FlagOIDCClient)
+		opts.OIDC.ClientSecret = c.String(FlagOIDCClientSecret)
+		opts.OIDC.RedirectURL = c.String(FlagOIDCClientRedirectURL)
+		if opts.OIDC.RedirectURL == "" {
+			opts.OIDC.RedirectURL = c.String(FlagURL)
+		}
+		opts.OIDC.Scopes = c.StringSlice(FlagOIDCScopes)
+		if len(opts.OIDC.Scopes) == 0 {
+			opts.OIDC.Scopes = []string{"email", "profile"}
+		}
+	}
+
 	s := server.New(e, opts)
 	s.URL = c.URL(FlagURL)
 	s.Heroku.Auth = newAuth(c, e)
 	s.Heroku.Secret = []byte(c.String(FlagSecret))
+	s.Secret = []byte(c.String(FlagSecret))
 
 	sp, err := c.SAMLServiceProvider()
 	if err != nil {
@@ -94,6 +204,7 @@ func newServer(c *Context, e *empire.Empire) http.Handler {
 
 	if sp != nil {
 		s.ServiceProvider = sp
+		s.SAMLGroupsAttribute = c.String(FlagSAMLGroupsAttribute)
 		s.Heroku.Unauthorized = heroku.SAMLUnauthorized(c.String(FlagURL) + "/saml/login")
 	}
 
@@ -142,6 +253,118 @@ func newImageBuilder(c *Context) github.ImageBuilder {
 }
 
 func newAuth(c *Context, e *empire.Empire) *auth.Auth {
+	a := newAuthBackend(c, e)
+
+	// Cache team checks for 5 minutes, since they're made for most
+	// requests.
+	teams := auth.CacheMembership(githubauth.NewTeamMembershipChecker(newGitHubAuthClient(c)), 5*time.Minute)
+
+	var authorizers []auth.ActionAuthorizer
+
+	policies, admin := newPolicyStores(c, e)
+	if policies != nil {
+		authorizers = append(authorizers, &auth.PolicyAuthorizer{
+			Policies: policies,
+			Teams:    teams,
+		})
+	}
+
+	// Admin actions are only allowed by the policy file, so that users
+	// can't grant themselves access through the /admin/policies API.
+	// Without a policy file, admin actions are denied.
+	if admin != nil {
+		a.AdminAuthorizer = &auth.PolicyAuthorizer{
+			Policies: admin,
+			Teams:    teams,
+		}
+	}
+
+	if c.Bool(FlagServerAuthCollaborators) {
+		admins := c.StringSlice(FlagServerAuthAdmins)
+		for _, p := range admins {
+			if err := auth.ValidatePrincipal(p); err != nil {
+				panic(err)
+			}
+		}
+
+		log.Println("Restricting changes to apps to collaborators with the following configuration:")
+		log.Println(fmt.Sprintf("  Admins: %v", admins))
+
+		authorizers = append(authorizers, &auth.CollaboratorAuthorizer{
+			Collaborators: auth.DatabaseCollaboratorStore(e),
+			Admins:        admins,
+			Teams:         teams,
+		})
+	}
+
+	// Without any authorizers, all authenticated users can perform any
+	// action.
+	if len(authorizers) > 0 {
+		a.ActionAuthorizer = auth.MultiActionAuthorizer(authorizers...)
+	}
+
+	return a
+}
+
+// newPolicyStores returns the authorization policies to evaluate for actions,
+// and the policies to evaluate for admin actions, which only come from the
+// policy file. Either is nil if there are none.
+func newPolicyStores(c *Context, e *empire.Empire) (policies, admin auth.PolicyStore) {
+	policyFile := c.String(FlagServerAuthPolicyFile)
+	policyDatabase := c.Bool(FlagServerAuthPolicyDatabase)
+
+	if policyFile == "" && !policyDatabase {
+		return nil, nil
+	}
+
+	// Policies in the database are managed with the admin:policies
+	// action, so the policy file needs to control who can perform it.
+	if policyDatabase && policyFile == "" {
+		panic(fmt.Sprintf("--%s requires a policy file in --%s that governs admin:policies", FlagServerAuthPolicyDatabase, FlagServerAuthPolicyFile))
+	}
+
+	var stores []auth.PolicyStore
+
+	// Statements in the policy file take precedence over policies in the
+	// database, so that admins can't be locked out through the API.
+	if policyFile != "" {
+		log.Println("Adding authorization policy from file:")
+		log.Println(fmt.Sprintf("  Path: %v", policyFile))
+
+		file, err := auth.PolicyFile(policyFile)
+		if err != nil {
+			panic(err)
+		}
+
+		if policyDatabase && !file.Governs("admin:policies") {
+			panic(fmt.Sprintf("%s must have a statement for admin:policies when --%s is enabled", policyFile, FlagServerAuthPolicyDatabase))
+		}
+
+		stores = append(stores, file)
+		admin = file
+	}
+
+	if policyDatabase {
+		log.Println("Adding authorization policies from the database")
+		stores = append(stores, auth.DatabasePolicyStore(e))
+	}
+
+	return auth.MultiPolicyStore(stores...), admin
+}
+
+func newGitHubAuthClient(c *Context) *githubauth.Client {
+	config := &oauth2.Config{
+		ClientID:     c.String(FlagGithubClient),
+		ClientSecret: c.String(FlagGithubClientSecret),
+		Scopes:       []string{"repo_deployment", "read:org"},
+	}
+
+	client := githubauth.NewClient(config)
+	client.URL = c.String(FlagGithubApiURL)
+	return client
+}
+
+func newAuthBackend(c *Context, e *empire.Empire) *auth.Auth {
 	authBackend := c.String(FlagServerAuth)
 
 	// For backwards compatibility. If the auth backend is unspecified, but
@@ -182,19 +405,12 @@ func newAuth(c *Context, e *empire.Empire) *auth.Auth {
 			},
 		}
 	case "github":
-		config := &oauth2.Config{
-			ClientID:     c.String(FlagGithubClient),
-			ClientSecret: c.String(FlagGithubClientSecret),
-			Scopes:       []string{"repo_deployment", "read:org"},
-		}
-
-		client := githubauth.NewClient(config)
-		client.URL = c.String(FlagGithubApiURL)
+		client := newGitHubAuthClient(c)
 
 		log.Println("Using GitHub authentication backend with the following configuration:")
-		log.Println(fmt.Sprintf("  ClientID: %v", config.ClientID))
+		log.Println(fmt.Sprintf("  ClientID: %v", client.ClientID))
 		log.Println(fmt.Sprintf("  ClientSecret: ****"))
-		log.Println(fmt.Sprintf("  Scopes: %v", config.Scopes))
+		log.Println(fmt.Sprintf("  Scopes: %v", client.Scopes))
 		log.Println(fmt.Sprintf("  GitHubAPI: %v", client.URL))
 
 		// an authenticator for authenticating requests with a users github
@@ -232,6 +448,12 @@ func newAuth(c *Context, e *empire.Empire) *auth.Auth {
 			a.Authorizer = auth.CacheAuthorization(authorizer, 30*time.Minute)
 		}
 
+		// Service accounts don't have a GitHub token, so the membership
+		// of the user that created them is checked instead.
+		if a.Authorizer != nil {
+			a.ServiceAccountAuthorizer = auth.CreatorAuthorizer(a.Authorizer)
+		}
+
 		return a
 	case "saml":
 		loginURL := c.String(FlagURL) + "/saml/login"
@@ -243,7 +465,7 @@ func newAuth(c *Context, e *empire.Empire) *auth.Auth {
 			return nil, fmt.Errorf("Authentication via username/password is disabled. Login at %s", loginURL)
 		})
 
-		return &auth.Auth{
+		a := &auth.Auth{
 			Strategies: auth.Strategies{
 				{
 					Name:          auth.StrategyUsernamePassword,
@@ -254,6 +476,73 @@ func newAuth(c *Context, e *empire.Empire) *auth.Auth {
 				},
 			},
 		}
+
+		// After the user is authenticated, check that they're a member
+		// of one of the allowed groups.
+		if groups := c.StringSlice(FlagSAMLGroups); len(groups) > 0 {
+			// Without the attribute, users wouldn't have any
+			// groups, and nobody could log in.
+			if c.String(FlagSAMLGroupsAttribute) == "" {
+				panic(fmt.Sprintf("--%s is required when using --%s", FlagSAMLGroupsAttribute, FlagSAMLGroups))
+			}
+
+			log.Println("Adding SAML group authorizer with the following configuration:")
+			log.Println(fmt.Sprintf("  GroupsAttribute: %v", c.String(FlagSAMLGroupsAttribute)))
+			log.Println(fmt.Sprintf("  Groups: %v", groups))
+
+			a.Authorizer = &auth.GroupAuthorizer{Groups: groups}
+		}
+
+		return a
+	case "oidc":
+		v, err := c.OIDCVerifier()
+		if err != nil {
+			panic(err)
+		}
+
+		if v == nil {
+			panic(fmt.Sprintf("--%s is required when using the oidc authentication backend", FlagOIDCIssuer))
+		}
+
+		authenticator := oidcauth.NewAuthenticator(v)
+		authenticator.UsernameClaim = c.String(FlagOIDCUsernameClaim)
+		authenticator.GroupsClaim = c.String(FlagOIDCGroupsClaim)
+
+		log.Println("Using OpenID Connect authentication backend with the following configuration:")
+		log.Println(fmt.Sprintf("  Issuer: %v", v.Provider.Issuer))
+		log.Println(fmt.Sprintf("  ClientID: %v", c.String(FlagOIDCClient)))
+		log.Println(fmt.Sprintf("  ClientSecret: ****"))
+		log.Println(fmt.Sprintf("  UsernameClaim: %v", authenticator.UsernameClaim))
+		log.Println(fmt.Sprintf("  GroupsClaim: %v", authenticator.GroupsClaim))
+		log.Println(fmt.Sprintf("  Groups: %v", c.StringSlice(FlagOIDCGroups)))
+
+		// Users log in through the browser with `emp weblogin`, which
+		// exchanges the ID token for an access token, so
+		// username/password authentication isn't possible.
+		idTokenOnly := auth.AuthenticatorFunc(func(username, password, otp string) (*auth.Session, error) {
+			if username != oidcauth.TokenUsername {
+				return nil, fmt.Errorf("Authentication via username/password is disabled. Login with `emp weblogin`")
+			}
+			return authenticator.Authenticate(username, password, otp)
+		})
+
+		a := &auth.Auth{
+			Strategies: auth.Strategies{
+				{
+					Name:          auth.StrategyUsernamePassword,
+					Authenticator: withSessionExpiration(idTokenOnly),
+					// ID tokens should only be used to
+					// create access tokens.
+					Disabled: true,
+				},
+			},
+		}
+
+		if groups := c.StringSlice(FlagOIDCGroups); len(groups) > 0 {
+			a.Authorizer = &auth.GroupAuthorizer{Groups: groups}
+		}
+
+		return a
 	default:
 		panic("unreachable")
 	}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/server/auth"
+	oidcauth "github.com/remind101/empire/server/auth/oidc"
+	"github.com/remind101/empire/server/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestNewAuthBackend_OIDCGroups(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	c := newTestContext(t,
+		"--"+FlagServerAuth, "oidc",
+		"--"+FlagOIDCIssuer, i.URL,
+		"--"+FlagOIDCClient, i.ClientID,
+		"--"+FlagOIDCGroups, "engineering",
+	)
+	a := newAuthBackend(c, &empire.Empire{})
+
+	_, err := a.Authenticate(context.Background(), oidcauth.TokenUsername, i.IDToken(map[string]interface{}{
+		"sub":    "00u1",
+		"email":  "ejholmes@example.com",
+		"groups": []string{"engineering"},
+	}), "", auth.StrategyUsernamePassword)
+	assert.NoError(t, err)
+
+	_, err = a.Authenticate(context.Background(), oidcauth.TokenUsername, i.IDToken(map[string]interface{}{
+		"sub":    "00u2",
+		"email":  "mallory@example.com",
+		"groups": []string{"marketing"},
+	}), "", auth.StrategyUsernamePassword)
+	assert.IsType(t, &auth.UnauthorizedError{}, err)
+}
+
+func TestNewAuthBackend_SAMLGroupsWithoutAttribute(t *testing.T) {
+	c := newTestContext(t,
+		"--"+FlagServerAuth, "saml",
+		"--"+FlagSAMLGroups, "engineering",
+	)
+
+	assert.Panics(t, func() {
+		newAuthBackend(c, &empire.Empire{})
+	})
+}
+
+// newTestContext returns a Context with the flags for the server command
+// parsed from args.
+func newTestContext(t testing.TB, args ...string) *Context {
+	set := flag.NewFlagSet("server", flag.ContinueOnError)
+	for _, f := range Commands[0].Flags {
+		// The values of slice flags are shared, so each test gets
+		// its own.
+		if f, ok := f.(cli.StringSliceFlag); ok {
+			f.Value = &cli.StringSlice{}
+			f.Apply(set)
+			continue
+		}
+		f.Apply(set)
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Context{Context: cli.NewContext(nil, set, nil)}
+}
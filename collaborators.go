@@ -0,0 +1,232 @@
+package empire
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// Collaborator gives a user access to make changes to an app. Apps without
+// any collaborators can be changed by anyone.
+type Collaborator struct {
+	// A unique uuid that identifies this collaborator.
+	ID string
+
+	// The app that the user collaborates on.
+	AppID string
+	App   *App
+
+	// The name of the user.
+	UserName string
+
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate sets created_at and updated_at before inserting.
+func (c *Collaborator) BeforeCreate() error {
+	t := timex.Now()
+	c.CreatedAt = &t
+	c.UpdatedAt = &t
+	return nil
+}
+
+// CollaboratorsQuery is a scope implementation for common things to filter
+// collaborators by.
+type CollaboratorsQuery struct {
+	// If provided, finds the collaborator with the given id.
+	ID *string
+
+	// If provided, finds the collaborator with the given user name.
+	UserName *string
+
+	// If provided, finds collaborators on the given app.
+	App *App
+}
+
+// scope implements the scope interface.
+func (q CollaboratorsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.UserName != nil {
+		scope = append(scope, fieldEquals("user_name", *q.UserName))
+	}
+
+	if q.App != nil {
+		scope = append(scope, forApp(q.App))
+	}
+
+	scope = append(scope, order("created_at"))
+
+	return scope.scope(db)
+}
+
+// collaboratorsPreload is a scope that will preload the app for a
+// collaborator.
+var collaboratorsPreload = preload("App")
+
+// collaboratorsFind returns the first matching collaborator.
+func collaboratorsFind(db *gorm.DB, scope scope) (*Collaborator, error) {
+	var collaborator Collaborator
+	scope = composedScope{collaboratorsPreload, scope}
+	return &collaborator, first(db, scope, &collaborator)
+}
+
+// collaborators returns all collaborators matching the scope.
+func collaborators(db *gorm.DB, scope scope) ([]*Collaborator, error) {
+	var collaborators []*Collaborator
+	scope = composedScope{collaboratorsPreload, scope}
+	return collaborators, find(db, scope, &collaborators)
+}
+
+// collaboratorsCreate inserts a new collaborator.
+func collaboratorsCreate(db *gorm.DB, c *Collaborator) (*Collaborator, error) {
+	return c, db.Create(c).Error
+}
+
+// collaboratorsDestroy deletes a collaborator.
+func collaboratorsDestroy(db *gorm.DB, c *Collaborator) error {
+	return db.Delete(c).Error
+}
+
+// CreateCollaboratorOpts are options provided when giving a user access to an
+// app.
+type CreateCollaboratorOpts struct {
+	// User performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// The name of the user to give access to.
+	UserName string
+}
+
+func (opts CreateCollaboratorOpts) Validate(e *Empire) error {
+	if opts.UserName == "" {
+		return &ValidationError{Err: fmt.Errorf("user is required")}
+	}
+
+	return nil
+}
+
+// CreateCollaborator gives a user access to an app.
+func (e *Empire) CreateCollaborator(ctx context.Context, opts CreateCollaboratorOpts) (*Collaborator, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	alreadyCollaborator := &ValidationError{Err: fmt.Errorf("%s is already a collaborator on %s", opts.UserName, opts.App.Name)}
+
+	tx := e.db.Begin()
+
+	_, err := collaboratorsFind(tx, CollaboratorsQuery{App: opts.App, UserName: &opts.UserName})
+	switch err {
+	case nil:
+		tx.Rollback()
+		return nil, alreadyCollaborator
+	case gorm.RecordNotFound:
+	default:
+		tx.Rollback()
+		return nil, err
+	}
+
+	c, err := collaboratorsCreate(tx, &Collaborator{
+		AppID:    opts.App.ID,
+		UserName: opts.UserName,
+	})
+	if err != nil {
+		tx.Rollback()
+		// A concurrent request added the same collaborator after we
+		// checked.
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			return nil, alreadyCollaborator
+		}
+		return c, err
+	}
+	c.App = opts.App
+
+	if err := e.publishEvent(tx, CollaboratorAddEvent{
+		User:         opts.User.Name,
+		App:          opts.App.Name,
+		Collaborator: c.UserName,
+		app:          opts.App,
+	}); err != nil {
+		tx.Rollback()
+		return c, err
+	}
+
+	return c, tx.Commit().Error
+}
+
+// DestroyCollaboratorOpts are options provided when removing a user's access to
+// an app.
+type DestroyCollaboratorOpts struct {
+	// User performing the action.
+	User *User
+
+	// The collaborator to remove.
+	Collaborator *Collaborator
+}
+
+// DestroyCollaborator removes a user's access to an app. The last collaborator
+// on an app can't be removed, since that would let anyone change the app.
+func (e *Empire) DestroyCollaborator(ctx context.Context, opts DestroyCollaboratorOpts) error {
+	tx := e.db.Begin()
+
+	// Lock the app's collaborators, so that concurrent removals can't
+	// remove all of them.
+	if err := tx.Exec(`SELECT id FROM collaborators WHERE app_id = ? FOR UPDATE`, opts.Collaborator.AppID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	cs, err := collaborators(tx, CollaboratorsQuery{App: &App{ID: opts.Collaborator.AppID}})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(cs) <= 1 {
+		tx.Rollback()
+		return &ValidationError{Err: fmt.Errorf("%s is the last collaborator, and can't be removed", opts.Collaborator.UserName)}
+	}
+
+	if err := collaboratorsDestroy(tx, opts.Collaborator); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	event := CollaboratorRemoveEvent{
+		User:         opts.User.Name,
+		Collaborator: opts.Collaborator.UserName,
+		app:          opts.Collaborator.App,
+	}
+	if app := opts.Collaborator.App; app != nil {
+		event.App = app.Name
+	}
+	if err := e.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// CollaboratorsFind returns the first collaborator matching the query.
+func (e *Empire) CollaboratorsFind(q CollaboratorsQuery) (*Collaborator, error) {
+	return collaboratorsFind(e.db, q)
+}
+
+// Collaborators returns all collaborators matching the query.
+func (e *Empire) Collaborators(q CollaboratorsQuery) ([]*Collaborator, error) {
+	return collaborators(e.db, q)
+}
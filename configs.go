@@ -137,7 +137,7 @@ func (s *configsService) Set(ctx context.Context, db *gorm.DB, opts SetOpts) (*C
 		return c, err
 	}
 
-	release, err := releasesFind(db, ReleasesQuery{App: app})
+	release, err := releasesCurrent(db, app)
 	if err != nil {
 		if err == gorm.RecordNotFound {
 			err = nil
@@ -158,7 +158,7 @@ func (s *configsService) Set(ctx context.Context, db *gorm.DB, opts SetOpts) (*C
 
 // Returns configs for latest release or the latest configs if there are no releases.
 func (s *configsService) Config(db *gorm.DB, app *App) (*Config, error) {
-	r, err := releasesFind(db, ReleasesQuery{App: app})
+	r, err := releasesCurrent(db, app)
 	if err != nil {
 		if err == gorm.RecordNotFound {
 			// It's possible to have config without releases, this handles that.
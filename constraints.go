@@ -22,6 +22,10 @@ var (
 
 	// DefaultConstraints defaults to 1X process size.
 	DefaultConstraints = Constraints1X
+
+	// DefaultSidecarMemory is the memory limit for sidecars that don't
+	// specify one.
+	DefaultSidecarMemory = constraints.Memory(128 * MB)
 )
 
 // Constraints aliases the constraints.Constraints type to implement the
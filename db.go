@@ -50,7 +50,13 @@ func OpenDB(uri string) (*DB, error) {
 		return nil, err
 	}
 
-	return NewDB(conn)
+	db, err := NewDB(conn)
+	if err != nil {
+		return nil, err
+	}
+	db.uri = uri
+
+	return db, nil
 }
 
 // NewDB wraps a sql.DB instance as a DB.
@@ -95,6 +101,8 @@ func (db *DB) Reset() error {
 	exec(`TRUNCATE TABLE apps CASCADE`)
 	exec(`TRUNCATE TABLE ports CASCADE`)
 	exec(`TRUNCATE TABLE slugs CASCADE`)
+	exec(`TRUNCATE TABLE audit_events`)
+	exec(`TRUNCATE TABLE outbox_events CASCADE`)
 	exec(`UPDATE ports SET app_id = NULL`)
 
 	return err
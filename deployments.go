@@ -1,23 +1,479 @@
 package empire
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire/pkg/jsonmessage"
+	"github.com/remind101/empire/pkg/timex"
 	"github.com/remind101/empire/twelvefactor"
+	"github.com/remind101/pkg/logger"
+	"github.com/remind101/pkg/reporter"
 	"golang.org/x/net/context"
 )
 
+// DeploymentState represents the state that a Deployment is in.
+type DeploymentState string
+
+// The states that a Deployment can be in.
+const (
+	// The deployment has been created, but hasn't started yet.
+	DeploymentQueued DeploymentState = "queued"
+
+	// The image is being pulled, and the release is being created.
+	DeploymentBuilding DeploymentState = "building"
+
+	// The release is being submitted to the scheduler.
+	DeploymentSubmitting DeploymentState = "submitting"
+
+	// The scheduler is waiting for the release to become stable.
+	DeploymentStabilizing DeploymentState = "stabilizing"
+
+	// The deployment finished successfully.
+	DeploymentSucceeded DeploymentState = "succeeded"
+
+	// The deployment failed.
+	DeploymentFailed DeploymentState = "failed"
+
+	// The deployment was cancelled by a user.
+	DeploymentCancelled DeploymentState = "cancelled"
+)
+
+const (
+	// How often a deployment that's in progress is saved, to show that the
+	// Empire instance performing it is still alive.
+	deploymentHeartbeatInterval = 30 * time.Second
+
+	// Deployments that are in progress, but haven't been saved for this
+	// long, are considered orphaned (e.g. the Empire instance performing
+	// them was stopped), and are marked as failed.
+	deploymentHeartbeatTimeout = 5 * time.Minute
+
+	// Messages written to a deployment are saved at most this often.
+	deploymentMessagesFlushInterval = time.Second
+)
+
+// ErrDeploymentOrphaned is the error recorded for deployments that stopped
+// sending heartbeats.
+var ErrDeploymentOrphaned = errors.New("the Empire instance performing the deployment stopped")
+
+// inProgressDeploymentStates are the states of deployments that haven't
+// finished yet.
+var inProgressDeploymentStates = []DeploymentState{
+	DeploymentQueued,
+	DeploymentBuilding,
+	DeploymentSubmitting,
+	DeploymentStabilizing,
+}
+
+// Finished returns true if the deployment has finished, successfully or not.
+func (s DeploymentState) Finished() bool {
+	return s == DeploymentSucceeded || s == DeploymentFailed || s == DeploymentCancelled
+}
+
+// submitted returns true if the release may have been submitted to the
+// scheduler by the time a deployment reached this state.
+func (s DeploymentState) submitted() bool {
+	return s == DeploymentSubmitting || s == DeploymentStabilizing
+}
+
+// ErrDeploymentCancelled is returned when a deployment is cancelled while it's
+// in progress.
+var ErrDeploymentCancelled = errors.New("deployment was cancelled")
+
+// Deployment records the progress of deploying an image to an app.
+type Deployment struct {
+	// A unique uuid that identifies this deployment.
+	ID string
+
+	// The app that's being deployed to.
+	AppID string
+	App   *App
+
+	// The image that's being deployed.
+	Image string
+
+	// The name of the user that started the deployment.
+	UserName string
+
+	// The current state of the deployment.
+	State DeploymentState
+
+	// The version of the release that was created, once it's been
+	// created.
+	Version *int
+
+	// If the deployment failed, the error that caused it to fail.
+	Error string
+
+	// If the deployment was cancelled, the state that it was in when it
+	// was cancelled.
+	CancelledState *DeploymentState
+
+	// The status messages that were written during the deployment.
+	Messages DeploymentMessages
+
+	CreatedAt  *time.Time
+	UpdatedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// BeforeCreate sets created_at before inserting.
+func (d *Deployment) BeforeCreate() error {
+	t := timex.Now()
+	d.CreatedAt = &t
+	d.UpdatedAt = &t
+	return nil
+}
+
+// DeploymentMessage is a status message that was written during a deployment.
+type DeploymentMessage struct {
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeploymentMessages represents the messages of a Deployment.
+type DeploymentMessages []DeploymentMessage
+
+// Scan implements the sql.Scanner interface.
+func (m *DeploymentMessages) Scan(src interface{}) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return error(errors.New("Scan source was not []bytes"))
+	}
+
+	var messages DeploymentMessages
+	if err := json.Unmarshal(bytes, &messages); err != nil {
+		return err
+	}
+	*m = messages
+
+	return nil
+}
+
+// Value implements the driver.Value interface.
+func (m DeploymentMessages) Value() (driver.Value, error) {
+	if m == nil {
+		m = DeploymentMessages{}
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(raw), nil
+}
+
+// DeploymentsQuery is a scope implementation for common things to filter
+// deployments by.
+type DeploymentsQuery struct {
+	// If provided, finds the deployment with the given id.
+	ID *string
+
+	// If provided, finds deployments for the given app.
+	App *App
+
+	// If true, only finds deployments that haven't finished.
+	InProgress bool
+}
+
+// scope implements the scope interface.
+func (q DeploymentsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.App != nil {
+		scope = append(scope, forApp(q.App))
+	}
+
+	if q.InProgress {
+		scope = append(scope, scopeFunc(func(db *gorm.DB) *gorm.DB {
+			return db.Where("state in (?)", inProgressDeploymentStates)
+		}))
+	}
+
+	scope = append(scope, order("created_at desc"))
+
+	return scope.scope(db)
+}
+
+// These associations are always available on a Deployment.
+var deploymentsPreload = preload("App")
+
+// deploymentsFind returns the first matching deployment.
+func deploymentsFind(db *gorm.DB, scope scope) (*Deployment, error) {
+	var deployment Deployment
+	scope = composedScope{deploymentsPreload, scope}
+	return &deployment, first(db, scope, &deployment)
+}
+
+// deploymentsCreate inserts a new deployment.
+func deploymentsCreate(db *gorm.DB, d *Deployment) (*Deployment, error) {
+	return d, db.Create(d).Error
+}
+
+// deploymentsUpdate updates an existing deployment. A deployment can be
+// cancelled while it's in progress, so the state and error of a cancelled
+// deployment are never changed.
+func deploymentsUpdate(db *gorm.DB, d *Deployment) error {
+	t := timex.Now()
+	d.UpdatedAt = &t
+
+	if err := db.Model(&Deployment{}).Where("id = ?", d.ID).UpdateColumns(map[string]interface{}{
+		"version":     d.Version,
+		"messages":    d.Messages,
+		"updated_at":  d.UpdatedAt,
+		"finished_at": d.FinishedAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&Deployment{}).Where("id = ? AND state <> ?", d.ID, DeploymentCancelled).UpdateColumns(map[string]interface{}{
+		"state": d.State,
+		"error": d.Error,
+	}).Error
+}
+
+// deploymentsFailOrphaned marks the deployments that are in progress, but
+// haven't been saved since the given time, as failed. The number of
+// deployments that were marked as failed is returned.
+func deploymentsFailOrphaned(db *gorm.DB, before time.Time) (int64, error) {
+	t := timex.Now()
+	result := db.Model(&Deployment{}).Where("state in (?) AND updated_at < ?", inProgressDeploymentStates, before).UpdateColumns(map[string]interface{}{
+		"state":       DeploymentFailed,
+		"error":       ErrDeploymentOrphaned.Error(),
+		"updated_at":  &t,
+		"finished_at": &t,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// deploymentsCancel marks the deployment as cancelled, and records the state
+// that it was in. If the deployment has already finished, a ValidationError is
+// returned. The given db should be a transaction, since the deployment is
+// locked until it's committed.
+func deploymentsCancel(tx *gorm.DB, d *Deployment, reason string) error {
+	var state DeploymentState
+	if err := tx.Raw(`SELECT state FROM deployments WHERE id = ? FOR UPDATE`, d.ID).Row().Scan(&state); err != nil {
+		return err
+	}
+
+	if state.Finished() {
+		return &ValidationError{Err: fmt.Errorf("deployment %s has already finished", d.ID)}
+	}
+
+	t := timex.Now()
+	if err := tx.Model(&Deployment{}).Where("id = ?", d.ID).UpdateColumns(map[string]interface{}{
+		"state":           DeploymentCancelled,
+		"error":           reason,
+		"cancelled_state": state,
+		"updated_at":      &t,
+	}).Error; err != nil {
+		return err
+	}
+
+	d.State = DeploymentCancelled
+	d.Error = reason
+	d.CancelledState = &state
+	d.UpdatedAt = &t
+	return nil
+}
+
+// deploymentRecorder records the progress of a deployment, as it's written to
+// a DeploymentStream. When deploying to an app that doesn't exist yet, the
+// deployment is only inserted once the app has been created, and any messages
+// up to that point are buffered. Messages are also buffered between saves, so
+// that the deployment isn't saved for every line of output.
+type deploymentRecorder struct {
+	db *gorm.DB
+
+	mu sync.Mutex
+	d  *Deployment
+
+	// When the deployment was last saved, and whether messages have been
+	// recorded since then.
+	saved time.Time
+	dirty bool
+}
+
+// newDeploymentRecorder returns a new deploymentRecorder for d. If d has
+// already been inserted, updates are saved to it.
+func newDeploymentRecorder(db *gorm.DB, d *Deployment) *deploymentRecorder {
+	return &deploymentRecorder{db: db, d: d}
+}
+
+// Deployment returns the recorded Deployment.
+func (r *deploymentRecorder) Deployment() *Deployment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.d
+}
+
+// SetRelease sets the release that was created for the deployment. If the
+// deployment was cancelled, ErrDeploymentCancelled is returned.
+func (r *deploymentRecorder) SetRelease(release *Release) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.d.App = release.App
+	r.d.AppID = release.App.ID
+	r.d.Version = &release.Version
+	return r.checkpoint()
+}
+
+// SetState transitions the deployment to the given state. If the deployment
+// was cancelled, ErrDeploymentCancelled is returned.
+func (r *deploymentRecorder) SetState(state DeploymentState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.d.State = state
+	return r.checkpoint()
+}
+
+// Cancelled returns true if the deployment has been cancelled. Deployments can
+// be cancelled from other processes, so this always checks the database.
+func (r *deploymentRecorder) Cancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.cancelled()
+	return ok
+}
+
+// CancelledState returns the state that the deployment was in when it was
+// cancelled, and whether it was cancelled.
+func (r *deploymentRecorder) CancelledState() (DeploymentState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled()
+}
+
+// Finish marks the deployment as succeeded, or failed if err is not nil.
+func (r *deploymentRecorder) Finish(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := timex.Now()
+	r.d.FinishedAt = &t
+	r.d.State = DeploymentSucceeded
+	if err != nil {
+		r.d.State = DeploymentFailed
+		r.d.Error = err.Error()
+	}
+	return r.save()
+}
+
+// Record records a jsonmessage that was written to the deployment stream.
+func (r *deploymentRecorder) Record(m jsonmessage.JSONMessage) error {
+	var message string
+	switch {
+	case m.ErrorMessage != "":
+		message = m.ErrorMessage
+	case m.Status != "":
+		message = m.Status
+	case m.Stream != "":
+		message = strings.TrimRight(m.Stream, "\r\n")
+	}
+	if message == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.d.Messages = append(r.d.Messages, DeploymentMessage{
+		Message:   message,
+		CreatedAt: timex.Now(),
+	})
+	if time.Since(r.saved) < deploymentMessagesFlushInterval {
+		r.dirty = true
+		return nil
+	}
+	return r.save()
+}
+
+// Heartbeat saves any messages that were buffered, and saves the deployment if
+// it hasn't been saved recently, to show that it's still in progress.
+func (r *deploymentRecorder) Heartbeat() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty && time.Since(r.saved) < deploymentHeartbeatInterval {
+		return nil
+	}
+	return r.save()
+}
+
+// checkpoint saves the deployment, then returns ErrDeploymentCancelled if the
+// deployment was cancelled.
+func (r *deploymentRecorder) checkpoint() error {
+	if err := r.save(); err != nil {
+		return err
+	}
+	if _, ok := r.cancelled(); ok {
+		return ErrDeploymentCancelled
+	}
+	return nil
+}
+
+// cancelled returns whether the deployment was cancelled, and the state that it
+// was in when it was cancelled.
+func (r *deploymentRecorder) cancelled() (DeploymentState, bool) {
+	if r.d.ID == "" {
+		return "", false
+	}
+	d, err := deploymentsFind(r.db, DeploymentsQuery{ID: &r.d.ID})
+	if err != nil || d.State != DeploymentCancelled {
+		return "", false
+	}
+	if d.CancelledState == nil {
+		return "", true
+	}
+	return *d.CancelledState, true
+}
+
+// save inserts or updates the deployment, if the app is known.
+func (r *deploymentRecorder) save() error {
+	if r.d.AppID == "" {
+		r.dirty = true
+		return nil
+	}
+
+	var err error
+	if r.d.ID == "" {
+		_, err = deploymentsCreate(r.db, r.d)
+	} else {
+		err = deploymentsUpdate(r.db, r.d)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.saved = time.Now()
+	r.dirty = false
+	return nil
+}
+
 // deployerService is an implementation of the deployer interface that performs
 // the core business logic to deploy.
 type deployerService struct {
 	*Empire
+
+	// Cancels the deployments that are in progress in this process.
+	mu       sync.Mutex
+	inflight map[*deploymentRecorder]context.CancelFunc
 }
 
-// createRelease creates a new release that can be deployed
-func (s *deployerService) createRelease(ctx context.Context, db *gorm.DB, ss twelvefactor.StatusStream, opts DeployOpts) (*Release, error) {
+// createRelease creates a new release that can be deployed. If the deploy is a
+// canary, the canary for the release is also returned.
+func (s *deployerService) createRelease(ctx context.Context, db *gorm.DB, ss twelvefactor.StatusStream, opts DeployOpts) (*Release, *Canary, error) {
 	app, img := opts.App, opts.Image
 
 	// If no app is specified, attempt to find the app that relates to this
@@ -26,26 +482,37 @@ func (s *deployerService) createRelease(ctx context.Context, db *gorm.DB, ss twe
 		var err error
 		app, err = appsFindOrCreateByRepo(db, img.Repository)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		// If the app doesn't already have a repo attached to it, we'll attach
 		// this image's repo.
 		if err := appsEnsureRepo(db, app, img.Repository); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	}
+
+	// Canaries are rolled out alongside the stable release, so there needs
+	// to be one.
+	var stable int
+	if opts.CanaryWeight > 0 {
+		var err error
+		stable, err = canaryStableVersion(db, app)
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
 	// Grab the latest config.
 	config, err := s.configs.Config(db, app)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create a new slug for the docker image.
 	slug, err := s.slugs.Create(ctx, db, img, opts.Output)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create a new release for the Config
@@ -59,61 +526,618 @@ func (s *deployerService) createRelease(ctx context.Context, db *gorm.DB, ss twe
 		Slug:        slug,
 		Description: desc,
 	})
-	return r, err
+	if err != nil {
+		return r, nil, err
+	}
+
+	// Releases with a release command stay pending until the command
+	// succeeds, so that they don't become the current release before then.
+	if _, ok := r.Formation[ReleaseProcessType]; ok {
+		r.Pending = true
+		if err := releasesUpdate(db, r); err != nil {
+			return r, nil, err
+		}
+	}
+
+	var c *Canary
+	if opts.CanaryWeight > 0 {
+		c = &Canary{
+			AppID:         app.ID,
+			Version:       r.Version,
+			StableVersion: stable,
+			Weight:        opts.CanaryWeight,
+		}
+		if opts.CanaryBakeTime != 0 {
+			t := timex.Now().Add(opts.CanaryBakeTime)
+			c.PromoteAt = &t
+		}
+
+		// The canary for a pending release is created when the
+		// release is promoted.
+		if !r.Pending {
+			if _, err := canariesCreate(db, c); err != nil {
+				return r, nil, err
+			}
+		}
+	}
+
+	return r, c, nil
+}
+
+// canaryStableVersion returns the release version that a new canary should be
+// rolled out alongside. If there's already a canary in progress, the new canary
+// replaces it, and uses the same stable release.
+func canaryStableVersion(db *gorm.DB, app *App) (int, error) {
+	c, err := canaryForApp(db, app)
+	if err != nil {
+		return 0, err
+	}
+	if c != nil {
+		return c.StableVersion, nil
+	}
+
+	v, err := releasesLastVersion(db, app.ID)
+	if err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		return 0, &ValidationError{Err: errors.New("canary deploys require an existing release")}
+	}
+	return v, nil
 }
 
-func (s *deployerService) createInTransaction(ctx context.Context, stream twelvefactor.StatusStream, opts DeployOpts) (*Release, error) {
+func (s *deployerService) createInTransaction(ctx context.Context, stream twelvefactor.StatusStream, opts DeployOpts) (*Release, *Canary, error) {
 	tx := s.db.Begin()
-	r, err := s.createRelease(ctx, tx, stream, opts)
+	r, c, err := s.createRelease(ctx, tx, stream, opts)
 	if err != nil {
 		tx.Rollback()
-		return r, err
+		return r, c, err
 	}
-	return r, tx.Commit().Error
+
+	// The event is written in the same transaction as the release, so
+	// that it's never lost or published for a release that doesn't exist.
+	event := opts.Event()
+	event.Release = r.Version
+	event.Environment = s.Environment
+	// Deals with new app creation on first deploy
+	if event.App == "" && r.App != nil {
+		event.App = r.App.Name
+		event.app = r.App
+	}
+	if err := s.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return r, c, err
+	}
+
+	return r, c, tx.Commit().Error
 }
 
-// Deploy is a thin wrapper around deploy to that adds the error to the
-// jsonmessage stream.
+// Queue inserts a new queued Deployment, which can be started by calling Deploy
+// with the returned options. If no app is provided, the app is found or created
+// from the image's repository, so that the deployment can be looked up later.
+func (s *deployerService) Queue(ctx context.Context, opts DeployOpts) (*Deployment, DeployOpts, error) {
+	app := opts.App
+	if app == nil {
+		var err error
+		app, err = appsFindOrCreateByRepo(s.db, opts.Image.Repository)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.App = app
+	}
+
+	d := &Deployment{
+		App:   app,
+		AppID: app.ID,
+		Image: opts.Image.String(),
+		State: DeploymentQueued,
+	}
+	if opts.User != nil {
+		d.UserName = opts.User.Name
+	}
+	if _, err := deploymentsCreate(s.db, d); err != nil {
+		return nil, opts, err
+	}
+
+	// Nobody is attached to the output of a queued deployment, so it's
+	// only recorded. The status stream is always enabled, so that the
+	// deployment is only marked as succeeded once it's stable.
+	opts.Output = NewDeploymentStream(ioutil.Discard)
+	opts.Output.recorder = newDeploymentRecorder(s.db, d)
+	opts.Stream = true
+
+	return d, opts, nil
+}
+
+// Deploy performs the deployment, and records its progress as a Deployment.
 func (s *deployerService) Deploy(ctx context.Context, opts DeployOpts) (*Release, error) {
 	w := opts.Output
 
+	// Detached deployments are inserted before they're started.
+	// Otherwise, we start recording the deployment here.
+	if w.recorder == nil {
+		d := &Deployment{
+			Image: opts.Image.String(),
+			State: DeploymentQueued,
+		}
+		if opts.User != nil {
+			d.UserName = opts.User.Name
+		}
+		if opts.App != nil {
+			d.App = opts.App
+			d.AppID = opts.App.ID
+		}
+		w.recorder = newDeploymentRecorder(s.db, d)
+	}
+
+	deployCtx, cancel := context.WithCancel(ctx)
+	s.track(w.recorder, cancel)
+	defer s.untrack(w.recorder)
+
+	done := make(chan struct{})
+	go heartbeat(ctx, w.recorder, done)
+
+	r, err := s.deploy(deployCtx, opts)
+	if state, ok := w.recorder.CancelledState(); ok {
+		err = s.cancelled(ctx, r, opts, state)
+	}
+
+	close(done)
+	if ferr := w.recorder.Finish(err); ferr != nil && err == nil {
+		err = ferr
+	}
+	return r, err
+}
+
+// heartbeat periodically saves the deployment until done is closed, so that
+// buffered messages are written, and the deployment isn't considered orphaned.
+func heartbeat(ctx context.Context, r *deploymentRecorder, done <-chan struct{}) {
+	t := time.NewTicker(deploymentMessagesFlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if err := r.Heartbeat(); err != nil {
+				reporter.Report(ctx, err)
+			}
+		}
+	}
+}
+
+// Cancel cancels a deployment that's in progress. The deployment is marked as
+// cancelled, and if it was being submitted to the scheduler, the scheduler is
+// asked to cancel the submission. If the deployment is running in this
+// process, it's also stopped by canceling its context. Otherwise, the process
+// performing the deployment stops at its next step. The event is published
+// within the same transaction that marks the deployment as cancelled.
+func (s *deployerService) Cancel(ctx context.Context, d *Deployment, user *User, message string, event Event) error {
+	reason := fmt.Sprintf("cancelled by %s", user.Name)
+	if message != "" {
+		reason = fmt.Sprintf("%s: %s", reason, message)
+	}
+
+	tx := s.db.Begin()
+
+	if err := deploymentsCancel(tx, d, reason); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// Before the release is submitted, the scheduler could only cancel an
+	// update to the app from some other operation.
+	if d.CancelledState.submitted() {
+		if err := s.Scheduler.Cancel(ctx, d.AppID); err != nil {
+			return fmt.Errorf("error canceling deployment: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for r, cancel := range s.inflight {
+		if r.Deployment().ID == d.ID {
+			cancel()
+		}
+	}
+
+	return nil
+}
+
+// track records the cancel function for a deployment that's in progress.
+func (s *deployerService) track(r *deploymentRecorder, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight == nil {
+		s.inflight = make(map[*deploymentRecorder]context.CancelFunc)
+	}
+	s.inflight[r] = cancel
+}
+
+// untrack removes a deployment that's finished, and releases its context.
+func (s *deployerService) untrack(r *deploymentRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.inflight[r]; ok {
+		cancel()
+		delete(s.inflight, r)
+	}
+}
+
+// cancelled is called when a deployment was cancelled, with the state that it
+// was in. If the release had been submitted to the scheduler, it's marked as
+// failed and the app is rolled back to the last release that didn't fail, which
+// also reverts any changes that the scheduler made before it was canceled. If
+// the release was created but never submitted, it's only marked as failed.
+func (s *deployerService) cancelled(ctx context.Context, r *Release, opts DeployOpts, state DeploymentState) error {
+	w := opts.Output
+
+	if r == nil {
+		return w.Error(ErrDeploymentCancelled)
+	}
+
+	if err := w.Status(fmt.Sprintf("Deployment of v%d was cancelled", r.Version)); err != nil {
+		return err
+	}
+
+	deployErr := &twelvefactor.DeploymentError{Reason: ErrDeploymentCancelled.Error()}
+	if !state.submitted() {
+		if err := s.releaseFailed(r, opts, deployErr); err != deployErr {
+			return w.Error(err)
+		}
+		return w.Error(ErrDeploymentCancelled)
+	}
+
+	if err := s.rollback(ctx, r, opts, deployErr); err != deployErr {
+		return w.Error(err)
+	}
+
+	return w.Error(ErrDeploymentCancelled)
+}
+
+// deploy is a thin wrapper around createRelease and Release that adds the
+// error to the jsonmessage stream.
+func (s *deployerService) deploy(ctx context.Context, opts DeployOpts) (*Release, error) {
+	w := opts.Output
+
 	var stream twelvefactor.StatusStream
 	if opts.Stream {
 		stream = w
 	}
 
-	r, err := s.createInTransaction(ctx, stream, opts)
+	if err := w.recorder.SetState(DeploymentBuilding); err != nil {
+		return nil, w.Error(err)
+	}
+
+	r, c, err := s.createInTransaction(ctx, stream, opts)
 	if err != nil {
 		return r, w.Error(err)
 	}
 
+	if err := w.recorder.SetRelease(r); err != nil {
+		return r, w.Error(err)
+	}
+
 	if err := w.Status(fmt.Sprintf("Created new release v%d for %s", r.Version, r.App.Name)); err != nil {
 		return r, err
 	}
 
-	if err := s.releases.Release(ctx, r, stream); err != nil {
+	// If the release defines a release command, it needs to succeed before
+	// the release is rolled out. Until then, the release is pending, and
+	// the previous release keeps running.
+	if r.Pending {
+		if err := s.runReleaseCommand(ctx, r, opts); err != nil {
+			if w.recorder.Cancelled() {
+				return r, w.Error(err)
+			}
+			deployErr := &twelvefactor.DeploymentError{Reason: fmt.Sprintf("release command failed: %v", err)}
+			return r, w.Error(s.releaseFailed(r, opts, deployErr))
+		}
+
+		if err := s.promote(r, c); err != nil {
+			return r, w.Error(err)
+		}
+	}
+
+	if opts.CanaryWeight > 0 {
+		if err := w.Status(fmt.Sprintf("Rolling out v%d as a canary with %d%% of traffic", r.Version, opts.CanaryWeight)); err != nil {
+			return r, err
+		}
+	}
+
+	if err := w.recorder.SetState(DeploymentSubmitting); err != nil {
+		return r, w.Error(err)
+	}
+
+	if err := s.releases.release(ctx, s.db, r, stream, s.autoRollbackEnabled(r.App)); err != nil {
+		if err, ok := err.(*twelvefactor.DeploymentError); ok && !w.recorder.Cancelled() {
+			if opts.CanaryWeight > 0 {
+				return r, w.Error(s.abortCanary(ctx, r, opts, err))
+			}
+			if s.autoRollbackEnabled(r.App) {
+				return r, w.Error(s.rollback(ctx, r, opts, err))
+			}
+		}
 		return r, w.Error(err)
 	}
 
 	return r, w.Status(fmt.Sprintf("Finished processing events for release v%d of %s", r.Version, r.App.Name))
 }
 
+// runReleaseCommand runs the release process for the release as a one-off, if
+// one is defined, and streams its output to the deployment stream.
+func (s *deployerService) runReleaseCommand(ctx context.Context, r *Release, opts DeployOpts) error {
+	w := opts.Output
+
+	p, ok := r.Formation[ReleaseProcessType]
+	if !ok {
+		return nil
+	}
+
+	if err := w.Status(fmt.Sprintf("Running release command for v%d: %s", r.Version, p.Command)); err != nil {
+		return err
+	}
+
+	p.NoService = false
+	p.Quantity = 1
+
+	release := *r
+	release.Formation = Formation{ReleaseProcessType: p}
+	a, err := newSchedulerApp(&release)
+	if err != nil {
+		return err
+	}
+
+	output := w.Output()
+	for _, p := range a.Processes {
+		p.Stdout = output
+		p.Stderr = output
+		if opts.User != nil {
+			p.Labels["empire.user"] = opts.User.Name
+		}
+	}
+
+	if err := s.Scheduler.Run(ctx, a); err != nil {
+		return err
+	}
+
+	return w.Status("Release command completed successfully")
+}
+
+// promote makes a pending release the current release, and creates its canary,
+// if there is one.
+func (s *deployerService) promote(r *Release, c *Canary) error {
+	tx := s.db.Begin()
+
+	r.Pending = false
+	if err := releasesUpdate(tx, r); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if c != nil {
+		if _, err := canariesCreate(tx, c); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// releaseFailed marks the release as failed, when it failed before it was
+// submitted to the scheduler (e.g. because its release command failed). The
+// previous release keeps running, so nothing needs to be rolled back. The
+// original deployment error is returned.
+func (s *deployerService) releaseFailed(r *Release, opts DeployOpts, deployErr *twelvefactor.DeploymentError) error {
+	w := opts.Output
+
+	tx := s.db.Begin()
+
+	r.Failed = true
+	if err := releasesUpdate(tx, r); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The canary for the release was never rolled out. Pending releases
+	// don't have a canary yet.
+	if opts.CanaryWeight > 0 && !r.Pending {
+		if err := canariesDestroyForApp(tx, r.App.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	if err := w.Status(fmt.Sprintf("Release v%d of %s failed, the previous release is still running", r.Version, r.App.Name)); err != nil {
+		return err
+	}
+
+	return deployErr
+}
+
+// rollback marks the release as failed, and rolls back to the last release
+// that didn't fail. The original deployment error is returned.
+func (s *deployerService) rollback(ctx context.Context, failed *Release, opts DeployOpts, deployErr *twelvefactor.DeploymentError) error {
+	w := opts.Output
+
+	if err := w.Status(fmt.Sprintf("Release v%d of %s failed, rolling back", failed.Version, failed.App.Name)); err != nil {
+		return err
+	}
+
+	previous, err := s.rollbackInTransaction(ctx, failed, opts.User, deployErr.Reason)
+	if err != nil {
+		return fmt.Errorf("error rolling back: %v", err)
+	}
+
+	if previous == nil {
+		if err := w.Status("No previous release to roll back to"); err != nil {
+			return err
+		}
+		return deployErr
+	}
+
+	if err := w.Status(fmt.Sprintf("Rolled back %s to v%d", failed.App.Name, previous.Version)); err != nil {
+		return err
+	}
+
+	return deployErr
+}
+
+// abortCanary aborts the canary for the release, which failed to stabilize. The
+// original deployment error is returned.
+func (s *deployerService) abortCanary(ctx context.Context, r *Release, opts DeployOpts, deployErr *twelvefactor.DeploymentError) error {
+	w := opts.Output
+
+	if err := w.Status(fmt.Sprintf("Canary v%d of %s failed, aborting", r.Version, r.App.Name)); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("v%d failed: %s", r.Version, deployErr.Reason)
+
+	tx := s.db.Begin()
+	c, err := canariesFind(tx, CanariesQuery{App: r.App})
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error aborting canary: %v", err)
+	}
+	if _, err := s.canaries.Abort(ctx, tx, c, opts.User, message); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error aborting canary: %v", err)
+	}
+	event := CanaryAbortEvent{
+		App:           r.App.Name,
+		Version:       c.Version,
+		StableVersion: c.StableVersion,
+		Message:       message,
+		app:           r.App,
+	}
+	if opts.User != nil {
+		event.User = opts.User.Name
+	}
+	if err := s.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error aborting canary: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("error aborting canary: %v", err)
+	}
+
+	if err := w.Status(fmt.Sprintf("Rolled back %s to v%d", r.App.Name, c.StableVersion)); err != nil {
+		return err
+	}
+
+	return deployErr
+}
+
+// rollbackInTransaction marks the release as failed, and creates a new release
+// from the last release that didn't fail, which is returned.
+func (s *deployerService) rollbackInTransaction(ctx context.Context, failed *Release, user *User, reason string) (*Release, error) {
+	tx := s.db.Begin()
+
+	failed.Failed = true
+	if err := releasesUpdate(tx, failed); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	previous, err := releasesLastSucceeded(tx, failed)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if previous != nil {
+		if _, err := s.releases.Rollback(ctx, tx, RollbackOpts{
+			User:    user,
+			App:     failed.App,
+			Version: previous.Version,
+			Message: fmt.Sprintf("v%d failed: %s", failed.Version, reason),
+		}); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		event := DeployRollbackEvent{
+			App:           failed.App.Name,
+			Environment:   s.Environment,
+			FailedRelease: failed.Version,
+			Version:       previous.Version,
+			Reason:        reason,
+			app:           failed.App,
+		}
+		if user != nil {
+			event.User = user.Name
+		}
+		if err := s.publishEvent(tx, event); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return previous, tx.Commit().Error
+}
+
 // DeploymentStream provides a wrapper around an io.Writer for writing
 // jsonmessage statuses, and implements the scheduler.StatusStream interface.
 type DeploymentStream struct {
 	*jsonmessage.Stream
+
+	// Records the messages written to the stream, and the state of the
+	// deployment.
+	recorder *deploymentRecorder
 }
 
 // NewDeploymentStream wraps the io.Writer as a DeploymentStream.
 func NewDeploymentStream(w io.Writer) *DeploymentStream {
-	return &DeploymentStream{jsonmessage.NewStream(w)}
+	return &DeploymentStream{Stream: jsonmessage.NewStream(w)}
+}
+
+// Encode records the jsonmessage, then writes it to the stream.
+func (w *DeploymentStream) Encode(m jsonmessage.JSONMessage) error {
+	if w.recorder != nil {
+		if err := w.recorder.Record(m); err != nil {
+			return err
+		}
+	}
+	return w.Stream.Encode(m)
 }
 
 // Publish implements the scheduler.StatusStream interface.
 func (w *DeploymentStream) Publish(status twelvefactor.Status) error {
+	if status.Stabilizing && w.recorder != nil {
+		if err := w.recorder.SetState(DeploymentStabilizing); err != nil {
+			return err
+		}
+	}
 	return w.Status(status.Message)
 }
 
+// Output returns an io.Writer that writes raw output, like the output of a
+// process, to the jsonmessage stream.
+func (w *DeploymentStream) Output() io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		if err := w.Encode(jsonmessage.JSONMessage{Stream: string(p)}); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	})
+}
+
 // Status writes a simple status update to the jsonmessage stream.
 func (w *DeploymentStream) Status(message string) error {
 	m := jsonmessage.JSONMessage{Status: fmt.Sprintf("Status: %s", message)}
@@ -128,3 +1152,25 @@ func (w *DeploymentStream) Error(err error) error {
 	}
 	return err
 }
+
+// detachedContext returns a new context.Context that isn't canceled when ctx is,
+// but still has the logger and error reporter from ctx.
+func detachedContext(ctx context.Context) context.Context {
+	detached := context.Background()
+	if l, ok := logger.FromContext(ctx); ok {
+		detached = logger.WithLogger(detached, l)
+	}
+	if r, ok := reporter.FromContext(ctx); ok {
+		detached = reporter.WithReporter(detached, r)
+	}
+	return detached
+}
+
+// writerFunc is an adapter to allow the use of ordinary functions as an
+// io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+// Write implements the io.Writer interface.
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
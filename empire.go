@@ -8,6 +8,7 @@ import (
 
 	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire/pkg/image"
+	"github.com/remind101/empire/pkg/timex"
 	"golang.org/x/net/context"
 )
 
@@ -82,6 +83,8 @@ type Empire struct {
 	runner   *runnerService
 	slugs    *slugsService
 	certs    *certsService
+	canaries *canariesService
+	events   *eventFeed
 
 	// Scheduler is the backend scheduler used to run applications.
 	Scheduler Scheduler
@@ -107,6 +110,23 @@ type Empire struct {
 	// Configures what type of commands are allowed to be run with the Run
 	// method. The zero value allows all commands to be run.
 	AllowedCommands AllowedCommands
+
+	// AutoRollback controls whether deployments that fail are automatically
+	// rolled back to the previous release. Apps can override this with
+	// SetAutoRollback.
+	AutoRollback bool
+
+	// ServiceAccountExpiration is the maximum lifetime of service account
+	// tokens. Service accounts that are created without an expiration
+	// expire after this long. If zero, service accounts can be created
+	// without an expiration.
+	ServiceAccountExpiration time.Duration
+
+	// NotificationSNSTopics are the prefixes of the SNS topic ARNs that
+	// notifications can publish to (e.g.
+	// "arn:aws:sns:us-east-1:123456789012:"). If empty, notifications
+	// can't publish to SNS topics.
+	NotificationSNSTopics []string
 }
 
 // New returns a new Empire instance.
@@ -115,6 +135,8 @@ func New(db *DB) *Empire {
 		LogsStreamer: logsDisabled,
 		EventStream:  NullEventStream,
 
+		ServiceAccountExpiration: DefaultServiceAccountExpiration,
+
 		DB: db,
 		db: db.DB,
 	}
@@ -128,6 +150,8 @@ func New(db *DB) *Empire {
 	e.runner = &runnerService{Empire: e}
 	e.releases = &releasesService{Empire: e}
 	e.certs = &certsService{Empire: e}
+	e.canaries = &canariesService{Empire: e}
+	e.events = &eventFeed{db: db}
 	return e
 }
 
@@ -148,6 +172,15 @@ func (e *Empire) requireMessages(m string) error {
 	return nil
 }
 
+// autoRollbackEnabled returns true if failed deployments to the app should be
+// automatically rolled back.
+func (e *Empire) autoRollbackEnabled(app *App) bool {
+	if app.AutoRollback != nil {
+		return *app.AutoRollback
+	}
+	return e.AutoRollback
+}
+
 // CreateOpts are options that are provided when creating a new application.
 type CreateOpts struct {
 	// User performing the action.
@@ -178,12 +211,31 @@ func (e *Empire) Create(ctx context.Context, opts CreateOpts) (*App, error) {
 		return nil, err
 	}
 
-	a, err := appsCreate(e.db, &App{Name: opts.Name})
+	tx := e.db.Begin()
+
+	a, err := appsCreate(tx, &App{Name: opts.Name})
 	if err != nil {
+		tx.Rollback()
+		return a, err
+	}
+
+	// The user that created the app becomes its first collaborator.
+	if _, err := collaboratorsCreate(tx, &Collaborator{
+		AppID:    a.ID,
+		UserName: opts.User.Name,
+	}); err != nil {
+		tx.Rollback()
+		return a, err
+	}
+
+	event := opts.Event()
+	event.app = a
+	if err := e.publishEvent(tx, event); err != nil {
+		tx.Rollback()
 		return a, err
 	}
 
-	return a, e.PublishEvent(opts.Event())
+	return a, tx.Commit().Error
 }
 
 // DestroyOpts are options provided when destroying an application.
@@ -223,11 +275,12 @@ func (e *Empire) Destroy(ctx context.Context, opts DestroyOpts) error {
 		return err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	return e.PublishEvent(opts.Event())
+	return tx.Commit().Error
 }
 
 // Config returns the current Config for a given app.
@@ -247,6 +300,62 @@ func (e *Empire) Config(app *App) (*Config, error) {
 	return c, nil
 }
 
+// SetAutoRollbackOpts are options provided when enabling or disabling
+// automatic rollbacks on an app.
+type SetAutoRollbackOpts struct {
+	// User performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// Whether failed deployments should be automatically rolled back.
+	AutoRollback bool
+
+	// Commit message
+	Message string
+}
+
+func (opts SetAutoRollbackOpts) Event() AutoRollbackEvent {
+	return AutoRollbackEvent{
+		User:         opts.User.Name,
+		App:          opts.App.Name,
+		AutoRollback: opts.AutoRollback,
+		Message:      opts.Message,
+		app:          opts.App,
+	}
+}
+
+func (opts SetAutoRollbackOpts) Validate(e *Empire) error {
+	return e.requireMessages(opts.Message)
+}
+
+// SetAutoRollback enables or disables automatic rollbacks on the app. When
+// enabled, deployments that fail to stabilize will be rolled back to the
+// previous release.
+func (e *Empire) SetAutoRollback(ctx context.Context, opts SetAutoRollbackOpts) error {
+	if err := opts.Validate(e); err != nil {
+		return err
+	}
+
+	app := opts.App
+	app.AutoRollback = &opts.AutoRollback
+
+	tx := e.db.Begin()
+
+	if err := appsUpdate(tx, app); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
 type SetMaintenanceModeOpts struct {
 	// User performing the action.
 	User *User
@@ -303,11 +412,12 @@ func (e *Empire) SetMaintenanceMode(ctx context.Context, opts SetMaintenanceMode
 		return err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	return e.PublishEvent(opts.Event())
+	return tx.Commit().Error
 }
 
 // SetOpts are options provided when setting new config vars on an app.
@@ -360,11 +470,12 @@ func (e *Empire) Set(ctx context.Context, opts SetOpts) (*Config, error) {
 		return c, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
 		return c, err
 	}
 
-	return c, e.PublishEvent(opts.Event())
+	return c, tx.Commit().Error
 }
 
 // DomainsFind returns the first domain matching the query.
@@ -452,12 +563,97 @@ func (e *Empire) Restart(ctx context.Context, opts RestartOpts) error {
 		return err
 	}
 
-	if err := e.apps.Restart(ctx, e.db, opts); err != nil {
+	tx := e.db.Begin()
+
+	if err := e.apps.Restart(ctx, tx, opts); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// SchedulerBackend returns the name of the scheduler backend that the app is
+// running on.
+func (e *Empire) SchedulerBackend(ctx context.Context, app *App) (string, error) {
+	m, ok := e.Scheduler.(SchedulerMigrator)
+	if !ok {
+		return "", &ValidationError{Err: ErrMigrationUnsupported}
+	}
+
+	return m.Backend(ctx, app.ID)
+}
+
+// MigrateOpts are options provided when migrating an app to a different
+// scheduler backend.
+type MigrateOpts struct {
+	// User performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// The name of the scheduler backend to migrate to.
+	Backend string
+
+	// Output is a DeploymentStream where the status of the migration will
+	// be streamed in jsonmessage format.
+	Output *DeploymentStream
+
+	// Commit message
+	Message string
+}
+
+func (opts MigrateOpts) Event() MigrateEvent {
+	return MigrateEvent{
+		User:    opts.User.Name,
+		App:     opts.App.Name,
+		Backend: opts.Backend,
+		Message: opts.Message,
+		app:     opts.App,
+	}
+}
+
+func (opts MigrateOpts) Validate(e *Empire) error {
+	if _, ok := e.Scheduler.(SchedulerMigrator); !ok {
+		return &ValidationError{Err: ErrMigrationUnsupported}
+	}
+	if opts.Backend == "" {
+		return &ValidationError{Err: errors.New("a scheduler backend is required")}
+	}
+	return e.requireMessages(opts.Message)
+}
+
+// Migrate moves an app to a different scheduler backend. The app is submitted
+// to the new backend, and once it's stable, removed from the old backend.
+func (e *Empire) Migrate(ctx context.Context, opts MigrateOpts) error {
+	if err := opts.Validate(e); err != nil {
+		return err
+	}
+
+	tx := e.db.Begin()
+
+	w := opts.Output
+	if err := e.releases.Migrate(ctx, tx, opts.App, opts.Backend, w); err != nil {
+		tx.Rollback()
+		return w.Error(err)
+	}
+
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	return e.PublishEvent(opts.Event())
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
 
+	return w.Status(fmt.Sprintf("Migrated %s to the %s scheduler", opts.App.Name, opts.Backend))
 }
 
 // RunOpts are options provided when running an attached/detached process.
@@ -541,6 +737,10 @@ func (e *Empire) Run(ctx context.Context, opts RunOpts) error {
 		}
 	}
 
+	// Running a process doesn't change the database, so there's no
+	// transaction for the events to join. Instead, the event is written
+	// before the process is started, so that a run is never left out of
+	// the outbox, and again once it finishes.
 	if err := e.PublishEvent(event); err != nil {
 		return err
 	}
@@ -607,11 +807,169 @@ func (e *Empire) Rollback(ctx context.Context, opts RollbackOpts) (*Release, err
 		return r, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err := e.publishEvent(tx, opts.Event()); err != nil {
+		tx.Rollback()
 		return r, err
 	}
 
-	return r, e.PublishEvent(opts.Event())
+	return r, tx.Commit().Error
+}
+
+// CanariesFind returns the canary that's in progress for an app.
+func (e *Empire) CanariesFind(q CanariesQuery) (*Canary, error) {
+	return canariesFind(e.db, q)
+}
+
+// PromoteCanaryOpts are options provided when promoting a canary.
+type PromoteCanaryOpts struct {
+	// The user performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// Commit message
+	Message string
+}
+
+func (opts PromoteCanaryOpts) Event() CanaryPromoteEvent {
+	return CanaryPromoteEvent{
+		User:    opts.User.Name,
+		App:     opts.App.Name,
+		Message: opts.Message,
+		app:     opts.App,
+	}
+}
+
+func (opts PromoteCanaryOpts) Validate(e *Empire) error {
+	return e.requireMessages(opts.Message)
+}
+
+// PromoteCanary promotes the canary that's in progress for the app, sending
+// all traffic to the canary release.
+func (e *Empire) PromoteCanary(ctx context.Context, opts PromoteCanaryOpts) (*Release, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	tx := e.db.Begin()
+
+	c, err := canariesFind(tx, CanariesQuery{App: opts.App})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	r, err := e.canaries.Promote(ctx, tx, c)
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	event := opts.Event()
+	event.Version = c.Version
+	if err := e.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	return r, tx.Commit().Error
+}
+
+// PromoteCanaries promotes all canaries that have passed their bake time.
+func (e *Empire) PromoteCanaries(ctx context.Context) error {
+	now := timex.Now()
+	cs, err := canaries(e.db, CanariesQuery{PromoteBefore: &now})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		tx := e.db.Begin()
+
+		if _, err := e.canaries.Promote(ctx, tx, c); err != nil {
+			tx.Rollback()
+			// The canary was promoted, or aborted, by someone
+			// else.
+			if err == gorm.RecordNotFound {
+				continue
+			}
+			return fmt.Errorf("error promoting canary for %s: %v", c.App.Name, err)
+		}
+
+		if err := e.publishEvent(tx, CanaryPromoteEvent{
+			App:     c.App.Name,
+			Version: c.Version,
+			app:     c.App,
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AbortCanaryOpts are options provided when aborting a canary.
+type AbortCanaryOpts struct {
+	// The user performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// Commit message
+	Message string
+}
+
+func (opts AbortCanaryOpts) Event() CanaryAbortEvent {
+	return CanaryAbortEvent{
+		User:    opts.User.Name,
+		App:     opts.App.Name,
+		Message: opts.Message,
+		app:     opts.App,
+	}
+}
+
+func (opts AbortCanaryOpts) Validate(e *Empire) error {
+	return e.requireMessages(opts.Message)
+}
+
+// AbortCanary aborts the canary that's in progress for the app. The canary
+// release is marked as failed, and a new release is created from the stable
+// release.
+func (e *Empire) AbortCanary(ctx context.Context, opts AbortCanaryOpts) (*Release, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	tx := e.db.Begin()
+
+	c, err := canariesFind(tx, CanariesQuery{App: opts.App})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	r, err := e.canaries.Abort(ctx, tx, c, opts.User, opts.Message)
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	event := opts.Event()
+	event.Version = c.Version
+	event.StableVersion = c.StableVersion
+	if err := e.publishEvent(tx, event); err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	return r, tx.Commit().Error
 }
 
 // DeployOpts represents options that can be passed when deploying to
@@ -638,13 +996,24 @@ type DeployOpts struct {
 
 	// Stream boolean for whether or not a status stream should be created.
 	Stream bool
+
+	// If non-zero, the new release is rolled out as a canary, and receives
+	// this percentage of traffic to ALB processes.
+	CanaryWeight int
+
+	// If non-zero, the canary is automatically promoted after this amount
+	// of time.
+	CanaryBakeTime time.Duration
 }
 
 func (opts DeployOpts) Event() DeployEvent {
 	e := DeployEvent{
-		User:    opts.User.Name,
 		Image:   opts.Image.String(),
 		Message: opts.Message,
+		Canary:  opts.CanaryWeight,
+	}
+	if opts.User != nil {
+		e.User = opts.User.Name
 	}
 	if opts.App != nil {
 		e.App = opts.App.Name
@@ -655,6 +1024,12 @@ func (opts DeployOpts) Event() DeployEvent {
 }
 
 func (opts DeployOpts) Validate(e *Empire) error {
+	if opts.CanaryWeight < 0 || opts.CanaryWeight > 99 {
+		return &ValidationError{Err: errors.New("canary weight must be between 1 and 99")}
+	}
+	if opts.CanaryBakeTime != 0 && opts.CanaryWeight == 0 {
+		return &ValidationError{Err: errors.New("a bake time can only be provided with a canary deploy")}
+	}
 	return e.requireMessages(opts.Message)
 }
 
@@ -664,21 +1039,91 @@ func (e *Empire) Deploy(ctx context.Context, opts DeployOpts) (*Release, error)
 		return nil, err
 	}
 
-	r, err := e.deployer.Deploy(ctx, opts)
+	return e.deployer.Deploy(ctx, opts)
+}
+
+// DeployAsync queues a deployment of an image, and returns the Deployment,
+// which can be used to follow its progress. The deployment is performed in the
+// background.
+func (e *Empire) DeployAsync(ctx context.Context, opts DeployOpts) (*Deployment, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	d, opts, err := e.deployer.Queue(ctx, opts)
 	if err != nil {
-		return r, err
+		return d, err
+	}
+
+	// The deployment outlives the request that started it.
+	go e.Deploy(detachedContext(ctx), opts)
+
+	return d, nil
+}
+
+// CancelDeploymentOpts are options provided when cancelling a deployment.
+type CancelDeploymentOpts struct {
+	// The user performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// The id of the deployment to cancel. If not provided, the most recent
+	// deployment that's in progress is cancelled.
+	ID *string
+
+	// Commit message
+	Message string
+}
+
+func (opts CancelDeploymentOpts) Event() DeployCancelEvent {
+	return DeployCancelEvent{
+		User:    opts.User.Name,
+		App:     opts.App.Name,
+		Message: opts.Message,
+		app:     opts.App,
+	}
+}
+
+func (opts CancelDeploymentOpts) Validate(e *Empire) error {
+	return e.requireMessages(opts.Message)
+}
+
+// CancelDeployment cancels a deployment that's in progress. If a release was
+// created for the deployment, it's marked as failed and the app is rolled back
+// to the last release that didn't fail.
+func (e *Empire) CancelDeployment(ctx context.Context, opts CancelDeploymentOpts) (*Deployment, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	d, err := deploymentsFind(e.db, DeploymentsQuery{
+		ID:         opts.ID,
+		App:        opts.App,
+		InProgress: opts.ID == nil,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	event := opts.Event()
-	event.Release = r.Version
 	event.Environment = e.Environment
-	// Deals with new app creation on first deploy
-	if event.App == "" && r.App != nil {
-		event.App = r.App.Name
-		event.app = r.App
-	}
+	event.Image = d.Image
+	return d, e.deployer.Cancel(ctx, d, opts.User, opts.Message, event)
+}
 
-	return r, e.PublishEvent(event)
+// DeploymentsFind returns the first deployment matching the query.
+func (e *Empire) DeploymentsFind(q DeploymentsQuery) (*Deployment, error) {
+	return deploymentsFind(e.db, q)
+}
+
+// FailOrphanedDeployments marks deployments that are in progress, but that the
+// Empire instance performing them has stopped saving (e.g. because it was
+// stopped), as failed. The number of deployments that were marked as failed is
+// returned.
+func (e *Empire) FailOrphanedDeployments(ctx context.Context) (int64, error) {
+	return deploymentsFailOrphaned(e.db, timex.Now().Add(-deploymentHeartbeatTimeout))
 }
 
 type ProcessUpdate struct {
@@ -756,8 +1201,16 @@ func (e *Empire) ListScale(ctx context.Context, app *App) (Formation, error) {
 }
 
 // Streamlogs streams logs from an app.
-func (e *Empire) StreamLogs(app *App, w io.Writer, duration time.Duration) error {
-	if err := e.LogsStreamer.StreamLogs(app, w, duration); err != nil {
+func (e *Empire) StreamLogs(app *App, w io.Writer, opts StreamLogsOpts) error {
+	if err := opts.Validate(e); err != nil {
+		return err
+	}
+
+	if opts.Filter != nil {
+		w = &filterWriter{w: w, filter: opts.Filter}
+	}
+
+	if err := e.LogsStreamer.StreamLogs(app, w, opts); err != nil {
 		return fmt.Errorf("error streaming logs: %v", err)
 	}
 
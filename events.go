@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 )
 
 type multiError struct {
@@ -99,6 +100,30 @@ func (e RestartEvent) GetApp() *App {
 	return e.app
 }
 
+// MigrateEvent is triggered when an app is migrated to a different scheduler
+// backend.
+type MigrateEvent struct {
+	User    string
+	App     string
+	Backend string
+	Message string
+
+	app *App
+}
+
+func (e MigrateEvent) Event() string {
+	return "migrate"
+}
+
+func (e MigrateEvent) String() string {
+	msg := fmt.Sprintf("%s migrated %s to the %s scheduler", e.User, e.App, e.Backend)
+	return appendCommitMessage(msg, e.Message)
+}
+
+func (e MigrateEvent) GetApp() *App {
+	return e.app
+}
+
 type MaintenanceEvent struct {
 	User        string
 	App         string
@@ -193,6 +218,7 @@ type DeployEvent struct {
 	Environment string
 	Release     int
 	Message     string
+	Canary      int
 
 	app *App
 }
@@ -208,6 +234,9 @@ func (e DeployEvent) String() string {
 	} else {
 		msg = fmt.Sprintf("%s deployed %s to %s %s (v%d)", e.User, e.Image, e.App, e.Environment, e.Release)
 	}
+	if e.Canary > 0 {
+		msg = fmt.Sprintf("%s as a canary with %d%% of traffic", msg, e.Canary)
+	}
 	return appendCommitMessage(msg, e.Message)
 }
 
@@ -215,6 +244,136 @@ func (e DeployEvent) GetApp() *App {
 	return e.app
 }
 
+// CanaryPromoteEvent is triggered when a canary is promoted, either by a user,
+// or automatically after the bake time.
+type CanaryPromoteEvent struct {
+	User    string
+	App     string
+	Version int
+	Message string
+
+	app *App
+}
+
+func (e CanaryPromoteEvent) Event() string {
+	return "canary_promote"
+}
+
+func (e CanaryPromoteEvent) String() string {
+	if e.User == "" {
+		return fmt.Sprintf("Automatically promoted canary v%d of %s", e.Version, e.App)
+	}
+	msg := fmt.Sprintf("%s promoted canary v%d of %s", e.User, e.Version, e.App)
+	return appendCommitMessage(msg, e.Message)
+}
+
+func (e CanaryPromoteEvent) GetApp() *App {
+	return e.app
+}
+
+// CanaryAbortEvent is triggered when a canary is aborted, and the app is rolled
+// back to the stable release.
+type CanaryAbortEvent struct {
+	User          string
+	App           string
+	Version       int
+	StableVersion int
+	Message       string
+
+	app *App
+}
+
+func (e CanaryAbortEvent) Event() string {
+	return "canary_abort"
+}
+
+func (e CanaryAbortEvent) String() string {
+	msg := fmt.Sprintf("%s aborted canary v%d of %s and rolled back to v%d", e.User, e.Version, e.App, e.StableVersion)
+	return appendCommitMessage(msg, e.Message)
+}
+
+func (e CanaryAbortEvent) GetApp() *App {
+	return e.app
+}
+
+// AutoRollbackEvent is triggered when a user enables or disables automatic
+// rollbacks on an app.
+type AutoRollbackEvent struct {
+	User         string
+	App          string
+	AutoRollback bool
+	Message      string
+
+	app *App
+}
+
+func (e AutoRollbackEvent) Event() string {
+	return "auto_rollback"
+}
+
+func (e AutoRollbackEvent) String() string {
+	state := "disabled"
+	if e.AutoRollback {
+		state = "enabled"
+	}
+	msg := fmt.Sprintf("%s %s automatic rollbacks on %s", e.User, state, e.App)
+	return appendCommitMessage(msg, e.Message)
+}
+
+func (e AutoRollbackEvent) GetApp() *App {
+	return e.app
+}
+
+// DeployRollbackEvent is triggered when a deployment fails, and Empire
+// automatically rolls back to the previous release.
+type DeployRollbackEvent struct {
+	User          string
+	App           string
+	Environment   string
+	FailedRelease int
+	Version       int
+	Reason        string
+
+	app *App
+}
+
+func (e DeployRollbackEvent) Event() string {
+	return "deploy_rollback"
+}
+
+func (e DeployRollbackEvent) String() string {
+	return fmt.Sprintf("v%d of %s %s (deployed by %s) failed and was automatically rolled back to v%d: %s", e.FailedRelease, e.App, e.Environment, e.User, e.Version, e.Reason)
+}
+
+func (e DeployRollbackEvent) GetApp() *App {
+	return e.app
+}
+
+// DeployCancelEvent is triggered when a user cancels a deployment that's in
+// progress.
+type DeployCancelEvent struct {
+	User        string
+	App         string
+	Environment string
+	Image       string
+	Message     string
+
+	app *App
+}
+
+func (e DeployCancelEvent) Event() string {
+	return "deploy_cancel"
+}
+
+func (e DeployCancelEvent) String() string {
+	msg := fmt.Sprintf("%s cancelled the deployment of %s to %s %s", e.User, e.Image, e.App, e.Environment)
+	return appendCommitMessage(msg, e.Message)
+}
+
+func (e DeployCancelEvent) GetApp() *App {
+	return e.app
+}
+
 // RollbackEvent is triggered when a user rolls back to an old version.
 type RollbackEvent struct {
 	User    string
@@ -267,6 +426,8 @@ type CreateEvent struct {
 	User    string
 	Name    string
 	Message string
+
+	app *App
 }
 
 func (e CreateEvent) Event() string {
@@ -278,6 +439,10 @@ func (e CreateEvent) String() string {
 	return appendCommitMessage(msg, e.Message)
 }
 
+func (e CreateEvent) GetApp() *App {
+	return e.app
+}
+
 // DestroyEvent is triggered when a user destroys an application.
 type DestroyEvent struct {
 	User    string
@@ -294,6 +459,82 @@ func (e DestroyEvent) String() string {
 	return appendCommitMessage(msg, e.Message)
 }
 
+// CollaboratorAddEvent is triggered when a user gives another user access to
+// an application.
+type CollaboratorAddEvent struct {
+	User         string
+	App          string
+	Collaborator string
+
+	app *App
+}
+
+func (e CollaboratorAddEvent) Event() string {
+	return "collaborator_add"
+}
+
+func (e CollaboratorAddEvent) String() string {
+	return fmt.Sprintf("%s added %s as a collaborator on %s", e.User, e.Collaborator, e.App)
+}
+
+func (e CollaboratorAddEvent) GetApp() *App {
+	return e.app
+}
+
+// CollaboratorRemoveEvent is triggered when a user removes another user's
+// access to an application.
+type CollaboratorRemoveEvent struct {
+	User         string
+	App          string
+	Collaborator string
+
+	app *App
+}
+
+func (e CollaboratorRemoveEvent) Event() string {
+	return "collaborator_remove"
+}
+
+func (e CollaboratorRemoveEvent) String() string {
+	return fmt.Sprintf("%s removed %s as a collaborator on %s", e.User, e.Collaborator, e.App)
+}
+
+func (e CollaboratorRemoveEvent) GetApp() *App {
+	return e.app
+}
+
+// ServiceAccountCreateEvent is triggered when a user creates a service account.
+type ServiceAccountCreateEvent struct {
+	User      string
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+func (e ServiceAccountCreateEvent) Event() string {
+	return "service_account_create"
+}
+
+func (e ServiceAccountCreateEvent) String() string {
+	return fmt.Sprintf("%s created service account %s (%s)", e.User, e.Name, strings.Join(e.Scopes, ","))
+}
+
+// ServiceAccountRevokeEvent is triggered when a user revokes a service
+// account.
+type ServiceAccountRevokeEvent struct {
+	User      string
+	Name      string
+	CreatedBy string
+}
+
+func (e ServiceAccountRevokeEvent) Event() string {
+	return "service_account_revoke"
+}
+
+func (e ServiceAccountRevokeEvent) String() string {
+	return fmt.Sprintf("%s revoked service account %s", e.User, e.Name)
+}
+
 // Event represents an event triggered within Empire.
 type Event interface {
 	// Returns the name of the event.
@@ -375,7 +616,72 @@ func (e *asyncEventStream) start() {
 	}
 }
 
-func (e *asyncEventStream) publishEvent(event Event) (err error) {
+func (e *asyncEventStream) publishEvent(event Event) error {
+	return safePublishEvent(e.e, event)
+}
+
+// NamedEventStream is an EventStream with a name. When events are delivered
+// from the outbox, delivery to each stream in a MultiEventStream is tracked
+// separately by name, so that a failure in one stream doesn't cause the event
+// to be delivered to the others again. The name should be stable across
+// restarts.
+type NamedEventStream struct {
+	Name string
+	EventStream
+}
+
+// EventSinker can be implemented by an EventStream that sends each event to
+// several targets, so that delivery from the outbox is tracked for each target
+// separately.
+type EventSinker interface {
+	// EventSinks returns the targets that the event is sent to. The names
+	// of the returned streams should be stable across restarts.
+	EventSinks(Event) ([]NamedEventStream, error)
+}
+
+// eventStreamSinks returns the streams that delivery of the event is tracked
+// for. Streams in a MultiEventStream that aren't named are named by their
+// position, and streams that implement EventSinker are expanded into their
+// targets.
+func eventStreamSinks(s EventStream, event Event) ([]NamedEventStream, error) {
+	streams, ok := s.(MultiEventStream)
+	if !ok {
+		streams = MultiEventStream{namedEventStream(s, "default")}
+	}
+
+	var sinks []NamedEventStream
+	for i, s := range streams {
+		named := namedEventStream(s, fmt.Sprintf("%d", i))
+
+		sinker, ok := named.EventStream.(EventSinker)
+		if !ok {
+			sinks = append(sinks, named)
+			continue
+		}
+
+		targets, err := sinker.EventSinks(event)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range targets {
+			sinks = append(sinks, NamedEventStream{Name: named.Name + "/" + t.Name, EventStream: t.EventStream})
+		}
+	}
+	return sinks, nil
+}
+
+// namedEventStream returns s as a NamedEventStream, using name if s isn't
+// already named.
+func namedEventStream(s EventStream, name string) NamedEventStream {
+	if s, ok := s.(NamedEventStream); ok {
+		return s
+	}
+	return NamedEventStream{Name: name, EventStream: s}
+}
+
+// safePublishEvent publishes the event to the stream, converting any panics to
+// an error.
+func safePublishEvent(s EventStream, event Event) (err error) {
 	defer func() {
 		if v := recover(); v != nil {
 			var ok bool
@@ -386,6 +692,6 @@ func (e *asyncEventStream) publishEvent(event Event) (err error) {
 			err = fmt.Errorf("panic: %v", v)
 		}
 	}()
-	err = e.e.PublishEvent(event)
+	err = s.PublishEvent(event)
 	return
 }
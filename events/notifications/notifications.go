@@ -0,0 +1,163 @@
+// Package notifications provides an empire.EventStream implementation that
+// sends an app's events to the notifications that were added to the app.
+package notifications
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/events/sns"
+	"github.com/remind101/empire/events/webhook"
+)
+
+// webhookClient is the http.Client used to send events to webhook
+// notifications. It refuses to connect to private addresses, since the host of
+// a webhook url can resolve to one.
+var webhookClient = &http.Client{
+	Timeout: webhook.DefaultTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: refusePrivate,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+type notificationsFinder interface {
+	Notifications(empire.NotificationsQuery) ([]*empire.Notification, error)
+}
+
+// EventStream is an implementation of the empire.EventStream interface that
+// fans out each empire.AppEvent to the notifications for the app.
+//
+// EventStream also implements the empire.EventSinker interface, so that when
+// events are delivered from the outbox, a broken target for one app only
+// causes the event to be sent to that target again.
+type EventStream struct {
+	finder notificationsFinder
+
+	// newStream returns an empire.EventStream that sends events to the
+	// target of the notification.
+	newStream func(*empire.Notification) (empire.EventStream, error)
+}
+
+// NewEventStream returns a new EventStream that looks up notifications from
+// e. The ConfigProvider is used to publish to SNS topics.
+func NewEventStream(e *empire.Empire, c client.ConfigProvider) *EventStream {
+	return &EventStream{
+		finder: e,
+		newStream: func(n *empire.Notification) (empire.EventStream, error) {
+			switch n.Kind {
+			case empire.NotificationWebhook:
+				s := webhook.NewEventStream([]webhook.Endpoint{{URL: n.Target}})
+				s.Secret = []byte(n.Secret)
+				s.Client = webhookClient
+				return s, nil
+			case empire.NotificationSNS:
+				if !e.NotificationSNSTopicAllowed(n.Target) {
+					return nil, fmt.Errorf("notifications can't be sent to the SNS topic %s", n.Target)
+				}
+				s := sns.NewEventStream(c)
+				s.TopicARN = n.Target
+				return s, nil
+			default:
+				return nil, fmt.Errorf("unknown notification kind: %s", n.Kind)
+			}
+		},
+	}
+}
+
+// PublishEvent implements the empire.EventStream interface. The event is sent
+// to every notification that accepts it, and an error is returned if sending
+// to any of them failed.
+func (s *EventStream) PublishEvent(event empire.Event) error {
+	sinks, err := s.EventSinks(event)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, sink := range sinks {
+		if err := sink.PublishEvent(event); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error sending %s event to notifications: %s", event.Event(), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// EventSinks implements the empire.EventSinker interface, returning a stream
+// for each notification that accepts the event, named by the id of the
+// notification.
+func (s *EventStream) EventSinks(event empire.Event) ([]empire.NamedEventStream, error) {
+	e, ok := event.(empire.AppEvent)
+	if !ok {
+		return nil, nil
+	}
+
+	app := e.GetApp()
+	if app == nil {
+		return nil, nil
+	}
+
+	notifications, err := s.finder.Notifications(empire.NotificationsQuery{App: app})
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []empire.NamedEventStream
+	for _, n := range notifications {
+		if !n.Accepts(event.Event()) {
+			continue
+		}
+
+		n := n
+		sinks = append(sinks, empire.NamedEventStream{
+			Name: n.ID,
+			EventStream: empire.EventStreamFunc(func(event empire.Event) error {
+				return s.publishEvent(n, event)
+			}),
+		})
+	}
+
+	return sinks, nil
+}
+
+func (s *EventStream) publishEvent(n *empire.Notification, event empire.Event) error {
+	stream, err := s.newStream(n)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.PublishEvent(event); err != nil {
+		return fmt.Errorf("%s: %v", n.Target, err)
+	}
+
+	return nil
+}
+
+// refusePrivate is a net.Dialer Control function that refuses connections to
+// private addresses.
+func refusePrivate(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || empire.IsPrivateIP(ip) {
+		return fmt.Errorf("refusing to connect to private address %s", host)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents_PublishEvent(t *testing.T) {
+	app := &empire.App{ID: "1234", Name: "acme-inc"}
+
+	var published []string
+	s := &EventStream{
+		finder: fakeFinder{
+			"1234": {
+				{ID: "a", Kind: "webhook", Target: "https://example.com/deploys", Events: empire.EventNames{"deploy"}},
+				{ID: "b", Kind: "webhook", Target: "https://example.com/broken", Events: empire.EventNames{"deploy"}},
+				{ID: "c", Kind: "webhook", Target: "https://example.com/all"},
+			},
+		},
+		newStream: func(n *empire.Notification) (empire.EventStream, error) {
+			return empire.EventStreamFunc(func(event empire.Event) error {
+				if n.Target == "https://example.com/broken" {
+					return errors.New("boom")
+				}
+				published = append(published, n.Target)
+				return nil
+			}), nil
+		},
+	}
+
+	err := s.PublishEvent(fakeEvent{name: "deploy", app: app})
+	assert.EqualError(t, err, "error sending deploy event to notifications: https://example.com/broken: boom")
+	assert.Equal(t, []string{"https://example.com/deploys", "https://example.com/all"}, published)
+
+	published = nil
+	err = s.PublishEvent(fakeEvent{name: "scale", app: app})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/all"}, published)
+
+	published = nil
+	err = s.PublishEvent(fakeEvent{name: "deploy", app: &empire.App{ID: "5678"}})
+	assert.NoError(t, err)
+	assert.Nil(t, published)
+}
+
+func TestEvents_EventSinks(t *testing.T) {
+	app := &empire.App{ID: "1234", Name: "acme-inc"}
+
+	var published []string
+	s := &EventStream{
+		finder: fakeFinder{
+			"1234": {
+				{ID: "a", Kind: "webhook", Target: "https://example.com/deploys", Events: empire.EventNames{"deploy"}},
+				{ID: "b", Kind: "webhook", Target: "https://example.com/broken"},
+			},
+		},
+		newStream: func(n *empire.Notification) (empire.EventStream, error) {
+			return empire.EventStreamFunc(func(event empire.Event) error {
+				if n.Target == "https://example.com/broken" {
+					return errors.New("boom")
+				}
+				published = append(published, n.Target)
+				return nil
+			}), nil
+		},
+	}
+
+	sinks, err := s.EventSinks(fakeEvent{name: "deploy", app: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(sinks))
+
+	assert.Equal(t, "a", sinks[0].Name)
+	assert.NoError(t, sinks[0].PublishEvent(fakeEvent{name: "deploy", app: app}))
+	assert.Equal(t, []string{"https://example.com/deploys"}, published)
+
+	assert.Equal(t, "b", sinks[1].Name)
+	assert.EqualError(t, sinks[1].PublishEvent(fakeEvent{name: "deploy", app: app}), "https://example.com/broken: boom")
+
+	sinks, err = s.EventSinks(fakeEvent{name: "scale", app: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sinks))
+	assert.Equal(t, "b", sinks[0].Name)
+}
+
+func TestRefusePrivate(t *testing.T) {
+	tests := []struct {
+		address string
+		err     bool
+	}{
+		{"93.184.216.34:443", false},
+		{"127.0.0.1:80", true},
+		{"10.0.0.1:80", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:80", true},
+		{"0.0.0.0:80", true},
+	}
+
+	for _, tt := range tests {
+		err := refusePrivate("tcp", tt.address, nil)
+		assert.Equal(t, tt.err, err != nil, tt.address)
+	}
+}
+
+type fakeFinder map[string][]*empire.Notification
+
+func (f fakeFinder) Notifications(q empire.NotificationsQuery) ([]*empire.Notification, error) {
+	return f[q.App.ID], nil
+}
+
+type fakeEvent struct {
+	name string
+	app  *empire.App
+}
+
+func (e fakeEvent) Event() string       { return e.name }
+func (e fakeEvent) String() string      { return e.name }
+func (e fakeEvent) GetApp() *empire.App { return e.app }
@@ -0,0 +1,226 @@
+// Package webhook provides an empire.EventStream implementation that POSTs
+// events to one or more URLs as JSON.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/remind101/empire"
+)
+
+const (
+	// EventHeader is the header that contains the name of the event.
+	EventHeader = "X-Empire-Event"
+
+	// EventIDHeader is the header that contains the unique id of the event.
+	// The id is the same each time delivery of an event is retried, so
+	// receivers can use it to ignore events they've already received.
+	EventIDHeader = "X-Empire-Event-Id"
+
+	// SignatureHeader is the header that contains the HMAC-SHA256 signature
+	// of the request body, as "sha256=<hex digest>".
+	SignatureHeader = "X-Empire-Signature"
+)
+
+const (
+	// DefaultTimeout is the default timeout for a single request to a
+	// webhook.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultRetries is the default number of times a request to a webhook
+	// is retried.
+	DefaultRetries = 2
+
+	// DefaultRetryDelay is the default delay before a failed request is
+	// retried. The delay doubles for each retry.
+	DefaultRetryDelay = time.Second
+)
+
+// Event represents the schema for a webhook payload.
+type Event struct {
+	ID      string `json:",omitempty"`
+	Event   string
+	Message string
+	Data    interface{}
+}
+
+// Endpoint is a URL that events are posted to.
+type Endpoint struct {
+	// The URL to POST events to.
+	URL string
+
+	// If provided, only events with these names are posted to the URL.
+	Events []string
+}
+
+// ParseEndpoint parses an Endpoint from a URL. The events that are posted to
+// the URL can be limited by adding them to the fragment of the URL, separated
+// by "+" (e.g. https://example.com/hooks/empire#deploy+rollback). The
+// fragment is never sent to the server.
+func ParseEndpoint(rawurl string) (Endpoint, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Endpoint{}, fmt.Errorf("webhook url must be http or https: %s", rawurl)
+	}
+
+	var events []string
+	if u.Fragment != "" {
+		events = strings.Split(u.Fragment, "+")
+		u.Fragment = ""
+	}
+
+	return Endpoint{URL: u.String(), Events: events}, nil
+}
+
+// accepts returns true if the event should be posted to this endpoint.
+func (e Endpoint) accepts(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+
+	for _, name := range e.Events {
+		if name == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EventStream is an implementation of the empire.EventStream interface that
+// POSTs events to webhooks.
+type EventStream struct {
+	// The endpoints to post events to.
+	Endpoints []Endpoint
+
+	// If provided, requests are signed with an HMAC-SHA256 signature of the
+	// body using this secret, which is sent in the SignatureHeader.
+	Secret []byte
+
+	// The number of times a request is retried if it fails. A request fails
+	// if there's a network error, or the response status is 429 or 5xx.
+	Retries int
+
+	// The delay before the first retry.
+	RetryDelay time.Duration
+
+	// The http.Client used to make requests.
+	Client *http.Client
+}
+
+// NewEventStream returns a new EventStream that posts events to the given
+// endpoints.
+func NewEventStream(endpoints []Endpoint) *EventStream {
+	return &EventStream{
+		Endpoints:  endpoints,
+		Retries:    DefaultRetries,
+		RetryDelay: DefaultRetryDelay,
+		Client:     &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// PublishEvent implements the empire.EventStream interface. The event is posted
+// to every endpoint that accepts it, and an error is returned if any of the
+// requests fail.
+func (s *EventStream) PublishEvent(event empire.Event) error {
+	id := empire.EventID(event)
+	raw, err := json.Marshal(&Event{
+		ID:      id,
+		Event:   event.Event(),
+		Message: event.String(),
+		Data:    event,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, e := range s.Endpoints {
+		if !e.accepts(event.Event()) {
+			continue
+		}
+
+		if err := s.post(e.URL, event.Event(), id, raw); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("webhook: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// post posts the body to the endpoint, retrying if the request fails.
+func (s *EventStream) post(endpoint, event, id string, body []byte) error {
+	delay := s.RetryDelay
+
+	var err error
+	for i := 0; i <= s.Retries; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var retry bool
+		if retry, err = s.do(endpoint, event, id, body); !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// do performs a single request, and returns whether the request can be
+// retried if it failed.
+func (s *EventStream) do(endpoint, event, id string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if id != "" {
+		req.Header.Set(EventIDHeader, id)
+	}
+	if len(s.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.Secret, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("error posting to %s: %v", endpoint, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == 429, resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	default:
+		return false, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+}
+
+// Sign returns the value of the SignatureHeader for the body, signed with the
+// secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents_PublishEvent(t *testing.T) {
+	var (
+		body      string
+		signature string
+		event     string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		body = string(raw)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get(EventHeader)
+	}))
+	defer s.Close()
+
+	e := NewEventStream([]Endpoint{{URL: s.URL}})
+	e.Secret = []byte("secret")
+
+	err := e.PublishEvent(fakeEvent{
+		User: "ejholmes",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"Event\":\"fake\",\"Message\":\"ejholmes did something\",\"Data\":{\"User\":\"ejholmes\"}}", body)
+	assert.Equal(t, "fake", event)
+	assert.Equal(t, Sign([]byte("secret"), []byte(body)), signature)
+}
+
+func TestEvents_PublishEvent_Filtered(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer s.Close()
+
+	e := NewEventStream([]Endpoint{
+		{URL: s.URL, Events: []string{"deploy"}},
+		{URL: s.URL, Events: []string{"deploy", "fake"}},
+	})
+
+	err := e.PublishEvent(fakeEvent{
+		User: "ejholmes",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestEvents_PublishEvent_Retries(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	e := NewEventStream([]Endpoint{{URL: s.URL}})
+	e.RetryDelay = 0
+
+	err := e.PublishEvent(fakeEvent{
+		User: "ejholmes",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestEvents_PublishEvent_Error(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+
+	e := NewEventStream([]Endpoint{{URL: s.URL}})
+	e.RetryDelay = 0
+
+	err := e.PublishEvent(fakeEvent{
+		User: "ejholmes",
+	})
+	assert.EqualError(t, err, fmt.Sprintf("webhook: %s returned 400 Bad Request", s.URL))
+	assert.Equal(t, 1, requests)
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		in  string
+		out Endpoint
+		err bool
+	}{
+		{"https://example.com/hooks/empire", Endpoint{URL: "https://example.com/hooks/empire"}, false},
+		{"https://example.com/hooks/empire#deploy+rollback", Endpoint{URL: "https://example.com/hooks/empire", Events: []string{"deploy", "rollback"}}, false},
+		{"ftp://example.com", Endpoint{}, true},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseEndpoint(tt.in)
+		if tt.err {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.out, e)
+	}
+}
+
+type fakeEvent struct {
+	User string
+}
+
+func (e fakeEvent) Event() string  { return "fake" }
+func (e fakeEvent) String() string { return fmt.Sprintf("%s did something", e.User) }
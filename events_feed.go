@@ -0,0 +1,155 @@
+package empire
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The postgres channel that the ids of audit events are sent on when
+	// they're recorded.
+	auditEventsChannel = "audit_events"
+
+	// The number of events that are buffered for a subscriber. If a
+	// subscriber falls this far behind, newer events are dropped until it
+	// catches up.
+	eventFeedBufferSize = 100
+)
+
+// ErrEventFeedUnavailable is returned from SubscribeEvents when the database
+// connection string isn't known, which is required to listen for events.
+var ErrEventFeedUnavailable = errors.New("live events are not available")
+
+// eventFeed streams events from the audit log to subscribers as they're
+// recorded. Since events can be recorded by any Empire instance, the feed
+// listens for notifications on the auditEventsChannel, instead of subscribing
+// to the EventStream directly.
+type eventFeed struct {
+	db *DB
+
+	sync.Mutex
+	listening   bool
+	subscribers map[*eventSubscriber]struct{}
+}
+
+// eventSubscriber is a single subscription to the feed.
+type eventSubscriber struct {
+	// If provided, only events for the app with this name are sent.
+	app *string
+
+	ch chan *AuditEvent
+}
+
+// accepts returns true if the event should be sent to this subscriber.
+func (s *eventSubscriber) accepts(event *AuditEvent) bool {
+	if s.app == nil {
+		return true
+	}
+	return event.AppName != nil && *event.AppName == *s.app
+}
+
+// listen starts listening for notifications, if the feed isn't already.
+func (f *eventFeed) listen() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.listening {
+		return nil
+	}
+
+	if f.db.uri == "" {
+		return ErrEventFeedUnavailable
+	}
+
+	l := pq.NewListener(f.db.uri, 10*time.Second, time.Minute, nil)
+	if err := l.Listen(auditEventsChannel); err != nil {
+		l.Close()
+		return err
+	}
+
+	go f.run(l)
+	f.listening = true
+
+	return nil
+}
+
+// run loads the audit event for each notification and broadcasts it to the
+// subscribers.
+func (f *eventFeed) run(l *pq.Listener) {
+	for n := range l.Notify {
+		// A nil notification is sent when the connection is
+		// re-established. Any events in between are missed.
+		if n == nil {
+			continue
+		}
+
+		event, err := auditEventsFind(f.db.DB, AuditEventsQuery{ID: &n.Extra})
+		if err != nil {
+			continue
+		}
+
+		f.broadcast(event)
+	}
+}
+
+// subscribe adds a new subscriber to the feed.
+func (f *eventFeed) subscribe(app *string) *eventSubscriber {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.subscribers == nil {
+		f.subscribers = make(map[*eventSubscriber]struct{})
+	}
+
+	s := &eventSubscriber{app: app, ch: make(chan *AuditEvent, eventFeedBufferSize)}
+	f.subscribers[s] = struct{}{}
+	return s
+}
+
+// unsubscribe removes the subscriber from the feed, and closes its channel.
+func (f *eventFeed) unsubscribe(s *eventSubscriber) {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.subscribers, s)
+	close(s.ch)
+}
+
+// broadcast sends the event to every subscriber that accepts it. Subscribers
+// that aren't keeping up miss the event, rather than blocking the feed.
+func (f *eventFeed) broadcast(event *AuditEvent) {
+	f.Lock()
+	defer f.Unlock()
+
+	for s := range f.subscribers {
+		if !s.accepts(event) {
+			continue
+		}
+
+		select {
+		case s.ch <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeEvents returns a channel that receives events from the audit log as
+// they're recorded, until the context is cancelled. If app is provided, only
+// events for the app with that name are received.
+func (e *Empire) SubscribeEvents(ctx context.Context, app *string) (<-chan *AuditEvent, error) {
+	if err := e.events.listen(); err != nil {
+		return nil, err
+	}
+
+	s := e.events.subscribe(app)
+	go func() {
+		<-ctx.Done()
+		e.events.unsubscribe(s)
+	}()
+
+	return s.ch, nil
+}
@@ -0,0 +1,51 @@
+package empire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFeed_Broadcast(t *testing.T) {
+	f := &eventFeed{}
+
+	app := "acme-inc"
+	all := f.subscribe(nil)
+	acme := f.subscribe(&app)
+
+	other := "other"
+	f.broadcast(&AuditEvent{ID: "1", AppName: &app})
+	f.broadcast(&AuditEvent{ID: "2", AppName: &other})
+	f.broadcast(&AuditEvent{ID: "3"})
+
+	f.unsubscribe(all)
+	f.unsubscribe(acme)
+
+	assert.Equal(t, []string{"1", "2", "3"}, receivedEventIDs(all.ch))
+	assert.Equal(t, []string{"1"}, receivedEventIDs(acme.ch))
+}
+
+func TestEventFeed_Broadcast_SlowSubscriber(t *testing.T) {
+	f := &eventFeed{}
+
+	s := f.subscribe(nil)
+	for i := 0; i < eventFeedBufferSize+10; i++ {
+		f.broadcast(&AuditEvent{})
+	}
+	f.unsubscribe(s)
+
+	assert.Equal(t, eventFeedBufferSize, len(receivedEventIDs(s.ch)))
+}
+
+func TestEventFeed_Unavailable(t *testing.T) {
+	f := &eventFeed{db: &DB{}}
+	assert.Equal(t, ErrEventFeedUnavailable, f.listen())
+}
+
+func receivedEventIDs(ch <-chan *AuditEvent) []string {
+	var ids []string
+	for event := range ch {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
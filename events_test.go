@@ -37,6 +37,10 @@ func TestEvents_String(t *testing.T) {
 		{RestartEvent{User: "ejholmes", App: "acme-inc", Message: "commit message"}, "ejholmes restarted acme-inc: 'commit message'"},
 		{RestartEvent{User: "ejholmes", App: "acme-inc", PID: "abcd", Message: "commit message"}, "ejholmes restarted `abcd` on acme-inc: 'commit message'"},
 
+		// MigrateEvent
+		{MigrateEvent{User: "ejholmes", App: "acme-inc", Backend: "kubernetes"}, "ejholmes migrated acme-inc to the kubernetes scheduler"},
+		{MigrateEvent{User: "ejholmes", App: "acme-inc", Backend: "kubernetes", Message: "moving to k8s"}, "ejholmes migrated acme-inc to the kubernetes scheduler: 'moving to k8s'"},
+
 		// MaintenanceEvent
 		{MaintenanceEvent{User: "ejholmes", App: "acme-inc", Maintenance: false}, "ejholmes disabled maintenance mode on acme-inc"},
 		{MaintenanceEvent{User: "ejholmes", App: "acme-inc", Maintenance: true}, "ejholmes enabled maintenance mode on acme-inc"},
@@ -110,6 +114,29 @@ func TestEvents_String(t *testing.T) {
 		{DeployEvent{User: "ejholmes", App: "acme-inc", Image: "remind101/acme-inc:master", Environment: "production", Release: 32, Message: "commit message"}, "ejholmes deployed remind101/acme-inc:master to acme-inc production (v32): 'commit message'"},
 		{DeployEvent{User: "ejholmes", Image: "remind101/acme-inc:master", Message: "commit message"}, "ejholmes deployed remind101/acme-inc:master: 'commit message'"},
 
+		// DeployEvent with a canary
+		{DeployEvent{User: "ejholmes", App: "acme-inc", Image: "remind101/acme-inc:v2", Environment: "production", Release: 2, Canary: 10}, "ejholmes deployed remind101/acme-inc:v2 to acme-inc production (v2) as a canary with 10% of traffic"},
+
+		// CanaryPromoteEvent
+		{CanaryPromoteEvent{User: "ejholmes", App: "acme-inc", Version: 2}, "ejholmes promoted canary v2 of acme-inc"},
+		{CanaryPromoteEvent{User: "ejholmes", App: "acme-inc", Version: 2, Message: "looks good"}, "ejholmes promoted canary v2 of acme-inc: 'looks good'"},
+		{CanaryPromoteEvent{App: "acme-inc", Version: 2}, "Automatically promoted canary v2 of acme-inc"},
+
+		// CanaryAbortEvent
+		{CanaryAbortEvent{User: "ejholmes", App: "acme-inc", Version: 2, StableVersion: 1}, "ejholmes aborted canary v2 of acme-inc and rolled back to v1"},
+		{CanaryAbortEvent{User: "ejholmes", App: "acme-inc", Version: 2, StableVersion: 1, Message: "elevated errors"}, "ejholmes aborted canary v2 of acme-inc and rolled back to v1: 'elevated errors'"},
+
+		// AutoRollbackEvent
+		{AutoRollbackEvent{User: "ejholmes", App: "acme-inc", AutoRollback: true}, "ejholmes enabled automatic rollbacks on acme-inc"},
+		{AutoRollbackEvent{User: "ejholmes", App: "acme-inc", AutoRollback: false, Message: "flaky healthchecks"}, "ejholmes disabled automatic rollbacks on acme-inc: 'flaky healthchecks'"},
+
+		// DeployRollbackEvent
+		{DeployRollbackEvent{User: "ejholmes", App: "acme-inc", Environment: "production", FailedRelease: 2, Version: 1, Reason: "deployment failed: web failed to stabilize within 30m0s"}, "v2 of acme-inc production (deployed by ejholmes) failed and was automatically rolled back to v1: deployment failed: web failed to stabilize within 30m0s"},
+
+		// DeployCancelEvent
+		{DeployCancelEvent{User: "ejholmes", App: "acme-inc", Environment: "production", Image: "remind101/acme-inc:master"}, "ejholmes cancelled the deployment of remind101/acme-inc:master to acme-inc production"},
+		{DeployCancelEvent{User: "ejholmes", App: "acme-inc", Environment: "production", Image: "remind101/acme-inc:master", Message: "wrong tag"}, "ejholmes cancelled the deployment of remind101/acme-inc:master to acme-inc production: 'wrong tag'"},
+
 		// RollbackEvent
 		{RollbackEvent{User: "ejholmes", App: "acme-inc", Version: 1}, "ejholmes rolled back acme-inc to v1"},
 		{RollbackEvent{User: "ejholmes", App: "acme-inc", Version: 1, Message: "commit message"}, "ejholmes rolled back acme-inc to v1: 'commit message'"},
@@ -124,6 +151,18 @@ func TestEvents_String(t *testing.T) {
 
 		// DestroyEvent
 		{DestroyEvent{User: "ejholmes", App: "acme-inc", Message: "commit message"}, "ejholmes destroyed acme-inc: 'commit message'"},
+
+		// CollaboratorAddEvent
+		{CollaboratorAddEvent{User: "ejholmes", App: "acme-inc", Collaborator: "mwildehahn"}, "ejholmes added mwildehahn as a collaborator on acme-inc"},
+
+		// CollaboratorRemoveEvent
+		{CollaboratorRemoveEvent{User: "ejholmes", App: "acme-inc", Collaborator: "mwildehahn"}, "ejholmes removed mwildehahn as a collaborator on acme-inc"},
+
+		// ServiceAccountCreateEvent
+		{ServiceAccountCreateEvent{User: "ejholmes", Name: "ci", Scopes: []string{"deploy", "app:acme-*"}}, "ejholmes created service account ci (deploy,app:acme-*)"},
+
+		// ServiceAccountRevokeEvent
+		{ServiceAccountRevokeEvent{User: "ejholmes", Name: "ci", CreatedBy: "ejholmes"}, "ejholmes revoked service account ci"},
 	}
 
 	for _, tt := range tests {
@@ -12,7 +12,7 @@ type MetadataTest struct{}
 var _ = Suite(&MetadataTest{})
 
 func (s *MetadataTest) TestCanProduceSPMetadata(c *C) {
-	validUntil, _ := time.Parse("2006-02-01T15:04:05.000000", "2013-10-03T00:32:19.104000")
+	validUntil := time.Date(2013, 3, 10, 0, 32, 19, 104000000, time.UTC)
 	metadata := Metadata{
 		EntityID:   "http://localhost:5000/e087a985171710fb9fb30f30f41384f9/saml2/metadata/",
 		ValidUntil: validUntil,
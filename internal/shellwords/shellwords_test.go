@@ -27,7 +27,7 @@ func TestSimple(t *testing.T) {
 	for _, testcase := range testcases {
 		args, err := Parse(testcase.line)
 		if err != nil {
-			t.Fatalf(err.Error())
+			t.Fatal(err)
 		}
 		if !reflect.DeepEqual(args, testcase.expected) {
 			t.Fatalf("Expected %v, but %v:", testcase.expected, args)
@@ -54,14 +54,14 @@ func TestError(t *testing.T) {
 func TestBacktick(t *testing.T) {
 	goversion, err := shellRun("go version")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 
 	parser := NewParser()
 	parser.ParseBacktick = true
 	args, err := parser.Parse("echo `go version`")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	expected := []string{"echo", goversion}
 	if !reflect.DeepEqual(args, expected) {
@@ -85,7 +85,7 @@ func TestEnv(t *testing.T) {
 	parser.ParseEnv = true
 	args, err := parser.Parse("echo $FOO")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	expected := []string{"echo", "bar"}
 	if !reflect.DeepEqual(args, expected) {
@@ -98,7 +98,7 @@ func TestNoEnv(t *testing.T) {
 	parser.ParseEnv = true
 	args, err := parser.Parse("echo $BAR")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	expected := []string{"echo", ""}
 	if !reflect.DeepEqual(args, expected) {
@@ -114,7 +114,7 @@ func TestDupEnv(t *testing.T) {
 	parser.ParseEnv = true
 	args, err := parser.Parse("echo $$FOO$")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	expected := []string{"echo", "$bar$"}
 	if !reflect.DeepEqual(args, expected) {
@@ -123,7 +123,7 @@ func TestDupEnv(t *testing.T) {
 
 	args, err = parser.Parse("echo $${FOO_BAR}$")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	expected = []string{"echo", "$baz$"}
 	if !reflect.DeepEqual(args, expected) {
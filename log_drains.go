@@ -0,0 +1,201 @@
+package empire
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/internal/uuid"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// The schemes that are supported for log drain urls.
+const (
+	// LogDrainSyslog sends logs to a syslog server over TCP.
+	LogDrainSyslog = "syslog"
+
+	// LogDrainSyslogTLS sends logs to a syslog server over TLS.
+	LogDrainSyslogTLS = "syslog+tls"
+
+	// LogDrainHTTPS posts batches of logs to a URL.
+	LogDrainHTTPS = "https"
+)
+
+// LogDrain forwards the logs for an app to a syslog server, or an HTTPS
+// endpoint.
+type LogDrain struct {
+	// A unique uuid that identifies this log drain.
+	ID string
+
+	// The app that this log drain belongs to.
+	AppID string
+	App   *App
+
+	// The URL that logs are sent to (e.g. syslog+tls://logs.example.com:6514).
+	URL string
+
+	// A token that identifies the drain, which is sent as the hostname of
+	// each syslog message.
+	Token string
+
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate sets created_at and updated_at, and generates a token before
+// inserting.
+func (d *LogDrain) BeforeCreate() error {
+	t := timex.Now()
+	d.CreatedAt = &t
+	d.UpdatedAt = &t
+	if d.Token == "" {
+		d.Token = fmt.Sprintf("d.%s", uuid.New())
+	}
+	return nil
+}
+
+// LogDrainsQuery is a scope implementation for common things to filter log
+// drains by.
+type LogDrainsQuery struct {
+	// If provided, finds the log drain with the given id.
+	ID *string
+
+	// If provided, finds the log drain with the given url.
+	URL *string
+
+	// If provided, finds log drains belonging to the given app.
+	App *App
+}
+
+// scope implements the scope interface.
+func (q LogDrainsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.URL != nil {
+		scope = append(scope, fieldEquals("url", *q.URL))
+	}
+
+	if q.App != nil {
+		scope = append(scope, forApp(q.App))
+	}
+
+	scope = append(scope, order("created_at"))
+
+	return scope.scope(db)
+}
+
+// logDrainsPreload is a scope that will preload the app for a log drain.
+var logDrainsPreload = preload("App")
+
+// logDrainsFind returns the first matching log drain.
+func logDrainsFind(db *gorm.DB, scope scope) (*LogDrain, error) {
+	var drain LogDrain
+	scope = composedScope{logDrainsPreload, scope}
+	return &drain, first(db, scope, &drain)
+}
+
+// logDrains returns all log drains matching the scope.
+func logDrains(db *gorm.DB, scope scope) ([]*LogDrain, error) {
+	var drains []*LogDrain
+	scope = composedScope{logDrainsPreload, scope}
+	return drains, find(db, scope, &drains)
+}
+
+// logDrainsCreate inserts a new log drain.
+func logDrainsCreate(db *gorm.DB, d *LogDrain) (*LogDrain, error) {
+	return d, db.Create(d).Error
+}
+
+// logDrainsDestroy deletes a log drain.
+func logDrainsDestroy(db *gorm.DB, d *LogDrain) error {
+	return db.Delete(d).Error
+}
+
+// CreateLogDrainOpts are options provided when adding a log drain to an app.
+type CreateLogDrainOpts struct {
+	// User performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// The URL to send logs to.
+	URL string
+}
+
+func (opts CreateLogDrainOpts) Validate(e *Empire) error {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Host == "" {
+		return &ValidationError{Err: fmt.Errorf("%q is not a valid log drain url", opts.URL)}
+	}
+
+	switch u.Scheme {
+	case LogDrainSyslog, LogDrainSyslogTLS:
+		if u.Port() == "" {
+			return &ValidationError{Err: fmt.Errorf("%q must include a port", opts.URL)}
+		}
+	case LogDrainHTTPS:
+	default:
+		return &ValidationError{Err: fmt.Errorf("log drain urls must be %s://, %s:// or %s://", LogDrainSyslog, LogDrainSyslogTLS, LogDrainHTTPS)}
+	}
+
+	return nil
+}
+
+// CreateLogDrain adds a log drain to an app.
+func (e *Empire) CreateLogDrain(ctx context.Context, opts CreateLogDrainOpts) (*LogDrain, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	_, err := logDrainsFind(e.db, LogDrainsQuery{App: opts.App, URL: &opts.URL})
+	switch err {
+	case nil:
+		return nil, &ValidationError{Err: fmt.Errorf("%s is already a log drain for %s", opts.URL, opts.App.Name)}
+	case gorm.RecordNotFound:
+	default:
+		return nil, err
+	}
+
+	d, err := logDrainsCreate(e.db, &LogDrain{
+		AppID: opts.App.ID,
+		URL:   opts.URL,
+	})
+	if err != nil {
+		return d, err
+	}
+	d.App = opts.App
+
+	return d, nil
+}
+
+// DestroyLogDrainOpts are options provided when removing a log drain from an
+// app.
+type DestroyLogDrainOpts struct {
+	// User performing the action.
+	User *User
+
+	// The log drain to remove.
+	LogDrain *LogDrain
+}
+
+// DestroyLogDrain removes a log drain from an app.
+func (e *Empire) DestroyLogDrain(ctx context.Context, opts DestroyLogDrainOpts) error {
+	return logDrainsDestroy(e.db, opts.LogDrain)
+}
+
+// LogDrainsFind returns the first log drain matching the query.
+func (e *Empire) LogDrainsFind(q LogDrainsQuery) (*LogDrain, error) {
+	return logDrainsFind(e.db, q)
+}
+
+// LogDrains returns all log drains matching the query.
+func (e *Empire) LogDrains(q LogDrainsQuery) ([]*LogDrain, error) {
+	return logDrains(e.db, q)
+}
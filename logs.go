@@ -1,19 +1,93 @@
 package empire
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"regexp"
 	"time"
 )
 
 type LogsStreamer interface {
-	StreamLogs(*App, io.Writer, time.Duration) error
+	StreamLogs(*App, io.Writer, StreamLogsOpts) error
+}
+
+// StreamLogsOpts are options provided when streaming logs.
+type StreamLogsOpts struct {
+	// If provided, only logs from this process type are streamed.
+	Process string
+
+	// If provided, only logs from tasks with an id starting with this
+	// value are streamed.
+	TaskID string
+
+	// If provided, logs are streamed starting from this time. The default
+	// is to start from now.
+	Since *time.Time
+
+	// If provided, logs are streamed until this time, and streaming stops
+	// once it's reached.
+	Until *time.Time
+
+	// If provided, only lines that match this regular expression are
+	// streamed. Filtering is done by Empire, so LogsStreamer
+	// implementations can ignore this.
+	Filter *regexp.Regexp
+
+	// If true, new logs are streamed as they arrive. Otherwise, the logs
+	// that already exist are streamed and streaming stops.
+	Follow bool
+}
+
+func (opts StreamLogsOpts) Validate(e *Empire) error {
+	if opts.Since != nil && opts.Until != nil && !opts.Until.After(*opts.Since) {
+		return &ValidationError{Err: fmt.Errorf("until must be after since")}
+	}
+	if !opts.Follow && opts.Since == nil {
+		return &ValidationError{Err: fmt.Errorf("since is required when not following logs")}
+	}
+	return nil
 }
 
 var logsDisabled = &nullLogsStreamer{}
 
 type nullLogsStreamer struct{}
 
-func (s *nullLogsStreamer) StreamLogs(app *App, w io.Writer, duration time.Duration) error {
+func (s *nullLogsStreamer) StreamLogs(app *App, w io.Writer, opts StreamLogsOpts) error {
 	io.WriteString(w, "Logs are disabled\n")
 	return nil
 }
+
+// filterWriter is an io.Writer that only writes lines that match a regular
+// expression to the underlying io.Writer.
+type filterWriter struct {
+	w      io.Writer
+	filter *regexp.Regexp
+
+	// Holds a partial line, until the rest of it is written.
+	buf []byte
+}
+
+func (w *filterWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := w.buf[:i+1]
+		w.buf = w.buf[i+1:]
+
+		if !w.filter.Match(line) {
+			continue
+		}
+
+		if _, err := w.w.Write(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
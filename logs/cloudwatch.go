@@ -0,0 +1,213 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/timex"
+	"github.com/remind101/empire/twelvefactor"
+)
+
+// DefaultCloudWatchPollInterval is the default interval that new log events
+// are polled for.
+const DefaultCloudWatchPollInterval = 2 * time.Second
+
+// cloudwatchlogsClient duck types the cloudwatchlogs.CloudWatchLogs methods
+// that we use.
+type cloudwatchlogsClient interface {
+	FilterLogEvents(*cloudwatchlogs.FilterLogEventsInput) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
+// CloudWatchLogsStreamer is an empire.LogsStreamer implementation that tails
+// the log group for an app in CloudWatch Logs, which is where logs end up when
+// ECS tasks use the awslogs log driver.
+//
+// Each line is prefixed with the time of the log event, and the process and
+// task that it came from. The process and task are parsed from the name of
+// the log stream, which requires awslogs-stream-prefix to be set. Otherwise,
+// the container id is used.
+type CloudWatchLogsStreamer struct {
+	// A text/template that returns the name of the log group for an app.
+	// This is executed with a twelvefactor.Manifest, so it can be the same
+	// template as the awslogs-group log option (e.g. /empire/{{.Name}}).
+	Group *template.Template
+
+	// The interval that new log events are polled for.
+	PollInterval time.Duration
+
+	client cloudwatchlogsClient
+}
+
+// NewCloudWatchLogsStreamer returns a new CloudWatchLogsStreamer that tails
+// the log group returned by the group template.
+func NewCloudWatchLogsStreamer(group string, config client.ConfigProvider) (*CloudWatchLogsStreamer, error) {
+	t, err := template.New("group").Parse(group)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloudWatchLogsStreamer{
+		Group:        t,
+		PollInterval: DefaultCloudWatchPollInterval,
+		client:       cloudwatchlogs.New(config),
+	}, nil
+}
+
+// StreamLogs implements the empire.LogsStreamer interface. When following,
+// logs are streamed until Until is reached, or writing to w fails.
+func (s *CloudWatchLogsStreamer) StreamLogs(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+	group, err := s.group(app)
+	if err != nil {
+		return fmt.Errorf("error determining log group: %v", err)
+	}
+
+	// Events are polled for starting from the timestamp of the last event
+	// that was written. Since multiple events can share a timestamp, the
+	// ids of events at that timestamp are kept, so they're not written
+	// twice.
+	start := toMillis(timex.Now())
+	if opts.Since != nil {
+		start = toMillis(*opts.Since)
+	}
+	seen := make(map[string]bool)
+
+	var end *int64
+	if opts.Until != nil {
+		end = aws.Int64(toMillis(*opts.Until))
+	}
+
+	for {
+		events, err := s.filterLogEvents(group, start, end)
+		if err != nil {
+			return fmt.Errorf("error reading log events from %s: %v", group, err)
+		}
+
+		for _, e := range events {
+			id := aws.StringValue(e.EventId)
+			if seen[id] {
+				continue
+			}
+
+			if ts := aws.Int64Value(e.Timestamp); ts > start {
+				start = ts
+				seen = make(map[string]bool)
+			}
+			seen[id] = true
+
+			process, task := logStreamSource(aws.StringValue(e.LogStreamName))
+			if opts.Process != "" && process != opts.Process {
+				continue
+			}
+			if opts.TaskID != "" && !strings.HasPrefix(task, opts.TaskID) {
+				continue
+			}
+
+			if _, err := io.WriteString(w, formatLogEvent(e, process, task)); err != nil {
+				return fmt.Errorf("error writing log event to log stream: %v", err)
+			}
+		}
+
+		if !opts.Follow {
+			return nil
+		}
+
+		if opts.Until != nil && !timex.Now().Before(*opts.Until) {
+			return nil
+		}
+
+		time.Sleep(s.PollInterval)
+	}
+}
+
+// group returns the name of the log group for the app.
+func (s *CloudWatchLogsStreamer) group(app *empire.App) (string, error) {
+	buf := new(bytes.Buffer)
+	err := s.Group.Execute(buf, &twelvefactor.Manifest{
+		AppID: app.ID,
+		Name:  app.Name,
+	})
+	return buf.String(), err
+}
+
+// filterLogEvents returns all of the log events in the group at or after
+// start, and before end if provided, ordered by timestamp.
+func (s *CloudWatchLogsStreamer) filterLogEvents(group string, start int64, end *int64) ([]*cloudwatchlogs.FilteredLogEvent, error) {
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(group),
+		StartTime:    aws.Int64(start),
+		EndTime:      end,
+		Interleaved:  aws.Bool(true),
+	}
+
+	var events []*cloudwatchlogs.FilteredLogEvent
+	for {
+		resp, err := s.client.FilterLogEvents(input)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, resp.Events...)
+
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	// Interleaving is best effort, so make sure that events from different
+	// log streams are in order.
+	sort.Stable(byTimestamp(events))
+
+	return events, nil
+}
+
+// formatLogEvent formats the log event as a line of output, prefixed with the
+// time, process and task.
+func formatLogEvent(e *cloudwatchlogs.FilteredLogEvent, process, task string) string {
+	t := time.Unix(0, aws.Int64Value(e.Timestamp)*int64(time.Millisecond)).UTC()
+	return formatLine(t, process, task, aws.StringValue(e.Message))
+}
+
+// formatLine formats a log message as a line of output, prefixed with the
+// time, process and task.
+func formatLine(t time.Time, process, task, message string) string {
+	message = strings.TrimRight(message, "\n")
+	return fmt.Sprintf("%s %s[%s]: %s\n", t.Format(time.RFC3339Nano), process, task, message)
+}
+
+// logStreamSource returns the process and task that a log stream belongs to.
+// When awslogs-stream-prefix is set, ECS names log streams as
+// prefix/container-name/task-id, and the container name is the process type.
+// Otherwise, the log stream is named after the container id, and the process
+// is unknown.
+func logStreamSource(name string) (process, task string) {
+	parts := strings.Split(name, "/")
+	if len(parts) == 3 {
+		return parts[1], parts[2]
+	}
+	return "", name
+}
+
+// toMillis returns the time as milliseconds since the epoch, which is what
+// CloudWatch Logs uses for timestamps.
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// byTimestamp sorts log events by their timestamp.
+type byTimestamp []*cloudwatchlogs.FilteredLogEvent
+
+func (e byTimestamp) Len() int      { return len(e) }
+func (e byTimestamp) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byTimestamp) Less(i, j int) bool {
+	return aws.Int64Value(e[i].Timestamp) < aws.Int64Value(e[j].Timestamp)
+}
@@ -0,0 +1,179 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/timex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudWatchLogsStreamer_StreamLogs(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	timex.Now = func() time.Time { return now }
+	defer func() { timex.Now = time.Now }()
+
+	c := &fakeCloudWatchLogs{
+		responses: []*cloudwatchlogs.FilterLogEventsOutput{
+			{
+				Events: []*cloudwatchlogs.FilteredLogEvent{
+					logEvent("1", "ecs/web/d7f8a6b3", 1483228800002, "Started GET /"),
+					logEvent("2", "ecs/worker/91b4e4a0", 1483228800001, "Processing job"),
+				},
+				NextToken: aws.String("next"),
+			},
+			{
+				Events: []*cloudwatchlogs.FilteredLogEvent{
+					logEvent("3", "ecs/web/d7f8a6b3", 1483228800002, "Completed 200 OK\n"),
+				},
+			},
+			// Events at the last timestamp are returned again on the
+			// next poll.
+			{
+				Events: []*cloudwatchlogs.FilteredLogEvent{
+					logEvent("1", "ecs/web/d7f8a6b3", 1483228800002, "Started GET /"),
+					logEvent("3", "ecs/web/d7f8a6b3", 1483228800002, "Completed 200 OK\n"),
+					logEvent("4", "2f9a6b1d4c3e", 1483228801000, "Container started"),
+				},
+			},
+		},
+	}
+
+	s := &CloudWatchLogsStreamer{
+		Group:  template.Must(template.New("group").Parse("/empire/{{.Name}}")),
+		client: c,
+	}
+
+	since := now.Add(-5 * time.Minute)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, w, empire.StreamLogsOpts{
+		Since:  &since,
+		Follow: true,
+	})
+	assert.EqualError(t, err, "error reading log events from /empire/acme-inc: no more responses")
+
+	assert.Equal(t, `2017-01-01T00:00:00.001Z worker[91b4e4a0]: Processing job
+2017-01-01T00:00:00.002Z web[d7f8a6b3]: Started GET /
+2017-01-01T00:00:00.002Z web[d7f8a6b3]: Completed 200 OK
+2017-01-01T00:00:01Z [2f9a6b1d4c3e]: Container started
+`, w.String())
+
+	assert.Equal(t, 4, len(c.inputs))
+	assert.Equal(t, "/empire/acme-inc", *c.inputs[0].LogGroupName)
+	assert.Equal(t, int64(1483228500000), *c.inputs[0].StartTime)
+	assert.Equal(t, "next", *c.inputs[1].NextToken)
+	assert.Equal(t, int64(1483228800002), *c.inputs[2].StartTime)
+	assert.Nil(t, c.inputs[2].NextToken)
+	assert.Equal(t, int64(1483228801000), *c.inputs[3].StartTime)
+}
+
+func TestCloudWatchLogsStreamer_StreamLogs_WriteError(t *testing.T) {
+	c := &fakeCloudWatchLogs{
+		responses: []*cloudwatchlogs.FilterLogEventsOutput{
+			{
+				Events: []*cloudwatchlogs.FilteredLogEvent{
+					logEvent("1", "ecs/web/d7f8a6b3", 1483228800000, "Started GET /"),
+				},
+			},
+		},
+	}
+
+	s := &CloudWatchLogsStreamer{
+		Group:  template.Must(template.New("group").Parse("{{.Name}}")),
+		client: c,
+	}
+
+	err := s.StreamLogs(&empire.App{Name: "acme-inc"}, errWriter{}, empire.StreamLogsOpts{Follow: true})
+	assert.EqualError(t, err, "error writing log event to log stream: write failed")
+}
+
+func TestCloudWatchLogsStreamer_StreamLogs_NoFollow(t *testing.T) {
+	c := &fakeCloudWatchLogs{
+		responses: []*cloudwatchlogs.FilterLogEventsOutput{
+			{
+				Events: []*cloudwatchlogs.FilteredLogEvent{
+					logEvent("1", "ecs/web/d7f8a6b3", 1483228800000, "Started GET /"),
+					logEvent("2", "ecs/worker/91b4e4a0", 1483228800001, "Processing job"),
+					logEvent("3", "ecs/web/0c2e4f6a", 1483228800002, "Started GET /"),
+				},
+			},
+		},
+	}
+
+	s := &CloudWatchLogsStreamer{
+		Group:  template.Must(template.New("group").Parse("{{.Name}}")),
+		client: c,
+	}
+
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{Name: "acme-inc"}, w, empire.StreamLogsOpts{
+		Process: "web",
+		TaskID:  "d7f8",
+		Since:   &since,
+		Until:   &until,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2017-01-01T00:00:00Z web[d7f8a6b3]: Started GET /\n", w.String())
+	assert.Equal(t, 1, len(c.inputs))
+	assert.Equal(t, int64(1483228800000), *c.inputs[0].StartTime)
+	assert.Equal(t, int64(1483232400000), *c.inputs[0].EndTime)
+}
+
+func TestLogStreamSource(t *testing.T) {
+	tests := []struct {
+		in      string
+		process string
+		task    string
+	}{
+		{"ecs/web/d7f8a6b3-c8e4-4b5a-9d0e-1f2a3b4c5d6e", "web", "d7f8a6b3-c8e4-4b5a-9d0e-1f2a3b4c5d6e"},
+		{"2f9a6b1d4c3e", "", "2f9a6b1d4c3e"},
+	}
+
+	for _, tt := range tests {
+		process, task := logStreamSource(tt.in)
+		assert.Equal(t, tt.process, process)
+		assert.Equal(t, tt.task, task)
+	}
+}
+
+// fakeCloudWatchLogs is a fake cloudwatchlogsClient that returns the responses
+// in order, recording the inputs.
+type fakeCloudWatchLogs struct {
+	responses []*cloudwatchlogs.FilterLogEventsOutput
+	inputs    []cloudwatchlogs.FilterLogEventsInput
+}
+
+func (c *fakeCloudWatchLogs) FilterLogEvents(input *cloudwatchlogs.FilterLogEventsInput) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	c.inputs = append(c.inputs, *input)
+	if len(c.responses) == 0 {
+		return nil, errors.New("no more responses")
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+// errWriter is an io.Writer that always returns an error.
+type errWriter struct{}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func logEvent(id, stream string, timestamp int64, message string) *cloudwatchlogs.FilteredLogEvent {
+	return &cloudwatchlogs.FilteredLogEvent{
+		EventId:       aws.String(id),
+		LogStreamName: aws.String(stream),
+		Timestamp:     aws.Int64(timestamp),
+		Message:       aws.String(message),
+	}
+}
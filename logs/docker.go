@@ -0,0 +1,388 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/stdcopy"
+	"github.com/remind101/empire/pkg/timex"
+)
+
+// DefaultDockerPollInterval is the default interval that new containers are
+// polled for.
+const DefaultDockerPollInterval = 2 * time.Second
+
+const (
+	// Label that determines what app the container belongs to.
+	appLabel = "empire.app.id"
+
+	// Label that determines what the name of the process is.
+	processLabel = "empire.app.process"
+)
+
+// errDockerLogsStopped is returned when writing a line after streaming logs
+// has stopped.
+var errDockerLogsStopped = errors.New("streaming logs stopped")
+
+// dockerClient duck types the docker.Client methods that we use.
+type dockerClient interface {
+	ListContainers(docker.ListContainersOptions) ([]docker.APIContainers, error)
+	InspectContainer(string) (*docker.Container, error)
+	Logs(docker.LogsOptions) error
+}
+
+// DockerLogsStreamer is an empire.LogsStreamer implementation that streams the
+// logs of an app's containers from a Docker daemon, which is where logs are
+// when using the Docker scheduler, or for attached runs.
+//
+// Each line is prefixed with the time that it was logged, and the process and
+// container that it came from, in the same format as CloudWatchLogsStreamer.
+type DockerLogsStreamer struct {
+	// The interval that new containers are polled for when following logs.
+	PollInterval time.Duration
+
+	client dockerClient
+}
+
+// NewDockerLogsStreamer returns a new DockerLogsStreamer that streams logs from
+// the Docker daemon that c is connected to.
+func NewDockerLogsStreamer(c *docker.Client) *DockerLogsStreamer {
+	return &DockerLogsStreamer{
+		PollInterval: DefaultDockerPollInterval,
+		client:       c,
+	}
+}
+
+// StreamLogs implements the empire.LogsStreamer interface. When not following,
+// the logs from all of the app's containers are interleaved by time. When
+// following, the logs from each container are written as they arrive, and
+// containers that start after streaming began are picked up.
+func (s *DockerLogsStreamer) StreamLogs(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+	since := timex.Now()
+	if opts.Since != nil {
+		since = *opts.Since
+	}
+
+	if opts.Follow {
+		return s.followLogs(app, w, since, opts)
+	}
+
+	return s.writeLogs(app, w, since, opts)
+}
+
+// writeLogs writes the existing logs for all of the app's containers, ordered
+// by time.
+func (s *DockerLogsStreamer) writeLogs(app *empire.App, w io.Writer, since time.Time, opts empire.StreamLogsOpts) error {
+	containers, err := s.containers(app, true, opts)
+	if err != nil {
+		return err
+	}
+
+	var lines []logLine
+	for _, c := range containers {
+		if err := s.containerLogs(c, since, false, opts, func(l logLine) error {
+			lines = append(lines, l)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	sort.Stable(byTime(lines))
+
+	for _, l := range lines {
+		if _, err := io.WriteString(w, l.line); err != nil {
+			return fmt.Errorf("error writing log line to log stream: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// followLogs follows the logs for each of the app's containers, polling for
+// new containers, until Until is reached, or writing to w fails.
+func (s *DockerLogsStreamer) followLogs(app *empire.App, w io.Writer, since time.Time, opts empire.StreamLogsOpts) error {
+	var (
+		mu      sync.Mutex
+		stopped bool
+		errs    = make(chan error, 1)
+	)
+
+	// stop stops writing lines. Containers that are still being followed
+	// stop the next time that they log a line.
+	stop := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		stopped = true
+		if err != nil {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+	}
+
+	write := func(l logLine) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stopped {
+			return errDockerLogsStopped
+		}
+
+		if _, err := io.WriteString(w, l.line); err != nil {
+			stopped = true
+			select {
+			case errs <- fmt.Errorf("error writing log line to log stream: %v", err):
+			default:
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	// The containers that are being followed, and the time of the last
+	// line from each container, so that containers that restart are
+	// followed again from where they left off.
+	following := make(map[string]bool)
+	last := make(map[string]time.Time)
+
+	// Stopped containers only need to be included when there are logs
+	// from before now.
+	all := opts.Since != nil
+
+	for {
+		containers, err := s.containers(app, all, opts)
+		if err != nil {
+			stop(nil)
+			return err
+		}
+
+		for _, c := range containers {
+			mu.Lock()
+			followed := following[c.ID]
+			following[c.ID] = true
+			t, resumed := last[c.ID]
+			mu.Unlock()
+
+			if followed {
+				continue
+			}
+
+			start := since
+			if resumed {
+				start = t.Add(time.Nanosecond)
+			}
+
+			go func(c docker.APIContainers, start time.Time) {
+				err := s.containerLogs(c, start, true, opts, func(l logLine) error {
+					if err := write(l); err != nil {
+						return err
+					}
+
+					mu.Lock()
+					last[c.ID] = l.time
+					mu.Unlock()
+					return nil
+				})
+
+				mu.Lock()
+				delete(following, c.ID)
+				mu.Unlock()
+
+				switch err.(type) {
+				case nil, *docker.NoSuchContainer:
+				default:
+					if err != errDockerLogsStopped {
+						stop(err)
+					}
+				}
+			}(c, start)
+		}
+
+		// Containers that start later are picked up by the next poll.
+		all = false
+
+		select {
+		case err := <-errs:
+			stop(nil)
+			return err
+		case <-time.After(s.PollInterval):
+		}
+
+		if opts.Until != nil && !timex.Now().Before(*opts.Until) {
+			stop(nil)
+			return nil
+		}
+	}
+}
+
+// containers returns the app's containers, filtered by process and task. If
+// all is false, only running containers are returned.
+func (s *DockerLogsStreamer) containers(app *empire.App, all bool, opts empire.StreamLogsOpts) ([]docker.APIContainers, error) {
+	labels := []string{fmt.Sprintf("%s=%s", appLabel, app.ID)}
+	if opts.Process != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", processLabel, opts.Process))
+	}
+
+	containers, err := s.client.ListContainers(docker.ListContainersOptions{
+		All: all,
+		Filters: map[string][]string{
+			"label": labels,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %v", err)
+	}
+
+	var filtered []docker.APIContainers
+	for _, c := range containers {
+		if opts.TaskID != "" && !strings.HasPrefix(c.ID, opts.TaskID) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+
+	return filtered, nil
+}
+
+// containerLogs reads the logs for the container, calling fn with each line
+// that was logged at or after since, and before Until.
+func (s *DockerLogsStreamer) containerLogs(c docker.APIContainers, since time.Time, follow bool, opts empire.StreamLogsOpts, fn func(logLine) error) error {
+	container, err := s.client.InspectContainer(c.ID)
+	if err != nil {
+		return err
+	}
+
+	task := c.ID
+	if len(task) > 12 {
+		task = task[:12]
+	}
+
+	newWriter := func() *logLineWriter {
+		return &logLineWriter{
+			process: c.Labels[processLabel],
+			task:    task,
+			since:   since,
+			until:   opts.Until,
+			fn:      fn,
+		}
+	}
+
+	// The logs are read raw, so that they can be demultiplexed here,
+	// unless the container has a tty, in which case stdout and stderr
+	// aren't multiplexed.
+	r, w := io.Pipe()
+	copied := make(chan error, 1)
+	go func() {
+		var err error
+		if container.Config != nil && container.Config.Tty {
+			_, err = io.Copy(newWriter(), r)
+		} else {
+			_, err = stdcopy.StdCopy(newWriter(), newWriter(), r)
+		}
+		r.CloseWithError(err)
+		copied <- err
+	}()
+
+	err = s.client.Logs(docker.LogsOptions{
+		Container:    c.ID,
+		OutputStream: w,
+		ErrorStream:  w,
+		Follow:       follow,
+		Stdout:       true,
+		Stderr:       true,
+		Since:        since.Unix(),
+		Timestamps:   true,
+		RawTerminal:  true,
+	})
+	w.CloseWithError(err)
+
+	if err := <-copied; err != nil {
+		return err
+	}
+
+	return err
+}
+
+// logLine is a line of output, and the time that it was logged.
+type logLine struct {
+	time time.Time
+	line string
+}
+
+// byTime sorts log lines by the time that they were logged.
+type byTime []logLine
+
+func (l byTime) Len() int           { return len(l) }
+func (l byTime) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byTime) Less(i, j int) bool { return l[i].time.Before(l[j].time) }
+
+// logLineWriter is an io.Writer that parses the timestamped lines that the
+// Docker daemon returns, and calls fn with each formatted line.
+type logLineWriter struct {
+	process, task string
+
+	// Lines outside of this range are skipped. The Docker API only
+	// filters by whole seconds.
+	since time.Time
+	until *time.Time
+
+	fn func(logLine) error
+
+	// Holds a partial line, until the rest of it is written.
+	buf []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+
+		t, message := parseTimestamp(line)
+		if t.Before(w.since) {
+			continue
+		}
+		if w.until != nil && !t.Before(*w.until) {
+			continue
+		}
+
+		if err := w.fn(logLine{
+			time: t,
+			line: formatLine(t, w.process, w.task, message),
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// parseTimestamp splits a line into the timestamp that the Docker daemon
+// prefixes it with, and the message.
+func parseTimestamp(line string) (time.Time, string) {
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) == 2 {
+		if t, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+			return t.UTC(), parts[1]
+		}
+	}
+	return timex.Now(), line
+}
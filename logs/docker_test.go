@@ -0,0 +1,221 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerLogsStreamer_StreamLogs_NoFollow(t *testing.T) {
+	c := &fakeDocker{
+		containers: []docker.APIContainers{
+			dockerContainer("d7f8a6b3c8e4aaaa", "web"),
+			dockerContainer("91b4e4a0ffffbbbb", "worker"),
+		},
+		logs: map[string][]string{
+			"d7f8a6b3c8e4aaaa": {
+				"2017-01-01T00:00:00.000000001Z Started GET /\n",
+				"2017-01-01T00:00:02Z Completed 200 OK\n",
+			},
+			"91b4e4a0ffffbbbb": {
+				"2017-01-01T00:00:01Z Processing job\n",
+			},
+		},
+	}
+
+	s := &DockerLogsStreamer{client: c}
+
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2017, 1, 1, 0, 0, 2, 0, time.UTC)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, w, empire.StreamLogsOpts{
+		Since: &since,
+		Until: &until,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, `2017-01-01T00:00:00.000000001Z web[d7f8a6b3c8e4]: Started GET /
+2017-01-01T00:00:01Z worker[91b4e4a0ffff]: Processing job
+`, w.String())
+
+	assert.Equal(t, []string{"empire.app.id=1234"}, c.listOptions[0].Filters["label"])
+	assert.True(t, c.listOptions[0].All)
+	assert.Equal(t, since.Unix(), c.logsOptions[0].Since)
+	assert.False(t, c.logsOptions[0].Follow)
+}
+
+func TestDockerLogsStreamer_StreamLogs_Filter(t *testing.T) {
+	c := &fakeDocker{
+		containers: []docker.APIContainers{
+			dockerContainer("d7f8a6b3c8e4aaaa", "web"),
+			dockerContainer("0c2e4f6a1b2cdddd", "web"),
+		},
+		logs: map[string][]string{
+			"d7f8a6b3c8e4aaaa": {"2017-01-01T00:00:00Z Started GET /\n"},
+			"0c2e4f6a1b2cdddd": {"2017-01-01T00:00:01Z Started GET /\n"},
+		},
+	}
+
+	s := &DockerLogsStreamer{client: c}
+
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, w, empire.StreamLogsOpts{
+		Process: "web",
+		TaskID:  "0c2e",
+		Since:   &since,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2017-01-01T00:00:01Z web[0c2e4f6a1b2c]: Started GET /\n", w.String())
+	assert.Equal(t, []string{"empire.app.id=1234", "empire.app.process=web"}, c.listOptions[0].Filters["label"])
+}
+
+func TestDockerLogsStreamer_StreamLogs_Follow(t *testing.T) {
+	now := time.Now().UTC()
+	c := &fakeDocker{
+		containers: []docker.APIContainers{
+			dockerContainer("d7f8a6b3c8e4aaaa", "web"),
+		},
+		logs: map[string][]string{
+			"d7f8a6b3c8e4aaaa": {fmt.Sprintf("%s Started GET /\n", now.Add(time.Millisecond).Format(time.RFC3339Nano))},
+			"91b4e4a0ffffbbbb": {fmt.Sprintf("%s Processing job\n", now.Add(2*time.Millisecond).Format(time.RFC3339Nano))},
+		},
+	}
+	c.onList = func(n int) {
+		// Start a new container after the first poll.
+		if n == 2 {
+			c.containers = append(c.containers, dockerContainer("91b4e4a0ffffbbbb", "worker"))
+		}
+	}
+
+	s := &DockerLogsStreamer{client: c, PollInterval: time.Millisecond}
+
+	until := now.Add(100 * time.Millisecond)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, w, empire.StreamLogsOpts{
+		Until:  &until,
+		Follow: true,
+	})
+	assert.NoError(t, err)
+
+	// Containers are followed again when their logs end (e.g. when they
+	// restart), without writing the same lines twice.
+	assert.Equal(t, 1, strings.Count(w.String(), "web[d7f8a6b3c8e4]: Started GET /\n"))
+	assert.Equal(t, 1, strings.Count(w.String(), "worker[91b4e4a0ffff]: Processing job\n"))
+	assert.False(t, c.listOptions[0].All)
+	assert.True(t, c.logsOptions[0].Follow)
+}
+
+func TestDockerLogsStreamer_StreamLogs_WriteError(t *testing.T) {
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &fakeDocker{
+		containers: []docker.APIContainers{
+			dockerContainer("d7f8a6b3c8e4aaaa", "web"),
+		},
+		logs: map[string][]string{
+			"d7f8a6b3c8e4aaaa": {"2017-01-01T00:00:00Z Started GET /\n"},
+		},
+	}
+
+	s := &DockerLogsStreamer{client: c, PollInterval: time.Millisecond}
+
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, errWriter{}, empire.StreamLogsOpts{
+		Since:  &since,
+		Follow: true,
+	})
+	assert.EqualError(t, err, "error writing log line to log stream: write failed")
+}
+
+func TestDockerLogsStreamer_StreamLogs_Tty(t *testing.T) {
+	c := &fakeDocker{
+		containers: []docker.APIContainers{
+			dockerContainer("d7f8a6b3c8e4aaaa", "run"),
+		},
+		logs: map[string][]string{
+			"d7f8a6b3c8e4aaaa": {"2017-01-01T00:00:00Z $ bash\n"},
+		},
+		tty: true,
+	}
+
+	s := &DockerLogsStreamer{client: c}
+
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(&empire.App{ID: "1234", Name: "acme-inc"}, w, empire.StreamLogsOpts{Since: &since})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2017-01-01T00:00:00Z run[d7f8a6b3c8e4]: $ bash\n", w.String())
+}
+
+// fakeDocker is a fake dockerClient that returns logs for containers.
+type fakeDocker struct {
+	sync.Mutex
+
+	containers []docker.APIContainers
+	logs       map[string][]string
+	tty        bool
+
+	// Called with the number of times ListContainers has been called.
+	onList func(int)
+
+	listOptions []docker.ListContainersOptions
+	logsOptions []docker.LogsOptions
+}
+
+func (c *fakeDocker) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.listOptions = append(c.listOptions, opts)
+	if c.onList != nil {
+		c.onList(len(c.listOptions))
+	}
+
+	return c.containers, nil
+}
+
+func (c *fakeDocker) InspectContainer(id string) (*docker.Container, error) {
+	return &docker.Container{
+		ID:     id,
+		Config: &docker.Config{Tty: c.tty},
+	}, nil
+}
+
+func (c *fakeDocker) Logs(opts docker.LogsOptions) error {
+	c.Lock()
+	c.logsOptions = append(c.logsOptions, opts)
+	c.Unlock()
+
+	var w io.Writer = opts.OutputStream
+	if !c.tty {
+		w = stdcopy.NewStdWriter(opts.OutputStream, stdcopy.Stdout)
+	}
+
+	for _, line := range c.logs[opts.Container] {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dockerContainer(id, process string) docker.APIContainers {
+	return docker.APIContainers{
+		ID: id,
+		Labels: map[string]string{
+			"empire.app.id":      "1234",
+			"empire.app.process": process,
+		},
+	}
+}
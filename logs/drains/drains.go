@@ -0,0 +1,356 @@
+// Package drains forwards the logs for apps to the log drains that were added
+// to them.
+package drains
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/remind101/empire"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultRefreshInterval is the default interval that the list of log
+	// drains is refreshed.
+	DefaultRefreshInterval = 30 * time.Second
+
+	// DefaultBufferSize is the default number of lines that are buffered
+	// for a drain. When a drain can't keep up, the oldest lines are
+	// dropped.
+	DefaultBufferSize = 1024
+
+	// The maximum number of lines that are sent to a drain at once.
+	maxBatchSize = 100
+
+	// The delay before retrying after an error streaming logs, or sending
+	// to a drain. The delay doubles for each consecutive error.
+	baseRetryDelay = time.Second
+	maxRetryDelay  = time.Minute
+)
+
+// errStopped is returned from Write when forwarding to the drain has been
+// stopped, to stop the LogsStreamer.
+var errStopped = errors.New("log drain stopped")
+
+type logDrainsFinder interface {
+	LogDrains(empire.LogDrainsQuery) ([]*empire.LogDrain, error)
+}
+
+type logsStreamer interface {
+	StreamLogs(*empire.App, io.Writer, empire.StreamLogsOpts) error
+}
+
+// Forwarder streams the logs for each app that has log drains from the
+// empire.LogsStreamer, and sends them to the drains.
+//
+// Only one Forwarder should run at a time, or logs will be sent to drains more
+// than once.
+type Forwarder struct {
+	// The interval that the list of log drains is refreshed, to pick up
+	// drains that were added or removed.
+	RefreshInterval time.Duration
+
+	// The number of lines that are buffered for each drain.
+	BufferSize int
+
+	finder   logDrainsFinder
+	streamer logsStreamer
+
+	// newSender returns a Sender that sends messages to the drain.
+	newSender func(*empire.LogDrain) (Sender, error)
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+// NewForwarder returns a new Forwarder that forwards logs to the log drains in
+// e.
+func NewForwarder(e *empire.Empire) *Forwarder {
+	return &Forwarder{
+		RefreshInterval: DefaultRefreshInterval,
+		BufferSize:      DefaultBufferSize,
+		finder:          e,
+		streamer:        e,
+		newSender:       NewSender,
+	}
+}
+
+// Run forwards logs until the context is cancelled.
+func (f *Forwarder) Run(ctx context.Context) error {
+	defer f.stopAll()
+
+	for {
+		if err := f.refresh(ctx); err != nil {
+			log.Printf("error refreshing log drains: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(f.RefreshInterval):
+		}
+	}
+}
+
+// refresh starts forwarding to drains that were added, and stops forwarding
+// to drains that were removed.
+func (f *Forwarder) refresh(ctx context.Context) error {
+	drains, err := f.finder.LogDrains(empire.LogDrainsQuery{})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.running == nil {
+		f.running = make(map[string]context.CancelFunc)
+	}
+
+	current := make(map[string]bool)
+	for _, d := range drains {
+		current[d.ID] = true
+
+		if _, ok := f.running[d.ID]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		f.running[d.ID] = cancel
+		go f.forward(ctx, d)
+	}
+
+	for id, cancel := range f.running {
+		if !current[id] {
+			cancel()
+			delete(f.running, id)
+		}
+	}
+
+	return nil
+}
+
+// stopAll stops forwarding to all drains.
+func (f *Forwarder) stopAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, cancel := range f.running {
+		cancel()
+		delete(f.running, id)
+	}
+}
+
+// forward streams the logs for the drain's app, and sends them to the drain,
+// until the context is cancelled.
+func (f *Forwarder) forward(ctx context.Context, d *empire.LogDrain) {
+	b := newBuffer(f.BufferSize)
+	go f.send(ctx, d, b)
+
+	w := &lineWriter{ctx: ctx, buffer: b}
+	retry := newRetrier()
+	for {
+		err := f.streamer.StreamLogs(d.App, w, empire.StreamLogsOpts{Follow: true})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("error streaming logs for %s to %s: %v\n", d.App.Name, d.URL, err)
+		if !retry.wait(ctx) {
+			return
+		}
+	}
+}
+
+// send sends the lines in the buffer to the drain, until the context is
+// cancelled. If sending fails, it's retried with exponential backoff, and
+// lines are dropped from the buffer in the meantime if it fills up.
+func (f *Forwarder) send(ctx context.Context, d *empire.LogDrain, b *buffer) {
+	var s Sender
+	defer func() {
+		if s != nil {
+			s.Close()
+		}
+	}()
+
+	retry := newRetrier()
+	for {
+		lines, dropped, ok := b.next(ctx, maxBatchSize)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		var msgs [][]byte
+		if dropped > 0 {
+			msgs = append(msgs, Format(d, now, []byte(fmt.Sprintf("Empire: dropped %d log lines because the drain could not keep up", dropped))))
+		}
+		for _, line := range lines {
+			msgs = append(msgs, Format(d, now, line))
+		}
+
+		for {
+			err := f.sendTo(&s, d, msgs)
+			if err == nil {
+				retry.reset()
+				break
+			}
+
+			log.Printf("error sending logs for %s to %s: %v\n", d.App.Name, d.URL, err)
+			if s != nil {
+				s.Close()
+				s = nil
+			}
+
+			if !retry.wait(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// sendTo sends the messages with the Sender, opening a new Sender if there
+// isn't one.
+func (f *Forwarder) sendTo(s *Sender, d *empire.LogDrain, msgs [][]byte) error {
+	if *s == nil {
+		var err error
+		if *s, err = f.newSender(d); err != nil {
+			return err
+		}
+	}
+
+	return (*s).Send(msgs)
+}
+
+// buffer is a bounded buffer of log lines. When the buffer is full, the oldest
+// line is dropped, so that a slow drain never blocks the LogsStreamer.
+type buffer struct {
+	mu      sync.Mutex
+	lines   [][]byte
+	size    int
+	dropped int
+
+	// Receives when lines are added to an empty buffer.
+	ready chan struct{}
+}
+
+func newBuffer(size int) *buffer {
+	return &buffer{
+		size:  size,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// add adds a line to the buffer, dropping the oldest line if it's full.
+func (b *buffer) add(line []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) >= b.size {
+		b.lines = b.lines[1:]
+		b.dropped++
+	}
+	b.lines = append(b.lines, line)
+
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for lines to be added to the buffer, and returns up to max of
+// them, along with the number of lines that were dropped since the last call.
+// ok is false if the context was cancelled.
+func (b *buffer) next(ctx context.Context, max int) (lines [][]byte, dropped int, ok bool) {
+	for {
+		b.mu.Lock()
+		if len(b.lines) > 0 {
+			n := len(b.lines)
+			if n > max {
+				n = max
+			}
+			lines = b.lines[:n:n]
+			b.lines = b.lines[n:]
+			dropped, b.dropped = b.dropped, 0
+			b.mu.Unlock()
+			return lines, dropped, true
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, false
+		case <-b.ready:
+		}
+	}
+}
+
+// lineWriter is an io.Writer that splits what's written into lines, and adds
+// them to a buffer.
+type lineWriter struct {
+	ctx    context.Context
+	buffer *buffer
+
+	// Holds a partial line, until the rest of it is written.
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, errStopped
+	}
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := make([]byte, i)
+		copy(line, w.partial[:i])
+		w.partial = w.partial[i+1:]
+
+		if len(line) > 0 {
+			w.buffer.add(line)
+		}
+	}
+
+	return len(p), nil
+}
+
+// retrier waits between attempts, with exponential backoff.
+type retrier struct {
+	delay time.Duration
+}
+
+func newRetrier() *retrier {
+	return &retrier{delay: baseRetryDelay}
+}
+
+// wait waits before the next attempt. It returns false if the context was
+// cancelled.
+func (r *retrier) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(r.delay):
+	}
+
+	r.delay *= 2
+	if r.delay > maxRetryDelay {
+		r.delay = maxRetryDelay
+	}
+	return true
+}
+
+// reset resets the delay after a successful attempt.
+func (r *retrier) reset() {
+	r.delay = baseRetryDelay
+}
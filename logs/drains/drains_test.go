@@ -0,0 +1,162 @@
+package drains
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestForwarder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	drain := &empire.LogDrain{
+		ID:    "1",
+		App:   &empire.App{Name: "acme-inc"},
+		URL:   "syslog://logs.example.com:514",
+		Token: "d.1234",
+	}
+	finder := &fakeFinder{drains: []*empire.LogDrain{drain}}
+	sender := &fakeSender{msgs: make(chan []byte, 10)}
+
+	f := &Forwarder{
+		BufferSize: DefaultBufferSize,
+		finder:     finder,
+		streamer: logsStreamerFunc(func(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+			assert.True(t, opts.Follow)
+			io.WriteString(w, "Started GET /\nCompleted ")
+			io.WriteString(w, "200 OK\n")
+			<-ctx.Done()
+			return nil
+		}),
+		newSender: func(d *empire.LogDrain) (Sender, error) {
+			return sender, nil
+		},
+	}
+
+	assert.NoError(t, f.refresh(ctx))
+	assert.Equal(t, 1, len(f.running))
+
+	assert.Regexp(t, `^<190>1 \S+ d\.1234 acme-inc - - - Started GET /$`, string(receive(t, sender.msgs)))
+	assert.Regexp(t, `^<190>1 \S+ d\.1234 acme-inc - - - Completed 200 OK$`, string(receive(t, sender.msgs)))
+
+	// Forwarding stops when the drain is removed.
+	finder.drains = nil
+	assert.NoError(t, f.refresh(ctx))
+	assert.Equal(t, 0, len(f.running))
+}
+
+func TestForwarder_SendError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	drain := &empire.LogDrain{ID: "1", App: &empire.App{Name: "acme-inc"}, Token: "d.1234"}
+	sender := &fakeSender{msgs: make(chan []byte, 10), errs: 1}
+
+	f := &Forwarder{
+		BufferSize: DefaultBufferSize,
+		finder:     &fakeFinder{drains: []*empire.LogDrain{drain}},
+		streamer: logsStreamerFunc(func(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+			io.WriteString(w, "Started GET /\n")
+			<-ctx.Done()
+			return nil
+		}),
+		newSender: func(d *empire.LogDrain) (Sender, error) {
+			return sender, nil
+		},
+	}
+
+	assert.NoError(t, f.refresh(ctx))
+
+	// The message is retried after the first attempt fails.
+	assert.Regexp(t, `Started GET /$`, string(receive(t, sender.msgs)))
+	assert.Equal(t, 1, sender.closed)
+}
+
+func TestBuffer(t *testing.T) {
+	ctx := context.Background()
+	b := newBuffer(3)
+
+	for i := 0; i < 5; i++ {
+		b.add([]byte(fmt.Sprintf("line %d", i)))
+	}
+
+	lines, dropped, ok := b.next(ctx, 2)
+	assert.True(t, ok)
+	assert.Equal(t, 2, dropped)
+	assert.Equal(t, [][]byte{[]byte("line 2"), []byte("line 3")}, lines)
+
+	lines, dropped, ok = b.next(ctx, 2)
+	assert.True(t, ok)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, [][]byte{[]byte("line 4")}, lines)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, ok = b.next(ctx, 2)
+	assert.False(t, ok)
+}
+
+func TestLineWriter_Stopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := &lineWriter{ctx: ctx, buffer: newBuffer(1)}
+	_, err := w.Write([]byte("line\n"))
+	assert.Equal(t, errStopped, err)
+}
+
+type fakeFinder struct {
+	drains []*empire.LogDrain
+}
+
+func (f *fakeFinder) LogDrains(q empire.LogDrainsQuery) ([]*empire.LogDrain, error) {
+	return f.drains, nil
+}
+
+type logsStreamerFunc func(*empire.App, io.Writer, empire.StreamLogsOpts) error
+
+func (fn logsStreamerFunc) StreamLogs(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+	return fn(app, w, opts)
+}
+
+// fakeSender is a Sender that sends messages to a channel. The first errs
+// calls to Send return an error.
+type fakeSender struct {
+	msgs   chan []byte
+	errs   int
+	closed int
+}
+
+func (s *fakeSender) Send(msgs [][]byte) error {
+	if s.errs > 0 {
+		s.errs--
+		return errors.New("connection refused")
+	}
+
+	for _, msg := range msgs {
+		s.msgs <- msg
+	}
+	return nil
+}
+
+func (s *fakeSender) Close() error {
+	s.closed++
+	return nil
+}
+
+func receive(t testing.TB, ch chan []byte) []byte {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
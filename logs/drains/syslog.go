@@ -0,0 +1,154 @@
+package drains
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/remind101/empire"
+)
+
+const (
+	// The priority of syslog messages, which is the local7 facility at the
+	// info severity, the same as Heroku's log drains.
+	syslogPriority = 190
+
+	// The timeout for connecting to, and writing to, a drain.
+	sendTimeout = 30 * time.Second
+)
+
+// Format formats a log line as an RFC5424 syslog message. The hostname is the
+// drain's token, and the app name is the name of the app.
+func Format(d *empire.LogDrain, t time.Time, line []byte) []byte {
+	appName := "-"
+	if d.App != nil {
+		appName = d.App.Name
+	}
+
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s - - - %s",
+		syslogPriority,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		d.Token,
+		appName,
+		line,
+	))
+}
+
+// frame frames the messages using octet counting, as described in RFC6587,
+// which is used by both syslog over TCP and HTTPS drains.
+func frame(msgs [][]byte) []byte {
+	var b bytes.Buffer
+	for _, msg := range msgs {
+		b.WriteString(strconv.Itoa(len(msg)))
+		b.WriteByte(' ')
+		b.Write(msg)
+	}
+	return b.Bytes()
+}
+
+// Sender sends syslog messages to a drain.
+type Sender interface {
+	// Send sends the messages to the drain.
+	Send([][]byte) error
+
+	// Close closes any connection to the drain.
+	Close() error
+}
+
+// NewSender returns a Sender for the drain, based on the scheme of its URL.
+func NewSender(d *empire.LogDrain) (Sender, error) {
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case empire.LogDrainSyslog:
+		return dialSyslog(u.Host, nil)
+	case empire.LogDrainSyslogTLS:
+		return dialSyslog(u.Host, &tls.Config{ServerName: u.Hostname()})
+	case empire.LogDrainHTTPS:
+		return &httpsSender{
+			url:    d.URL,
+			token:  d.Token,
+			client: &http.Client{Timeout: sendTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log drain scheme: %s", u.Scheme)
+	}
+}
+
+// syslogSender sends messages to a syslog server over TCP, or TLS.
+type syslogSender struct {
+	conn net.Conn
+}
+
+// dialSyslog connects to the syslog server at addr. If config is provided,
+// the connection uses TLS.
+func dialSyslog(addr string, config *tls.Config) (*syslogSender, error) {
+	dialer := &net.Dialer{Timeout: sendTimeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if config != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSender{conn: conn}, nil
+}
+
+func (s *syslogSender) Send(msgs [][]byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	_, err := s.conn.Write(frame(msgs))
+	return err
+}
+
+func (s *syslogSender) Close() error {
+	return s.conn.Close()
+}
+
+// httpsSender posts batches of messages to a URL, in the same format as
+// Heroku's HTTPS drains.
+type httpsSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *httpsSender) Send(msgs [][]byte) error {
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(frame(msgs)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/logplex-1")
+	req.Header.Set("Logplex-Msg-Count", strconv.Itoa(len(msgs)))
+	req.Header.Set("Logplex-Drain-Token", s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", s.url, resp.Status)
+	}
+
+	return nil
+}
+
+func (s *httpsSender) Close() error {
+	return nil
+}
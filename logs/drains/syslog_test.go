@@ -0,0 +1,84 @@
+package drains
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	d := &empire.LogDrain{App: &empire.App{Name: "acme-inc"}, Token: "d.1234"}
+	msg := Format(d, time.Date(2017, 1, 2, 15, 4, 5, 123456000, time.UTC), []byte("Started GET /"))
+	assert.Equal(t, "<190>1 2017-01-02T15:04:05.123456Z d.1234 acme-inc - - - Started GET /", string(msg))
+}
+
+func TestFrame(t *testing.T) {
+	assert.Equal(t, "5 hello3 foo", string(frame([][]byte{[]byte("hello"), []byte("foo")})))
+}
+
+func TestNewSender_Syslog(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		b := make([]byte, 12)
+		_, err = r.Read(b)
+		if err == nil {
+			received <- string(b)
+		}
+	}()
+
+	s, err := NewSender(&empire.LogDrain{URL: "syslog://" + l.Addr().String()})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.Send([][]byte{[]byte("hello"), []byte("foo")}))
+	assert.Equal(t, "5 hello3 foo", <-received)
+}
+
+func TestNewSender_HTTPS(t *testing.T) {
+	var (
+		body    string
+		headers http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		body = string(raw)
+		headers = r.Header
+	}))
+	defer srv.Close()
+
+	s := &httpsSender{url: srv.URL, token: "d.1234", client: http.DefaultClient}
+	assert.NoError(t, s.Send([][]byte{[]byte("hello"), []byte("foo")}))
+
+	assert.Equal(t, "5 hello3 foo", body)
+	assert.Equal(t, "application/logplex-1", headers.Get("Content-Type"))
+	assert.Equal(t, "2", headers.Get("Logplex-Msg-Count"))
+	assert.Equal(t, "d.1234", headers.Get("Logplex-Drain-Token"))
+}
+
+func TestNewSender_HTTPSError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := &httpsSender{url: srv.URL, client: http.DefaultClient}
+	assert.EqualError(t, s.Send([][]byte{[]byte("hello")}), srv.URL+" returned 503 Service Unavailable")
+}
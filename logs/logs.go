@@ -1,6 +1,7 @@
 package logs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -10,16 +11,34 @@ import (
 	"github.com/ejholmes/cloudwatch"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/uuid"
+	"github.com/remind101/empire/pkg/timex"
 	"github.com/remind101/kinesumer"
+	kinesumeriface "github.com/remind101/kinesumer/interface"
 )
 
+// When not following logs, the Kinesis stream is read until no new records
+// arrive for this long.
+const kinesisIdleTimeout = 5 * time.Second
+
 type KinesisLogsStreamer struct{}
 
 func NewKinesisLogsStreamer() *KinesisLogsStreamer {
 	return &KinesisLogsStreamer{}
 }
 
-func (s *KinesisLogsStreamer) StreamLogs(app *empire.App, w io.Writer, duration time.Duration) error {
+// StreamLogs implements the empire.LogsStreamer interface. Records in the
+// Kinesis stream are raw log lines, so logs can't be filtered by process or
+// task.
+func (s *KinesisLogsStreamer) StreamLogs(app *empire.App, w io.Writer, opts empire.StreamLogsOpts) error {
+	if opts.Process != "" || opts.TaskID != "" {
+		return errors.New("the kinesis logs streamer does not support filtering by process or task")
+	}
+
+	var duration time.Duration
+	if opts.Since != nil {
+		duration = timex.Now().Sub(*opts.Since)
+	}
+
 	k, err := kinesumer.NewDefault(app.ID, duration)
 	if err != nil {
 		return fmt.Errorf("error initializing kinesumer: %v", err)
@@ -31,11 +50,36 @@ func (s *KinesisLogsStreamer) StreamLogs(app *empire.App, w io.Writer, duration
 	}
 	defer k.End()
 
+	return streamKinesisRecords(k.Records(), w, opts)
+}
+
+// streamKinesisRecords writes the records to w.
+//
+// Records don't have a timestamp, so the time of a record is approximated from
+// how far behind the tip of the stream it is. When not following, records are
+// written until the stream is idle.
+func streamKinesisRecords(records <-chan kinesumeriface.Record, w io.Writer, opts empire.StreamLogsOpts) error {
 	for {
-		rec := <-k.Records()
-		msg := append(rec.Data(), '\n')
-		if _, err := w.Write(msg); err != nil {
-			return fmt.Errorf("error writing kinesis record to log stream: %v", err)
+		var idle <-chan time.Time
+		if !opts.Follow {
+			idle = time.After(kinesisIdleTimeout)
+		}
+
+		select {
+		case rec := <-records:
+			if opts.Until != nil {
+				t := timex.Now().Add(-time.Duration(rec.MillisBehindLatest()) * time.Millisecond)
+				if !t.Before(*opts.Until) {
+					return nil
+				}
+			}
+
+			msg := append(rec.Data(), '\n')
+			if _, err := w.Write(msg); err != nil {
+				return fmt.Errorf("error writing kinesis record to log stream: %v", err)
+			}
+		case <-idle:
+			return nil
 		}
 	}
 }
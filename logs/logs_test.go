@@ -0,0 +1,48 @@
+package logs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/timex"
+	kinesumeriface "github.com/remind101/kinesumer/interface"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKinesisLogsStreamer_StreamLogs_Unsupported(t *testing.T) {
+	s := NewKinesisLogsStreamer()
+	err := s.StreamLogs(&empire.App{}, new(bytes.Buffer), empire.StreamLogsOpts{Process: "web"})
+	assert.EqualError(t, err, "the kinesis logs streamer does not support filtering by process or task")
+}
+
+func TestStreamKinesisRecords_Until(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	timex.Now = func() time.Time { return now }
+	defer func() { timex.Now = time.Now }()
+
+	records := make(chan kinesumeriface.Record, 3)
+	records <- &fakeRecord{data: "line 1", behind: int64(2 * time.Hour / time.Millisecond)}
+	records <- &fakeRecord{data: "line 2", behind: int64(90 * time.Minute / time.Millisecond)}
+	records <- &fakeRecord{data: "line 3", behind: int64(30 * time.Minute / time.Millisecond)}
+
+	until := now.Add(-time.Hour)
+	w := new(bytes.Buffer)
+	err := streamKinesisRecords(records, w, empire.StreamLogsOpts{Until: &until, Follow: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", w.String())
+}
+
+// fakeRecord is a kinesumeriface.Record implementation for testing.
+type fakeRecord struct {
+	data   string
+	behind int64
+}
+
+func (r *fakeRecord) Data() []byte              { return []byte(r.data) }
+func (r *fakeRecord) PartitionKey() string      { return "" }
+func (r *fakeRecord) SequenceNumber() string    { return "" }
+func (r *fakeRecord) ShardId() string           { return "" }
+func (r *fakeRecord) MillisBehindLatest() int64 { return r.behind }
+func (r *fakeRecord) Done()                     {}
@@ -0,0 +1,45 @@
+package empire
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLogsOpts_Validate(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		opts StreamLogsOpts
+		err  bool
+	}{
+		{StreamLogsOpts{Follow: true}, false},
+		{StreamLogsOpts{Since: &now}, false},
+		{StreamLogsOpts{Since: &now, Until: &later}, false},
+		{StreamLogsOpts{Since: &later, Until: &now}, true},
+		{StreamLogsOpts{}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate(nil)
+		if tt.err {
+			assert.IsType(t, &ValidationError{}, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestFilterWriter(t *testing.T) {
+	b := new(bytes.Buffer)
+	w := &filterWriter{w: b, filter: regexp.MustCompile("GET")}
+
+	w.Write([]byte("Started GET /\nStarted POST /"))
+	w.Write([]byte("\nStarted GET /health\n"))
+
+	assert.Equal(t, "Started GET /\nStarted GET /health\n", b.String())
+}
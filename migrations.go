@@ -695,4 +695,288 @@ ALTER TABLE apps ADD COLUMN exposure TEXT NOT NULL default 'private'`,
 			`ALTER TABLE apps DROP COLUMN deleted_at`,
 		}),
 	},
+
+	// Adds support for automatically rolling back deployments that fail to
+	// stabilize.
+	{
+		ID: 22,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE apps ADD COLUMN auto_rollback boolean`,
+			`ALTER TABLE releases ADD COLUMN failed boolean DEFAULT false NOT NULL`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE apps DROP COLUMN auto_rollback`,
+			`ALTER TABLE releases DROP COLUMN failed`,
+		}),
+	},
+
+	// This migration adds a table to track canary deployments.
+	{
+		ID: 23,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE canaries (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid NOT NULL references apps(id) ON DELETE CASCADE,
+  version integer NOT NULL,
+  stable_version integer NOT NULL,
+  weight integer NOT NULL,
+  promote_at timestamp without time zone,
+  created_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_canaries_on_app_id ON canaries USING btree (app_id)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE canaries`,
+		}),
+	},
+
+	// This migration adds a table to track the progress of deployments.
+	{
+		ID: 24,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE deployments (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid NOT NULL references apps(id) ON DELETE CASCADE,
+  image text NOT NULL,
+  user_name text,
+  state text NOT NULL,
+  version integer,
+  error text,
+  messages json NOT NULL,
+  created_at timestamp without time zone default (now() at time zone 'utc'),
+  updated_at timestamp without time zone,
+  finished_at timestamp without time zone
+)`,
+			`CREATE INDEX index_deployments_on_app_id ON deployments USING btree (app_id)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE deployments`,
+		}),
+	},
+
+	// This migration adds an outbox table, which events are written to
+	// before they're delivered to the configured event streams.
+	{
+		ID: 25,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE outbox_events (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid,
+  name text NOT NULL,
+  message text NOT NULL,
+  data json NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text,
+  next_attempt_at timestamp without time zone NOT NULL,
+  delivered_at timestamp without time zone,
+  dead_at timestamp without time zone,
+  created_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE INDEX index_outbox_events_on_next_attempt_at ON outbox_events USING btree (next_attempt_at) WHERE (delivered_at IS NULL AND dead_at IS NULL)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE outbox_events`,
+		}),
+	},
+
+	// This migration adds a table for the audit log of events.
+	{
+		ID: 26,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE audit_events (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid,
+  app_name text,
+  user_name text,
+  event text NOT NULL,
+  message text NOT NULL,
+  data json NOT NULL,
+  created_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE INDEX index_audit_events_on_created_at ON audit_events USING btree (created_at)`,
+			`CREATE INDEX index_audit_events_on_app_name ON audit_events USING btree (app_name)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE audit_events`,
+		}),
+	},
+
+	// This migration adds a table for per-app event notifications.
+	{
+		ID: 27,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE notifications (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid NOT NULL references apps(id) ON DELETE CASCADE,
+  kind text NOT NULL,
+  target text NOT NULL,
+  events json,
+  created_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE INDEX index_notifications_on_app_id ON notifications USING btree (app_id)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE notifications`,
+		}),
+	},
+
+	// This migration adds a table for log drains.
+	{
+		ID: 28,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE log_drains (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid NOT NULL references apps(id) ON DELETE CASCADE,
+  url text NOT NULL,
+  token text NOT NULL,
+  created_at timestamp without time zone default (now() at time zone 'utc'),
+  updated_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_log_drains_on_app_id_and_url ON log_drains USING btree (app_id, url)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE log_drains`,
+		}),
+	},
+
+	// This migration adds a table for authorization policies.
+	{
+		ID: 29,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE policies (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  name text NOT NULL,
+  document text NOT NULL,
+  created_at timestamp without time zone default (now() at time zone 'utc'),
+  updated_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_policies_on_name ON policies USING btree (name)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE policies`,
+		}),
+	},
+
+	// This migration adds a table for app collaborators.
+	{
+		ID: 30,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE collaborators (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  app_id uuid NOT NULL references apps(id) ON DELETE CASCADE,
+  user_name text NOT NULL,
+  created_at timestamp without time zone default (now() at time zone 'utc'),
+  updated_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_collaborators_on_app_id_and_user_name ON collaborators USING btree (app_id, user_name)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE collaborators`,
+		}),
+	},
+
+	// This migration adds a table for service accounts, which have scoped
+	// API tokens.
+	{
+		ID: 31,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE service_accounts (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  name text NOT NULL,
+  token_hash text NOT NULL,
+  scopes json NOT NULL,
+  created_by text NOT NULL,
+  expires_at timestamp without time zone,
+  last_used_at timestamp without time zone,
+  created_at timestamp without time zone default (now() at time zone 'utc'),
+  updated_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_service_accounts_on_name ON service_accounts USING btree (name)`,
+			`CREATE UNIQUE INDEX index_service_accounts_on_token_hash ON service_accounts USING btree (token_hash)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE service_accounts`,
+		}),
+	},
+
+	// This migration adds a table to track delivery of outbox events to
+	// each event stream.
+	{
+		ID: 32,
+		Up: migrate.Queries([]string{
+			`CREATE TABLE outbox_deliveries (
+  id uuid NOT NULL DEFAULT uuid_generate_v4() primary key,
+  event_id uuid NOT NULL references outbox_events(id) ON DELETE CASCADE,
+  stream text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text,
+  delivered_at timestamp without time zone,
+  created_at timestamp without time zone default (now() at time zone 'utc')
+)`,
+			`CREATE UNIQUE INDEX index_outbox_deliveries_on_event_id_and_stream ON outbox_deliveries USING btree (event_id, stream)`,
+		}),
+		Down: migrate.Queries([]string{
+			`DROP TABLE outbox_deliveries`,
+		}),
+	},
+
+	// This migration records the state that a deployment was in when it was
+	// cancelled.
+	{
+		ID: 33,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE deployments ADD COLUMN cancelled_state text`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE deployments DROP COLUMN cancelled_state`,
+		}),
+	},
+
+	// This migration adds a secret to notifications, which requests to
+	// webhooks are signed with.
+	{
+		ID: 34,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE notifications ADD COLUMN secret text`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE notifications DROP COLUMN secret`,
+		}),
+	},
+
+	// This migration stores the groups of the user that created a service
+	// account, which the service account is authorized with.
+	{
+		ID: 35,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE service_accounts ADD COLUMN groups json`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE service_accounts DROP COLUMN groups`,
+		}),
+	},
+
+	// This migration adds a pending flag to releases, which is set while
+	// the release command for the release runs.
+	{
+		ID: 36,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE releases ADD COLUMN pending boolean DEFAULT false NOT NULL`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE releases DROP COLUMN pending`,
+		}),
+	},
+
+	// This migration stores a credential of the user that created a service
+	// account, which is used to check that they still have access.
+	{
+		ID: 37,
+		Up: migrate.Queries([]string{
+			`ALTER TABLE service_accounts ADD COLUMN creator_token text`,
+		}),
+		Down: migrate.Queries([]string{
+			`ALTER TABLE service_accounts DROP COLUMN creator_token`,
+		}),
+	},
 }
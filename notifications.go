@@ -0,0 +1,297 @@
+package empire
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// The kinds of targets that notifications can be sent to.
+const (
+	// NotificationWebhook posts events to a URL.
+	NotificationWebhook = "webhook"
+
+	// NotificationSNS publishes events to an SNS topic.
+	NotificationSNS = "sns"
+)
+
+// Notification is a subscription to the events for an app. Events are sent to
+// the target of the notification, which can be a webhook URL or an SNS topic.
+type Notification struct {
+	// A unique uuid that identifies this notification.
+	ID string
+
+	// The app that this notification belongs to.
+	AppID string
+	App   *App
+
+	// The kind of target (e.g. "webhook" or "sns").
+	Kind string
+
+	// The URL, or SNS topic ARN, that events are sent to.
+	Target string
+
+	// If provided, only events with these names are sent.
+	Events EventNames
+
+	// The secret that requests to a webhook target are signed with. SNS
+	// targets don't have a secret.
+	Secret string
+
+	// The time that this notification was created.
+	CreatedAt *time.Time
+}
+
+// BeforeCreate sets created_at before inserting.
+func (n *Notification) BeforeCreate() error {
+	t := timex.Now()
+	n.CreatedAt = &t
+	return nil
+}
+
+// Accepts returns true if the event should be sent to this notification's
+// target.
+func (n *Notification) Accepts(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+
+	for _, name := range n.Events {
+		if name == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EventNames is a list of event names.
+type EventNames []string
+
+// Scan implements the sql.Scanner interface.
+func (e *EventNames) Scan(src interface{}) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return error(errors.New("Scan source was not []bytes"))
+	}
+
+	var names EventNames
+	if err := json.Unmarshal(bytes, &names); err != nil {
+		return err
+	}
+	*e = names
+
+	return nil
+}
+
+// Value implements the driver.Value interface.
+func (e EventNames) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(raw), nil
+}
+
+// NotificationsQuery is a scope implementation for common things to filter
+// notifications by.
+type NotificationsQuery struct {
+	// If provided, finds the notification with the given id.
+	ID *string
+
+	// If provided, finds notifications belonging to the given app.
+	App *App
+}
+
+// scope implements the scope interface.
+func (q NotificationsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.App != nil {
+		scope = append(scope, forApp(q.App))
+	}
+
+	scope = append(scope, order("created_at"))
+
+	return scope.scope(db)
+}
+
+// notificationsFind returns the first matching notification.
+func notificationsFind(db *gorm.DB, scope scope) (*Notification, error) {
+	var notification Notification
+	return &notification, first(db, scope, &notification)
+}
+
+// notifications returns all notifications matching the scope.
+func notifications(db *gorm.DB, scope scope) ([]*Notification, error) {
+	var notifications []*Notification
+	return notifications, find(db, scope, &notifications)
+}
+
+// notificationsCreate inserts a new notification.
+func notificationsCreate(db *gorm.DB, n *Notification) (*Notification, error) {
+	return n, db.Create(n).Error
+}
+
+// notificationsDestroy deletes a notification.
+func notificationsDestroy(db *gorm.DB, n *Notification) error {
+	return db.Delete(n).Error
+}
+
+// CreateNotificationOpts are options provided when adding a notification to
+// an app.
+type CreateNotificationOpts struct {
+	// User performing the action.
+	User *User
+
+	// The associated app.
+	App *App
+
+	// The kind of target (e.g. "webhook" or "sns").
+	Kind string
+
+	// The URL, or SNS topic ARN, that events are sent to.
+	Target string
+
+	// If provided, only events with these names are sent.
+	Events []string
+}
+
+func (opts CreateNotificationOpts) Validate(e *Empire) error {
+	switch opts.Kind {
+	case NotificationWebhook:
+		u, err := url.Parse(opts.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ValidationError{Err: fmt.Errorf("%q is not a valid webhook url", opts.Target)}
+		}
+		if privateHost(u.Hostname()) {
+			return &ValidationError{Err: fmt.Errorf("%q is not a public webhook url", opts.Target)}
+		}
+	case NotificationSNS:
+		if !strings.HasPrefix(opts.Target, "arn:aws:sns:") {
+			return &ValidationError{Err: fmt.Errorf("%q is not a valid SNS topic arn", opts.Target)}
+		}
+		if !e.NotificationSNSTopicAllowed(opts.Target) {
+			return &ValidationError{Err: fmt.Errorf("notifications can't be sent to the SNS topic %s", opts.Target)}
+		}
+	default:
+		return &ValidationError{Err: fmt.Errorf("notifications can only be sent to a %s or %s target", NotificationWebhook, NotificationSNS)}
+	}
+	return nil
+}
+
+// CreateNotification adds a notification to an app, which sends the app's
+// events to the target.
+func (e *Empire) CreateNotification(ctx context.Context, opts CreateNotificationOpts) (*Notification, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	n := &Notification{
+		AppID:  opts.App.ID,
+		Kind:   opts.Kind,
+		Target: opts.Target,
+	}
+	if len(opts.Events) > 0 {
+		n.Events = EventNames(opts.Events)
+	}
+
+	if n.Kind == NotificationWebhook {
+		secret, err := generateNotificationSecret()
+		if err != nil {
+			return nil, err
+		}
+		n.Secret = secret
+	}
+
+	return notificationsCreate(e.db, n)
+}
+
+// NotificationSNSTopicAllowed returns true if notifications can publish to the
+// SNS topic, because it matches one of the NotificationSNSTopics.
+func (e *Empire) NotificationSNSTopicAllowed(arn string) bool {
+	for _, prefix := range e.NotificationSNSTopics {
+		if strings.HasPrefix(arn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivateIP returns true if the ip is a loopback, link-local, private or
+// unspecified address. Notifications can't be sent to these addresses, since
+// they could be used to reach services on Empire's network.
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// privateHost returns true if the host is a private ip address, or a name for
+// the local host. Names that resolve to private addresses are rejected when
+// the request is made.
+func privateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPrivateIP(ip)
+	}
+
+	return false
+}
+
+// generateNotificationSecret generates a new random secret for signing webhook
+// requests.
+func generateNotificationSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating secret: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// DestroyNotificationOpts are options provided when removing a notification
+// from an app.
+type DestroyNotificationOpts struct {
+	// User performing the action.
+	User *User
+
+	// The notification to remove.
+	Notification *Notification
+}
+
+// DestroyNotification removes a notification from an app.
+func (e *Empire) DestroyNotification(ctx context.Context, opts DestroyNotificationOpts) error {
+	return notificationsDestroy(e.db, opts.Notification)
+}
+
+// NotificationsFind returns the first notification matching the query.
+func (e *Empire) NotificationsFind(q NotificationsQuery) (*Notification, error) {
+	return notificationsFind(e.db, q)
+}
+
+// Notifications returns all notifications matching the query.
+func (e *Empire) Notifications(q NotificationsQuery) ([]*Notification, error) {
+	return notifications(e.db, q)
+}
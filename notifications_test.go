@@ -0,0 +1,37 @@
+package empire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNotificationOpts_Validate(t *testing.T) {
+	e := &Empire{NotificationSNSTopics: []string{"arn:aws:sns:us-east-1:123456789012:"}}
+
+	tests := []struct {
+		kind   string
+		target string
+		err    string
+	}{
+		{"webhook", "https://example.com/deploys", ""},
+		{"webhook", "ftp://example.com/deploys", `"ftp://example.com/deploys" is not a valid webhook url`},
+		{"webhook", "http://localhost:8080/deploys", `"http://localhost:8080/deploys" is not a public webhook url`},
+		{"webhook", "http://127.0.0.1/deploys", `"http://127.0.0.1/deploys" is not a public webhook url`},
+		{"webhook", "http://10.0.0.1/deploys", `"http://10.0.0.1/deploys" is not a public webhook url`},
+		{"webhook", "http://169.254.169.254/latest/meta-data", `"http://169.254.169.254/latest/meta-data" is not a public webhook url`},
+		{"webhook", "http://[::1]/deploys", `"http://[::1]/deploys" is not a public webhook url`},
+		{"sns", "arn:aws:sns:us-east-1:123456789012:deploys", ""},
+		{"sns", "arn:aws:sns:us-east-1:210987654321:deploys", "notifications can't be sent to the SNS topic arn:aws:sns:us-east-1:210987654321:deploys"},
+		{"sns", "deploys", `"deploys" is not a valid SNS topic arn`},
+	}
+
+	for _, tt := range tests {
+		err := CreateNotificationOpts{Kind: tt.kind, Target: tt.target}.Validate(e)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.target)
+		} else {
+			assert.EqualError(t, err, tt.err, tt.target)
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package empire
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The number of times delivery of an event is attempted before it's
+	// dead-lettered.
+	outboxMaxAttempts = 15
+
+	// The delay before the first retry of an event that failed to be
+	// delivered. The delay doubles for each attempt after that.
+	outboxBaseBackoff = 5 * time.Second
+
+	// The maximum delay between attempts to deliver an event.
+	outboxMaxBackoff = time.Hour
+
+	// The number of events that are claimed for delivery at once.
+	outboxBatchSize = 100
+
+	// How long a claimed event is leased to the Empire instance that claimed
+	// it. If the instance dies before recording the outcome, the event is
+	// delivered again once the lease expires.
+	outboxLeaseDuration = 5 * time.Minute
+)
+
+// OutboxEvent is an event that has been written to the outbox. Events are
+// written to the outbox in the same transaction as the operation that
+// triggered them, and are delivered to the EventStream in the background by
+// DeliverEvents.
+type OutboxEvent struct {
+	// A unique uuid that identifies this event.
+	ID string
+
+	// The app that this event relates to, if any.
+	AppID *string
+
+	// The name of the event (e.g. "deploy").
+	Name string
+
+	// The human readable message for the event.
+	Message string
+
+	// The JSON representation of the event.
+	Data string `sql:"type:json"`
+
+	// The number of times that delivery of this event has been attempted.
+	Attempts int
+
+	// The error from the last failed attempt to deliver this event.
+	LastError *string
+
+	// The time after which delivery of this event should be attempted.
+	NextAttemptAt time.Time
+
+	// The time that this event was delivered.
+	DeliveredAt *time.Time
+
+	// The time that this event was dead-lettered, after failing to be
+	// delivered too many times.
+	DeadAt *time.Time
+
+	CreatedAt *time.Time
+}
+
+// BeforeCreate sets created_at before inserting.
+func (e *OutboxEvent) BeforeCreate() error {
+	t := timex.Now()
+	e.CreatedAt = &t
+	if e.NextAttemptAt.IsZero() {
+		e.NextAttemptAt = t
+	}
+	return nil
+}
+
+// Dead returns true if this event has been dead-lettered.
+func (e *OutboxEvent) Dead() bool {
+	return e.DeadAt != nil
+}
+
+// OutboxEventsQuery is a scope implementation for common things to filter
+// outbox events by.
+type OutboxEventsQuery struct {
+	// If provided, finds the event with the given id.
+	ID *string
+
+	// If true, only finds events that haven't been delivered, including
+	// events that have been dead-lettered.
+	Undelivered bool
+}
+
+// scope implements the scope interface.
+func (q OutboxEventsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.Undelivered {
+		scope = append(scope, isNull("delivered_at"))
+	}
+
+	scope = append(scope, order("created_at"))
+
+	return scope.scope(db)
+}
+
+// outboxEventsFind returns the first matching outbox event.
+func outboxEventsFind(db *gorm.DB, scope scope) (*OutboxEvent, error) {
+	var event OutboxEvent
+	return &event, first(db, scope, &event)
+}
+
+// outboxEvents returns all outbox events matching the scope.
+func outboxEvents(db *gorm.DB, scope scope) ([]*OutboxEvent, error) {
+	var events []*OutboxEvent
+	return events, find(db, scope, &events)
+}
+
+// outboxEventsCreate writes the event to the outbox.
+func outboxEventsCreate(db *gorm.DB, event Event) (*OutboxEvent, error) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &OutboxEvent{
+		Name:    event.Event(),
+		Message: event.String(),
+		Data:    string(raw),
+	}
+
+	if event, ok := event.(AppEvent); ok {
+		if app := event.GetApp(); app != nil && app.ID != "" {
+			e.AppID = &app.ID
+		}
+	}
+
+	return e, db.Create(e).Error
+}
+
+// outboxEventsClaim claims the events that are due to be delivered, by moving
+// their next_attempt_at forward until the lease expires. The claim is a single
+// statement, so no locks are held while the events are delivered. Events that
+// are being claimed by another instance are skipped.
+func outboxEventsClaim(db *gorm.DB, now, until time.Time, limit int) ([]*OutboxEvent, error) {
+	var events []*OutboxEvent
+	if err := db.Raw(`UPDATE outbox_events SET next_attempt_at = ?
+WHERE id IN (
+  SELECT id FROM outbox_events
+  WHERE delivered_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+  ORDER BY created_at
+  LIMIT ?
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *`, until, now, limit).Scan(&events).Error; err != nil {
+		return nil, err
+	}
+
+	sort.Sort(outboxEventsByCreatedAt(events))
+	return events, nil
+}
+
+// outboxEventsByCreatedAt sorts events in the order they were created.
+type outboxEventsByCreatedAt []*OutboxEvent
+
+func (s outboxEventsByCreatedAt) Len() int      { return len(s) }
+func (s outboxEventsByCreatedAt) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s outboxEventsByCreatedAt) Less(i, j int) bool {
+	return s[i].CreatedAt.Before(*s[j].CreatedAt)
+}
+
+// outboxEventsUpdate updates an existing outbox event.
+func outboxEventsUpdate(db *gorm.DB, e *OutboxEvent) error {
+	return db.Save(e).Error
+}
+
+// OutboxDelivery records the delivery of an outbox event to a single event
+// stream. Streams that an event was delivered to are skipped when delivery of
+// the event is retried.
+type OutboxDelivery struct {
+	// A unique uuid that identifies this delivery.
+	ID string
+
+	// The outbox event that this delivery is for.
+	EventID string
+
+	// The name of the event stream.
+	Stream string
+
+	// The number of times that delivery to this stream has been attempted.
+	Attempts int
+
+	// The error from the last failed attempt to deliver to this stream.
+	LastError *string
+
+	// The time that the event was delivered to this stream.
+	DeliveredAt *time.Time
+
+	CreatedAt *time.Time
+}
+
+// BeforeCreate sets created_at before inserting.
+func (d *OutboxDelivery) BeforeCreate() error {
+	t := timex.Now()
+	d.CreatedAt = &t
+	return nil
+}
+
+// OutboxDeliveriesQuery is a scope implementation for common things to filter
+// outbox deliveries by.
+type OutboxDeliveriesQuery struct {
+	// If provided, finds the deliveries for the event with the given id.
+	EventID *string
+}
+
+// scope implements the scope interface.
+func (q OutboxDeliveriesQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.EventID != nil {
+		scope = append(scope, fieldEquals("event_id", *q.EventID))
+	}
+
+	scope = append(scope, order("stream"))
+
+	return scope.scope(db)
+}
+
+// outboxDeliveries returns all outbox deliveries matching the scope.
+func outboxDeliveries(db *gorm.DB, scope scope) ([]*OutboxDelivery, error) {
+	var deliveries []*OutboxDelivery
+	return deliveries, find(db, scope, &deliveries)
+}
+
+// outboxDeliveriesUpdate inserts or updates an outbox delivery.
+func outboxDeliveriesUpdate(db *gorm.DB, d *OutboxDelivery) error {
+	if d.ID == "" {
+		return db.Create(d).Error
+	}
+	return db.Save(d).Error
+}
+
+// outboxBackoff returns how long to wait before attempting to deliver an event
+// again, after the given number of failed attempts.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= outboxMaxBackoff {
+			return outboxMaxBackoff
+		}
+	}
+	return d
+}
+
+// storedEvent adapts an OutboxEvent to the Event interface, so that it can be
+// delivered to an EventStream.
+type storedEvent struct {
+	e *OutboxEvent
+}
+
+func (e storedEvent) Event() string {
+	return e.e.Name
+}
+
+func (e storedEvent) String() string {
+	return e.e.Message
+}
+
+// MarshalJSON returns the JSON representation of the original event.
+func (e storedEvent) MarshalJSON() ([]byte, error) {
+	return []byte(e.e.Data), nil
+}
+
+func (e storedEvent) outboxEvent() *OutboxEvent {
+	return e.e
+}
+
+// outboxEventer is implemented by events that were delivered from the outbox.
+type outboxEventer interface {
+	outboxEvent() *OutboxEvent
+}
+
+// EventID returns the id of the outbox event that the event was delivered
+// from, or an empty string if it wasn't delivered from the outbox. The id is
+// the same each time delivery of an event is attempted, so consumers can use it
+// to ignore events that they've already received.
+func EventID(event Event) string {
+	if o, ok := event.(outboxEventer); ok {
+		return o.outboxEvent().ID
+	}
+	return ""
+}
+
+// storedAppEvent is a storedEvent that relates to an App.
+type storedAppEvent struct {
+	storedEvent
+	app *App
+}
+
+func (e storedAppEvent) GetApp() *App {
+	return e.app
+}
+
+// PublishEvent writes the event to the outbox, to be delivered to the
+// EventStream by DeliverEvents.
+func (e *Empire) PublishEvent(event Event) error {
+	return e.publishEvent(e.db, event)
+}
+
+// publishEvent writes the event to the outbox within the given transaction.
+func (e *Empire) publishEvent(db *gorm.DB, event Event) error {
+	_, err := outboxEventsCreate(db, event)
+	return err
+}
+
+// OutboxEventsFind returns the first event in the outbox matching the query.
+func (e *Empire) OutboxEventsFind(q OutboxEventsQuery) (*OutboxEvent, error) {
+	return outboxEventsFind(e.db, q)
+}
+
+// OutboxEvents returns the events in the outbox matching the query.
+func (e *Empire) OutboxEvents(q OutboxEventsQuery) ([]*OutboxEvent, error) {
+	return outboxEvents(e.db, q)
+}
+
+// OutboxDeliveries returns the status of delivery to each event stream
+// matching the query.
+func (e *Empire) OutboxDeliveries(q OutboxDeliveriesQuery) ([]*OutboxDelivery, error) {
+	return outboxDeliveries(e.db, q)
+}
+
+// RetryOutboxEvent requeues an event that was dead-lettered, so that delivery
+// is attempted again.
+func (e *Empire) RetryOutboxEvent(ctx context.Context, id string) (*OutboxEvent, error) {
+	event, err := e.OutboxEventsFind(OutboxEventsQuery{ID: &id})
+	if err != nil {
+		return event, err
+	}
+
+	if !event.Dead() {
+		return event, &ValidationError{Err: fmt.Errorf("event %s has not been dead-lettered", id)}
+	}
+
+	event.Attempts = 0
+	event.DeadAt = nil
+	event.NextAttemptAt = timex.Now()
+	return event, outboxEventsUpdate(e.db, event)
+}
+
+// DeliverEvents delivers the events in the outbox that are due to the
+// EventStream. Events that fail to be delivered are retried with exponential
+// backoff, and are dead-lettered after too many failed attempts.
+func (e *Empire) DeliverEvents(ctx context.Context) error {
+	for {
+		n, err := e.deliverEvents(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// deliverEvents delivers a single batch of events, returning the number of
+// events that delivery was attempted for.
+func (e *Empire) deliverEvents(ctx context.Context) (int, error) {
+	now := timex.Now()
+	events, err := outboxEventsClaim(e.db, now, now.Add(outboxLeaseDuration), outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := e.deliverEvent(event); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// deliverEvent attempts to deliver the event to each of the event streams that
+// it hasn't already been delivered to, and records the outcome.
+func (e *Empire) deliverEvent(event *OutboxEvent) error {
+	var stored Event = storedEvent{event}
+	if event.AppID != nil {
+		app, err := appsFind(e.db, AppsQuery{ID: event.AppID})
+		switch err {
+		case nil:
+			stored = storedAppEvent{storedEvent: storedEvent{event}, app: app}
+		case gorm.RecordNotFound:
+			// The app has since been destroyed.
+		default:
+			return err
+		}
+	}
+
+	deliveries, err := outboxDeliveries(e.db, OutboxDeliveriesQuery{EventID: &event.ID})
+	if err != nil {
+		return err
+	}
+
+	byStream := make(map[string]*OutboxDelivery)
+	for _, d := range deliveries {
+		byStream[d.Stream] = d
+	}
+
+	now := timex.Now()
+	event.Attempts++
+
+	var errs []error
+
+	// If the targets of the event can't be determined, the event is retried
+	// like any other failure.
+	sinks, err := eventStreamSinks(e.EventStream, stored)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, s := range sinks {
+		d := byStream[s.Name]
+		if d == nil {
+			d = &OutboxDelivery{EventID: event.ID, Stream: s.Name}
+		}
+
+		if d.DeliveredAt != nil {
+			continue
+		}
+
+		d.Attempts++
+		if err := safePublishEvent(s.EventStream, stored); err != nil {
+			msg := err.Error()
+			d.LastError = &msg
+			errs = append(errs, err)
+		} else {
+			d.LastError = nil
+			d.DeliveredAt = &now
+		}
+
+		if err := outboxDeliveriesUpdate(e.db, d); err != nil {
+			return err
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		event.LastError = nil
+		event.DeliveredAt = &now
+	default:
+		var err error = &multiError{Errors: errs}
+		if len(errs) == 1 {
+			err = errs[0]
+		}
+		msg := err.Error()
+		event.LastError = &msg
+		if event.Attempts >= outboxMaxAttempts {
+			event.DeadAt = &now
+		} else {
+			event.NextAttemptAt = now.Add(outboxBackoff(event.Attempts))
+		}
+	}
+
+	return outboxEventsUpdate(e.db, event)
+}
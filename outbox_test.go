@@ -0,0 +1,65 @@
+package empire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{10, 2560 * time.Second},
+		{11, time.Hour},
+		{14, time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.backoff, outboxBackoff(tt.attempts))
+	}
+}
+
+func TestEventStreamSinks(t *testing.T) {
+	a := EventStreamFunc(func(event Event) error { return nil })
+	b := fakeEventSinker{"1", "2"}
+
+	sinks, err := eventStreamSinks(a, RestartEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default"}, eventStreamNames(sinks))
+
+	sinks, err = eventStreamSinks(MultiEventStream{
+		NamedEventStream{Name: "a", EventStream: a},
+		NamedEventStream{Name: "b", EventStream: b},
+		a,
+	}, RestartEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b/1", "b/2", "2"}, eventStreamNames(sinks))
+}
+
+type fakeEventSinker []string
+
+func (s fakeEventSinker) PublishEvent(event Event) error {
+	return nil
+}
+
+func (s fakeEventSinker) EventSinks(event Event) ([]NamedEventStream, error) {
+	var sinks []NamedEventStream
+	for _, name := range s {
+		sinks = append(sinks, NamedEventStream{Name: name, EventStream: s})
+	}
+	return sinks, nil
+}
+
+func eventStreamNames(sinks []NamedEventStream) []string {
+	var names []string
+	for _, s := range sinks {
+		names = append(names, s.Name)
+	}
+	return names
+}
@@ -29,6 +29,10 @@ type App struct {
 	// maintenance status of app
 	Maintenance bool `json:"maintenance"`
 
+	// whether failed deployments are automatically rolled back. nil if the
+	// server default is used.
+	AutoRollback *bool `json:"auto_rollback"`
+
 	// unique name of app
 	Name string `json:"name"`
 
@@ -138,6 +142,8 @@ func (c *Client) AppUpdate(appIdentity string, options *AppUpdateOpts, message s
 type AppUpdateOpts struct {
 	// maintenance status of app
 	Maintenance *bool `json:"maintenance,omitempty"`
+	// whether failed deployments are automatically rolled back
+	AutoRollback *bool `json:"auto_rollback,omitempty"`
 	// unique name of app
 	Name *string `json:"name,omitempty"`
 	// DEPRECATED:
@@ -0,0 +1,50 @@
+package heroku
+
+import "time"
+
+// Canary represents a release that's being gradually rolled out alongside the
+// stable release of an app.
+type Canary struct {
+	// The version of the canary release.
+	Version int `json:"version"`
+
+	// The version of the release that receives the rest of the traffic.
+	StableVersion int `json:"stable_version"`
+
+	// The percentage of traffic that's sent to the canary release.
+	Weight int `json:"weight"`
+
+	// If set, the time that the canary will be automatically promoted.
+	PromoteAt *time.Time `json:"promote_at"`
+
+	// When the canary was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CanaryInfo returns the canary that's in progress for an app.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) CanaryInfo(appIdentity string) (*Canary, error) {
+	var canary Canary
+	return &canary, c.Get(&canary, "/apps/"+appIdentity+"/canary")
+}
+
+// CanaryPromote promotes the canary that's in progress for an app, sending all
+// traffic to the canary release.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) CanaryPromote(appIdentity, message string) (*Release, error) {
+	rh := RequestHeaders{CommitMessage: message}
+	var releaseRes Release
+	return &releaseRes, c.PostWithHeaders(&releaseRes, "/apps/"+appIdentity+"/canary/promote", nil, rh.Headers())
+}
+
+// CanaryAbort aborts the canary that's in progress for an app, and rolls back
+// to the stable release.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) CanaryAbort(appIdentity, message string) (*Release, error) {
+	rh := RequestHeaders{CommitMessage: message}
+	var releaseRes Release
+	return &releaseRes, c.PostWithHeaders(&releaseRes, "/apps/"+appIdentity+"/canary/abort", nil, rh.Headers())
+}
@@ -0,0 +1,75 @@
+package heroku
+
+import "time"
+
+// Deployment represents a deployment of an image to an app, which is performed
+// in the background.
+type Deployment struct {
+	// Unique identifier of the deployment.
+	Id string `json:"id"`
+
+	// The app that's being deployed to.
+	App struct {
+		Name string `json:"name"`
+	} `json:"app"`
+
+	// The image that's being deployed.
+	Image string `json:"image"`
+
+	// The current state of the deployment. One of "queued", "building",
+	// "submitting", "stabilizing", "succeeded", "failed" or "cancelled".
+	State string `json:"state"`
+
+	// The version of the release that was created, once it's been created.
+	Version *int `json:"version"`
+
+	// If the deployment failed, or was cancelled, the reason why.
+	Error string `json:"error"`
+
+	// The status messages that were written during the deployment.
+	Messages []DeploymentMessage `json:"messages"`
+
+	// When the deployment was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// When the deployment was last updated.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// When the deployment finished, if it has finished.
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// DeploymentMessage is a status message that was written during a deployment.
+type DeploymentMessage struct {
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Finished returns true if the deployment has succeeded, failed, or was
+// cancelled.
+func (d *Deployment) Finished() bool {
+	return d.State == "succeeded" || d.State == "failed" || d.State == "cancelled"
+}
+
+// DeploymentInfo returns a deployment of an app.
+//
+// appIdentity is the unique identifier of the App. deploymentIdentity is the
+// unique identifier of the Deployment.
+func (c *Client) DeploymentInfo(appIdentity, deploymentIdentity string) (*Deployment, error) {
+	var deployment Deployment
+	return &deployment, c.Get(&deployment, "/apps/"+appIdentity+"/deployments/"+deploymentIdentity)
+}
+
+// DeploymentCancel cancels a deployment of an app that's in progress. If
+// deploymentIdentity is empty, the most recent deployment that's in progress is
+// cancelled.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) DeploymentCancel(appIdentity, deploymentIdentity, message string) (*Deployment, error) {
+	params := struct {
+		Id string `json:"id,omitempty"`
+	}{Id: deploymentIdentity}
+	rh := RequestHeaders{CommitMessage: message}
+	var deployment Deployment
+	return &deployment, c.PostWithHeaders(&deployment, "/apps/"+appIdentity+"/deployments/cancel", params, rh.Headers())
+}
@@ -50,6 +50,21 @@ type Dyno struct {
 
 	// when process last changed state
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// sidecar containers running alongside this process
+	Sidecars []DynoSidecar `json:"sidecars,omitempty"`
+}
+
+// DynoSidecar represents a sidecar container running alongside a dyno.
+type DynoSidecar struct {
+	// the name of the sidecar
+	Name string `json:"name"`
+
+	// command used to start this sidecar
+	Command string `json:"command"`
+
+	// current status of the sidecar
+	State string `json:"state"`
 }
 
 // Create a new dyno.
@@ -0,0 +1,149 @@
+package heroku
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Event is an entry in the audit log of events that happened within Empire.
+type Event struct {
+	// Unique identifier of the event.
+	Id string `json:"id"`
+
+	// The name of the app that the event relates to, if any.
+	App *string `json:"app"`
+
+	// The name of the user that triggered the event, if any.
+	User *string `json:"user"`
+
+	// The name of the event (e.g. "deploy").
+	Event string `json:"event"`
+
+	// The human readable message for the event.
+	Message string `json:"message"`
+
+	// The JSON representation of the event.
+	Data json.RawMessage `json:"data"`
+
+	// When the event happened.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventListOpts holds the optional parameters for EventList.
+type EventListOpts struct {
+	// If provided, only lists events triggered by this user.
+	User *string
+
+	// If provided, only lists events with this name.
+	Event *string
+
+	// If provided, only lists events that happened at or after this time.
+	Since *time.Time
+
+	// If provided, only lists events that happened before this time.
+	Until *time.Time
+}
+
+// EventList lists events from the audit log, most recent first.
+//
+// appIdentity is the unique identifier of the App. If empty, events for all
+// apps are listed.
+func (c *Client) EventList(appIdentity string, options *EventListOpts, lr *ListRange) ([]Event, error) {
+	path := "/events"
+	if appIdentity != "" {
+		path = "/apps/" + appIdentity + "/events"
+	}
+
+	if options != nil {
+		params := url.Values{}
+		if options.User != nil {
+			params.Set("user", *options.User)
+		}
+		if options.Event != nil {
+			params.Set("event", *options.Event)
+		}
+		if options.Since != nil {
+			params.Set("since", options.Since.Format(time.RFC3339))
+		}
+		if options.Until != nil {
+			params.Set("until", options.Until.Format(time.RFC3339))
+		}
+		if len(params) > 0 {
+			path += "?" + params.Encode()
+		}
+	}
+
+	req, err := c.NewRequest("GET", path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if lr != nil {
+		lr.SetHeader(req)
+	}
+
+	var eventsRes []Event
+	return eventsRes, c.DoReq(req, &eventsRes)
+}
+
+// EventStream streams events as they happen, calling fn for each one, until
+// the stream is closed or fn returns an error. Events are sent by the server
+// as Server-Sent Events.
+//
+// appIdentity is the unique identifier of the App. If empty, events for all
+// apps are streamed.
+func (c *Client) EventStream(appIdentity string, fn func(Event) error) error {
+	path := "/events/stream"
+	if appIdentity != "" {
+		path = "/apps/" + appIdentity + "/events/stream"
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(c.Get(w, path))
+	}()
+
+	err := readEvents(r, fn)
+	r.CloseWithError(err)
+	return err
+}
+
+// readEvents reads Server-Sent Events from r, and calls fn with the data of
+// each event, decoded as an Event.
+func readEvents(r io.Reader, fn func(Event) error) error {
+	var data bytes.Buffer
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Bytes()
+
+		switch {
+		case len(line) == 0:
+			// A blank line dispatches the event.
+			if data.Len() == 0 {
+				continue
+			}
+
+			var event Event
+			if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+				return err
+			}
+			data.Reset()
+
+			if err := fn(event); err != nil {
+				return err
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+	}
+
+	return s.Err()
+}
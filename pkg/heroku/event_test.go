@@ -0,0 +1,50 @@
+package heroku
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": connected\n\n" +
+		"id: 1\nevent: deploy\ndata: {\"id\":\"1\",\"event\":\"deploy\"}\n\n" +
+		": keepalive\n\n" +
+		"id: 2\nevent: scale\ndata: {\"id\":\"2\",\ndata: \"event\":\"scale\"}\n\n"
+
+	var events []Event
+	err := readEvents(strings.NewReader(stream), func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Id != "1" || events[0].Event != "deploy" {
+		t.Errorf("unexpected event: %#v", events[0])
+	}
+	if events[1].Id != "2" || events[1].Event != "scale" {
+		t.Errorf("unexpected event: %#v", events[1])
+	}
+}
+
+func TestReadEvents_Error(t *testing.T) {
+	stream := "data: {\"id\":\"1\"}\n\ndata: {\"id\":\"2\"}\n\n"
+
+	errStop := errors.New("stop")
+	var n int
+	err := readEvents(strings.NewReader(stream), func(e Event) error {
+		n++
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("expected %v, got %v", errStop, err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 event, got %d", n)
+	}
+}
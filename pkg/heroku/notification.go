@@ -0,0 +1,63 @@
+package heroku
+
+import "time"
+
+// Notification is a subscription to the events for an app.
+type Notification struct {
+	// Unique identifier of the notification.
+	Id string `json:"id"`
+
+	// The kind of target that events are sent to. One of "webhook" or
+	// "sns".
+	Kind string `json:"kind"`
+
+	// The URL, or SNS topic ARN, that events are sent to.
+	Target string `json:"target"`
+
+	// If not empty, only events with these names are sent.
+	Events []string `json:"events"`
+
+	// The secret that requests to a webhook are signed with. This is only
+	// returned when the notification is created.
+	Secret string `json:"secret,omitempty"`
+
+	// When the notification was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationCreateOpts holds the parameters for NotificationCreate.
+type NotificationCreateOpts struct {
+	// The kind of target that events are sent to. One of "webhook" or
+	// "sns".
+	Kind string `json:"kind"`
+
+	// The URL, or SNS topic ARN, that events are sent to.
+	Target string `json:"target"`
+
+	// If not empty, only events with these names are sent.
+	Events []string `json:"events,omitempty"`
+}
+
+// NotificationCreate adds a notification to an app.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) NotificationCreate(appIdentity string, options NotificationCreateOpts) (*Notification, error) {
+	var notification Notification
+	return &notification, c.Post(&notification, "/apps/"+appIdentity+"/notifications", options)
+}
+
+// NotificationDelete removes a notification from an app.
+//
+// appIdentity is the unique identifier of the App. notificationIdentity is the
+// unique identifier of the Notification.
+func (c *Client) NotificationDelete(appIdentity, notificationIdentity string) error {
+	return c.Delete("/apps/" + appIdentity + "/notifications/" + notificationIdentity)
+}
+
+// NotificationList lists the notifications for an app.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) NotificationList(appIdentity string) ([]Notification, error) {
+	var notifications []Notification
+	return notifications, c.Get(&notifications, "/apps/"+appIdentity+"/notifications")
+}
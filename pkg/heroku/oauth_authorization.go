@@ -29,6 +29,12 @@ type OAuthAuthorization struct {
 	// when OAuth authorization was created
 	CreatedAt time.Time `json:"created_at"`
 
+	// human-friendly description of this OAuth authorization
+	Description string `json:"description"`
+
+	// name of the user that created this OAuth authorization
+	CreatedBy string `json:"created_by,omitempty"`
+
 	// this authorization's grant
 	Grant *struct {
 		Code      string `json:"code"`
@@ -39,6 +45,9 @@ type OAuthAuthorization struct {
 	// unique identifier of OAuth authorization
 	Id string `json:"id"`
 
+	// when the OAuth authorization's token was last used
+	LastUsedAt *time.Time `json:"last_used_at"`
+
 	// refresh token for this authorization
 	RefreshToken *struct {
 		ExpiresIn *int   `json:"expires_in"`
@@ -0,0 +1,48 @@
+package heroku
+
+import "time"
+
+// OutboxEvent is an event that hasn't been delivered to the configured event
+// streams yet.
+type OutboxEvent struct {
+	// Unique identifier of the event.
+	Id string `json:"id"`
+
+	// The name of the event (e.g. "deploy").
+	Event string `json:"event"`
+
+	// The human readable message for the event.
+	Message string `json:"message"`
+
+	// The number of times that delivery of the event has been attempted.
+	Attempts int `json:"attempts"`
+
+	// The error from the last failed attempt to deliver the event.
+	LastError *string `json:"last_error"`
+
+	// When delivery of the event will next be attempted.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+
+	// If set, the time that the event was dead-lettered, after failing to
+	// be delivered too many times. Dead-lettered events are not retried
+	// until they're requeued.
+	DeadAt *time.Time `json:"dead_at"`
+
+	// When the event was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxEventList lists the events that haven't been delivered.
+func (c *Client) OutboxEventList() ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	return events, c.Get(&events, "/admin/events/outbox")
+}
+
+// OutboxEventRetry requeues an event that was dead-lettered, so that delivery
+// is attempted again.
+//
+// eventIdentity is the unique identifier of the OutboxEvent.
+func (c *Client) OutboxEventRetry(eventIdentity string) (*OutboxEvent, error) {
+	var event OutboxEvent
+	return &event, c.Post(&event, "/admin/events/outbox/"+eventIdentity+"/retry", nil)
+}
@@ -0,0 +1,52 @@
+package heroku
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Policy is a named authorization policy, which controls the actions that
+// users can perform on apps.
+type Policy struct {
+	// Unique identifier of the policy.
+	Id string `json:"id"`
+
+	// The unique name of the policy.
+	Name string `json:"name"`
+
+	// The policy document.
+	Document json.RawMessage `json:"document"`
+
+	// When the policy was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// When the policy was last updated.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PolicyList lists the policies that are stored in the database.
+func (c *Client) PolicyList() ([]Policy, error) {
+	var policies []Policy
+	return policies, c.Get(&policies, "/admin/policies")
+}
+
+// PolicyPut creates or replaces a policy.
+//
+// policyName is the unique name of the Policy. document is the policy
+// document.
+func (c *Client) PolicyPut(policyName string, document json.RawMessage) (*Policy, error) {
+	params := struct {
+		Document json.RawMessage `json:"document"`
+	}{
+		Document: document,
+	}
+	var policy Policy
+	return &policy, c.Put(&policy, "/admin/policies/"+policyName, params)
+}
+
+// PolicyDelete deletes a policy.
+//
+// policyName is the unique name of the Policy.
+func (c *Client) PolicyDelete(policyName string) error {
+	return c.Delete("/admin/policies/" + policyName)
+}
@@ -17,6 +17,9 @@ type Release struct {
 	// description of changes in this release
 	Description string `json:"description"`
 
+	// current status of the release. One of "succeeded" or "failed".
+	Status string `json:"status"`
+
 	// unique identifier of release
 	Id string `json:"id"`
 
@@ -0,0 +1,22 @@
+package heroku
+
+// Scheduler represents the scheduler backend that an app is running on.
+type Scheduler struct {
+	// The name of the scheduler backend.
+	Backend string `json:"backend"`
+}
+
+// SchedulerInfo returns the scheduler backend that an app is running on.
+//
+// appIdentity is the unique identifier of the App.
+func (c *Client) SchedulerInfo(appIdentity string) (*Scheduler, error) {
+	var scheduler Scheduler
+	return &scheduler, c.Get(&scheduler, "/apps/"+appIdentity+"/scheduler")
+}
+
+// SchedulerMigrateOpts are the options for migrating an app to a different
+// scheduler backend.
+type SchedulerMigrateOpts struct {
+	// The name of the scheduler backend to migrate to.
+	Backend string `json:"backend"`
+}
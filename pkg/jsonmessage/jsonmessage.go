@@ -29,6 +29,7 @@ func (w *Stream) Encode(m JSONMessage) error {
 }
 
 type JSONMessage struct {
+	Stream       string     `json:"stream,omitempty"`
 	Status       string     `json:"status,omitempty"`
 	Error        *JSONError `json:"errorDetail,omitempty"`
 	ErrorMessage string     `json:"error,omitempty"` //deprecated
@@ -26,6 +26,10 @@ type AdvisoryLock struct {
 	// timeout.
 	LockTimeout time.Duration
 
+	// When true, the lock is obtained in shared mode. Shared locks don't
+	// block each other, but block (and are blocked by) exclusive locks.
+	Shared bool
+
 	// The advisory lock key.
 	key uint32
 
@@ -86,7 +90,7 @@ func (l *AdvisoryLock) Lock() error {
 		}
 	}
 
-	_, err := l.tx.Exec(fmt.Sprintf("SELECT pg_advisory_lock($1) /* %s */", l.Context), l.key)
+	_, err := l.tx.Exec(fmt.Sprintf("SELECT %s($1) /* %s */", l.fn("pg_advisory_lock"), l.Context), l.key)
 	if err != nil {
 		// If there's an error trying to obtain the lock, probably the
 		// safest thing to do is commit the transaction and make this
@@ -123,7 +127,7 @@ func (l *AdvisoryLock) Unlock() error {
 		panic("unlock of unlocked advisory lock")
 	}
 
-	_, err := l.tx.Exec(fmt.Sprintf("SELECT pg_advisory_unlock($1) /* %s */", l.Context), l.key)
+	_, err := l.tx.Exec(fmt.Sprintf("SELECT %s($1) /* %s */", l.fn("pg_advisory_unlock"), l.Context), l.key)
 	if err != nil {
 		return err
 	}
@@ -139,6 +143,32 @@ func (l *AdvisoryLock) Unlock() error {
 	return err
 }
 
+// Close ends the transaction for a lock that was never obtained (e.g. when the
+// AdvisoryLock was only used to cancel pending locks).
+func (l *AdvisoryLock) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.commited {
+		return nil
+	}
+
+	if l.c != 0 {
+		panic("close of locked advisory lock")
+	}
+
+	return l.commit()
+}
+
+// fn returns the name of the advisory lock function to call, depending on
+// whether the lock is shared.
+func (l *AdvisoryLock) fn(name string) string {
+	if l.Shared {
+		return name + "_shared"
+	}
+	return name
+}
+
 func (l *AdvisoryLock) commit() error {
 	l.commited = true
 	return l.tx.Commit()
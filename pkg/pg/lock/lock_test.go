@@ -122,6 +122,24 @@ func TestAdvisoryLock_CancelPending(t *testing.T) {
 	}
 }
 
+func TestAdvisoryLock_Close(t *testing.T) {
+	db := dbtest.Open(t)
+	defer db.Close()
+
+	a, err := NewAdvisoryLock(db, testKey)
+	assert.NoError(t, err)
+
+	err = a.CancelPending()
+	assert.NoError(t, err)
+
+	err = a.Close()
+	assert.NoError(t, err)
+
+	// Closing an already closed lock is a noop.
+	err = a.Close()
+	assert.NoError(t, err)
+}
+
 func TestAdvisoryLock_Timeout(t *testing.T) {
 	db := dbtest.Open(t)
 	defer db.Close()
@@ -142,6 +160,36 @@ func TestAdvisoryLock_Timeout(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAdvisoryLock_Shared(t *testing.T) {
+	db := dbtest.Open(t)
+	defer db.Close()
+
+	a, err := NewAdvisoryLock(db, testKey)
+	a.Shared = true
+	assert.NoError(t, err)
+	b, err := NewAdvisoryLock(db, testKey)
+	b.Shared = true
+	assert.NoError(t, err)
+	c, err := NewAdvisoryLock(db, testKey)
+	c.LockTimeout = time.Second
+	assert.NoError(t, err)
+
+	// Shared locks don't block each other.
+	err = a.Lock()
+	assert.NoError(t, err)
+	err = b.Lock()
+	assert.NoError(t, err)
+
+	// But they block exclusive locks.
+	err = c.Lock()
+	assert.Equal(t, ErrLockTimeout, err)
+
+	err = a.Unlock()
+	assert.NoError(t, err)
+	err = b.Unlock()
+	assert.NoError(t, err)
+}
+
 func TestAdvisoryLock_Unlocked(t *testing.T) {
 	db := dbtest.Open(t)
 	defer db.Close()
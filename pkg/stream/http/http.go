@@ -2,6 +2,7 @@
 package http
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -83,3 +84,44 @@ func Heartbeat(outStream io.Writer, interval time.Duration) chan struct{} {
 
 	return stop
 }
+
+// EventWriter writes Server-Sent Events to the client, flushing each one to
+// the connection as it's written. See
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+type EventWriter struct {
+	w io.Writer
+}
+
+// NewEventWriter sets the headers for an event stream on the response, and
+// returns an EventWriter that writes events to it. If the provided
+// ResponseWriter does not implement http.Flusher, this function will panic.
+func NewEventWriter(w http.ResponseWriter) *EventWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	return &EventWriter{w: StreamingResponseWriter(w)}
+}
+
+// WriteEvent writes a single event, with the given id, event type and data.
+func (w *EventWriter) WriteEvent(id, event string, data []byte) error {
+	var b bytes.Buffer
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range bytes.Split(bytes.TrimRight(data, "\n"), []byte{'\n'}) {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+
+	_, err := w.w.Write(b.Bytes())
+	return err
+}
+
+// WriteComment writes a comment, which clients ignore. This can be used to
+// keep the connection alive.
+func (w *EventWriter) WriteComment(comment string) error {
+	_, err := fmt.Fprintf(w.w, ": %s\n\n", comment)
+	return err
+}
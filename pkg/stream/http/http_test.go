@@ -0,0 +1,20 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventWriter(t *testing.T) {
+	resp := httptest.NewRecorder()
+	w := NewEventWriter(resp)
+
+	assert.NoError(t, w.WriteEvent("1", "deploy", []byte(`{"App":"acme-inc"}`+"\n")))
+	assert.NoError(t, w.WriteEvent("", "", []byte("foo\nbar")))
+	assert.NoError(t, w.WriteComment("keepalive"))
+
+	assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "id: 1\nevent: deploy\ndata: {\"App\":\"acme-inc\"}\n\ndata: foo\ndata: bar\n\n: keepalive\n\n", resp.Body.String())
+}
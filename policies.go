@@ -0,0 +1,162 @@
+package empire
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// Policy is a named authorization policy document, which controls what users
+// can do through the API. Empire only stores the document. It's parsed and
+// evaluated by the server.
+type Policy struct {
+	// A unique uuid that identifies this policy.
+	ID string
+
+	// A unique name for the policy. Policies are evaluated in order of
+	// their name.
+	Name string
+
+	// The JSON policy document.
+	Document string
+
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate sets created_at and updated_at before inserting.
+func (p *Policy) BeforeCreate() error {
+	t := timex.Now()
+	p.CreatedAt = &t
+	p.UpdatedAt = &t
+	return nil
+}
+
+// BeforeUpdate sets updated_at before updating.
+func (p *Policy) BeforeUpdate() error {
+	t := timex.Now()
+	p.UpdatedAt = &t
+	return nil
+}
+
+// PoliciesQuery is a scope implementation for common things to filter policies
+// by.
+type PoliciesQuery struct {
+	// If provided, finds the policy with the given name.
+	Name *string
+}
+
+// scope implements the scope interface.
+func (q PoliciesQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.Name != nil {
+		scope = append(scope, fieldEquals("name", *q.Name))
+	}
+
+	scope = append(scope, order("name"))
+
+	return scope.scope(db)
+}
+
+// policiesFind returns the first matching policy.
+func policiesFind(db *gorm.DB, scope scope) (*Policy, error) {
+	var policy Policy
+	return &policy, first(db, scope, &policy)
+}
+
+// policies returns all policies matching the scope.
+func policies(db *gorm.DB, scope scope) ([]*Policy, error) {
+	var policies []*Policy
+	return policies, find(db, scope, &policies)
+}
+
+// policiesCreate inserts a new policy.
+func policiesCreate(db *gorm.DB, p *Policy) (*Policy, error) {
+	return p, db.Create(p).Error
+}
+
+// policiesUpdate updates an existing policy.
+func policiesUpdate(db *gorm.DB, p *Policy) error {
+	return db.Save(p).Error
+}
+
+// policiesDestroy deletes a policy.
+func policiesDestroy(db *gorm.DB, p *Policy) error {
+	return db.Delete(p).Error
+}
+
+// SetPolicyOpts are options provided when creating or updating a policy.
+type SetPolicyOpts struct {
+	// User performing the action.
+	User *User
+
+	// The name of the policy.
+	Name string
+
+	// The JSON policy document.
+	Document string
+}
+
+func (opts SetPolicyOpts) Validate(e *Empire) error {
+	if opts.Name == "" {
+		return &ValidationError{Err: fmt.Errorf("policy name is required")}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(opts.Document), &v); err != nil {
+		return &ValidationError{Err: fmt.Errorf("policy document is not valid JSON: %v", err)}
+	}
+
+	return nil
+}
+
+// SetPolicy creates the named policy, or replaces the document of an existing
+// policy.
+func (e *Empire) SetPolicy(ctx context.Context, opts SetPolicyOpts) (*Policy, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	p, err := policiesFind(e.db, PoliciesQuery{Name: &opts.Name})
+	switch err {
+	case nil:
+		p.Document = opts.Document
+		return p, policiesUpdate(e.db, p)
+	case gorm.RecordNotFound:
+		return policiesCreate(e.db, &Policy{
+			Name:     opts.Name,
+			Document: opts.Document,
+		})
+	default:
+		return nil, err
+	}
+}
+
+// DestroyPolicyOpts are options provided when removing a policy.
+type DestroyPolicyOpts struct {
+	// User performing the action.
+	User *User
+
+	// The policy to remove.
+	Policy *Policy
+}
+
+// DestroyPolicy removes a policy.
+func (e *Empire) DestroyPolicy(ctx context.Context, opts DestroyPolicyOpts) error {
+	return policiesDestroy(e.db, opts.Policy)
+}
+
+// PoliciesFind returns the first policy matching the query.
+func (e *Empire) PoliciesFind(q PoliciesQuery) (*Policy, error) {
+	return policiesFind(e.db, q)
+}
+
+// Policies returns all policies matching the query.
+func (e *Empire) Policies(q PoliciesQuery) ([]*Policy, error) {
+	return policies(e.db, q)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/remind101/empire/internal/shellwords"
 	"github.com/remind101/empire/pkg/constraints"
+	"github.com/remind101/empire/pkg/image"
 	"github.com/remind101/empire/procfile"
 )
 
@@ -18,6 +19,11 @@ var DefaultQuantities = map[string]int{
 	"web": 1,
 }
 
+// ReleaseProcessType is the name of the process that's run as a one-off before a
+// new release is rolled out, like Heroku's release phase. It's never run as a
+// service.
+const ReleaseProcessType = "release"
+
 // Command represents a command and it's arguments. For example:
 type Command []string
 
@@ -100,8 +106,53 @@ type Process struct {
 
 	// ECS specific parameters.
 	ECS *procfile.ECS `json:"ECS,omitempty"`
+
+	// If provided, the process will be automatically scaled between the
+	// given bounds. Quantity acts as the baseline.
+	Autoscaling *procfile.Autoscaling `json:"Autoscaling,omitempty"`
+
+	// Configures load balancer and container health checks.
+	HealthCheck *procfile.HealthCheck `json:"HealthCheck,omitempty"`
+
+	// Additional containers that run alongside each instance of the
+	// process.
+	Sidecars []Sidecar `json:"Sidecars,omitempty"`
 }
 
+// Sidecar represents an additional container that runs alongside each instance
+// of a process.
+type Sidecar struct {
+	// The name of the sidecar, which is unique within the process.
+	Name string `json:"Name"`
+
+	// The image to run.
+	Image string `json:"Image"`
+
+	// The command to run. If empty, the default command for the image is
+	// used.
+	Command Command `json:"Command,omitempty"`
+
+	// Sidecar specific environment variables.
+	Environment map[string]string `json:"Environment,omitempty"`
+
+	// Container ports that the sidecar listens on.
+	Ports []int `json:"Ports,omitempty"`
+
+	// When true, the instance is stopped if the sidecar exits.
+	Essential bool `json:"Essential,omitempty"`
+
+	// The memory limit, in bytes.
+	Memory constraints.Memory `json:"Memory,omitempty"`
+}
+
+// sidecarsByName implements the sort.Interface interface to sort sidecars by
+// name.
+type sidecarsByName []Sidecar
+
+func (s sidecarsByName) Len() int           { return len(s) }
+func (s sidecarsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s sidecarsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 type Port struct {
 	Host      int    `json:"Host"`
 	Container int    `json:"Container"`
@@ -117,6 +168,44 @@ func (p *Process) IsValid() error {
 		}
 	}
 
+	if a := p.Autoscaling; a != nil {
+		if p.NoService || p.Cron != nil {
+			return errors.New("only long running processes can be autoscaled")
+		}
+		if a.Min < 1 || a.Max < a.Min {
+			return fmt.Errorf("autoscaling min (%d) must be at least 1, and no greater than max (%d)", a.Min, a.Max)
+		}
+		if a.TargetCPUUtilization == 0 && a.TargetMemoryUtilization == 0 && a.TargetRequestCount == 0 {
+			return errors.New("autoscaling requires a cpu, memory or request count target")
+		}
+		for _, u := range []int{a.TargetCPUUtilization, a.TargetMemoryUtilization} {
+			if u < 0 || u > 100 {
+				return fmt.Errorf("autoscaling utilization targets must be between 1 and 100, got %d", u)
+			}
+		}
+		if a.TargetRequestCount < 0 {
+			return fmt.Errorf("autoscaling request count target must be positive, got %d", a.TargetRequestCount)
+		}
+	}
+
+	if h := p.HealthCheck; h != nil {
+		if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("health check path must start with a /, got %q", h.Path)
+		}
+		if c := h.Container; c != nil && len(c.Command) == 0 {
+			return errors.New("container health checks require a command")
+		}
+	}
+
+	for _, s := range p.Sidecars {
+		if s.Name == "" {
+			return errors.New("sidecars require a name")
+		}
+		if _, err := image.Decode(s.Image); s.Image == "" || err != nil {
+			return fmt.Errorf("sidecar %s has an invalid image: %q", s.Name, s.Image)
+		}
+	}
+
 	return nil
 }
 
@@ -146,6 +235,11 @@ func (f Formation) IsValid() error {
 		if err := p.IsValid(); err != nil {
 			return fmt.Errorf("process %s is not valid: %v", n, err)
 		}
+		for _, s := range p.Sidecars {
+			if s.Name == n {
+				return fmt.Errorf("process %s is not valid: sidecars can't have the same name as the process", n)
+			}
+		}
 	}
 
 	return nil
@@ -196,6 +290,11 @@ func (f Formation) Merge(other Formation) Formation {
 		} else {
 			p.Quantity = DefaultQuantities[name]
 			p.SetConstraints(DefaultConstraints)
+
+			// New autoscaled processes start out at their minimum.
+			if p.Autoscaling != nil {
+				p.Quantity = p.Autoscaling.Min
+			}
 		}
 
 		new[name] = p
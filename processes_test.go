@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/remind101/empire/procfile"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -72,6 +73,27 @@ func TestNewFormation(t *testing.T) {
 			},
 		},
 
+		// Check that new autoscaled processes start at their minimum.
+		{
+			f: Formation{
+				"worker": Process{
+					Command:     Command{"sidekiq"},
+					Autoscaling: &procfile.Autoscaling{Min: 2, Max: 4, TargetCPUUtilization: 70},
+				},
+			},
+			other: nil,
+			expected: Formation{
+				"worker": Process{
+					Quantity:    2,
+					Command:     Command{"sidekiq"},
+					Memory:      NamedConstraints["1X"].Memory,
+					CPUShare:    NamedConstraints["1X"].CPUShare,
+					Nproc:       NamedConstraints["1X"].Nproc,
+					Autoscaling: &procfile.Autoscaling{Min: 2, Max: 4, TargetCPUUtilization: 70},
+				},
+			},
+		},
+
 		// Check that removed processes are ignored.
 		{
 			f: Formation{
@@ -106,6 +128,39 @@ func TestNewFormation(t *testing.T) {
 	}
 }
 
+func TestProcess_IsValid(t *testing.T) {
+	cron := "* * * * *"
+	tests := []struct {
+		p   Process
+		err string
+	}{
+		{Process{}, ""},
+		{Process{NoService: true, Quantity: 1}, "non-service processes cannot be scaled up"},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 1, Max: 2, TargetCPUUtilization: 50}}, ""},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 1, Max: 2, TargetRequestCount: 100}}, ""},
+		{Process{Cron: &cron, Autoscaling: &procfile.Autoscaling{Min: 1, Max: 2, TargetCPUUtilization: 50}}, "only long running processes can be autoscaled"},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 0, Max: 2, TargetCPUUtilization: 50}}, "autoscaling min (0) must be at least 1, and no greater than max (2)"},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 3, Max: 2, TargetCPUUtilization: 50}}, "autoscaling min (3) must be at least 1, and no greater than max (2)"},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 1, Max: 2}}, "autoscaling requires a cpu, memory or request count target"},
+		{Process{Autoscaling: &procfile.Autoscaling{Min: 1, Max: 2, TargetMemoryUtilization: 120}}, "autoscaling utilization targets must be between 1 and 100, got 120"},
+		{Process{HealthCheck: &procfile.HealthCheck{Path: "/health"}}, ""},
+		{Process{HealthCheck: &procfile.HealthCheck{Path: "health"}}, `health check path must start with a /, got "health"`},
+		{Process{HealthCheck: &procfile.HealthCheck{Container: &procfile.ContainerHealthCheck{}}}, "container health checks require a command"},
+		{Process{Sidecars: []Sidecar{{Name: "statsd", Image: "remind101/statsd:latest"}}}, ""},
+		{Process{Sidecars: []Sidecar{{Image: "remind101/statsd:latest"}}}, "sidecars require a name"},
+		{Process{Sidecars: []Sidecar{{Name: "statsd"}}}, `sidecar statsd has an invalid image: ""`},
+	}
+
+	for _, tt := range tests {
+		err := tt.p.IsValid()
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
 func ExampleCommand() {
 	cmd := Command{"/bin/ls", "-h"}
 	fmt.Println(cmd)
@@ -26,12 +26,15 @@ func (e ExtendedProcfile) version() string {
 }
 
 type Process struct {
-	Command     interface{}       `yaml:"command"`
-	Cron        *string           `yaml:"cron,omitempty"`
-	NoService   bool              `yaml:"noservice,omitempty"`
-	Ports       []Port            `yaml:"ports,omitempty"`
-	Environment map[string]string `yaml:"environment,omitempty"`
-	ECS         *ECS              `yaml:"ecs,omitempty"`
+	Command     interface{}        `yaml:"command"`
+	Cron        *string            `yaml:"cron,omitempty"`
+	NoService   bool               `yaml:"noservice,omitempty"`
+	Ports       []Port             `yaml:"ports,omitempty"`
+	Environment map[string]string  `yaml:"environment,omitempty"`
+	ECS         *ECS               `yaml:"ecs,omitempty"`
+	Autoscaling *Autoscaling       `yaml:"autoscaling,omitempty"`
+	HealthCheck *HealthCheck       `yaml:"healthcheck,omitempty"`
+	Sidecars    map[string]Sidecar `yaml:"sidecars,omitempty"`
 }
 
 // ECS specific options.
@@ -40,6 +43,98 @@ type ECS struct {
 	PlacementStrategy    []*ecs.PlacementStrategy   `yaml:"placement_strategy"`
 }
 
+// Autoscaling configures automatic scaling of a process.
+type Autoscaling struct {
+	// The minimum and maximum number of instances to run.
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+
+	// Target average CPU and memory utilization, as a percentage.
+	TargetCPUUtilization    int `yaml:"target_cpu_utilization,omitempty"`
+	TargetMemoryUtilization int `yaml:"target_memory_utilization,omitempty"`
+
+	// Target number of load balancer requests per instance. Only supported
+	// for processes exposed through an ALB.
+	TargetRequestCount int `yaml:"target_request_count,omitempty"`
+}
+
+// HealthCheck configures how the health of a process is checked. Intervals and
+// timeouts are in seconds.
+type HealthCheck struct {
+	// Load balancer health check settings. When Path is empty, the load
+	// balancer checks that it can connect to the process.
+	Path               string `yaml:"path,omitempty"`
+	Interval           int    `yaml:"interval,omitempty"`
+	Timeout            int    `yaml:"timeout,omitempty"`
+	HealthyThreshold   int    `yaml:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold,omitempty"`
+
+	// The HTTP status codes that indicate a healthy process (e.g.
+	// "200-299"). Only supported by Application Load Balancers.
+	StatusCodes string `yaml:"status_codes,omitempty"`
+
+	// A health check that's run inside the container.
+	Container *ContainerHealthCheck `yaml:"container,omitempty"`
+}
+
+// ContainerHealthCheck represents a health check command that's run inside the
+// container.
+type ContainerHealthCheck struct {
+	Command     HealthCheckCommand `yaml:"command"`
+	Interval    int                `yaml:"interval,omitempty"`
+	Timeout     int                `yaml:"timeout,omitempty"`
+	Retries     int                `yaml:"retries,omitempty"`
+	StartPeriod int                `yaml:"start_period,omitempty"`
+}
+
+// HealthCheckCommand is the command that's used to check the health of a
+// container, in the format that Docker expects (e.g. ["CMD", "curl", "-f",
+// "http://localhost"]).
+type HealthCheckCommand []string
+
+// UnmarshalYAML allows the command to be given as a string, which is run with
+// the container's default shell, or as a list of arguments.
+func (c *HealthCheckCommand) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*c = HealthCheckCommand{"CMD-SHELL", s}
+		return nil
+	}
+
+	var args []string
+	if err := unmarshal(&args); err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "CMD", "CMD-SHELL", "NONE":
+		default:
+			args = append([]string{"CMD"}, args...)
+		}
+	}
+
+	*c = HealthCheckCommand(args)
+	return nil
+}
+
+// Sidecar represents an additional container that runs alongside each instance
+// of a process (e.g. a log shipper or proxy).
+type Sidecar struct {
+	Image       string            `yaml:"image"`
+	Command     interface{}       `yaml:"command,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+
+	// Container ports that the sidecar listens on.
+	Ports []int `yaml:"ports,omitempty"`
+
+	// When true, the whole instance is stopped if the sidecar exits.
+	Essential bool `yaml:"essential,omitempty"`
+
+	// The memory limit for the sidecar (e.g. "128MB").
+	Memory string `yaml:"memory,omitempty"`
+}
+
 // Port represents a port mapping.
 type Port struct {
 	Host      int
@@ -152,6 +152,104 @@ web:
 			},
 		},
 	},
+
+	// Autoscaling
+	{
+		strings.NewReader(`---
+web:
+  command: nginx
+  autoscaling:
+    min: 2
+    max: 10
+    target_cpu_utilization: 70
+    target_request_count: 1000`),
+		ExtendedProcfile{
+			"web": Process{
+				Command: "nginx",
+				Autoscaling: &Autoscaling{
+					Min:                  2,
+					Max:                  10,
+					TargetCPUUtilization: 70,
+					TargetRequestCount:   1000,
+				},
+			},
+		},
+	},
+
+	// Health checks
+	{
+		strings.NewReader(`---
+web:
+  command: nginx
+  healthcheck:
+    path: /health
+    interval: 10
+    unhealthy_threshold: 3
+    status_codes: "200-299"
+    container:
+      command: curl -f http://localhost:8080/health
+      start_period: 60
+worker:
+  command: ./bin/worker
+  healthcheck:
+    container:
+      command: ["./bin/healthcheck", "--quiet"]`),
+		ExtendedProcfile{
+			"web": Process{
+				Command: "nginx",
+				HealthCheck: &HealthCheck{
+					Path:               "/health",
+					Interval:           10,
+					UnhealthyThreshold: 3,
+					StatusCodes:        "200-299",
+					Container: &ContainerHealthCheck{
+						Command:     HealthCheckCommand{"CMD-SHELL", "curl -f http://localhost:8080/health"},
+						StartPeriod: 60,
+					},
+				},
+			},
+			"worker": Process{
+				Command: "./bin/worker",
+				HealthCheck: &HealthCheck{
+					Container: &ContainerHealthCheck{
+						Command: HealthCheckCommand{"CMD", "./bin/healthcheck", "--quiet"},
+					},
+				},
+			},
+		},
+	},
+
+	// Sidecars
+	{
+		strings.NewReader(`---
+web:
+  command: nginx
+  sidecars:
+    statsd:
+      image: remind101/statsd:latest
+      command: ["statsd", "--port", "8125"]
+      environment:
+        FLUSH_INTERVAL: "10"
+      ports:
+        - 8125
+      essential: true
+      memory: 64MB`),
+		ExtendedProcfile{
+			"web": Process{
+				Command: "nginx",
+				Sidecars: map[string]Sidecar{
+					"statsd": Sidecar{
+						Image:       "remind101/statsd:latest",
+						Command:     []interface{}{"statsd", "--port", "8125"},
+						Environment: map[string]string{"FLUSH_INTERVAL": "10"},
+						Ports:       []int{8125},
+						Essential:   true,
+						Memory:      "64MB",
+					},
+				},
+			},
+		},
+	},
 }
 
 func TestParse(t *testing.T) {
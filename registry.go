@@ -3,9 +3,11 @@ package empire
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"golang.org/x/net/context"
 
+	"github.com/remind101/empire/pkg/constraints"
 	"github.com/remind101/empire/pkg/image"
 	"github.com/remind101/empire/pkg/jsonmessage"
 	"github.com/remind101/empire/procfile"
@@ -48,16 +50,32 @@ type ImageRegistry interface {
 }
 
 func formationFromProcfile(p procfile.Procfile) (Formation, error) {
+	var (
+		f   Formation
+		err error
+	)
+
 	switch p := p.(type) {
 	case procfile.StandardProcfile:
-		return formationFromStandardProcfile(p)
+		f, err = formationFromStandardProcfile(p)
 	case procfile.ExtendedProcfile:
-		return formationFromExtendedProcfile(p)
+		f, err = formationFromExtendedProcfile(p)
 	default:
 		return nil, &ProcfileError{
 			Err: errors.New("unknown Procfile format"),
 		}
 	}
+	if err != nil {
+		return f, err
+	}
+
+	// The release process is only ever run as a one-off, when deploying.
+	if p, ok := f[ReleaseProcessType]; ok {
+		p.NoService = true
+		f[ReleaseProcessType] = p
+	}
+
+	return f, nil
 }
 
 func formationFromStandardProcfile(p procfile.StandardProcfile) (Formation, error) {
@@ -81,21 +99,9 @@ func formationFromExtendedProcfile(p procfile.ExtendedProcfile) (Formation, erro
 	f := make(Formation)
 
 	for name, process := range p {
-		var cmd Command
-		var err error
-
-		switch command := process.Command.(type) {
-		case string:
-			cmd, err = ParseCommand(command)
-			if err != nil {
-				return nil, err
-			}
-		case []interface{}:
-			for _, v := range command {
-				cmd = append(cmd, v.(string))
-			}
-		default:
-			return nil, errors.New("unknown command format")
+		cmd, err := commandFromProcfile(process.Command)
+		if err != nil {
+			return nil, err
 		}
 
 		var ports []Port
@@ -113,6 +119,11 @@ func formationFromExtendedProcfile(p procfile.ExtendedProcfile) (Formation, erro
 			})
 		}
 
+		sidecars, err := sidecarsFromProcfile(process.Sidecars)
+		if err != nil {
+			return nil, fmt.Errorf("process %s: %v", name, err)
+		}
+
 		f[name] = Process{
 			Command:     cmd,
 			Cron:        process.Cron,
@@ -120,12 +131,77 @@ func formationFromExtendedProcfile(p procfile.ExtendedProcfile) (Formation, erro
 			Ports:       ports,
 			Environment: process.Environment,
 			ECS:         process.ECS,
+			Autoscaling: process.Autoscaling,
+			HealthCheck: process.HealthCheck,
+			Sidecars:    sidecars,
 		}
 	}
 
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+
 	return f, nil
 }
 
+// commandFromProcfile converts a command from an extended Procfile, which can
+// either be a string or a list of arguments, into a Command.
+func commandFromProcfile(command interface{}) (Command, error) {
+	switch command := command.(type) {
+	case string:
+		return ParseCommand(command)
+	case []interface{}:
+		var cmd Command
+		for _, v := range command {
+			cmd = append(cmd, v.(string))
+		}
+		return cmd, nil
+	default:
+		return nil, errors.New("unknown command format")
+	}
+}
+
+// sidecarsFromProcfile converts the sidecars for a process in an extended
+// Procfile into Sidecars, sorted by name.
+func sidecarsFromProcfile(p map[string]procfile.Sidecar) ([]Sidecar, error) {
+	var sidecars []Sidecar
+
+	for name, sidecar := range p {
+		var (
+			cmd Command
+			err error
+		)
+		if sidecar.Command != nil {
+			cmd, err = commandFromProcfile(sidecar.Command)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		memory := DefaultSidecarMemory
+		if sidecar.Memory != "" {
+			memory, err = constraints.ParseMemory(sidecar.Memory)
+			if err != nil {
+				return nil, fmt.Errorf("sidecar %s: %v", name, err)
+			}
+		}
+
+		sidecars = append(sidecars, Sidecar{
+			Name:        name,
+			Image:       sidecar.Image,
+			Command:     cmd,
+			Environment: sidecar.Environment,
+			Ports:       sidecar.Ports,
+			Essential:   sidecar.Essential,
+			Memory:      memory,
+		})
+	}
+
+	sort.Sort(sidecarsByName(sidecars))
+
+	return sidecars, nil
+}
+
 // protocolFromPort attempts to automatically determine what protocol a port
 // should use. For example, port 80 is well known to be http, so we can assume
 // that http should be used. Defaults to "tcp" if unknown.
@@ -6,6 +6,7 @@ import (
 
 	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire/pkg/headerutil"
+	"github.com/remind101/empire/pkg/image"
 	"github.com/remind101/empire/pkg/timex"
 	"github.com/remind101/empire/procfile"
 	"github.com/remind101/empire/twelvefactor"
@@ -48,6 +49,14 @@ type Release struct {
 	// the release was created (e.g. deployment, config changes, etc).
 	Description string
 
+	// Failed is true if the release failed to deploy, and was automatically
+	// rolled back.
+	Failed bool
+
+	// Pending is true if the release is waiting for its release command to
+	// succeed. Pending releases don't become the current release.
+	Pending bool
+
 	// The time that this release was created.
 	CreatedAt *time.Time
 }
@@ -73,6 +82,12 @@ type ReleasesQuery struct {
 	// If provided, a version to filter by.
 	Version *int
 
+	// If provided, filters releases by whether they failed to deploy.
+	Failed *bool
+
+	// If provided, filters releases by whether they're pending.
+	Pending *bool
+
 	// If provided, uses the limit and sorting parameters specified in the range.
 	Range headerutil.Range
 }
@@ -89,6 +104,14 @@ func (q ReleasesQuery) scope(db *gorm.DB) *gorm.DB {
 		scope = append(scope, fieldEquals("version", *version))
 	}
 
+	if failed := q.Failed; failed != nil {
+		scope = append(scope, fieldEquals("failed", *failed))
+	}
+
+	if pending := q.Pending; pending != nil {
+		scope = append(scope, fieldEquals("pending", *pending))
+	}
+
 	scope = append(scope, inRange(q.Range.WithDefaults(q.DefaultRange())))
 
 	return scope.scope(db)
@@ -116,7 +139,7 @@ func (s *releasesService) CreateAndRelease(ctx context.Context, db *gorm.DB, r *
 		return r, err
 	}
 	// Schedule the new release onto the cluster.
-	return r, s.Release(ctx, r, ss)
+	return r, s.Release(ctx, db, r, ss)
 }
 
 // Create creates a new release.
@@ -160,16 +183,70 @@ func (s *releasesService) Rollback(ctx context.Context, db *gorm.DB, opts Rollba
 }
 
 // Release submits a release to the scheduler.
-func (s *releasesService) Release(ctx context.Context, release *Release, ss twelvefactor.StatusStream) error {
-	a, err := newSchedulerApp(release)
+func (s *releasesService) Release(ctx context.Context, db *gorm.DB, release *Release, ss twelvefactor.StatusStream) error {
+	return s.release(ctx, db, release, ss, false)
+}
+
+// release submits a release to the scheduler. When detectFailures is true, the
+// scheduler returns a twelvefactor.DeploymentError if the release fails to
+// become healthy, so that it can be rolled back. Canaries always detect
+// failures, since a failed canary is aborted.
+func (s *releasesService) release(ctx context.Context, db *gorm.DB, release *Release, ss twelvefactor.StatusStream, detectFailures bool) error {
+	c, err := canaryForApp(db, release.App)
 	if err != nil {
 		return err
 	}
+
+	// Any newer release (e.g. a config change, or a rollback) supersedes a
+	// canary that's in progress.
+	if c != nil && release.Version > c.Version {
+		if err := canariesDestroy(db, c); err != nil {
+			return err
+		}
+		c = nil
+	}
+
+	a, err := s.manifest(db, release, c)
+	if err != nil {
+		return err
+	}
+	a.DetectFailures = detectFailures || a.Canary != nil
 	return s.Scheduler.Submit(ctx, a, ss)
 }
 
+// manifest returns the twelvefactor.Manifest for the release. If the release is
+// being rolled out as a canary, the Manifest for the stable release is
+// returned, with the release attached as the canary.
+func (s *releasesService) manifest(db *gorm.DB, release *Release, c *Canary) (*twelvefactor.Manifest, error) {
+	a, err := newSchedulerApp(release)
+	if err != nil {
+		return nil, err
+	}
+
+	if c == nil || c.Version != release.Version {
+		return a, nil
+	}
+
+	stable, err := releasesFind(db, ReleasesQuery{App: release.App, Version: &c.StableVersion})
+	if err != nil {
+		return nil, err
+	}
+	stable.App = release.App
+
+	m, err := newSchedulerApp(stable)
+	if err != nil {
+		return nil, err
+	}
+	m.Canary = &twelvefactor.Canary{
+		Manifest: a,
+		Weight:   c.Weight,
+	}
+
+	return m, nil
+}
+
 func (s *releasesService) ReleaseApp(ctx context.Context, db *gorm.DB, app *App, ss twelvefactor.StatusStream) error {
-	release, err := releasesFind(db, ReleasesQuery{App: app})
+	release, err := releasesCurrent(db, app)
 	if err != nil {
 		if err == gorm.RecordNotFound {
 			return ErrNoReleases
@@ -182,7 +259,7 @@ func (s *releasesService) ReleaseApp(ctx context.Context, db *gorm.DB, app *App,
 		return nil
 	}
 
-	return s.Release(ctx, release, ss)
+	return s.Release(ctx, db, release, ss)
 }
 
 // Restart will find the last release for an app and submit it to the scheduler
@@ -191,6 +268,36 @@ func (s *releasesService) Restart(ctx context.Context, db *gorm.DB, app *App) er
 	return s.Scheduler.Restart(ctx, app.ID, nil)
 }
 
+// Migrate submits the last release for an app to a different scheduler
+// backend.
+func (s *releasesService) Migrate(ctx context.Context, db *gorm.DB, app *App, backend string, ss twelvefactor.StatusStream) error {
+	m, ok := s.Scheduler.(SchedulerMigrator)
+	if !ok {
+		return ErrMigrationUnsupported
+	}
+
+	release, err := releasesCurrent(db, app)
+	if err != nil {
+		if err == gorm.RecordNotFound {
+			return ErrNoReleases
+		}
+
+		return err
+	}
+
+	c, err := canaryForApp(db, app)
+	if err != nil {
+		return err
+	}
+
+	a, err := s.manifest(db, release, c)
+	if err != nil {
+		return err
+	}
+
+	return m.Migrate(ctx, a, backend, ss)
+}
+
 // These associations are always available on a Release.
 var releasesPreload = preload("App", "Config", "Slug")
 
@@ -206,6 +313,13 @@ func releasesFind(db *gorm.DB, scope scope) (*Release, error) {
 	return &release, nil
 }
 
+// releasesCurrent returns the current release for the app, which is the last
+// release that didn't fail to deploy.
+func releasesCurrent(db *gorm.DB, app *App) (*Release, error) {
+	failed := false
+	return releasesFind(db, ReleasesQuery{App: app, Failed: &failed})
+}
+
 // releases returns all releases matching the scope.
 func releases(db *gorm.DB, scope scope) ([]*Release, error) {
 	var releases []*Release
@@ -213,6 +327,23 @@ func releases(db *gorm.DB, scope scope) ([]*Release, error) {
 	return releases, find(db, scope, &releases)
 }
 
+// releasesLastSucceeded returns the last release before the given release that
+// didn't fail, and isn't pending. If there is no such release, nil is returned.
+func releasesLastSucceeded(db *gorm.DB, before *Release) (*Release, error) {
+	rs, err := releases(db, ReleasesQuery{App: before.App})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rs {
+		if r.Version < before.Version && !r.Failed && !r.Pending {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
 func releasesUpdate(db *gorm.DB, release *Release) error {
 	return db.Save(release).Error
 }
@@ -221,7 +352,7 @@ func buildFormation(db *gorm.DB, release *Release) error {
 	var existing Formation
 
 	// Get the old release, so we can copy the Formation.
-	last, err := releasesFind(db, ReleasesQuery{App: release.App})
+	last, err := releasesCurrent(db, release.App)
 	if err != nil {
 		if err != gorm.RecordNotFound {
 			return err
@@ -242,7 +373,7 @@ func buildFormation(db *gorm.DB, release *Release) error {
 // currentFormations gets the current formations for an app
 func currentFormation(db *gorm.DB, app *App) (Formation, error) {
 	// Get the current release
-	current, err := releasesFind(db, ReleasesQuery{App: app})
+	current, err := releasesCurrent(db, app)
 	if err != nil {
 		return nil, err
 	}
@@ -366,22 +497,65 @@ func newSchedulerProcess(release *Release, name string, p Process) (*twelvefacto
 		quantity = 0
 	}
 
+	sidecars, err := newSchedulerSidecars(release, name, p)
+	if err != nil {
+		return nil, err
+	}
+
 	return &twelvefactor.Process{
-		Type:      name,
-		Env:       env,
-		Labels:    labels,
-		Command:   []string(p.Command),
-		Image:     release.Slug.Image,
-		Quantity:  quantity,
-		Memory:    uint(p.Memory),
-		CPUShares: uint(p.CPUShare),
-		Nproc:     uint(p.Nproc),
-		Exposure:  exposure,
-		Schedule:  processSchedule(name, p),
-		ECS:       p.ECS,
+		Type:        name,
+		Env:         env,
+		Labels:      labels,
+		Command:     []string(p.Command),
+		Image:       release.Slug.Image,
+		Quantity:    quantity,
+		Memory:      uint(p.Memory),
+		CPUShares:   uint(p.CPUShare),
+		Nproc:       uint(p.Nproc),
+		Exposure:    exposure,
+		Schedule:    processSchedule(name, p),
+		ECS:         p.ECS,
+		Autoscaling: p.Autoscaling,
+		HealthCheck: p.HealthCheck,
+		Sidecars:    sidecars,
 	}, nil
 }
 
+// newSchedulerSidecars builds the sidecars for a process. Sidecars don't
+// receive the app's config vars, only their own environment, and some
+// information about the process they're running alongside.
+func newSchedulerSidecars(release *Release, name string, p Process) ([]*twelvefactor.Sidecar, error) {
+	var sidecars []*twelvefactor.Sidecar
+
+	for _, s := range p.Sidecars {
+		img, err := image.Decode(s.Image)
+		if err != nil {
+			return nil, fmt.Errorf("sidecar %s: %v", s.Name, err)
+		}
+
+		env := make(map[string]string)
+		for k, v := range s.Environment {
+			env[k] = v
+		}
+		env["EMPIRE_APPID"] = release.App.ID
+		env["EMPIRE_APPNAME"] = release.App.Name
+		env["EMPIRE_RELEASE"] = fmt.Sprintf("v%d", release.Version)
+		env["EMPIRE_PROCESS"] = name
+
+		sidecars = append(sidecars, &twelvefactor.Sidecar{
+			Name:      s.Name,
+			Image:     img,
+			Command:   []string(s.Command),
+			Env:       env,
+			Ports:     s.Ports,
+			Essential: s.Essential,
+			Memory:    uint(s.Memory),
+		})
+	}
+
+	return sidecars, nil
+}
+
 // environment coerces a Vars into a map[string]string.
 func environment(vars Vars) map[string]string {
 	env := make(map[string]string)
@@ -473,6 +647,14 @@ func processSchedule(name string, p Process) twelvefactor.Schedule {
 }
 
 func appendMessageToDescription(main string, user *User, message string) string {
+	// Deployments can be performed without a user.
+	if user == nil {
+		if message == "" {
+			return main
+		}
+		return fmt.Sprintf("%s ('%s')", main, message)
+	}
+
 	var formatted string
 	if message != "" {
 		formatted = fmt.Sprintf(": '%s'", message)
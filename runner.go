@@ -20,7 +20,7 @@ type runnerService struct {
 }
 
 func (r *runnerService) Run(ctx context.Context, opts RunOpts) error {
-	release, err := releasesFind(r.db, ReleasesQuery{App: opts.App})
+	release, err := releasesCurrent(r.db, opts.App)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package empire
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -11,6 +12,21 @@ import (
 
 type Scheduler twelvefactor.Scheduler
 
+// ErrMigrationUnsupported is returned when attempting to migrate an app to a
+// different scheduler backend, but the Scheduler only has a single backend.
+var ErrMigrationUnsupported = errors.New("the scheduler does not support migrating apps between backends")
+
+// SchedulerMigrator is an optional interface that a Scheduler can implement to
+// support moving apps between multiple backends.
+type SchedulerMigrator interface {
+	// Backend returns the name of the backend that the app is running on.
+	Backend(ctx context.Context, appID string) (string, error)
+
+	// Migrate submits the app to the given backend, waits for it to
+	// become stable, then removes it from the old backend.
+	Migrate(ctx context.Context, app *twelvefactor.Manifest, backend string, ss twelvefactor.StatusStream) error
+}
+
 type FakeScheduler struct {
 	sync.Mutex
 	apps map[string]*twelvefactor.Manifest
@@ -33,6 +49,10 @@ func (m *FakeScheduler) Restart(ctx context.Context, appID string, ss twelvefact
 	return nil
 }
 
+func (m *FakeScheduler) Cancel(ctx context.Context, appID string) error {
+	return nil
+}
+
 func (m *FakeScheduler) Remove(ctx context.Context, appID string) error {
 	delete(m.apps, appID)
 	return nil
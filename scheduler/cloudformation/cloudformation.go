@@ -11,6 +11,7 @@ import (
 	"hash/crc32"
 	"html/template"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -65,6 +66,10 @@ var (
 	pollServicesWait = 20 * time.Second
 )
 
+// DefaultStabilizationTimeout is the default maximum amount of time we'll wait
+// for a deployment to stabilize before we consider it failed.
+const DefaultStabilizationTimeout = 30 * time.Minute
+
 // CloudFormation limits
 //
 // See http://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/cloudformation-limits.html
@@ -92,6 +97,7 @@ var errNoStack = errors.New("no stack for app found")
 type cloudformationClient interface {
 	CreateStack(*cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error)
 	UpdateStack(*cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error)
+	CancelUpdateStack(*cloudformation.CancelUpdateStackInput) (*cloudformation.CancelUpdateStackOutput, error)
 	DeleteStack(*cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error)
 	ListStackResourcesPages(*cloudformation.ListStackResourcesInput, func(*cloudformation.ListStackResourcesOutput, bool) bool) error
 	DescribeStackResource(*cloudformation.DescribeStackResourceInput) (*cloudformation.DescribeStackResourceOutput, error)
@@ -130,6 +136,7 @@ type ec2Client interface {
 type DockerClient interface {
 	ListContainers(docker.ListContainersOptions) ([]docker.APIContainers, error)
 	AttachToContainer(docker.AttachToContainerOptions) error
+	WaitContainer(string) (int, error)
 }
 
 // Data handed to template generators.
@@ -169,6 +176,10 @@ type Scheduler struct {
 	// instance.
 	NewDockerClient func(*ec2.Instance) (DockerClient, error)
 
+	// The maximum amount of time to wait for a deployment to stabilize
+	// before it's considered failed. The default is 30 minutes.
+	StabilizationTimeout time.Duration
+
 	// CloudFormation client for creating stacks.
 	cloudformation cloudformationClient
 
@@ -217,12 +228,21 @@ func (s *Scheduler) SubmitWithOptions(ctx context.Context, app *twelvefactor.Man
 		return err
 	}
 
-	err = s.submit(ctx, tx, app, ss, opts)
+	output, err := s.submit(ctx, tx, app, ss, opts)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// The stack operation has been submitted, so the stack is tracked even
+	// if it fails to complete, or the deployment fails to stabilize.
+	if ss != nil {
+		return s.wait(ctx, app, output, ss)
+	}
+	return nil
 }
 
 func (s *Scheduler) Restart(ctx context.Context, appID string, ss twelvefactor.StatusStream) error {
@@ -254,8 +274,52 @@ func (s *Scheduler) Restart(ctx context.Context, appID string, ss twelvefactor.S
 	return nil
 }
 
-// Submit creates (or updates) the CloudFormation stack for the app.
-func (s *Scheduler) submit(ctx context.Context, tx *sql.Tx, app *twelvefactor.Manifest, ss twelvefactor.StatusStream, opts SubmitOptions) error {
+// Cancel cancels any pending stack operations for the app, and cancels the
+// stack update that's in progress, which CloudFormation rolls back.
+func (s *Scheduler) Cancel(ctx context.Context, appID string) error {
+	stackName, err := s.stackName(appID)
+	if err == errNoStack {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Stack operations waiting on the lock would otherwise start as soon
+	// as the in progress update is rolled back.
+	l, err := newAdvisoryLock(s.db, stackName)
+	if err != nil {
+		return err
+	}
+	if err := l.CancelPending(); err != nil {
+		l.Close()
+		return fmt.Errorf("error canceling pending stack operation: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		return err
+	}
+
+	stack, err := s.stack(aws.String(stackName))
+	if err != nil {
+		return fmt.Errorf("error describing stack: %v", err)
+	}
+
+	if *stack.StackStatus != cloudformation.StackStatusUpdateInProgress {
+		return nil
+	}
+
+	if _, err := s.cloudformation.CancelUpdateStack(&cloudformation.CancelUpdateStackInput{
+		StackName: aws.String(stackName),
+	}); err != nil {
+		return fmt.Errorf("error canceling stack update: %v", err)
+	}
+
+	return nil
+}
+
+// submit creates (or updates) the CloudFormation stack for the app. The
+// result of the stack operation is sent to the returned channel once it
+// completes.
+func (s *Scheduler) submit(ctx context.Context, tx *sql.Tx, app *twelvefactor.Manifest, ss twelvefactor.StatusStream, opts SubmitOptions) (<-chan stackOperationOutput, error) {
 	stackName, err := s.stackName(app.AppID)
 	if err == errNoStack {
 		t := s.StackNameTemplate
@@ -264,21 +328,21 @@ func (s *Scheduler) submit(ctx context.Context, tx *sql.Tx, app *twelvefactor.Ma
 		}
 		buf := new(bytes.Buffer)
 		if err := t.Execute(buf, app); err != nil {
-			return fmt.Errorf("error generating stack name: %v", err)
+			return nil, fmt.Errorf("error generating stack name: %v", err)
 		}
 		stackName = buf.String()
 		if _, err := tx.Exec(`INSERT INTO stacks (app_id, stack_name) VALUES ($1, $2)`, app.AppID, stackName); err != nil {
-			return err
+			return nil, err
 		}
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
 	stackTags := append(s.Tags, tagsFromLabels(app.Labels)...)
 
 	t, err := s.createTemplate(ctx, app, stackTags)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stats.Histogram(ctx, "scheduler.cloudformation.template_size", float32(t.Size), 1.0, []string{
@@ -313,7 +377,7 @@ func (s *Scheduler) submit(ctx context.Context, tx *sql.Tx, app *twelvefactor.Ma
 			Tags:       stackTags,
 			Parameters: parameters,
 		}, output, ss); err != nil {
-			return fmt.Errorf("error creating stack: %v", err)
+			return nil, fmt.Errorf("error creating stack: %v", err)
 		}
 	} else if err == nil {
 		if err := s.updateStack(ctx, &updateStackInput{
@@ -322,37 +386,108 @@ func (s *Scheduler) submit(ctx context.Context, tx *sql.Tx, app *twelvefactor.Ma
 			Parameters: parameters,
 			Tags:       stackTags,
 		}, output, ss); err != nil {
-			return err
+			return nil, err
 		}
 	} else {
-		return fmt.Errorf("error describing stack: %v", err)
+		return nil, fmt.Errorf("error describing stack: %v", err)
 	}
 
-	if ss != nil {
-		o := <-output
-		if o.err != nil || o.stack == nil {
-			return o.err
+	return output, nil
+}
+
+// wait waits for the stack operation for the app to complete, then waits for
+// the new ECS deployments to stabilize. Unless the app opted in to detecting
+// failures, a deployment that fails to stabilize is only reported to the
+// status stream.
+func (s *Scheduler) wait(ctx context.Context, app *twelvefactor.Manifest, output <-chan stackOperationOutput, ss twelvefactor.StatusStream) error {
+	o := <-output
+	if o.err != nil {
+		if app.DetectFailures {
+			return s.stackOperationError(o.err)
+		}
+		return o.err
+	}
+	if o.stack == nil {
+		return nil
+	}
+
+	if err := ss.Publish(twelvefactor.Status{Message: "Waiting for services to stabilize", Stabilizing: true}); err != nil {
+		logger.Warn(ctx, fmt.Sprintf("error publishing to stream: %v", err))
+	}
+	if err := s.waitUntilStable(ctx, o.stack, ss); err != nil {
+		if err == ctx.Err() {
+			return err
 		}
-		if err := s.waitUntilStable(ctx, o.stack, ss); err != nil {
-			logger.Warn(ctx, fmt.Sprintf("error waiting for submit to stabilize: %v", err))
+		if _, ok := err.(*twelvefactor.DeploymentError); ok && app.DetectFailures {
+			return err
 		}
+		logger.Warn(ctx, fmt.Sprintf("error waiting for submit to stabilize: %v", err))
 	}
 	return nil
 }
 
+// stackOperationError returns a DeploymentError if the stack operation failed
+// because CloudFormation rolled the stack back to the previous version.
+// Otherwise, the original error is returned.
+func (s *Scheduler) stackOperationError(err error) error {
+	werr, ok := err.(*stackWaitError)
+	if !ok {
+		return err
+	}
+
+	stack, serr := s.stack(werr.StackName)
+	if serr != nil {
+		return err
+	}
+
+	if aws.StringValue(stack.StackStatus) == cloudformation.StackStatusUpdateRollbackComplete {
+		return &twelvefactor.DeploymentError{
+			Reason: fmt.Sprintf("stack update failed and was rolled back: %s", aws.StringValue(stack.StackStatusReason)),
+		}
+	}
+
+	return err
+}
+
 func (s *Scheduler) waitUntilStable(ctx context.Context, stack *cloudformation.Stack, ss twelvefactor.StatusStream) error {
 	deployments, err := deploymentsToWatch(stack)
 	if err != nil {
 		return err
 	}
+	var unstable []string
 	deploymentStatuses := s.waitForDeploymentsToStabilize(ctx, deployments)
 	for status := range deploymentStatuses {
 		publish(ctx, ss, fmt.Sprintf("Service %s became %s", status.deployment.process, status))
+		if status.status == "unstable" {
+			unstable = append(unstable, status.deployment.process)
+		}
 	}
-	// TODO publish notification to empire
+
+	if len(unstable) > 0 {
+		sort.Strings(unstable)
+		return &twelvefactor.DeploymentError{
+			Reason: fmt.Sprintf("%s failed to stabilize within %v", strings.Join(unstable, ", "), s.stabilizationTimeout()),
+		}
+	}
+
+	// If the context was canceled before all of the deployments
+	// stabilized, we don't know whether they would have.
+	if err := ctx.Err(); err != nil && len(deployments) > 0 {
+		return err
+	}
+
 	return nil
 }
 
+// stabilizationTimeout returns the maximum amount of time to wait for a
+// deployment to stabilize.
+func (s *Scheduler) stabilizationTimeout() time.Duration {
+	if s.StabilizationTimeout == 0 {
+		return DefaultStabilizationTimeout
+	}
+	return s.StabilizationTimeout
+}
+
 type deploymentStatus struct {
 	deployment *ecsDeployment
 	status     string
@@ -403,6 +538,9 @@ func (s *Scheduler) waitForDeploymentsToStabilize(ctx context.Context, deploymen
 	}
 
 	go func(deployments map[string]*ecsDeployment) {
+		defer close(ch)
+
+		timeout := s.after(s.stabilizationTimeout())
 		keepWaiting := true
 		var err error
 		for keepWaiting && len(deployments) > 0 {
@@ -412,10 +550,20 @@ func (s *Scheduler) waitForDeploymentsToStabilize(ctx context.Context, deploymen
 				break
 			}
 			if keepWaiting {
-				<-s.after(pollServicesWait)
+				select {
+				case <-timeout:
+					// Any deployments that haven't stabilized by
+					// now are considered failed.
+					for _, d := range deployments {
+						ch <- &deploymentStatus{d, "unstable"}
+					}
+					return
+				case <-ctx.Done():
+					return
+				case <-s.after(pollServicesWait):
+				}
 			}
 		}
-		close(ch)
 	}(deployments)
 
 	return ch
@@ -811,6 +959,7 @@ func (s *Scheduler) Tasks(ctx context.Context, app string) ([]*twelvefactor.Task
 			ID:        id,
 			Host:      twelvefactor.Host{ID: hostId},
 			UpdatedAt: updatedAt,
+			Sidecars:  sidecarTasks(taskDefinition, t),
 		})
 	}
 
@@ -1083,6 +1232,14 @@ func (m *Scheduler) attach(ctx context.Context, task *ecs.Task, stdin io.Reader,
 		return fmt.Errorf("error attaching to container (%s): %v", containerID, err)
 	}
 
+	code, err := d.WaitContainer(containerID)
+	if err != nil {
+		return fmt.Errorf("error waiting for container (%s): %v", containerID, err)
+	}
+	if code != 0 {
+		return &twelvefactor.ExitError{Code: code}
+	}
+
 	return nil
 }
 
@@ -1144,13 +1301,27 @@ func (s *Scheduler) waitFor(ctx context.Context, op stackOperation, ss twelvefac
 		start := time.Now()
 		err := wait(input)
 		stats.Timing(ctx, fmt.Sprintf("scheduler.cloudformation.%s", op), time.Since(start), 1.0, tags)
-		if err == nil {
-			publish(ctx, ss, waiter.successMessage)
+		if err != nil {
+			return &stackWaitError{StackName: input.StackName, Err: err}
 		}
-		return err
+		publish(ctx, ss, waiter.successMessage)
+		return nil
 	}
 }
 
+// stackWaitError is returned when waiting for a stack operation to complete
+// fails. The stack may have been rolled back, or we may have just stopped
+// waiting for it.
+type stackWaitError struct {
+	StackName *string
+	Err       error
+}
+
+// Error implements the error interface.
+func (e *stackWaitError) Error() string {
+	return e.Err.Error()
+}
+
 // extractProcessData extracts a map that maps the process name to some
 // corresponding value.
 func extractProcessData(value string) map[string]string {
@@ -1198,6 +1369,39 @@ func taskDefinitionToProcess(td *ecs.TaskDefinition) (*twelvefactor.Process, err
 	}, nil
 }
 
+// sidecarTasks returns the state of the sidecar containers within the task,
+// which is every container other than the process' container.
+func sidecarTasks(td *ecs.TaskDefinition, t *ecs.Task) []*twelvefactor.SidecarTask {
+	if len(td.ContainerDefinitions) == 0 {
+		return nil
+	}
+
+	main := aws.StringValue(td.ContainerDefinitions[0].Name)
+
+	var sidecars []*twelvefactor.SidecarTask
+	for _, c := range t.Containers {
+		name := aws.StringValue(c.Name)
+		if name == main {
+			continue
+		}
+
+		var command []string
+		for _, cd := range td.ContainerDefinitions {
+			if aws.StringValue(cd.Name) == name {
+				command = aws.StringValueSlice(cd.Command)
+			}
+		}
+
+		sidecars = append(sidecars, &twelvefactor.SidecarTask{
+			Name:    name,
+			Command: command,
+			State:   aws.StringValue(c.LastStatus),
+		})
+	}
+
+	return sidecars
+}
+
 func softLimit(ulimits []*ecs.Ulimit, name string) int64 {
 	if ulimits == nil {
 		return 0
@@ -344,6 +344,334 @@ func TestScheduler_Submit_ExistingStack(t *testing.T) {
 	x.AssertExpectations(t)
 }
 
+func TestScheduler_Submit_Unstable(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	x := new(mockS3Client)
+	c := new(mockCloudFormationClient)
+	e := new(mockECSClient)
+	s := &Scheduler{
+		Template:       template.Must(template.New("t").Parse("{}")),
+		Bucket:         "bucket",
+		Cluster:        "cluster",
+		cloudformation: c,
+		ecs:            e,
+		s3:             x,
+		db:             db,
+		after: func(d time.Duration) <-chan time.Time {
+			if d == DefaultStabilizationTimeout {
+				// Return a channel that receives immediately.
+				ch := make(chan time.Time)
+				close(ch)
+				return ch
+			}
+
+			return nil
+		},
+	}
+
+	x.On("PutObject", &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Body:        bytes.NewReader([]byte("{}")),
+		Key:         aws.String("/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+		ContentType: aws.String("application/json"),
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	c.On("ValidateTemplate", &cloudformation.ValidateTemplateInput{
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.ValidateTemplateOutput{}, nil)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackStatus: aws.String("CREATE_COMPLETE")},
+		},
+	}, nil).Once()
+
+	c.On("UpdateStack", &cloudformation.UpdateStackInput{
+		StackName:   aws.String("acme-inc"),
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.UpdateStackOutput{}, nil)
+
+	c.On("WaitUntilStackUpdateComplete", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(nil)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{
+				StackStatus: aws.String("UPDATE_COMPLETE"),
+				Outputs: []*cloudformation.Output{
+					{
+						OutputKey:   aws.String("Services"),
+						OutputValue: aws.String("web=arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+					},
+					{
+						OutputKey:   aws.String("Deployments"),
+						OutputValue: aws.String("web=1"),
+					},
+				},
+			},
+		},
+	}, nil)
+
+	// The new deployment never replaces the old one.
+	e.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceArn: aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+				Deployments: []*ecs.Deployment{
+					&ecs.Deployment{Id: aws.String("1"), Status: aws.String("PRIMARY")},
+					&ecs.Deployment{Id: aws.String("2"), Status: aws.String("ACTIVE")},
+				},
+			},
+		},
+	}, nil)
+
+	err := s.Submit(context.Background(), &twelvefactor.Manifest{
+		AppID:          "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:           "acme-inc",
+		DetectFailures: true,
+	}, twelvefactor.NullStatusStream)
+	assert.IsType(t, &twelvefactor.DeploymentError{}, err)
+	assert.EqualError(t, err, "deployment failed: web failed to stabilize within 30m0s")
+
+	// The stack should still be tracked, even though the deployment
+	// failed.
+	stackName, err := s.stackName("c9366591-ab68-4d49-a333-95ce5a23df68")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme-inc", stackName)
+
+	c.AssertExpectations(t)
+	x.AssertExpectations(t)
+	e.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_Unstable_NoDetectFailures(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	x := new(mockS3Client)
+	c := new(mockCloudFormationClient)
+	e := new(mockECSClient)
+	s := &Scheduler{
+		Template:             template.Must(template.New("t").Parse("{}")),
+		Bucket:               "bucket",
+		Cluster:              "cluster",
+		cloudformation:       c,
+		ecs:                  e,
+		s3:                   x,
+		db:                   db,
+		StabilizationTimeout: 10 * time.Minute,
+		after: func(d time.Duration) <-chan time.Time {
+			if d == 10*time.Minute {
+				// Return a channel that receives immediately.
+				ch := make(chan time.Time)
+				close(ch)
+				return ch
+			}
+
+			return nil
+		},
+	}
+
+	x.On("PutObject", &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Body:        bytes.NewReader([]byte("{}")),
+		Key:         aws.String("/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+		ContentType: aws.String("application/json"),
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	c.On("ValidateTemplate", &cloudformation.ValidateTemplateInput{
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.ValidateTemplateOutput{}, nil)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackStatus: aws.String("CREATE_COMPLETE")},
+		},
+	}, nil).Once()
+
+	c.On("UpdateStack", &cloudformation.UpdateStackInput{
+		StackName:   aws.String("acme-inc"),
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.UpdateStackOutput{}, nil)
+
+	c.On("WaitUntilStackUpdateComplete", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(nil)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{
+				StackStatus: aws.String("UPDATE_COMPLETE"),
+				Outputs: []*cloudformation.Output{
+					{
+						OutputKey:   aws.String("Services"),
+						OutputValue: aws.String("web=arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+					},
+					{
+						OutputKey:   aws.String("Deployments"),
+						OutputValue: aws.String("web=1"),
+					},
+				},
+			},
+		},
+	}, nil)
+
+	// The new deployment never replaces the old one.
+	e.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceArn: aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+				Deployments: []*ecs.Deployment{
+					&ecs.Deployment{Id: aws.String("1"), Status: aws.String("PRIMARY")},
+					&ecs.Deployment{Id: aws.String("2"), Status: aws.String("ACTIVE")},
+				},
+			},
+		},
+	}, nil)
+
+	err := s.Submit(context.Background(), &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+	}, twelvefactor.NullStatusStream)
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+	x.AssertExpectations(t)
+	e.AssertExpectations(t)
+}
+
+func TestScheduler_waitUntilStable_Canceled(t *testing.T) {
+	e := new(mockECSClient)
+	s := &Scheduler{
+		Cluster: "cluster",
+		ecs:     e,
+		after: func(d time.Duration) <-chan time.Time {
+			return nil
+		},
+	}
+
+	// The new deployment hasn't replaced the old one yet.
+	e.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceArn: aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+				Deployments: []*ecs.Deployment{
+					&ecs.Deployment{Id: aws.String("1"), Status: aws.String("PRIMARY")},
+					&ecs.Deployment{Id: aws.String("2"), Status: aws.String("ACTIVE")},
+				},
+			},
+		},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.waitUntilStable(ctx, &cloudformation.Stack{
+		Outputs: []*cloudformation.Output{
+			{
+				OutputKey:   aws.String("Services"),
+				OutputValue: aws.String("web=arn:aws:ecs:us-east-1:012345678910:service/acme-inc-web"),
+			},
+			{
+				OutputKey:   aws.String("Deployments"),
+				OutputValue: aws.String("web=1"),
+			},
+		},
+	}, twelvefactor.NullStatusStream)
+	assert.Equal(t, context.Canceled, err)
+
+	e.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_UpdateRolledBack(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	x := new(mockS3Client)
+	c := new(mockCloudFormationClient)
+	e := new(mockECSClient)
+	s := &Scheduler{
+		Template:       template.Must(template.New("t").Parse("{}")),
+		Bucket:         "bucket",
+		Cluster:        "cluster",
+		cloudformation: c,
+		ecs:            e,
+		s3:             x,
+		db:             db,
+		after:          fakeAfter,
+	}
+
+	x.On("PutObject", &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Body:        bytes.NewReader([]byte("{}")),
+		Key:         aws.String("/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+		ContentType: aws.String("application/json"),
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	c.On("ValidateTemplate", &cloudformation.ValidateTemplateInput{
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.ValidateTemplateOutput{}, nil)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackStatus: aws.String("CREATE_COMPLETE")},
+		},
+	}, nil).Twice()
+
+	c.On("UpdateStack", &cloudformation.UpdateStackInput{
+		StackName:   aws.String("acme-inc"),
+		TemplateURL: aws.String("https://bucket.s3.amazonaws.com/acme-inc/c9366591-ab68-4d49-a333-95ce5a23df68/bf21a9e8fbc5a3846fb05b4fa0859e0917b2202f"),
+	}).Return(&cloudformation.UpdateStackOutput{}, nil)
+
+	c.On("WaitUntilStackUpdateComplete", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(errors.New("ResourceNotReady: failed waiting for successful resource state"))
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{
+				StackStatus:       aws.String("UPDATE_ROLLBACK_COMPLETE"),
+				StackStatusReason: aws.String("Resource creation cancelled"),
+			},
+		},
+	}, nil)
+
+	err := s.Submit(context.Background(), &twelvefactor.Manifest{
+		AppID:          "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:           "acme-inc",
+		DetectFailures: true,
+	}, twelvefactor.NullStatusStream)
+	assert.IsType(t, &twelvefactor.DeploymentError{}, err)
+	assert.EqualError(t, err, "deployment failed: stack update failed and was rolled back: Resource creation cancelled")
+
+	c.AssertExpectations(t)
+	x.AssertExpectations(t)
+}
+
 func TestScheduler_Submit_Superseded(t *testing.T) {
 	db := newDB(t)
 	defer db.Close()
@@ -1487,6 +1815,66 @@ func TestScheduler_Restart(t *testing.T) {
 	x.AssertExpectations(t)
 }
 
+func TestScheduler_Cancel(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	c := new(mockCloudFormationClient)
+	s := &Scheduler{
+		cloudformation: c,
+		db:             db,
+		after:          fakeAfter,
+	}
+
+	_, err := db.Exec(`INSERT INTO stacks (app_id, stack_name) VALUES ($1, $2)`, "c9366591-ab68-4d49-a333-95ce5a23df68", "acme-inc")
+	assert.NoError(t, err)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackStatus: aws.String("UPDATE_IN_PROGRESS")},
+		},
+	}, nil)
+
+	c.On("CancelUpdateStack", &cloudformation.CancelUpdateStackInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.CancelUpdateStackOutput{}, nil)
+
+	err = s.Cancel(context.Background(), "c9366591-ab68-4d49-a333-95ce5a23df68")
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Cancel_NotUpdating(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	c := new(mockCloudFormationClient)
+	s := &Scheduler{
+		cloudformation: c,
+		db:             db,
+		after:          fakeAfter,
+	}
+
+	_, err := db.Exec(`INSERT INTO stacks (app_id, stack_name) VALUES ($1, $2)`, "c9366591-ab68-4d49-a333-95ce5a23df68", "acme-inc")
+	assert.NoError(t, err)
+
+	c.On("DescribeStacks", &cloudformation.DescribeStacksInput{
+		StackName: aws.String("acme-inc"),
+	}).Return(&cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackStatus: aws.String("UPDATE_COMPLETE")},
+		},
+	}, nil)
+
+	err = s.Cancel(context.Background(), "c9366591-ab68-4d49-a333-95ce5a23df68")
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+}
+
 func TestScheduler_Run_Detached(t *testing.T) {
 	db := newDB(t)
 	defer db.Close()
@@ -1677,6 +2065,8 @@ func TestScheduler_Run_Attached(t *testing.T) {
 		RawTerminal:  true,
 	}).Return(nil)
 
+	d.On("WaitContainer", "4c01db0b339c").Return(0, nil)
+
 	err = s.Run(context.Background(), &twelvefactor.Manifest{
 		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
 		Name:  "acme-inc",
@@ -1761,6 +2151,11 @@ func (m *mockCloudFormationClient) UpdateStack(input *cloudformation.UpdateStack
 	return args.Get(0).(*cloudformation.UpdateStackOutput), args.Error(1)
 }
 
+func (m *mockCloudFormationClient) CancelUpdateStack(input *cloudformation.CancelUpdateStackInput) (*cloudformation.CancelUpdateStackOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*cloudformation.CancelUpdateStackOutput), args.Error(1)
+}
+
 func (m *mockCloudFormationClient) DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
 	args := m.Called(input)
 	return args.Get(0).(*cloudformation.DeleteStackOutput), args.Error(1)
@@ -1880,10 +2275,15 @@ func (m *mockDockerClient) AttachToContainer(options docker.AttachToContainerOpt
 	return args.Error(0)
 }
 
+func (m *mockDockerClient) WaitContainer(id string) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
 // fakeAfter is a helper function that will resolve immediately
 // except in cases where a lockWait is specified.
 func fakeAfter(d time.Duration) <-chan time.Time {
-	if d == lockWait || d == stackOperationTimeout {
+	if d == lockWait || d == stackOperationTimeout || d == DefaultStabilizationTimeout {
 		return nil
 	}
 	ch := make(chan time.Time)
@@ -22,6 +22,8 @@ type ContainerDefinitionProperties struct {
 	PortMappings     []*PortMappingProperties `json:",omitempty"`
 	Ulimits          interface{}              `json:",omitempty"`
 	LogConfiguration interface{}              `json:",omitempty"`
+	HealthCheck      interface{}              `json:",omitempty"`
+	Links            interface{}              `json:",omitempty"`
 }
 
 type TaskDefinitionProperties struct {
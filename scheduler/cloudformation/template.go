@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -20,6 +21,7 @@ import (
 	"github.com/remind101/empire/pkg/arn"
 	"github.com/remind101/empire/pkg/bytesize"
 	"github.com/remind101/empire/pkg/troposphere"
+	"github.com/remind101/empire/procfile"
 	"github.com/remind101/empire/twelvefactor"
 )
 
@@ -98,9 +100,14 @@ const (
 
 	runTaskFunction = "RunTaskFunction"
 
-	appEnvironment = "AppEnvironment"
+	appEnvironment       = "AppEnvironment"
+	canaryAppEnvironment = "CanaryAppEnvironment"
 
 	restartLabel = "cloudformation.restart-key"
+
+	// Label that identifies sidecar containers, and the name of the
+	// sidecar.
+	sidecarLabel = "empire.app.sidecar"
 )
 
 // This implements the Template interface to create a suitable CloudFormation
@@ -140,6 +147,9 @@ type EmpireTemplate struct {
 	// The ARN of the SNS topic to provision instance ports.
 	CustomResourcesTopic string
 
+	// The log configuration for containers. Option values are rendered as a
+	// text/template with the app's twelvefactor.Manifest, so that logs can
+	// be sent to a log group per app (e.g. awslogs-group=/empire/{{.Name}}).
 	LogConfiguration *ecs.LogConfiguration
 
 	// Any extra outputs to attach to the template.
@@ -179,6 +189,11 @@ func (t *EmpireTemplate) Validate() error {
 	if t.CustomResourcesTopic == "" {
 		return r("CustomResourcesTopic")
 	}
+	if t.LogConfiguration != nil {
+		if _, err := t.logConfiguration(&twelvefactor.Manifest{}); err != nil {
+			return fmt.Errorf("invalid LogConfiguration: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -255,12 +270,19 @@ func (t *EmpireTemplate) Build(data *TemplateData) (*troposphere.Template, error
 			taskDefinition := t.addScheduledTask(tmpl, app, p)
 			scheduledProcesses[p.Type] = taskDefinition.Name
 		default:
-			service, err := t.addService(tmpl, app, p, data.StackTags)
+			canary := canaryProcess(app, p)
+			service, err := t.addService(tmpl, app, p, canary, data.StackTags)
 			if err != nil {
 				return tmpl, err
 			}
 			serviceMappings = append(serviceMappings, Join("=", p.Type, Ref(service)))
 			deploymentMappings = append(deploymentMappings, Join("=", p.Type, GetAtt(service, "DeploymentId")))
+
+			if canary != nil {
+				canaryService := t.addCanaryService(tmpl, app, canary, service)
+				serviceMappings = append(serviceMappings, Join("=", canaryProcessType(p), Ref(canaryService)))
+				deploymentMappings = append(deploymentMappings, Join("=", canaryProcessType(p), GetAtt(canaryService, "DeploymentId")))
+			}
 		}
 	}
 
@@ -275,8 +297,7 @@ func (t *EmpireTemplate) Build(data *TemplateData) (*troposphere.Template, error
 	return tmpl, nil
 }
 
-func (t *EmpireTemplate) addTaskDefinition(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process) (troposphere.NamedResource, *ContainerDefinitionProperties) {
-	key := processResourceName(p.Type)
+func (t *EmpireTemplate) addTaskDefinition(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process, key, appEnv string) (troposphere.NamedResource, *ContainerDefinitionProperties) {
 	// The task definition that will be used to run the ECS task.
 	taskDefinition := troposphere.NamedResource{
 		Name: fmt.Sprintf("%sTaskDefinition", key),
@@ -285,6 +306,18 @@ func (t *EmpireTemplate) addTaskDefinition(tmpl *troposphere.Template, app *twel
 	cd := t.ContainerDefinition(app, p)
 	containerDefinition := cloudformationContainerDefinition(cd)
 
+	// Sidecars run in the same task, and are linked to the process'
+	// container so that they can be reached by name.
+	var sidecars []*ContainerDefinitionProperties
+	var links []string
+	for _, s := range p.Sidecars {
+		sidecars = append(sidecars, t.sidecarContainerDefinition(app, p, s))
+		links = append(links, s.Name)
+	}
+	if len(links) > 0 {
+		containerDefinition.Links = links
+	}
+
 	// If provided in the app environment, this role will be used when
 	// running tasks.
 	taskRole := toInterface(taskRoleArn(app))
@@ -316,26 +349,40 @@ func (t *EmpireTemplate) addTaskDefinition(tmpl *troposphere.Template, app *twel
 		}
 
 		containerDefinition.Environment = []interface{}{
-			Ref(appEnvironment),
+			Ref(appEnv),
 			Ref(processEnvironment),
 		}
+
+		for i, s := range p.Sidecars {
+			sidecarEnvironment := fmt.Sprintf("%s%sEnvironment", key, processResourceName(s.Name))
+			tmpl.Resources[sidecarEnvironment] = troposphere.Resource{
+				Type: "Custom::ECSEnvironment",
+				Properties: map[string]interface{}{
+					"ServiceToken": t.CustomResourcesTopic,
+					"Environment":  sortedEnvironment(s.Env),
+				},
+			}
+			sidecars[i].Environment = []interface{}{
+				Ref(sidecarEnvironment),
+			}
+		}
+
 		taskDefinitionProperties = &CustomTaskDefinitionProperties{
-			Volumes:      []interface{}{},
-			ServiceToken: t.CustomResourcesTopic,
-			Family:       fmt.Sprintf("%s-%s", app.Name, p.Type),
-			ContainerDefinitions: []*ContainerDefinitionProperties{
-				containerDefinition,
-			},
+			Volumes:              []interface{}{},
+			ServiceToken:         t.CustomResourcesTopic,
+			Family:               fmt.Sprintf("%s-%s", app.Name, p.Type),
+			ContainerDefinitions: append([]*ContainerDefinitionProperties{containerDefinition}, sidecars...),
 			TaskRoleArn:          taskRole,
 			PlacementConstraints: placementConstraints,
 		}
 	} else {
 		containerDefinition.Environment = cd.Environment
+		if h := p.HealthCheck; h != nil && h.Container != nil {
+			containerDefinition.HealthCheck = containerHealthCheck(h.Container)
+		}
 		taskDefinitionProperties = &TaskDefinitionProperties{
-			Volumes: []interface{}{},
-			ContainerDefinitions: []*ContainerDefinitionProperties{
-				containerDefinition,
-			},
+			Volumes:              []interface{}{},
+			ContainerDefinitions: append([]*ContainerDefinitionProperties{containerDefinition}, sidecars...),
 			TaskRoleArn:          taskRole,
 			PlacementConstraints: placementConstraints,
 		}
@@ -353,7 +400,7 @@ func (t *EmpireTemplate) addTaskDefinition(tmpl *troposphere.Template, app *twel
 func (t *EmpireTemplate) addScheduledTask(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process) troposphere.NamedResource {
 	key := processResourceName(p.Type)
 
-	taskDefinition, _ := t.addTaskDefinition(tmpl, app, p)
+	taskDefinition, _ := t.addTaskDefinition(tmpl, app, p, key, appEnvironment)
 
 	state := "DISABLED"
 	if p.Quantity > 0 {
@@ -392,7 +439,7 @@ func (t *EmpireTemplate) addScheduledTask(tmpl *troposphere.Template, app *twelv
 	return taskDefinition
 }
 
-func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process, stackTags []*cloudformation.Tag) (serviceName string, err error) {
+func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process, canary *twelvefactor.Process, stackTags []*cloudformation.Tag) (serviceName string, err error) {
 	key := processResourceName(p.Type)
 
 	// Process specific tags to apply to resources.
@@ -413,6 +460,9 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 
 	var serviceDependencies []string
 	loadBalancers := []map[string]interface{}{}
+
+	// Identifies the ALB target group, for request count based autoscaling.
+	var targetGroupResourceLabel interface{}
 	if p.Exposure != nil {
 		scheme := schemeInternal
 		sg := t.InternalSecurityGroupID
@@ -449,17 +499,56 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 
 			tmpl.AddResource(loadBalancer)
 
+			targetGroupProperties := map[string]interface{}{
+				"Port":     65535, // Not used. ECS sets a port override when registering targets.
+				"Protocol": "HTTP",
+				"VpcId":    t.VpcId,
+				"Tags":     append(stackTags, tags...),
+			}
+			if h := p.HealthCheck; h != nil {
+				targetGroupHealthCheck(targetGroupProperties, h)
+			}
+
 			targetGroup := fmt.Sprintf("%sTargetGroup", key)
 			tmpl.Resources[targetGroup] = troposphere.Resource{
-				Type: "AWS::ElasticLoadBalancingV2::TargetGroup",
-				Properties: map[string]interface{}{
-					"Port":     65535, // Not used. ECS sets a port override when registering targets.
-					"Protocol": "HTTP",
-					"VpcId":    t.VpcId,
-					"Tags":     append(stackTags, tags...),
+				Type:       "AWS::ElasticLoadBalancingV2::TargetGroup",
+				Properties: targetGroupProperties,
+			}
+
+			defaultActions := []interface{}{
+				map[string]interface{}{
+					"TargetGroupArn": Ref(targetGroup),
+					"Type":           "forward",
 				},
 			}
 
+			// When a canary is being rolled out, the new version
+			// runs behind a second target group, and the
+			// listeners split traffic between the two.
+			if canary != nil {
+				canaryTargetGroup := fmt.Sprintf("%sCanaryTargetGroup", key)
+				tmpl.Resources[canaryTargetGroup] = tmpl.Resources[targetGroup]
+
+				weight := app.Canary.Weight
+				defaultActions = []interface{}{
+					map[string]interface{}{
+						"Type": "forward",
+						"ForwardConfig": map[string]interface{}{
+							"TargetGroups": []interface{}{
+								map[string]interface{}{
+									"TargetGroupArn": Ref(targetGroup),
+									"Weight":         100 - weight,
+								},
+								map[string]interface{}{
+									"TargetGroupArn": Ref(canaryTargetGroup),
+									"Weight":         weight,
+								},
+							},
+						},
+					},
+				}
+			}
+
 			// Add a port mapping for each unique container port.
 			containerPorts := make(map[int]bool)
 			for _, port := range p.Exposure.Ports {
@@ -501,12 +590,7 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 							"LoadBalancerArn": Ref(loadBalancer),
 							"Port":            port.Host,
 							"Protocol":        "HTTP",
-							"DefaultActions": []interface{}{
-								map[string]interface{}{
-									"TargetGroupArn": Ref(targetGroup),
-									"Type":           "forward",
-								},
-							},
+							"DefaultActions":  defaultActions,
 						},
 					}
 				case *twelvefactor.HTTPS:
@@ -528,12 +612,7 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 							"LoadBalancerArn": Ref(loadBalancer),
 							"Port":            port.Host,
 							"Protocol":        "HTTPS",
-							"DefaultActions": []interface{}{
-								map[string]interface{}{
-									"TargetGroupArn": Ref(targetGroup),
-									"Type":           "forward",
-								},
-							},
+							"DefaultActions":  defaultActions,
 						},
 					}
 				default:
@@ -549,6 +628,7 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 				"ContainerPort":  p.Exposure.Ports[0].Container,
 				"TargetGroupArn": Ref(targetGroup),
 			})
+			targetGroupResourceLabel = Join("/", GetAtt(loadBalancer, "LoadBalancerFullName"), GetAtt(targetGroup, "TargetGroupFullName"))
 		default:
 			loadBalancer = troposphere.NamedResource{
 				Name: fmt.Sprintf("%sLoadBalancer", key),
@@ -631,21 +711,27 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 				}
 			}
 
-			loadBalancer.Resource = troposphere.Resource{
-				Type: "AWS::ElasticLoadBalancing::LoadBalancer",
-				Properties: map[string]interface{}{
-					"Scheme":         scheme,
-					"SecurityGroups": []string{sg},
-					"Subnets":        subnets,
-					"Listeners":      listeners,
-					"CrossZone":      true,
-					"Tags":           tags,
-					"ConnectionDrainingPolicy": map[string]interface{}{
-						"Enabled": true,
-						"Timeout": defaultConnectionDrainingTimeout,
-					},
+			loadBalancerProperties := map[string]interface{}{
+				"Scheme":         scheme,
+				"SecurityGroups": []string{sg},
+				"Subnets":        subnets,
+				"Listeners":      listeners,
+				"CrossZone":      true,
+				"Tags":           tags,
+				"ConnectionDrainingPolicy": map[string]interface{}{
+					"Enabled": true,
+					"Timeout": defaultConnectionDrainingTimeout,
 				},
 			}
+			if h := p.HealthCheck; h != nil {
+				instancePort := instancePorts[p.Exposure.Ports[0].Container]
+				loadBalancerProperties["HealthCheck"] = elbHealthCheck(GetAtt(instancePort, "InstancePort"), h)
+			}
+
+			loadBalancer.Resource = troposphere.Resource{
+				Type:       "AWS::ElasticLoadBalancing::LoadBalancer",
+				Properties: loadBalancerProperties,
+			}
 			tmpl.AddResource(loadBalancer)
 
 			loadBalancers = append(loadBalancers, map[string]interface{}{
@@ -691,7 +777,7 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 		}
 	}
 
-	taskDefinition, containerDefinition := t.addTaskDefinition(tmpl, app, p)
+	taskDefinition, containerDefinition := t.addTaskDefinition(tmpl, app, p, key, appEnvironment)
 
 	containerDefinition.DockerLabels[restartLabel] = Ref(restartParameter)
 	containerDefinition.PortMappings = portMappings
@@ -704,17 +790,8 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 		"ServiceName":    fmt.Sprintf("%s-%s", app.Name, p.Type),
 		"ServiceToken":   t.CustomResourcesTopic,
 	}
-	if v := p.ECS; v != nil {
-		if len(v.PlacementStrategy) > 0 {
-			var placementStrategy []interface{}
-			for _, c := range v.PlacementStrategy {
-				placementStrategy = append(placementStrategy, map[string]interface{}{
-					"Type":  c.Type,
-					"Field": c.Field,
-				})
-			}
-			serviceProperties["PlacementStrategy"] = placementStrategy
-		}
+	if placementStrategy := placementStrategy(p); placementStrategy != nil {
+		serviceProperties["PlacementStrategy"] = placementStrategy
 	}
 	if len(loadBalancers) > 0 {
 		serviceProperties["Role"] = t.ServiceRole
@@ -730,9 +807,295 @@ func (t *EmpireTemplate) addService(tmpl *troposphere.Template, app *twelvefacto
 		service.Resource.DependsOn = serviceDependencies
 	}
 	tmpl.AddResource(service)
+
+	if p.Autoscaling != nil {
+		if err = t.addAutoscaling(tmpl, p, key, service, targetGroupResourceLabel); err != nil {
+			return
+		}
+	}
+
 	return service.Name, nil
 }
 
+// addAutoscaling adds an Application Auto Scaling target for the service, and
+// a target tracking policy for each of the configured targets. The quantity of
+// the process is used as the minimum capacity, so that scaling the process
+// sets a baseline for the autoscaler, rather than fighting with it.
+func (t *EmpireTemplate) addAutoscaling(tmpl *troposphere.Template, p *twelvefactor.Process, key string, service troposphere.NamedResource, targetGroupResourceLabel interface{}) error {
+	a := p.Autoscaling
+
+	if a.TargetRequestCount != 0 && targetGroupResourceLabel == nil {
+		return fmt.Errorf("autoscaling on request count requires %s to be exposed through an Application Load Balancer", p.Type)
+	}
+
+	// Autoscaling is suspended while the process is scaled down (e.g. in
+	// maintenance mode).
+	if p.Quantity == 0 {
+		return nil
+	}
+
+	minCapacity := a.Min
+	if p.Quantity > minCapacity {
+		minCapacity = p.Quantity
+	}
+	maxCapacity := a.Max
+	if minCapacity > maxCapacity {
+		maxCapacity = minCapacity
+	}
+
+	scalableTarget := troposphere.NamedResource{
+		Name: fmt.Sprintf("%sScalableTarget", key),
+		Resource: troposphere.Resource{
+			Type: "AWS::ApplicationAutoScaling::ScalableTarget",
+			Properties: map[string]interface{}{
+				"MinCapacity":       minCapacity,
+				"MaxCapacity":       maxCapacity,
+				"ResourceId":        Join("/", "service", t.Cluster, GetAtt(service, "Name")),
+				"RoleARN":           t.serviceRoleArn(),
+				"ScalableDimension": "ecs:service:DesiredCount",
+				"ServiceNamespace":  "ecs",
+			},
+		},
+	}
+	tmpl.AddResource(scalableTarget)
+
+	addPolicy := func(name string, target int, metric map[string]interface{}) {
+		tmpl.Resources[fmt.Sprintf("%s%sScalingPolicy", key, name)] = troposphere.Resource{
+			Type: "AWS::ApplicationAutoScaling::ScalingPolicy",
+			Properties: map[string]interface{}{
+				"PolicyName":      Join("-", Ref("AWS::StackName"), p.Type, name),
+				"PolicyType":      "TargetTrackingScaling",
+				"ScalingTargetId": Ref(scalableTarget),
+				"TargetTrackingScalingPolicyConfiguration": map[string]interface{}{
+					"TargetValue":                   target,
+					"PredefinedMetricSpecification": metric,
+				},
+			},
+		}
+	}
+
+	if a.TargetCPUUtilization != 0 {
+		addPolicy("CPU", a.TargetCPUUtilization, map[string]interface{}{
+			"PredefinedMetricType": "ECSServiceAverageCPUUtilization",
+		})
+	}
+	if a.TargetMemoryUtilization != 0 {
+		addPolicy("Memory", a.TargetMemoryUtilization, map[string]interface{}{
+			"PredefinedMetricType": "ECSServiceAverageMemoryUtilization",
+		})
+	}
+	if a.TargetRequestCount != 0 {
+		addPolicy("RequestCount", a.TargetRequestCount, map[string]interface{}{
+			"PredefinedMetricType": "ALBRequestCountPerTarget",
+			"ResourceLabel":        targetGroupResourceLabel,
+		})
+	}
+
+	return nil
+}
+
+// addCanaryService adds an ECS service that runs the canary version of an ALB
+// process, behind the canary target group that was created by addService. The
+// service runs a percentage of the instances of the process, based on the
+// canary weight.
+func (t *EmpireTemplate) addCanaryService(tmpl *troposphere.Template, app *twelvefactor.Manifest, p *twelvefactor.Process, service string) (serviceName string) {
+	canary := app.Canary.Manifest
+	key := processResourceName(p.Type)
+
+	appEnv := appEnvironment
+	if taskDefinitionResourceType(canary) == "Custom::ECSTaskDefinition" {
+		appEnv = canaryAppEnvironment
+		tmpl.Resources[appEnv] = troposphere.Resource{
+			Type: "Custom::ECSEnvironment",
+			Properties: map[string]interface{}{
+				"ServiceToken": t.CustomResourcesTopic,
+				"Environment":  sortedEnvironment(canary.Env),
+			},
+		}
+	}
+
+	taskDefinition, containerDefinition := t.addTaskDefinition(tmpl, canary, p, fmt.Sprintf("%sCanary", key), appEnv)
+
+	containerDefinition.DockerLabels[restartLabel] = Ref(restartParameter)
+	containerDefinition.PortMappings = []*PortMappingProperties{
+		{
+			ContainerPort: p.Exposure.Ports[0].Container,
+			HostPort:      0,
+		},
+	}
+
+	serviceProperties := map[string]interface{}{
+		"Cluster":      t.Cluster,
+		"DesiredCount": canaryDesiredCount(p.Quantity, app.Canary.Weight),
+		"LoadBalancers": []map[string]interface{}{
+			{
+				"ContainerName":  p.Type,
+				"ContainerPort":  p.Exposure.Ports[0].Container,
+				"TargetGroupArn": Ref(fmt.Sprintf("%sCanaryTargetGroup", key)),
+			},
+		},
+		"TaskDefinition": Ref(taskDefinition),
+		"ServiceName":    fmt.Sprintf("%s-%s", app.Name, canaryProcessType(p)),
+		"ServiceToken":   t.CustomResourcesTopic,
+		"Role":           t.ServiceRole,
+	}
+	if placementStrategy := placementStrategy(p); placementStrategy != nil {
+		serviceProperties["PlacementStrategy"] = placementStrategy
+	}
+
+	canaryService := troposphere.NamedResource{
+		Name: fmt.Sprintf("%sCanaryService", key),
+		Resource: troposphere.Resource{
+			Type:       "Custom::ECSService",
+			Properties: serviceProperties,
+			// The canary target group can only be used once the
+			// listeners that reference it have been created.
+			DependsOn: []string{service},
+		},
+	}
+	tmpl.AddResource(canaryService)
+	return canaryService.Name
+}
+
+// canaryProcess returns the canary version of the process, if the process
+// should have a canary. Only processes that are exposed through an ALB, in both
+// versions of the app, support canaries.
+func canaryProcess(app *twelvefactor.Manifest, p *twelvefactor.Process) *twelvefactor.Process {
+	if app.Canary == nil || p.Exposure == nil {
+		return nil
+	}
+
+	canary := app.Canary.Manifest.Process(p.Type)
+	if canary == nil || canary.Exposure == nil {
+		return nil
+	}
+
+	if loadBalancerType(app, p) != applicationLoadBalancer || loadBalancerType(app.Canary.Manifest, canary) != applicationLoadBalancer {
+		return nil
+	}
+
+	return canary
+}
+
+// canaryProcessType returns the name used to identify the canary version of a
+// process.
+func canaryProcessType(p *twelvefactor.Process) string {
+	return fmt.Sprintf("%s-canary", p.Type)
+}
+
+// canaryDesiredCount returns the number of instances of a process that should
+// run the canary version, given the weight of the canary. At least 1 instance
+// is run, unless the process is scaled down to 0.
+func canaryDesiredCount(quantity, weight int) int {
+	if quantity <= 0 {
+		return 0
+	}
+
+	n := (quantity*weight + 99) / 100
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Defaults for classic ELB health checks, which require all of the settings to
+// be provided. These match the defaults that AWS uses.
+const (
+	defaultELBHealthCheckInterval           = 30
+	defaultELBHealthCheckTimeout            = 5
+	defaultELBHealthCheckHealthyThreshold   = 10
+	defaultELBHealthCheckUnhealthyThreshold = 2
+)
+
+// targetGroupHealthCheck sets the health check properties on an ALB target
+// group. Settings that aren't provided use the AWS defaults.
+func targetGroupHealthCheck(properties map[string]interface{}, h *procfile.HealthCheck) {
+	if h.Path != "" {
+		properties["HealthCheckPath"] = h.Path
+	}
+	if h.Interval != 0 {
+		properties["HealthCheckIntervalSeconds"] = h.Interval
+	}
+	if h.Timeout != 0 {
+		properties["HealthCheckTimeoutSeconds"] = h.Timeout
+	}
+	if h.HealthyThreshold != 0 {
+		properties["HealthyThresholdCount"] = h.HealthyThreshold
+	}
+	if h.UnhealthyThreshold != 0 {
+		properties["UnhealthyThresholdCount"] = h.UnhealthyThreshold
+	}
+	if h.StatusCodes != "" {
+		properties["Matcher"] = map[string]interface{}{
+			"HttpCode": h.StatusCodes,
+		}
+	}
+}
+
+// elbHealthCheck returns the HealthCheck property for a classic ELB. When a
+// path is provided, the ELB makes an HTTP request to the instance port,
+// otherwise, it checks that a TCP connection can be established.
+func elbHealthCheck(instancePort interface{}, h *procfile.HealthCheck) map[string]interface{} {
+	target := Join("", "TCP:", instancePort)
+	if h.Path != "" {
+		target = Join("", "HTTP:", instancePort, h.Path)
+	}
+
+	withDefault := func(v, d int) int {
+		if v == 0 {
+			return d
+		}
+		return v
+	}
+
+	return map[string]interface{}{
+		"Target":             target,
+		"Interval":           fmt.Sprintf("%d", withDefault(h.Interval, defaultELBHealthCheckInterval)),
+		"Timeout":            fmt.Sprintf("%d", withDefault(h.Timeout, defaultELBHealthCheckTimeout)),
+		"HealthyThreshold":   fmt.Sprintf("%d", withDefault(h.HealthyThreshold, defaultELBHealthCheckHealthyThreshold)),
+		"UnhealthyThreshold": fmt.Sprintf("%d", withDefault(h.UnhealthyThreshold, defaultELBHealthCheckUnhealthyThreshold)),
+	}
+}
+
+// containerHealthCheck returns the HealthCheck property for an ECS container
+// definition.
+func containerHealthCheck(c *procfile.ContainerHealthCheck) map[string]interface{} {
+	hc := map[string]interface{}{
+		"Command": []string(c.Command),
+	}
+	if c.Interval != 0 {
+		hc["Interval"] = c.Interval
+	}
+	if c.Timeout != 0 {
+		hc["Timeout"] = c.Timeout
+	}
+	if c.Retries != 0 {
+		hc["Retries"] = c.Retries
+	}
+	if c.StartPeriod != 0 {
+		hc["StartPeriod"] = c.StartPeriod
+	}
+	return hc
+}
+
+// placementStrategy returns the ECS placement strategy for the process, or nil
+// if none was provided.
+func placementStrategy(p *twelvefactor.Process) []interface{} {
+	v := p.ECS
+	if v == nil || len(v.PlacementStrategy) == 0 {
+		return nil
+	}
+
+	var placementStrategy []interface{}
+	for _, c := range v.PlacementStrategy {
+		placementStrategy = append(placementStrategy, map[string]interface{}{
+			"Type":  c.Type,
+			"Field": c.Field,
+		})
+	}
+	return placementStrategy
+}
+
 // If the ServiceRole option is not an ARN, it will return a CloudFormation
 // expression that expands the ServiceRole to an ARN.
 func (t *EmpireTemplate) serviceRoleArn() interface{} {
@@ -782,12 +1145,90 @@ func (t *EmpireTemplate) ContainerDefinition(app *twelvefactor.Manifest, p *twel
 		Essential:        aws.Bool(true),
 		Memory:           aws.Int64(int64(p.Memory / bytesize.MB)),
 		Environment:      sortedEnvironment(twelvefactor.Env(app, p)),
-		LogConfiguration: t.LogConfiguration,
+		LogConfiguration: t.appLogConfiguration(app),
 		DockerLabels:     labels,
 		Ulimits:          ulimits,
 	}
 }
 
+// appLogConfiguration returns the LogConfiguration for the containers of an
+// app. The template is validated in Validate, so if rendering fails the
+// LogConfiguration is used as is.
+func (t *EmpireTemplate) appLogConfiguration(app *twelvefactor.Manifest) *ecs.LogConfiguration {
+	c, err := t.logConfiguration(app)
+	if err != nil {
+		return t.LogConfiguration
+	}
+	return c
+}
+
+// logConfiguration renders the option values of the LogConfiguration with the
+// app.
+func (t *EmpireTemplate) logConfiguration(app *twelvefactor.Manifest) (*ecs.LogConfiguration, error) {
+	if t.LogConfiguration == nil {
+		return nil, nil
+	}
+
+	options := make(map[string]*string)
+	for k, v := range t.LogConfiguration.Options {
+		if v == nil || !strings.Contains(*v, "{{") {
+			options[k] = v
+			continue
+		}
+
+		tmpl, err := template.New(k).Parse(*v)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, app); err != nil {
+			return nil, err
+		}
+		options[k] = aws.String(buf.String())
+	}
+
+	return &ecs.LogConfiguration{
+		LogDriver: t.LogConfiguration.LogDriver,
+		Options:   options,
+	}, nil
+}
+
+// sidecarContainerDefinition generates the container definition for a sidecar
+// of a process. Sidecar ports are mapped to dynamic host ports.
+func (t *EmpireTemplate) sidecarContainerDefinition(app *twelvefactor.Manifest, p *twelvefactor.Process, s *twelvefactor.Sidecar) *ContainerDefinitionProperties {
+	labels := make(map[string]interface{})
+	for k, v := range twelvefactor.Labels(app, p) {
+		labels[k] = v
+	}
+	labels[sidecarLabel] = s.Name
+
+	var portMappings []*PortMappingProperties
+	for _, port := range s.Ports {
+		portMappings = append(portMappings, &PortMappingProperties{
+			ContainerPort: port,
+			HostPort:      0,
+		})
+	}
+
+	c := &ContainerDefinitionProperties{
+		Name:         s.Name,
+		Image:        s.Image.String(),
+		Essential:    s.Essential,
+		Memory:       int64(s.Memory / bytesize.MB),
+		Environment:  sortedEnvironment(s.Env),
+		DockerLabels: labels,
+		PortMappings: portMappings,
+	}
+	if len(s.Command) > 0 {
+		c.Command = s.Command
+	}
+	if t.LogConfiguration != nil {
+		c.LogConfiguration = t.appLogConfiguration(app)
+	}
+	return c
+}
+
 // HostedZone returns the HostedZone for the ZoneID.
 func HostedZone(config client.ConfigProvider, hostedZoneID string) (*route53.HostedZone, error) {
 	r := route53.New(config)
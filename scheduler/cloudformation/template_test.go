@@ -133,6 +133,272 @@ func TestEmpireTemplate(t *testing.T) {
 			},
 		},
 
+		{
+			"canary-alb.json",
+			&twelvefactor.Manifest{
+				AppID:   "1234",
+				Release: "v1",
+				Name:    "acme-inc",
+				Env: map[string]string{
+					"LOAD_BALANCER_TYPE": "alb",
+				},
+				Processes: []*twelvefactor.Process{
+					{
+						Type:    "web",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "v1"},
+						Command: []string{"./bin/web"},
+						Exposure: &twelvefactor.Exposure{
+							Ports: []twelvefactor.Port{
+								{
+									Host:      80,
+									Container: 8080,
+									Protocol:  &twelvefactor.HTTP{},
+								},
+							},
+						},
+						Labels: map[string]string{
+							"empire.app.process": "web",
+						},
+						Env: map[string]string{
+							"PORT": "8080",
+						},
+						Memory:    128 * bytesize.MB,
+						CPUShares: 256,
+						Quantity:  10,
+						Nproc:     256,
+					},
+					{
+						Type:    "worker",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "v1"},
+						Command: []string{"./bin/worker"},
+						Labels: map[string]string{
+							"empire.app.process": "worker",
+						},
+					},
+				},
+				Canary: &twelvefactor.Canary{
+					Weight: 10,
+					Manifest: &twelvefactor.Manifest{
+						AppID:   "1234",
+						Release: "v2",
+						Name:    "acme-inc",
+						Env: map[string]string{
+							"LOAD_BALANCER_TYPE": "alb",
+						},
+						Processes: []*twelvefactor.Process{
+							{
+								Type:    "web",
+								Image:   image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+								Command: []string{"./bin/web"},
+								Exposure: &twelvefactor.Exposure{
+									Ports: []twelvefactor.Port{
+										{
+											Host:      80,
+											Container: 8080,
+											Protocol:  &twelvefactor.HTTP{},
+										},
+									},
+								},
+								Labels: map[string]string{
+									"empire.app.process": "web",
+								},
+								Env: map[string]string{
+									"PORT": "8080",
+								},
+								Memory:    128 * bytesize.MB,
+								CPUShares: 256,
+								Quantity:  10,
+								Nproc:     256,
+							},
+							{
+								Type:    "worker",
+								Image:   image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+								Command: []string{"./bin/worker"},
+								Labels: map[string]string{
+									"empire.app.process": "worker",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			"autoscaling.json",
+			&twelvefactor.Manifest{
+				AppID:   "1234",
+				Release: "v1",
+				Name:    "acme-inc",
+				Env: map[string]string{
+					"LOAD_BALANCER_TYPE": "alb",
+				},
+				Processes: []*twelvefactor.Process{
+					{
+						Type:    "web",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+						Command: []string{"./bin/web"},
+						Exposure: &twelvefactor.Exposure{
+							Ports: []twelvefactor.Port{
+								{
+									Host:      80,
+									Container: 8080,
+									Protocol:  &twelvefactor.HTTP{},
+								},
+							},
+						},
+						Labels: map[string]string{
+							"empire.app.process": "web",
+						},
+						Env: map[string]string{
+							"PORT": "8080",
+						},
+						Memory:    128 * bytesize.MB,
+						CPUShares: 256,
+						Quantity:  3,
+						Nproc:     256,
+						Autoscaling: &procfile.Autoscaling{
+							Min:                2,
+							Max:                10,
+							TargetRequestCount: 1000,
+						},
+					},
+					{
+						Type:    "worker",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+						Command: []string{"./bin/worker"},
+						Labels: map[string]string{
+							"empire.app.process": "worker",
+						},
+						Quantity: 1,
+						Autoscaling: &procfile.Autoscaling{
+							Min:                     2,
+							Max:                     5,
+							TargetCPUUtilization:    70,
+							TargetMemoryUtilization: 80,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			"healthcheck.json",
+			&twelvefactor.Manifest{
+				AppID:   "1234",
+				Release: "v1",
+				Name:    "acme-inc",
+				Processes: []*twelvefactor.Process{
+					{
+						Type:    "web",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+						Command: []string{"./bin/web"},
+						Env: map[string]string{
+							"PORT":                        "8080",
+							"EMPIRE_X_LOAD_BALANCER_TYPE": "alb",
+						},
+						Exposure: &twelvefactor.Exposure{
+							Ports: []twelvefactor.Port{
+								{
+									Host:      80,
+									Container: 8080,
+									Protocol:  &twelvefactor.HTTP{},
+								},
+							},
+						},
+						Labels: map[string]string{
+							"empire.app.process": "web",
+						},
+						Quantity: 1,
+						HealthCheck: &procfile.HealthCheck{
+							Path:               "/health",
+							Interval:           10,
+							UnhealthyThreshold: 3,
+							StatusCodes:        "200-299",
+							Container: &procfile.ContainerHealthCheck{
+								Command:     procfile.HealthCheckCommand{"CMD-SHELL", "curl -f http://localhost:8080/health"},
+								StartPeriod: 60,
+							},
+						},
+					},
+					{
+						Type:    "api",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+						Command: []string{"./bin/api"},
+						Env: map[string]string{
+							"PORT": "8080",
+						},
+						Exposure: &twelvefactor.Exposure{
+							Ports: []twelvefactor.Port{
+								{
+									Host:      80,
+									Container: 8080,
+									Protocol:  &twelvefactor.HTTP{},
+								},
+							},
+						},
+						Labels: map[string]string{
+							"empire.app.process": "api",
+						},
+						Quantity: 1,
+						HealthCheck: &procfile.HealthCheck{
+							Path:    "/health",
+							Timeout: 2,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			"sidecars.json",
+			&twelvefactor.Manifest{
+				AppID:   "1234",
+				Release: "v1",
+				Name:    "acme-inc",
+				Processes: []*twelvefactor.Process{
+					{
+						Type:    "web",
+						Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+						Command: []string{"./bin/web"},
+						Env: map[string]string{
+							"PORT": "8080",
+						},
+						Exposure: &twelvefactor.Exposure{
+							Ports: []twelvefactor.Port{
+								{
+									Host:      80,
+									Container: 8080,
+									Protocol:  &twelvefactor.HTTP{},
+								},
+							},
+						},
+						Labels: map[string]string{
+							"empire.app.process": "web",
+						},
+						Quantity: 1,
+						Sidecars: []*twelvefactor.Sidecar{
+							{
+								Name:      "envoy",
+								Image:     image.Image{Repository: "envoyproxy/envoy", Tag: "v1.4.0"},
+								Command:   []string{"envoy", "-c", "/etc/envoy.yaml"},
+								Env:       map[string]string{"EMPIRE_PROCESS": "web"},
+								Ports:     []int{9901},
+								Essential: true,
+								Memory:    128 * bytesize.MB,
+							},
+							{
+								Name:   "statsd",
+								Image:  image.Image{Repository: "remind101/statsd", Tag: "latest"},
+								Env:    map[string]string{"EMPIRE_PROCESS": "web"},
+								Memory: 64 * bytesize.MB,
+							},
+						},
+					},
+				},
+			},
+		},
+
 		{
 			"https.json",
 			&twelvefactor.Manifest{
@@ -638,6 +904,32 @@ func TestScheduleExpression(t *testing.T) {
 	}
 }
 
+func TestEmpireTemplate_LogConfiguration(t *testing.T) {
+	tmpl := newTemplate()
+	tmpl.VpcId = "vpc-c68d3aa3"
+	tmpl.LogConfiguration = &ecs.LogConfiguration{
+		LogDriver: aws.String("awslogs"),
+		Options: map[string]*string{
+			"awslogs-region": aws.String("us-east-1"),
+			"awslogs-group":  aws.String("/empire/{{.Name}}"),
+		},
+	}
+	assert.NoError(t, tmpl.Validate())
+
+	app := &twelvefactor.Manifest{Name: "acme-inc"}
+	cd := tmpl.ContainerDefinition(app, &twelvefactor.Process{Type: "web"})
+	assert.Equal(t, &ecs.LogConfiguration{
+		LogDriver: aws.String("awslogs"),
+		Options: map[string]*string{
+			"awslogs-region": aws.String("us-east-1"),
+			"awslogs-group":  aws.String("/empire/acme-inc"),
+		},
+	}, cd.LogConfiguration)
+
+	tmpl.LogConfiguration.Options["awslogs-group"] = aws.String("/empire/{{.Foo}}")
+	assert.Error(t, tmpl.Validate())
+}
+
 func newTemplate() *EmpireTemplate {
 	return &EmpireTemplate{
 		Cluster:                 "cluster",
@@ -658,3 +950,21 @@ func newTemplate() *EmpireTemplate {
 		},
 	}
 }
+
+func TestCanaryDesiredCount(t *testing.T) {
+	tests := []struct {
+		quantity, weight int
+		count            int
+	}{
+		{0, 10, 0},
+		{1, 10, 1},
+		{10, 10, 1},
+		{10, 25, 3},
+		{10, 50, 5},
+		{3, 99, 3},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.count, canaryDesiredCount(tt.quantity, tt.weight))
+	}
+}
@@ -1,17 +1,27 @@
 // Package docker implements the Scheduler interface backed by the Docker API.
+//
+// Each instance of a process is run as a container on a single Docker daemon.
+// This makes it possible to run Empire without ECS, which is useful for small
+// environments and CI.
 package docker
 
 import (
 	"bytes"
-	"errors"
+	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/remind101/empire/internal/uuid"
 	"github.com/remind101/empire/pkg/dockerutil"
+	"github.com/remind101/empire/pkg/image"
 	"github.com/remind101/empire/twelvefactor"
+	"github.com/remind101/pkg/logger"
 	"golang.org/x/net/context"
 )
 
@@ -28,6 +38,7 @@ type dockerClient interface {
 	StartContainer(context.Context, string, *docker.HostConfig) error
 	StopContainer(context.Context, string, uint) error
 	AttachToContainer(context.Context, docker.AttachToContainerOptions) error
+	WaitContainer(string) (int, error)
 }
 
 const (
@@ -40,6 +51,24 @@ const (
 
 	// Label that determines what the name of the process is.
 	processLabel = "empire.app.process"
+
+	// Label that holds a hash of the container definition for long running
+	// processes. When the definition of a process changes (e.g. a new
+	// release, or a change in memory), the hash will change and the
+	// container will be replaced.
+	hashLabel = "empire.process.hash"
+
+	// Label that holds the names of the sidecars that run alongside a
+	// process container.
+	sidecarsLabel = "empire.app.sidecars"
+
+	// Label that determines what the name of the sidecar is. Only set on
+	// sidecar containers.
+	sidecarLabel = "empire.app.sidecar"
+
+	// Label that holds the id of the process container that a sidecar
+	// container runs alongside.
+	parentLabel = "empire.app.sidecar.parent"
 )
 
 // Values for `runLabel`.
@@ -137,8 +166,8 @@ func (s *AttachedScheduler) Stop(ctx context.Context, maybeContainerID string) e
 	return err
 }
 
-// Scheduler provides an implementation of the scheduler.Scheduler interface
-// backed by Docker.
+// Scheduler provides an implementation of the twelvefactor.Scheduler
+// interface backed by Docker.
 type Scheduler struct {
 	docker dockerClient
 }
@@ -151,80 +180,306 @@ func NewScheduler(client *dockerutil.Client) *Scheduler {
 	}
 }
 
-func (s *Scheduler) Run(ctx context.Context, app *twelvefactor.Manifest) error {
+// Submit converges the containers for each process to the desired quantity.
+// Containers that were created from an older definition of the process are
+// replaced one at a time, and containers for processes that no longer exist
+// are removed.
+func (s *Scheduler) Submit(ctx context.Context, app *twelvefactor.Manifest, ss twelvefactor.StatusStream) error {
+	existing, err := s.processContainers(ctx, app.AppID)
+	if err != nil {
+		return err
+	}
+
+	pulled := make(map[string]bool)
 	for _, p := range app.Processes {
-		attached := p.Stdin != nil || p.Stdout != nil || p.Stderr != nil
+		// Stop converging processes if the submission was canceled.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if p.Schedule != nil {
+			publish(ctx, ss, fmt.Sprintf("Skipping %s: scheduled processes are not supported by the Docker scheduler", p.Type))
+			continue
+		}
 
-		if !attached {
-			return errors.New("cannot run detached processes with Docker scheduler")
+		if img := p.Image.String(); !pulled[img] {
+			if err := s.pullImage(ctx, p.Image, ioutil.Discard); err != nil {
+				return err
+			}
+			pulled[img] = true
 		}
 
-		labels := twelvefactor.Labels(app, p)
-		labels[runLabel] = Attached
+		for _, sidecar := range p.Sidecars {
+			if img := sidecar.Image.String(); !pulled[img] {
+				if err := s.pullImage(ctx, sidecar.Image, ioutil.Discard); err != nil {
+					return err
+				}
+				pulled[img] = true
+			}
+		}
 
-		pullOptions, err := dockerutil.PullImageOptions(p.Image)
-		if err != nil {
+		if err := s.converge(ctx, app, p, existing[p.Type], ss); err != nil {
 			return err
 		}
-		pullOptions.OutputStream = replaceNL(p.Stderr)
-
-		if err := s.docker.PullImage(ctx, pullOptions); err != nil {
-			return fmt.Errorf("error pulling image: %v", err)
-		}
-
-		container, err := s.docker.CreateContainer(ctx, docker.CreateContainerOptions{
-			Name: uuid.New(),
-			Config: &docker.Config{
-				Tty:          true,
-				AttachStdin:  true,
-				AttachStdout: true,
-				AttachStderr: true,
-				OpenStdin:    true,
-				Memory:       int64(p.Memory),
-				CPUShares:    int64(p.CPUShares),
-				Image:        p.Image.String(),
-				Cmd:          p.Command,
-				Env:          envKeys(twelvefactor.Env(app, p)),
-				Labels:       labels,
-			},
-			HostConfig: &docker.HostConfig{
-				LogConfig: docker.LogConfig{
-					Type: "json-file",
-				},
-			},
-		})
+		delete(existing, p.Type)
+	}
+
+	// Anything left over belongs to a process that was removed.
+	for process, containers := range existing {
+		publish(ctx, ss, fmt.Sprintf("Removing %d %s containers", len(containers), process))
+		for _, c := range containers {
+			if err := s.removeProcessContainer(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// converge ensures that there are exactly p.Quantity running containers with
+// the current definition of the process.
+func (s *Scheduler) converge(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process, containers []docker.APIContainers, ss twelvefactor.StatusStream) error {
+	options := newContainerOptions(app, p)
+	hash := options.Config.Labels[hashLabel]
+
+	// Ports are published on the host, so only a single container can
+	// bind to them at a time.
+	exposed := len(options.HostConfig.PortBindings) > 0
+	if exposed && p.Quantity > 1 {
+		return fmt.Errorf("docker: cannot run %d instances of %s: processes that publish ports are limited to 1 instance", p.Quantity, p.Type)
+	}
+
+	var (
+		current []docker.APIContainers
+		stale   []docker.APIContainers
+	)
+	for _, c := range containers {
+		if c.Labels[hashLabel] == hash && c.State == "running" {
+			current = append(current, c)
+		} else {
+			stale = append(stale, c)
+		}
+	}
+
+	// Scale down.
+	for len(current) > p.Quantity {
+		c := current[len(current)-1]
+		if err := s.removeProcessContainer(ctx, c); err != nil {
+			return err
+		}
+		current = current[:len(current)-1]
+	}
+
+	// Scale up, replacing stale containers as new ones are started.
+	if n := p.Quantity - len(current); n > 0 {
+		publish(ctx, ss, fmt.Sprintf("Starting %d %s containers", n, p.Type))
+	}
+	for i := len(current); i < p.Quantity; i++ {
+		if exposed && len(stale) > 0 {
+			if err := s.removeProcessContainer(ctx, stale[0]); err != nil {
+				return err
+			}
+			stale = stale[1:]
+		}
+
+		options.Name = containerName(app.Name, p.Type)
+		container, err := s.startContainer(ctx, options)
 		if err != nil {
-			return fmt.Errorf("error creating container: %v", err)
+			return err
 		}
-		defer s.docker.RemoveContainer(ctx, docker.RemoveContainerOptions{
-			ID:            container.ID,
-			RemoveVolumes: true,
-			Force:         true,
-		})
 
-		if err := s.docker.StartContainer(ctx, container.ID, nil); err != nil {
-			return fmt.Errorf("error starting container: %v", err)
-		}
-
-		if err := s.docker.AttachToContainer(ctx, docker.AttachToContainerOptions{
-			Container:    container.ID,
-			InputStream:  p.Stdin,
-			OutputStream: p.Stdout,
-			ErrorStream:  p.Stderr,
-			Logs:         true,
-			Stream:       true,
-			Stdin:        true,
-			Stdout:       true,
-			Stderr:       true,
-			RawTerminal:  true,
-		}); err != nil {
-			return fmt.Errorf("error attaching to container: %v", err)
+		for _, sidecar := range p.Sidecars {
+			if _, err := s.startContainer(ctx, newSidecarOptions(app, p, sidecar, container.ID)); err != nil {
+				return err
+			}
+		}
+
+		if len(stale) > 0 {
+			if err := s.removeProcessContainer(ctx, stale[0]); err != nil {
+				return err
+			}
+			stale = stale[1:]
+		}
+	}
+
+	for _, c := range stale {
+		if err := s.removeProcessContainer(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes all of the containers for the app, one at a time.
+func (s *Scheduler) Remove(ctx context.Context, appID string) error {
+	existing, err := s.processContainers(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	for _, containers := range existing {
+		for _, c := range containers {
+			if err := s.removeProcessContainer(ctx, c); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// Cancel implements the twelvefactor.Scheduler interface. Submit converges
+// processes synchronously, and stops when its context is canceled, so there's
+// nothing else to cancel.
+func (s *Scheduler) Cancel(ctx context.Context, appID string) error {
+	return nil
+}
+
+// Restart replaces all of the containers for the app, one at a time.
+func (s *Scheduler) Restart(ctx context.Context, appID string, ss twelvefactor.StatusStream) error {
+	existing, err := s.processContainers(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	for process, containers := range existing {
+		publish(ctx, ss, fmt.Sprintf("Restarting %d %s containers", len(containers), process))
+		for _, c := range containers {
+			if err := s.replaceContainer(ctx, c.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tasks returns all of the running containers for the app, including
+// containers from one-off runs.
+func (s *Scheduler) Tasks(ctx context.Context, app string) ([]*twelvefactor.Task, error) {
+	return s.instances(ctx, app)
+}
+
+// Run runs attached processes by attaching to the container, and detached
+// processes by starting the container in the background.
+func (s *Scheduler) Run(ctx context.Context, app *twelvefactor.Manifest) error {
+	for _, p := range app.Processes {
+		attached := p.Stdin != nil || p.Stdout != nil || p.Stderr != nil
+
+		var err error
+		if attached {
+			err = s.runAttached(ctx, app, p)
+		} else {
+			err = s.runDetached(ctx, app, p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runDetached starts a container for the process, which is removed
+// automatically when the process exits.
+func (s *Scheduler) runDetached(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	labels := twelvefactor.Labels(app, p)
+	labels[runLabel] = Detached
+
+	if err := s.pullImage(ctx, p.Image, ioutil.Discard); err != nil {
+		return err
+	}
+
+	_, err := s.startContainer(ctx, docker.CreateContainerOptions{
+		Name: uuid.New(),
+		Config: &docker.Config{
+			Memory:    int64(p.Memory),
+			CPUShares: int64(p.CPUShares),
+			Image:     p.Image.String(),
+			Cmd:       p.Command,
+			Env:       envKeys(twelvefactor.Env(app, p)),
+			Labels:    labels,
+		},
+		HostConfig: &docker.HostConfig{
+			AutoRemove: true,
+			LogConfig: docker.LogConfig{
+				Type: "json-file",
+			},
+		},
+	})
+	return err
+}
+
+func (s *Scheduler) runAttached(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	labels := twelvefactor.Labels(app, p)
+	labels[runLabel] = Attached
+
+	if err := s.pullImage(ctx, p.Image, replaceNL(p.Stderr)); err != nil {
+		return err
+	}
+
+	container, err := s.docker.CreateContainer(ctx, docker.CreateContainerOptions{
+		Name: uuid.New(),
+		Config: &docker.Config{
+			Tty:          true,
+			AttachStdin:  true,
+			AttachStdout: true,
+			AttachStderr: true,
+			OpenStdin:    true,
+			Memory:       int64(p.Memory),
+			CPUShares:    int64(p.CPUShares),
+			Image:        p.Image.String(),
+			Cmd:          p.Command,
+			Env:          envKeys(twelvefactor.Env(app, p)),
+			Labels:       labels,
+		},
+		HostConfig: &docker.HostConfig{
+			LogConfig: docker.LogConfig{
+				Type: "json-file",
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating container: %v", err)
+	}
+	defer s.docker.RemoveContainer(ctx, docker.RemoveContainerOptions{
+		ID:            container.ID,
+		RemoveVolumes: true,
+		Force:         true,
+	})
+
+	if err := s.docker.StartContainer(ctx, container.ID, nil); err != nil {
+		return fmt.Errorf("error starting container: %v", err)
+	}
+
+	if err := s.docker.AttachToContainer(ctx, docker.AttachToContainerOptions{
+		Container:    container.ID,
+		InputStream:  p.Stdin,
+		OutputStream: p.Stdout,
+		ErrorStream:  p.Stderr,
+		Logs:         true,
+		Stream:       true,
+		Stdin:        true,
+		Stdout:       true,
+		Stderr:       true,
+		RawTerminal:  true,
+	}); err != nil {
+		return fmt.Errorf("error attaching to container: %v", err)
+	}
+
+	code, err := s.docker.WaitContainer(container.ID)
+	if err != nil {
+		return fmt.Errorf("error waiting for container: %v", err)
+	}
+	if code != 0 {
+		return &twelvefactor.ExitError{Code: code}
+	}
+
+	return nil
+}
+
 func (s *Scheduler) Instances(ctx context.Context, app string) ([]*twelvefactor.Task, error) {
 	return s.InstancesFromAttachedRuns(ctx, app)
 }
@@ -253,7 +508,25 @@ func (s *Scheduler) instances(ctx context.Context, app string, labels ...string)
 		return nil, fmt.Errorf("error listing containers from attached runs: %v", err)
 	}
 
+	// Sidecars are shown as part of the process container that they run
+	// alongside, rather than as separate instances.
+	sidecars := make(map[string][]*twelvefactor.SidecarTask)
+	for _, apiContainer := range containers {
+		if name, ok := apiContainer.Labels[sidecarLabel]; ok {
+			parent := apiContainer.Labels[parentLabel]
+			sidecars[parent] = append(sidecars[parent], &twelvefactor.SidecarTask{
+				Name:    name,
+				Command: strings.Fields(apiContainer.Command),
+				State:   strings.ToUpper(apiContainer.State),
+			})
+		}
+	}
+
 	for _, apiContainer := range containers {
+		if _, ok := apiContainer.Labels[sidecarLabel]; ok {
+			continue
+		}
+
 		container, err := s.docker.InspectContainer(apiContainer.ID)
 		if err != nil {
 			return instances, fmt.Errorf("error inspecting container %s: %v", apiContainer.ID, err)
@@ -272,13 +545,15 @@ func (s *Scheduler) instances(ctx context.Context, app string, labels ...string)
 				Memory:    uint(container.HostConfig.Memory),
 				CPUShares: uint(container.HostConfig.CPUShares),
 			},
+			Sidecars: sidecars[container.ID],
 		})
 	}
 
 	return instances, nil
 }
 
-// Stop stops the given container.
+// Stop stops the given container. Containers for long running processes are
+// replaced with a new container, like an ECS service would do.
 func (s *Scheduler) Stop(ctx context.Context, containerID string) error {
 	container, err := s.docker.InspectContainer(containerID)
 	if err != nil {
@@ -288,19 +563,346 @@ func (s *Scheduler) Stop(ctx context.Context, containerID string) error {
 	// Some extra protection around stopping containers. We don't want to
 	// allow users to stop containers that may have been started outside of
 	// Empire.
-	if _, ok := container.Config.Labels[runLabel]; !ok {
-		return &docker.NoSuchContainer{
-			ID: containerID,
+	if _, ok := container.Config.Labels[runLabel]; ok {
+		return s.docker.StopContainer(ctx, containerID, stopContainerTimeout)
+	}
+
+	if _, ok := container.Config.Labels[appLabel]; ok {
+		return s.replaceContainer(ctx, containerID)
+	}
+
+	return &docker.NoSuchContainer{
+		ID: containerID,
+	}
+}
+
+// processContainers returns the containers for long running processes,
+// grouped by process type. Containers from one-off runs, and sidecar
+// containers, are excluded.
+func (s *Scheduler) processContainers(ctx context.Context, app string) (map[string][]docker.APIContainers, error) {
+	containers, err := s.docker.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				fmt.Sprintf("%s=%s", appLabel, app),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %v", err)
+	}
+
+	processes := make(map[string][]docker.APIContainers)
+	for _, c := range containers {
+		if _, ok := c.Labels[runLabel]; ok {
+			continue
 		}
+		// Sidecars are managed along with their process container.
+		if _, ok := c.Labels[sidecarLabel]; ok {
+			continue
+		}
+		process := c.Labels[processLabel]
+		processes[process] = append(processes[process], c)
 	}
 
-	if err := s.docker.StopContainer(ctx, containerID, stopContainerTimeout); err != nil {
+	return processes, nil
+}
+
+// replaceContainer starts a new container with the same configuration as the
+// given container, then removes the old one. If the container publishes ports
+// on the host, the old container is removed first.
+func (s *Scheduler) replaceContainer(ctx context.Context, containerID string) error {
+	container, err := s.docker.InspectContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	// Sidecars are replaced along with the process container, so we need
+	// their definitions before anything is removed.
+	var sidecars []*docker.Container
+	if _, ok := container.Config.Labels[sidecarsLabel]; ok {
+		sidecars, err = s.sidecarContainers(ctx, containerID)
+		if err != nil {
+			return err
+		}
+	}
+
+	config := container.Config
+	// Let Docker assign a new hostname.
+	config.Hostname = ""
+
+	options := docker.CreateContainerOptions{
+		Name:       replacementName(container.Name),
+		Config:     config,
+		HostConfig: container.HostConfig,
+	}
+
+	exposed := container.HostConfig != nil && len(container.HostConfig.PortBindings) > 0
+	if exposed {
+		if err := s.removeSidecars(ctx, sidecars); err != nil {
+			return err
+		}
+		if err := s.removeContainer(ctx, containerID); err != nil {
+			return err
+		}
+	}
+
+	replacement, err := s.startContainer(ctx, options)
+	if err != nil {
 		return err
 	}
 
+	for _, sidecar := range sidecars {
+		config := sidecar.Config
+		config.Hostname = ""
+		config.Labels[parentLabel] = replacement.ID
+		hostConfig := sidecar.HostConfig
+		hostConfig.NetworkMode = "container:" + replacement.ID
+
+		if _, err := s.startContainer(ctx, docker.CreateContainerOptions{
+			Name:       replacementName(sidecar.Name),
+			Config:     config,
+			HostConfig: hostConfig,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if !exposed {
+		if err := s.removeSidecars(ctx, sidecars); err != nil {
+			return err
+		}
+		return s.removeContainer(ctx, containerID)
+	}
+
+	return nil
+}
+
+// sidecarContainers returns the sidecar containers that run alongside the
+// given process container.
+func (s *Scheduler) sidecarContainers(ctx context.Context, containerID string) ([]*docker.Container, error) {
+	containers, err := s.docker.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				fmt.Sprintf("%s=%s", parentLabel, containerID),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing sidecar containers: %v", err)
+	}
+
+	var sidecars []*docker.Container
+	for _, c := range containers {
+		container, err := s.docker.InspectContainer(c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting container %s: %v", c.ID, err)
+		}
+		sidecars = append(sidecars, container)
+	}
+
+	return sidecars, nil
+}
+
+// removeSidecars removes the given sidecar containers.
+func (s *Scheduler) removeSidecars(ctx context.Context, sidecars []*docker.Container) error {
+	for _, sidecar := range sidecars {
+		if err := s.removeContainer(ctx, sidecar.ID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// removeProcessContainer removes the container for a long running process,
+// along with any sidecars that run alongside it.
+func (s *Scheduler) removeProcessContainer(ctx context.Context, c docker.APIContainers) error {
+	if _, ok := c.Labels[sidecarsLabel]; ok {
+		sidecars, err := s.sidecarContainers(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.removeSidecars(ctx, sidecars); err != nil {
+			return err
+		}
+	}
+
+	return s.removeContainer(ctx, c.ID)
+}
+
+// pullImage pulls the given image.
+func (s *Scheduler) pullImage(ctx context.Context, img image.Image, w io.Writer) error {
+	pullOptions, err := dockerutil.PullImageOptions(img)
+	if err != nil {
+		return err
+	}
+	pullOptions.OutputStream = w
+
+	if err := s.docker.PullImage(ctx, pullOptions); err != nil {
+		return fmt.Errorf("error pulling image: %v", err)
+	}
+
+	return nil
+}
+
+// startContainer creates and starts a container.
+func (s *Scheduler) startContainer(ctx context.Context, options docker.CreateContainerOptions) (*docker.Container, error) {
+	container, err := s.docker.CreateContainer(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container: %v", err)
+	}
+
+	if err := s.docker.StartContainer(ctx, container.ID, nil); err != nil {
+		return nil, fmt.Errorf("error starting container: %v", err)
+	}
+
+	return container, nil
+}
+
+// removeContainer gracefully stops the container, then removes it.
+func (s *Scheduler) removeContainer(ctx context.Context, containerID string) error {
+	if err := s.docker.StopContainer(ctx, containerID, stopContainerTimeout); err != nil {
+		if _, ok := err.(*docker.ContainerNotRunning); !ok {
+			return fmt.Errorf("error stopping container %s: %v", containerID, err)
+		}
+	}
+
+	if err := s.docker.RemoveContainer(ctx, docker.RemoveContainerOptions{
+		ID:            containerID,
+		RemoveVolumes: true,
+		Force:         true,
+	}); err != nil {
+		return fmt.Errorf("error removing container %s: %v", containerID, err)
+	}
+
+	return nil
+}
+
+// newContainerOptions returns the options to create a container for a long
+// running process. The returned options include a label with a hash of the
+// definition, which is used to determine if existing containers need to be
+// replaced.
+func newContainerOptions(app *twelvefactor.Manifest, p *twelvefactor.Process) docker.CreateContainerOptions {
+	config := &docker.Config{
+		Image:  p.Image.String(),
+		Cmd:    p.Command,
+		Env:    envKeys(twelvefactor.Env(app, p)),
+		Labels: twelvefactor.Labels(app, p),
+	}
+
+	hostConfig := &docker.HostConfig{
+		Memory:        int64(p.Memory),
+		CPUShares:     int64(p.CPUShares),
+		RestartPolicy: docker.AlwaysRestart(),
+		LogConfig: docker.LogConfig{
+			Type: "json-file",
+		},
+	}
+
+	if p.Nproc != 0 {
+		hostConfig.Ulimits = []docker.ULimit{
+			{Name: "nproc", Soft: int64(p.Nproc), Hard: int64(p.Nproc)},
+		}
+	}
+
+	if p.Exposure != nil && len(p.Exposure.Ports) > 0 {
+		config.ExposedPorts = make(map[docker.Port]struct{})
+		hostConfig.PortBindings = make(map[docker.Port][]docker.PortBinding)
+		for _, port := range p.Exposure.Ports {
+			containerPort := docker.Port(fmt.Sprintf("%d/tcp", port.Container))
+			config.ExposedPorts[containerPort] = struct{}{}
+			hostConfig.PortBindings[containerPort] = append(hostConfig.PortBindings[containerPort], docker.PortBinding{
+				HostPort: strconv.Itoa(port.Host),
+			})
+		}
+	}
+
+	// Always label the container with the app and process, so we can
+	// find it later.
+	config.Labels[appLabel] = app.AppID
+	config.Labels[processLabel] = p.Type
+
+	if len(p.Sidecars) > 0 {
+		var names []string
+		for _, sidecar := range p.Sidecars {
+			names = append(names, sidecar.Name)
+		}
+		config.Labels[sidecarsLabel] = strings.Join(names, ",")
+	}
+
+	config.Labels[hashLabel] = definitionHash(config, hostConfig, p.Sidecars)
+
+	return docker.CreateContainerOptions{
+		Name:       containerName(app.Name, p.Type),
+		Config:     config,
+		HostConfig: hostConfig,
+	}
+}
+
+// newSidecarOptions returns the options to create a sidecar container, which
+// shares the network namespace of the process container that it runs
+// alongside.
+func newSidecarOptions(app *twelvefactor.Manifest, p *twelvefactor.Process, sidecar *twelvefactor.Sidecar, parentID string) docker.CreateContainerOptions {
+	config := &docker.Config{
+		Image: sidecar.Image.String(),
+		Cmd:   sidecar.Command,
+		Env:   envKeys(sidecar.Env),
+		Labels: map[string]string{
+			appLabel:     app.AppID,
+			processLabel: p.Type,
+			sidecarLabel: sidecar.Name,
+			parentLabel:  parentID,
+		},
+	}
+
+	hostConfig := &docker.HostConfig{
+		Memory:        int64(sidecar.Memory),
+		NetworkMode:   "container:" + parentID,
+		RestartPolicy: docker.AlwaysRestart(),
+		LogConfig: docker.LogConfig{
+			Type: "json-file",
+		},
+	}
+
+	return docker.CreateContainerOptions{
+		Name:       containerName(app.Name, p.Type+"."+sidecar.Name),
+		Config:     config,
+		HostConfig: hostConfig,
+	}
+}
+
+// definitionHash returns a hash of the container definition, including the
+// definitions of any sidecars.
+func definitionHash(config *docker.Config, hostConfig *docker.HostConfig, sidecars []*twelvefactor.Sidecar) string {
+	raw, err := json.Marshal(struct {
+		Config     *docker.Config
+		HostConfig *docker.HostConfig
+		Sidecars   []*twelvefactor.Sidecar `json:",omitempty"`
+	}{config, hostConfig, sidecars})
+	if err != nil {
+		// Both of these types are always encodable.
+		panic(err)
+	}
+	return fmt.Sprintf("%x", sha1.Sum(raw))
+}
+
+// containerName returns a unique name for a container of the process.
+func containerName(app, process string) string {
+	return fmt.Sprintf("%s.%s.%s", app, process, uuid.New())
+}
+
+// replacementName returns a new unique name for a container that's replacing
+// a container with the given name.
+func replacementName(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return uuid.New()
+	}
+	return name[:i+1] + uuid.New()
+}
+
 func parseEnv(env []string) map[string]string {
 	m := make(map[string]string)
 	for _, e := range env {
@@ -316,6 +918,7 @@ func envKeys(env map[string]string) []string {
 	for k, v := range env {
 		s = append(s, fmt.Sprintf("%s=%s", k, v))
 	}
+	sort.Strings(s)
 
 	return s
 }
@@ -335,3 +938,11 @@ type writerFunc func([]byte) (int, error)
 func (f writerFunc) Write(p []byte) (int, error) {
 	return f(p)
 }
+
+func publish(ctx context.Context, stream twelvefactor.StatusStream, msg string) {
+	if stream != nil {
+		if err := stream.Publish(twelvefactor.Status{Message: msg}); err != nil {
+			logger.Warn(ctx, fmt.Sprintf("error publishing to stream: %v", err))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package docker
 
 import (
+	"bytes"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -8,6 +11,7 @@ import (
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/remind101/empire/pkg/bytesize"
+	"github.com/remind101/empire/pkg/image"
 	"github.com/remind101/empire/twelvefactor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -118,6 +122,384 @@ func TestScheduler_Stop_ContainerNotStartedByEmpire(t *testing.T) {
 	d.AssertExpectations(t)
 }
 
+func TestScheduler_Stop_LongRunning(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	container := &docker.Container{
+		ID:   "container_id",
+		Name: "/acme-inc.web.1a2b3c",
+		Config: &docker.Config{
+			Hostname: "container_id",
+			Labels: map[string]string{
+				"empire.app.id":      "2cdc4941-e36d-4855-a0ec-51525db4a500",
+				"empire.app.process": "web",
+			},
+		},
+		HostConfig: &docker.HostConfig{},
+	}
+	d.On("InspectContainer", "container_id").Return(container, nil)
+
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new_container_id"}, nil)
+	d.On("StartContainer", "new_container_id").Return(nil)
+	d.On("StopContainer", "container_id", uint(10)).Return(nil)
+	d.On("RemoveContainer", docker.RemoveContainerOptions{
+		ID:            "container_id",
+		RemoveVolumes: true,
+		Force:         true,
+	}).Return(nil)
+
+	err := s.Stop(ctx, "container_id")
+	assert.NoError(t, err)
+
+	if assert.Equal(t, 1, len(d.created)) {
+		opts := d.created[0]
+		assert.True(t, strings.HasPrefix(opts.Name, "acme-inc.web."))
+		assert.Equal(t, "", opts.Config.Hostname)
+	}
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_NewProcess(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				"empire.app.id=2cdc4941-e36d-4855-a0ec-51525db4a500",
+			},
+		},
+	}).Return([]docker.APIContainers{}, nil)
+
+	d.On("PullImage", docker.PullImageOptions{
+		Repository:   "remind101/acme-inc",
+		Tag:          "latest",
+		OutputStream: ioutil.Discard,
+	}).Return(nil)
+
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "container_id"}, nil).Once()
+	d.On("StartContainer", "container_id").Return(nil)
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:      "web",
+				Image:     image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command:   []string{"./bin/web"},
+				Memory:    128 * bytesize.MB,
+				CPUShares: 256,
+				Quantity:  1,
+				Exposure: &twelvefactor.Exposure{
+					Ports: []twelvefactor.Port{
+						{Host: 80, Container: 8080, Protocol: &twelvefactor.HTTP{}},
+					},
+				},
+			},
+		},
+	}, twelvefactor.NullStatusStream)
+	assert.NoError(t, err)
+
+	if assert.Equal(t, 1, len(d.created)) {
+		opts := d.created[0]
+		assert.True(t, strings.HasPrefix(opts.Name, "acme-inc.web."))
+		assert.Equal(t, "remind101/acme-inc:latest", opts.Config.Image)
+		assert.Equal(t, "web", opts.Config.Labels["empire.app.process"])
+		assert.NotEmpty(t, opts.Config.Labels["empire.process.hash"])
+		assert.Equal(t, int64(128*bytesize.MB), opts.HostConfig.Memory)
+		assert.Equal(t, int64(256), opts.HostConfig.CPUShares)
+		assert.Equal(t, docker.AlwaysRestart(), opts.HostConfig.RestartPolicy)
+		assert.Equal(t, map[docker.Port][]docker.PortBinding{
+			"8080/tcp": []docker.PortBinding{{HostPort: "80"}},
+		}, opts.HostConfig.PortBindings)
+	}
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_Sidecars(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("ListContainers", mock.Anything).Return([]docker.APIContainers{}, nil)
+	d.On("PullImage", mock.Anything).Return(nil).Twice()
+
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "container_id"}, nil).Once()
+	d.On("StartContainer", "container_id").Return(nil)
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "sidecar_id"}, nil).Once()
+	d.On("StartContainer", "sidecar_id").Return(nil)
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:     "web",
+				Image:    image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command:  []string{"./bin/web"},
+				Quantity: 1,
+				Sidecars: []*twelvefactor.Sidecar{
+					{
+						Name:   "statsd",
+						Image:  image.Image{Repository: "remind101/statsd", Tag: "latest"},
+						Env:    map[string]string{"EMPIRE_PROCESS": "web"},
+						Memory: 64 * bytesize.MB,
+					},
+				},
+			},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	if assert.Equal(t, 2, len(d.created)) {
+		assert.Equal(t, "statsd", d.created[0].Config.Labels["empire.app.sidecars"])
+
+		opts := d.created[1]
+		assert.True(t, strings.HasPrefix(opts.Name, "acme-inc.web.statsd."))
+		assert.Equal(t, "remind101/statsd:latest", opts.Config.Image)
+		assert.Equal(t, "statsd", opts.Config.Labels["empire.app.sidecar"])
+		assert.Equal(t, "container_id", opts.Config.Labels["empire.app.sidecar.parent"])
+		assert.Equal(t, "container:container_id", opts.HostConfig.NetworkMode)
+		assert.Equal(t, int64(64*bytesize.MB), opts.HostConfig.Memory)
+		assert.Equal(t, []string{"EMPIRE_PROCESS=web"}, opts.Config.Env)
+	}
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_Converge(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	app := &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:     "worker",
+				Image:    image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command:  []string{"./bin/worker"},
+				Quantity: 2,
+			},
+		},
+	}
+	hash := newContainerOptions(app, app.Processes[0]).Config.Labels[hashLabel]
+
+	d.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				"empire.app.id=2cdc4941-e36d-4855-a0ec-51525db4a500",
+			},
+		},
+	}).Return([]docker.APIContainers{
+		// Up to date.
+		{ID: "current", State: "running", Labels: map[string]string{"empire.app.process": "worker", "empire.process.hash": hash}},
+		// From an old release.
+		{ID: "stale", State: "running", Labels: map[string]string{"empire.app.process": "worker", "empire.process.hash": "old"}},
+		// Process was removed.
+		{ID: "removed", State: "running", Labels: map[string]string{"empire.app.process": "web", "empire.process.hash": "old"}},
+		// Attached runs should be left alone.
+		{ID: "attached", State: "running", Labels: map[string]string{"empire.app.process": "run", "run": "attached"}},
+	}, nil)
+
+	d.On("PullImage", mock.Anything).Return(nil)
+
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new"}, nil).Once()
+	d.On("StartContainer", "new").Return(nil)
+
+	for _, id := range []string{"stale", "removed"} {
+		d.On("StopContainer", id, uint(10)).Return(nil)
+		d.On("RemoveContainer", docker.RemoveContainerOptions{
+			ID:            id,
+			RemoveVolumes: true,
+			Force:         true,
+		}).Return(nil)
+	}
+
+	err := s.Submit(ctx, app, nil)
+	assert.NoError(t, err)
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Submit_ExposedQuantity(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("ListContainers", mock.Anything).Return([]docker.APIContainers{}, nil)
+	d.On("PullImage", mock.Anything).Return(nil)
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:     "web",
+				Image:    image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Quantity: 2,
+				Exposure: &twelvefactor.Exposure{
+					Ports: []twelvefactor.Port{
+						{Host: 80, Container: 8080, Protocol: &twelvefactor.HTTP{}},
+					},
+				},
+			},
+		},
+	}, nil)
+	assert.EqualError(t, err, "docker: cannot run 2 instances of web: processes that publish ports are limited to 1 instance")
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Remove_Sidecars(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				"empire.app.id=2cdc4941-e36d-4855-a0ec-51525db4a500",
+			},
+		},
+	}).Return([]docker.APIContainers{
+		{ID: "web", State: "running", Labels: map[string]string{"empire.app.process": "web", "empire.app.sidecars": "statsd"}},
+		{ID: "statsd", State: "running", Labels: map[string]string{"empire.app.process": "web", "empire.app.sidecar": "statsd", "empire.app.sidecar.parent": "web"}},
+	}, nil)
+	d.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": []string{
+				"empire.app.sidecar.parent=web",
+			},
+		},
+	}).Return([]docker.APIContainers{
+		{ID: "statsd"},
+	}, nil)
+	d.On("InspectContainer", "statsd").Return(&docker.Container{ID: "statsd"}, nil)
+
+	for _, id := range []string{"statsd", "web"} {
+		d.On("StopContainer", id, uint(10)).Return(nil).Once()
+		d.On("RemoveContainer", docker.RemoveContainerOptions{
+			ID:            id,
+			RemoveVolumes: true,
+			Force:         true,
+		}).Return(nil).Once()
+	}
+
+	err := s.Remove(ctx, "2cdc4941-e36d-4855-a0ec-51525db4a500")
+	assert.NoError(t, err)
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("ListContainers", mock.Anything).Return([]docker.APIContainers{
+		{ID: "web", State: "exited", Labels: map[string]string{"empire.app.process": "web"}},
+	}, nil)
+	d.On("StopContainer", "web", uint(10)).Return(&docker.ContainerNotRunning{ID: "web"})
+	d.On("RemoveContainer", docker.RemoveContainerOptions{
+		ID:            "web",
+		RemoveVolumes: true,
+		Force:         true,
+	}).Return(nil)
+
+	err := s.Remove(ctx, "2cdc4941-e36d-4855-a0ec-51525db4a500")
+	assert.NoError(t, err)
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Run_Detached(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("PullImage", mock.Anything).Return(nil)
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "container_id"}, nil)
+	d.On("StartContainer", "container_id").Return(nil)
+
+	err := s.Run(ctx, &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "run",
+				Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command: []string{"rake", "db:migrate"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	if assert.Equal(t, 1, len(d.created)) {
+		opts := d.created[0]
+		assert.Equal(t, "detached", opts.Config.Labels["run"])
+		assert.True(t, opts.HostConfig.AutoRemove)
+		assert.False(t, opts.Config.Tty)
+	}
+
+	d.AssertExpectations(t)
+}
+
+func TestScheduler_Run_AttachedExitCode(t *testing.T) {
+	d := new(mockDockerClient)
+	s := Scheduler{
+		docker: d,
+	}
+
+	d.On("PullImage", mock.Anything).Return(nil)
+	d.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "container_id"}, nil)
+	d.On("StartContainer", "container_id").Return(nil)
+	d.On("AttachToContainer", mock.Anything).Return(nil)
+	d.On("WaitContainer", "container_id").Return(1, nil)
+	d.On("RemoveContainer", docker.RemoveContainerOptions{
+		ID:            "container_id",
+		RemoveVolumes: true,
+		Force:         true,
+	}).Return(nil)
+
+	stdout := new(bytes.Buffer)
+	err := s.Run(ctx, &twelvefactor.Manifest{
+		AppID: "2cdc4941-e36d-4855-a0ec-51525db4a500",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "release",
+				Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command: []string{"rake", "db:migrate"},
+				Stdout:  stdout,
+				Stderr:  stdout,
+			},
+		},
+	})
+	assert.Equal(t, &twelvefactor.ExitError{Code: 1}, err)
+
+	d.AssertExpectations(t)
+}
+
 func TestAttachedScheduler_Stop_ContainerNotFound(t *testing.T) {
 	w := new(mockScheduler)
 	d := new(mockDockerClient)
@@ -160,6 +542,9 @@ func TestParseEnv(t *testing.T) {
 type mockDockerClient struct {
 	dockerClient
 	mock.Mock
+
+	// Containers that were created.
+	created []docker.CreateContainerOptions
 }
 
 func (m *mockDockerClient) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
@@ -176,11 +561,46 @@ func (m *mockDockerClient) InspectContainer(id string) (*docker.Container, error
 	return container, args.Error(1)
 }
 
+func (m *mockDockerClient) PullImage(ctx context.Context, opts docker.PullImageOptions) error {
+	args := m.Called(opts)
+	return args.Error(0)
+}
+
+func (m *mockDockerClient) CreateContainer(ctx context.Context, opts docker.CreateContainerOptions) (*docker.Container, error) {
+	m.created = append(m.created, opts)
+	args := m.Called(opts)
+	var container *docker.Container
+	if v := args.Get(0); v != nil {
+		container = v.(*docker.Container)
+	}
+	return container, args.Error(1)
+}
+
+func (m *mockDockerClient) StartContainer(ctx context.Context, id string, config *docker.HostConfig) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockDockerClient) RemoveContainer(ctx context.Context, opts docker.RemoveContainerOptions) error {
+	args := m.Called(opts)
+	return args.Error(0)
+}
+
 func (m *mockDockerClient) StopContainer(ctx context.Context, id string, timeout uint) error {
 	args := m.Called(id, timeout)
 	return args.Error(0)
 }
 
+func (m *mockDockerClient) AttachToContainer(ctx context.Context, opts docker.AttachToContainerOptions) error {
+	args := m.Called(opts)
+	return args.Error(0)
+}
+
+func (m *mockDockerClient) WaitContainer(id string) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
 type mockScheduler struct {
 	twelvefactor.Scheduler
 	mock.Mock
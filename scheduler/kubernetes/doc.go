@@ -0,0 +1,11 @@
+// Package kubernetes implements the Scheduler interface backed by Kubernetes.
+//
+// Each twelvefactor.Process is mapped to a Deployment (and a Service, if the
+// process is exposed), scheduled processes are mapped to CronJobs, and one off
+// processes are mapped to Pods (attached) or Jobs (detached). All resources are
+// labeled with the `empire.app.id` and `empire.app.process` labels, which are
+// used to find the resources that belong to an app.
+//
+// This package is only built with the `kubernetes` build tag, which keeps the
+// Kubernetes client libraries out of the default build.
+package kubernetes
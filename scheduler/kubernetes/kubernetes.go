@@ -1,4 +1,992 @@
-// Package kubernetes implements the Scheduler interface backed by Kubernetes.
-//
-// TODO: Implement this.
+//go:build kubernetes
+// +build kubernetes
+
 package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/remind101/empire/internal/uuid"
+	"github.com/remind101/empire/twelvefactor"
+	"github.com/remind101/pkg/logger"
+	"golang.org/x/net/context"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// DefaultNamespace is the namespace that resources will be created in when no
+// namespace is provided.
+const DefaultNamespace = "default"
+
+const (
+	// Label that determines what app the resource relates to.
+	appLabel = "empire.app.id"
+
+	// Label that determines what the name of the process is.
+	processLabel = "empire.app.process"
+
+	// Label that determines whether the pod is from a one-off run or not.
+	// The value of this label will be `attached` or `detached`.
+	runLabel = "run"
+
+	// Annotation that gets updated on the pod template to trigger a
+	// rolling restart of a Deployment.
+	restartedAtAnnotation = "empire.restartedAt"
+
+	// Annotations used to attach certificates to load balancers when using
+	// the AWS cloud provider.
+	sslCertAnnotation  = "service.beta.kubernetes.io/aws-load-balancer-ssl-cert"
+	sslPortsAnnotation = "service.beta.kubernetes.io/aws-load-balancer-ssl-ports"
+)
+
+// Values for `runLabel`.
+const (
+	Attached = "attached"
+	Detached = "detached"
+)
+
+// Variables that control polling. Set as vars so they can be stubbed out in
+// tests.
+var (
+	// Controls how long we'll wait between requests to get a Deployment
+	// when waiting for it to finish rolling out.
+	pollDeploymentWait = 5 * time.Second
+
+	// Controls how long we'll wait between requests to get a Pod when
+	// waiting for it to start running.
+	pollPodWait = 1 * time.Second
+)
+
+// attacher defines the interface we use to attach to a running pod.
+type attacher interface {
+	Attach(ctx context.Context, pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// Scheduler provides an implementation of the twelvefactor.Scheduler
+// interface backed by Kubernetes.
+type Scheduler struct {
+	// The namespace to create resources within. The zero value is
+	// DefaultNamespace.
+	Namespace string
+
+	client   kubernetes.Interface
+	attacher attacher
+}
+
+// NewScheduler returns a new Scheduler instance that uses the given
+// rest.Config to interact with the Kubernetes API.
+func NewScheduler(config *rest.Config) (*Scheduler, error) {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{
+		client: client,
+		attacher: &restAttacher{
+			config: config,
+			client: client,
+		},
+	}, nil
+}
+
+// Submit creates (or updates) the Deployments, Services and CronJobs for the
+// app, then removes any resources for processes that no longer exist.
+func (s *Scheduler) Submit(ctx context.Context, app *twelvefactor.Manifest, ss twelvefactor.StatusStream) error {
+	var deployments []string
+
+	for _, p := range app.Processes {
+		if p.Schedule != nil {
+			if err := s.applyCronJob(ctx, app, p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.applyDeployment(ctx, app, p); err != nil {
+			return err
+		}
+		deployments = append(deployments, resourceName(app.Name, p.Type))
+
+		if p.Exposure != nil && len(p.Exposure.Ports) > 0 {
+			if err := s.applyService(ctx, app, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.prune(ctx, app); err != nil {
+		return err
+	}
+
+	if ss != nil {
+		// Once the processes are submitted, we wait for the
+		// deployments to roll out.
+		status := twelvefactor.Status{
+			Message:     fmt.Sprintf("Submitted %d processes", len(app.Processes)),
+			Stabilizing: true,
+		}
+		if err := ss.Publish(status); err != nil {
+			logger.Warn(ctx, fmt.Sprintf("error publishing to stream: %v", err))
+		}
+
+		for _, name := range deployments {
+			if err := s.waitForRollout(ctx, name, ss); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyDeployment creates or updates the Deployment for the process.
+func (s *Scheduler) applyDeployment(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	deployments := s.client.AppsV1().Deployments(s.namespace())
+
+	d := newDeployment(app, p)
+	existing, err := deployments.Get(ctx, d.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err := deployments.Create(ctx, d, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating deployment %s: %v", d.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting deployment %s: %v", d.Name, err)
+	}
+
+	// Keep the restart annotation, so that a deploy doesn't undo a
+	// restart.
+	if v, ok := existing.Spec.Template.Annotations[restartedAtAnnotation]; ok {
+		d.Spec.Template.Annotations[restartedAtAnnotation] = v
+	}
+	d.ResourceVersion = existing.ResourceVersion
+
+	if _, err := deployments.Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating deployment %s: %v", d.Name, err)
+	}
+	return nil
+}
+
+// applyService creates or updates the Service for the process.
+func (s *Scheduler) applyService(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	services := s.client.CoreV1().Services(s.namespace())
+
+	svc := newService(app, p)
+	existing, err := services.Get(ctx, svc.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err := services.Create(ctx, svc, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating service %s: %v", svc.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting service %s: %v", svc.Name, err)
+	}
+
+	// The cluster ip and node ports are allocated by Kubernetes, and need
+	// to be carried over.
+	svc.ResourceVersion = existing.ResourceVersion
+	svc.Spec.ClusterIP = existing.Spec.ClusterIP
+	svc.Spec.ClusterIPs = existing.Spec.ClusterIPs
+	nodePorts := make(map[int32]int32)
+	for _, port := range existing.Spec.Ports {
+		nodePorts[port.Port] = port.NodePort
+	}
+	for i, port := range svc.Spec.Ports {
+		if svc.Spec.Type != corev1.ServiceTypeClusterIP {
+			svc.Spec.Ports[i].NodePort = nodePorts[port.Port]
+		}
+	}
+
+	if _, err := services.Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating service %s: %v", svc.Name, err)
+	}
+	return nil
+}
+
+// applyCronJob creates or updates the CronJob for a scheduled process.
+func (s *Scheduler) applyCronJob(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	cronJobs := s.client.BatchV1().CronJobs(s.namespace())
+
+	cj, err := newCronJob(app, p)
+	if err != nil {
+		return err
+	}
+
+	existing, err := cronJobs.Get(ctx, cj.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err := cronJobs.Create(ctx, cj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating cronjob %s: %v", cj.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting cronjob %s: %v", cj.Name, err)
+	}
+
+	cj.ResourceVersion = existing.ResourceVersion
+	if _, err := cronJobs.Update(ctx, cj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating cronjob %s: %v", cj.Name, err)
+	}
+	return nil
+}
+
+// prune removes any resources for processes that are no longer defined in
+// the manifest.
+func (s *Scheduler) prune(ctx context.Context, app *twelvefactor.Manifest) error {
+	services := make(map[string]bool)
+	deployments := make(map[string]bool)
+	cronJobs := make(map[string]bool)
+	for _, p := range app.Processes {
+		name := resourceName(app.Name, p.Type)
+		if p.Schedule != nil {
+			cronJobs[name] = true
+			continue
+		}
+		deployments[name] = true
+		if p.Exposure != nil && len(p.Exposure.Ports) > 0 {
+			services[name] = true
+		}
+	}
+
+	return s.removeResources(ctx, app.AppID, func(kind, name string) bool {
+		switch kind {
+		case "deployment":
+			return !deployments[name]
+		case "service":
+			return !services[name]
+		case "cronjob":
+			return !cronJobs[name]
+		default:
+			return false
+		}
+	})
+}
+
+// waitForRollout waits until the Deployment has finished rolling out.
+func (s *Scheduler) waitForRollout(ctx context.Context, name string, ss twelvefactor.StatusStream) error {
+	deployments := s.client.AppsV1().Deployments(s.namespace())
+
+	for {
+		d, err := deployments.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting deployment %s: %v", name, err)
+		}
+
+		if rolledOut(d) {
+			publish(ctx, ss, fmt.Sprintf("Deployment %s became stable", name))
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollDeploymentWait):
+		}
+	}
+}
+
+// rolledOut returns true if all replicas of the Deployment have been updated
+// and are available.
+func rolledOut(d *appsv1.Deployment) bool {
+	var replicas int32 = 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+// Remove removes all of the resources for the app.
+func (s *Scheduler) Remove(ctx context.Context, appID string) error {
+	return s.removeResources(ctx, appID, func(kind, name string) bool {
+		return true
+	})
+}
+
+// removeResources removes the resources labeled with the given app id, when
+// shouldRemove returns true.
+func (s *Scheduler) removeResources(ctx context.Context, appID string, shouldRemove func(kind, name string) bool) error {
+	namespace := s.namespace()
+	opts := metav1.ListOptions{LabelSelector: appSelector(appID)}
+	propagation := metav1.DeletePropagationForeground
+	deleteOpts := metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	deployments, err := s.client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error listing deployments: %v", err)
+	}
+	for _, d := range deployments.Items {
+		if !shouldRemove("deployment", d.Name) {
+			continue
+		}
+		if err := s.client.AppsV1().Deployments(namespace).Delete(ctx, d.Name, deleteOpts); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting deployment %s: %v", d.Name, err)
+		}
+	}
+
+	services, err := s.client.CoreV1().Services(namespace).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error listing services: %v", err)
+	}
+	for _, svc := range services.Items {
+		if !shouldRemove("service", svc.Name) {
+			continue
+		}
+		if err := s.client.CoreV1().Services(namespace).Delete(ctx, svc.Name, deleteOpts); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting service %s: %v", svc.Name, err)
+		}
+	}
+
+	cronJobs, err := s.client.BatchV1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error listing cronjobs: %v", err)
+	}
+	for _, cj := range cronJobs.Items {
+		if !shouldRemove("cronjob", cj.Name) {
+			continue
+		}
+		if err := s.client.BatchV1().CronJobs(namespace).Delete(ctx, cj.Name, deleteOpts); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting cronjob %s: %v", cj.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Cancel implements the twelvefactor.Scheduler interface. Submit stops waiting
+// for deployments to roll out when its context is canceled, so there's nothing
+// else to cancel.
+func (s *Scheduler) Cancel(ctx context.Context, appID string) error {
+	return nil
+}
+
+// Restart triggers a rolling restart of all of the Deployments for the app.
+func (s *Scheduler) Restart(ctx context.Context, appID string, ss twelvefactor.StatusStream) error {
+	deployments := s.client.AppsV1().Deployments(s.namespace())
+
+	list, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: appSelector(appID)})
+	if err != nil {
+		return fmt.Errorf("error listing deployments: %v", err)
+	}
+
+	restartedAt := time.Now().UTC().Format(time.RFC3339)
+	for i := range list.Items {
+		d := &list.Items[i]
+		if d.Spec.Template.Annotations == nil {
+			d.Spec.Template.Annotations = make(map[string]string)
+		}
+		d.Spec.Template.Annotations[restartedAtAnnotation] = restartedAt
+
+		if _, err := deployments.Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("error restarting deployment %s: %v", d.Name, err)
+		}
+		publish(ctx, ss, fmt.Sprintf("Restarting %s", d.Name))
+	}
+
+	if ss != nil {
+		for _, d := range list.Items {
+			if err := s.waitForRollout(ctx, d.Name, ss); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tasks returns the pods for the app.
+func (s *Scheduler) Tasks(ctx context.Context, appID string) ([]*twelvefactor.Task, error) {
+	var tasks []*twelvefactor.Task
+
+	pods, err := s.client.CoreV1().Pods(s.namespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: appSelector(appID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		updatedAt := pod.CreationTimestamp.Time
+		if t := pod.Status.StartTime; t != nil {
+			updatedAt = t.Time
+		}
+
+		tasks = append(tasks, &twelvefactor.Task{
+			ID:        pod.Name,
+			Host:      twelvefactor.Host{ID: pod.Spec.NodeName},
+			State:     strings.ToUpper(string(pod.Status.Phase)),
+			UpdatedAt: updatedAt,
+			Process:   podToProcess(pod),
+		})
+	}
+
+	return tasks, nil
+}
+
+// Stop deletes the given pod. If the pod is managed by a Deployment, a new
+// pod will be started to replace it.
+func (s *Scheduler) Stop(ctx context.Context, podName string) error {
+	pods := s.client.CoreV1().Pods(s.namespace())
+
+	pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Some extra protection around deleting pods. We don't want to allow
+	// users to delete pods that may have been started outside of Empire.
+	if _, ok := pod.Labels[appLabel]; !ok {
+		return fmt.Errorf("pod %s is not managed by Empire", podName)
+	}
+
+	return pods.Delete(ctx, podName, metav1.DeleteOptions{})
+}
+
+// Run runs attached processes as a Pod, which is attached to, and detached
+// processes as a Job.
+func (s *Scheduler) Run(ctx context.Context, app *twelvefactor.Manifest) error {
+	for _, p := range app.Processes {
+		attached := p.Stdin != nil || p.Stdout != nil || p.Stderr != nil
+
+		var err error
+		if attached {
+			err = s.runAttached(ctx, app, p)
+		} else {
+			err = s.runDetached(ctx, app, p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runDetached creates a Job to run the process to completion.
+func (s *Scheduler) runDetached(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	labels, annotations := objectLabels(app, p)
+	labels[runLabel] = Detached
+
+	template := newPodTemplate(app, p, labels, annotations)
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	var backoffLimit int32
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        runName(app.Name, p.Type),
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     template,
+		},
+	}
+
+	if _, err := s.client.BatchV1().Jobs(s.namespace()).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error creating job: %v", err)
+	}
+
+	return nil
+}
+
+// runAttached creates a Pod, waits for it to start running, then attaches
+// to it. The Pod is deleted once we detach.
+func (s *Scheduler) runAttached(ctx context.Context, app *twelvefactor.Manifest, p *twelvefactor.Process) error {
+	labels, annotations := objectLabels(app, p)
+	labels[runLabel] = Attached
+
+	template := newPodTemplate(app, p, labels, annotations)
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	container := &template.Spec.Containers[0]
+	container.Stdin = true
+	container.StdinOnce = true
+	container.TTY = true
+
+	pods := s.client.CoreV1().Pods(s.namespace())
+	pod, err := pods.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        runName(app.Name, p.Type),
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("error creating pod: %v", err)
+	}
+
+	// Ensure that we atleast try to delete the pod, after we detach from
+	// the process. This ensures that we don't have zombie one-off
+	// processes lying around.
+	defer func() {
+		if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			logger.Warn(ctx, fmt.Sprintf("error deleting pod %s: %v", pod.Name, err))
+		}
+	}()
+
+	if p.Stderr != nil {
+		fmt.Fprintf(p.Stderr, "Attaching to %s...\r\n", pod.Name)
+	}
+
+	pod, err = s.waitForPod(ctx, pod.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.attacher.Attach(ctx, pod, p.Stdin, p.Stdout, p.Stderr); err != nil {
+		return fmt.Errorf("error attaching to pod %s: %v", pod.Name, err)
+	}
+
+	return s.waitForExit(ctx, pod.Name)
+}
+
+// waitForExit waits for the container in the pod to terminate, and returns an
+// ExitError if it exited with a non-zero exit code. If the pod doesn't report
+// the container as running, there's nothing to wait for.
+func (s *Scheduler) waitForExit(ctx context.Context, name string) error {
+	pods := s.client.CoreV1().Pods(s.namespace())
+
+	for {
+		pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting pod %s: %v", name, err)
+		}
+
+		if len(pod.Status.ContainerStatuses) == 0 {
+			return nil
+		}
+
+		state := pod.Status.ContainerStatuses[0].State
+		if t := state.Terminated; t != nil {
+			if t.ExitCode != 0 {
+				return &twelvefactor.ExitError{Code: int(t.ExitCode)}
+			}
+			return nil
+		}
+		if state.Running == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollPodWait):
+		}
+	}
+}
+
+// waitForPod waits for the pod to transition out of the Pending phase.
+func (s *Scheduler) waitForPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	pods := s.client.CoreV1().Pods(s.namespace())
+
+	for {
+		pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting pod %s: %v", name, err)
+		}
+
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return pod, nil
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return nil, fmt.Errorf("pod %s exited before it could be attached to", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollPodWait):
+		}
+	}
+}
+
+func (s *Scheduler) namespace() string {
+	if s.Namespace == "" {
+		return DefaultNamespace
+	}
+	return s.Namespace
+}
+
+// restAttacher is an attacher implementation that attaches to a pod using the
+// pods/attach subresource.
+type restAttacher struct {
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+func (a *restAttacher) Attach(ctx context.Context, pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error {
+	req := a.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: pod.Spec.Containers[0].Name,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			// With a TTY, stderr is merged into stdout.
+			Stderr: false,
+			TTY:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(a.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Tty:    true,
+	})
+}
+
+// newDeployment returns the Deployment for a long running process.
+func newDeployment(app *twelvefactor.Manifest, p *twelvefactor.Process) *appsv1.Deployment {
+	labels, annotations := objectLabels(app, p)
+	replicas := int32(p.Quantity)
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        resourceName(app.Name, p.Type),
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: processSelector(app.AppID, p.Type),
+			},
+			Template: newPodTemplate(app, p, labels, annotations),
+		},
+	}
+}
+
+// newService returns the Service for an exposed process.
+func newService(app *twelvefactor.Manifest, p *twelvefactor.Process) *corev1.Service {
+	labels, annotations := objectLabels(app, p)
+
+	serviceType := corev1.ServiceTypeClusterIP
+	if p.Exposure.External {
+		serviceType = corev1.ServiceTypeLoadBalancer
+	}
+
+	var (
+		ports    []corev1.ServicePort
+		sslPorts []string
+	)
+	for _, port := range p.Exposure.Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:       fmt.Sprintf("%s-%d", port.Protocol.Protocol(), port.Host),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(port.Host),
+			TargetPort: intstr.FromInt(port.Container),
+		})
+
+		switch protocol := port.Protocol.(type) {
+		case *twelvefactor.HTTPS:
+			annotations[sslCertAnnotation] = protocol.Cert
+			sslPorts = append(sslPorts, fmt.Sprintf("%d", port.Host))
+		case *twelvefactor.SSL:
+			annotations[sslCertAnnotation] = protocol.Cert
+			sslPorts = append(sslPorts, fmt.Sprintf("%d", port.Host))
+		}
+	}
+	if len(sslPorts) > 0 {
+		annotations[sslPortsAnnotation] = strings.Join(sslPorts, ",")
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        resourceName(app.Name, p.Type),
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     serviceType,
+			Selector: processSelector(app.AppID, p.Type),
+			Ports:    ports,
+		},
+	}
+}
+
+// newCronJob returns the CronJob for a scheduled process. The quantity of
+// the process controls how many pods are started each time the schedule
+// triggers, and a quantity of 0 suspends the CronJob.
+func newCronJob(app *twelvefactor.Manifest, p *twelvefactor.Process) (*batchv1.CronJob, error) {
+	schedule, err := cronSchedule(p.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, annotations := objectLabels(app, p)
+
+	template := newPodTemplate(app, p, labels, annotations)
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	quantity := int32(p.Quantity)
+	suspend := quantity == 0
+	var backoffLimit int32
+
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        resourceName(app.Name, p.Type),
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          schedule,
+			Suspend:           &suspend,
+			ConcurrencyPolicy: batchv1.AllowConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: annotations,
+				},
+				Spec: batchv1.JobSpec{
+					Parallelism:  &quantity,
+					Completions:  &quantity,
+					BackoffLimit: &backoffLimit,
+					Template:     template,
+				},
+			},
+		},
+	}, nil
+}
+
+// newPodTemplate returns the PodTemplateSpec used to run the process.
+func newPodTemplate(app *twelvefactor.Manifest, p *twelvefactor.Process, labels, annotations map[string]string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      copyMap(labels),
+			Annotations: copyMap(annotations),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				newContainer(app, p),
+			},
+		},
+	}
+}
+
+// newContainer returns the Container definition for the process. The command
+// is provided as the container args, so that the ENTRYPOINT of the image is
+// preserved, which matches the behavior of `docker run`.
+func newContainer(app *twelvefactor.Manifest, p *twelvefactor.Process) corev1.Container {
+	c := corev1.Container{
+		Name:  containerName(p.Type),
+		Image: p.Image.String(),
+		Args:  p.Command,
+		Env:   envVars(twelvefactor.Env(app, p)),
+	}
+
+	if p.Exposure != nil {
+		for _, port := range p.Exposure.Ports {
+			c.Ports = append(c.Ports, corev1.ContainerPort{
+				ContainerPort: int32(port.Container),
+				Protocol:      corev1.ProtocolTCP,
+			})
+		}
+	}
+
+	requests := make(corev1.ResourceList)
+	limits := make(corev1.ResourceList)
+	if p.Memory != 0 {
+		memory := resource.NewQuantity(int64(p.Memory), resource.BinarySI)
+		requests[corev1.ResourceMemory] = *memory
+		limits[corev1.ResourceMemory] = *memory
+	}
+	if p.CPUShares != 0 {
+		// CPU shares are out of 1024, which maps to a single core.
+		requests[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(p.CPUShares)*1000/1024, resource.DecimalSI)
+	}
+	if len(requests) > 0 {
+		c.Resources.Requests = requests
+	}
+	if len(limits) > 0 {
+		c.Resources.Limits = limits
+	}
+
+	return c
+}
+
+// podToProcess converts a Pod into a twelvefactor.Process.
+func podToProcess(pod *corev1.Pod) *twelvefactor.Process {
+	p := &twelvefactor.Process{
+		Type: pod.Labels[processLabel],
+	}
+
+	if len(pod.Spec.Containers) == 0 {
+		return p
+	}
+
+	container := pod.Spec.Containers[0]
+	p.Command = container.Args
+
+	env := make(map[string]string)
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	p.Env = env
+
+	if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		p.Memory = uint(memory.Value())
+	}
+	if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+		p.CPUShares = uint(cpu.MilliValue() * 1024 / 1000)
+	}
+
+	return p
+}
+
+// cronSchedule converts a twelvefactor.Schedule into a cron expression that
+// Kubernetes understands. CRON expressions in the extended Procfile follow the
+// CloudWatch Events format, which has an additional year field and supports
+// `?` as a wildcard, so those are converted to the standard format.
+func cronSchedule(schedule twelvefactor.Schedule) (string, error) {
+	switch v := schedule.(type) {
+	case twelvefactor.CRONSchedule:
+		fields := strings.Fields(string(v))
+		if len(fields) == 6 {
+			fields = fields[:5]
+		}
+		if len(fields) != 5 {
+			return "", fmt.Errorf("invalid cron expression: %s", v)
+		}
+		for i, f := range fields {
+			if f == "?" {
+				fields[i] = "*"
+			}
+		}
+		return strings.Join(fields, " "), nil
+	default:
+		return "", errors.New("unsupported schedule type")
+	}
+}
+
+// objectLabels returns the labels and annotations to set on the resources
+// for the process. Kubernetes is strict about what characters label values
+// can contain, so any labels that are not valid label values are stored as
+// annotations instead.
+func objectLabels(app *twelvefactor.Manifest, p *twelvefactor.Process) (labels, annotations map[string]string) {
+	labels = make(map[string]string)
+	annotations = make(map[string]string)
+
+	for k, v := range twelvefactor.Labels(app, p) {
+		if len(validation.IsQualifiedName(k)) == 0 && len(validation.IsValidLabelValue(v)) == 0 {
+			labels[k] = v
+		} else {
+			annotations[k] = v
+		}
+	}
+
+	// These labels are required to find the resources for an app.
+	labels[appLabel] = app.AppID
+	labels[processLabel] = p.Type
+
+	return labels, annotations
+}
+
+// appSelector returns a label selector that matches all resources for the
+// app.
+func appSelector(appID string) string {
+	return fmt.Sprintf("%s=%s", appLabel, appID)
+}
+
+// processSelector returns the labels that select the pods for a process.
+func processSelector(appID, process string) map[string]string {
+	return map[string]string{
+		appLabel:     appID,
+		processLabel: process,
+	}
+}
+
+// Kubernetes resource names must be valid DNS labels.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// resourceName returns the name of the resources for the process.
+func resourceName(app, process string) string {
+	return dnsLabel(fmt.Sprintf("%s-%s", app, process))
+}
+
+// runName returns a unique name for a one-off process.
+func runName(app, process string) string {
+	id := strings.Replace(uuid.New(), "-", "", -1)[:8]
+	// Leave room for the unique suffix.
+	name := resourceName(app, process)
+	if len(name) > validation.DNS1123LabelMaxLength-9 {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength-9], "-")
+	}
+	return fmt.Sprintf("%s-%s", name, id)
+}
+
+// containerName returns the name of the container for the process.
+func containerName(process string) string {
+	return dnsLabel(process)
+}
+
+// dnsLabel coerces s into a valid DNS label.
+func dnsLabel(s string) string {
+	s = invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > validation.DNS1123LabelMaxLength {
+		s = s[:validation.DNS1123LabelMaxLength]
+	}
+	return strings.Trim(s, "-")
+}
+
+// envVars converts the environment map into a list of EnvVars, sorted by name
+// so that the generated resources are stable.
+func envVars(env map[string]string) []corev1.EnvVar {
+	var keys []string
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var vars []corev1.EnvVar
+	for _, k := range keys {
+		vars = append(vars, corev1.EnvVar{Name: k, Value: env[k]})
+	}
+	return vars
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string)
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func publish(ctx context.Context, stream twelvefactor.StatusStream, msg string) {
+	if stream != nil {
+		if err := stream.Publish(twelvefactor.Status{Message: msg}); err != nil {
+			logger.Warn(ctx, fmt.Sprintf("error publishing to stream: %v", err))
+		}
+	}
+}
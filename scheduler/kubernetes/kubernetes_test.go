@@ -0,0 +1,490 @@
+//go:build kubernetes
+// +build kubernetes
+
+package kubernetes
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/remind101/empire/pkg/bytesize"
+	"github.com/remind101/empire/pkg/image"
+	"github.com/remind101/empire/twelvefactor"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+var ctx = context.Background()
+
+func init() {
+	pollDeploymentWait = 0
+	pollPodWait = 0
+}
+
+func TestScheduler_Submit(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID:   "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:    "acme-inc",
+		Release: "v1",
+		Env: map[string]string{
+			"FOO": "bar",
+		},
+		Labels: map[string]string{
+			"empire.app.id":      "c9366591-ab68-4d49-a333-95ce5a23df68",
+			"empire.app.name":    "acme-inc",
+			"empire.app.release": "v1",
+		},
+		Processes: []*twelvefactor.Process{
+			{
+				Type:      "web",
+				Image:     image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command:   []string{"./bin/web"},
+				Env:       map[string]string{"PORT": "8080"},
+				Labels:    map[string]string{"empire.app.process": "web"},
+				Memory:    128 * bytesize.MB,
+				CPUShares: 256,
+				Quantity:  2,
+				Exposure: &twelvefactor.Exposure{
+					External: true,
+					Ports: []twelvefactor.Port{
+						{Host: 80, Container: 8080, Protocol: &twelvefactor.HTTP{}},
+						{Host: 443, Container: 8080, Protocol: &twelvefactor.HTTPS{Cert: "arn:aws:iam::012345678901:server-certificate/AcmeIncDotCom"}},
+					},
+				},
+			},
+			{
+				Type:     "scheduled",
+				Image:    image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command:  []string{"./bin/scheduled"},
+				Labels:   map[string]string{"empire.app.process": "scheduled"},
+				Quantity: 1,
+				Schedule: twelvefactor.CRONSchedule("0/2 * * * ? *"),
+			},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	d, err := c.AppsV1().Deployments("default").Get(ctx, "acme-inc-web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *d.Spec.Replicas)
+	assert.Equal(t, map[string]string{
+		"empire.app.id":      "c9366591-ab68-4d49-a333-95ce5a23df68",
+		"empire.app.process": "web",
+	}, d.Spec.Selector.MatchLabels)
+	assert.Equal(t, "v1", d.Labels["empire.app.release"])
+
+	container := d.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "web", container.Name)
+	assert.Equal(t, "remind101/acme-inc:latest", container.Image)
+	assert.Equal(t, []string{"./bin/web"}, container.Args)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "FOO", Value: "bar"},
+		{Name: "PORT", Value: "8080"},
+	}, container.Env)
+	memory := container.Resources.Limits[corev1.ResourceMemory]
+	assert.Equal(t, "128Mi", memory.String())
+	cpu := container.Resources.Requests[corev1.ResourceCPU]
+	assert.Equal(t, "250m", cpu.String())
+
+	svc, err := c.CoreV1().Services("default").Get(ctx, "acme-inc-web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.ServiceTypeLoadBalancer, svc.Spec.Type)
+	assert.Equal(t, []corev1.ServicePort{
+		{Name: "http-80", Protocol: corev1.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(8080)},
+		{Name: "https-443", Protocol: corev1.ProtocolTCP, Port: 443, TargetPort: intstr.FromInt(8080)},
+	}, svc.Spec.Ports)
+	assert.Equal(t, "arn:aws:iam::012345678901:server-certificate/AcmeIncDotCom", svc.Annotations[sslCertAnnotation])
+	assert.Equal(t, "443", svc.Annotations[sslPortsAnnotation])
+
+	cj, err := c.BatchV1().CronJobs("default").Get(ctx, "acme-inc-scheduled", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "0/2 * * * *", cj.Spec.Schedule)
+	assert.False(t, *cj.Spec.Suspend)
+	assert.Equal(t, corev1.RestartPolicyNever, cj.Spec.JobTemplate.Spec.Template.Spec.RestartPolicy)
+
+	_, err = c.AppsV1().Deployments("default").Get(ctx, "acme-inc-scheduled", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestScheduler_Submit_Update(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	app := &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{Type: "web", Quantity: 1, Image: image.Image{Repository: "remind101/acme-inc", Tag: "v1"}},
+			{Type: "worker", Quantity: 1, Image: image.Image{Repository: "remind101/acme-inc", Tag: "v1"}},
+		},
+	}
+	assert.NoError(t, s.Submit(ctx, app, nil))
+
+	app.Processes = []*twelvefactor.Process{
+		{Type: "web", Quantity: 3, Image: image.Image{Repository: "remind101/acme-inc", Tag: "v2"}},
+	}
+	assert.NoError(t, s.Submit(ctx, app, nil))
+
+	d, err := c.AppsV1().Deployments("default").Get(ctx, "acme-inc-web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), *d.Spec.Replicas)
+	assert.Equal(t, "remind101/acme-inc:v2", d.Spec.Template.Spec.Containers[0].Image)
+
+	// The worker process was removed, so the deployment should be
+	// removed.
+	_, err = c.AppsV1().Deployments("default").Get(ctx, "acme-inc-worker", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestScheduler_Submit_StatusStream(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	// Simulate the deployment controller rolling out the new pods.
+	c.PrependReactor("get", "deployments", func(action ktesting.Action) (bool, runtime.Object, error) {
+		name := action.(ktesting.GetAction).GetName()
+		obj, err := c.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("deployments"), "default", name)
+		if err != nil {
+			return true, nil, err
+		}
+		d := obj.(*appsv1.Deployment).DeepCopy()
+		d.Status.Replicas = *d.Spec.Replicas
+		d.Status.UpdatedReplicas = *d.Spec.Replicas
+		d.Status.AvailableReplicas = *d.Spec.Replicas
+		return true, d, nil
+	})
+
+	var messages []string
+	ss := twelvefactor.StatusStreamFunc(func(status twelvefactor.Status) error {
+		messages = append(messages, status.Message)
+		return nil
+	})
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{Type: "web", Quantity: 1, Image: image.Image{Repository: "remind101/acme-inc", Tag: "latest"}},
+		},
+	}, ss)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Submitted 1 processes",
+		"Deployment acme-inc-web became stable",
+	}, messages)
+}
+
+func TestScheduler_Tasks(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "acme-inc-web-4bc7b9c8d-x2vkp",
+				Namespace: "default",
+				Labels: map[string]string{
+					"empire.app.id":      "c9366591-ab68-4d49-a333-95ce5a23df68",
+					"empire.app.process": "web",
+				},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "ip-10-0-0-1",
+				Containers: []corev1.Container{
+					{
+						Name: "web",
+						Args: []string{"./bin/web"},
+						Env:  []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("250m"),
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-web-4bc7b9c8d-x2vkp",
+				Namespace: "default",
+				Labels: map[string]string{
+					"empire.app.id": "e3d2ae67-6b5e-4e6f-8d1b-cd4a3c5e5f6b",
+				},
+			},
+		},
+	)
+	s := &Scheduler{client: c}
+
+	tasks, err := s.Tasks(ctx, "c9366591-ab68-4d49-a333-95ce5a23df68")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "acme-inc-web-4bc7b9c8d-x2vkp", tasks[0].ID)
+	assert.Equal(t, "ip-10-0-0-1", tasks[0].Host.ID)
+	assert.Equal(t, "RUNNING", tasks[0].State)
+	assert.Equal(t, &twelvefactor.Process{
+		Type:      "web",
+		Command:   []string{"./bin/web"},
+		Env:       map[string]string{"FOO": "bar"},
+		Memory:    128 * bytesize.MB,
+		CPUShares: 256,
+	}, tasks[0].Process)
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "acme-inc-web-4bc7b9c8d-x2vkp",
+				Namespace: "default",
+				Labels: map[string]string{
+					"empire.app.id": "c9366591-ab68-4d49-a333-95ce5a23df68",
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kube-dns",
+				Namespace: "default",
+			},
+		},
+	)
+	s := &Scheduler{client: c}
+
+	err := s.Stop(ctx, "acme-inc-web-4bc7b9c8d-x2vkp")
+	assert.NoError(t, err)
+
+	_, err = c.CoreV1().Pods("default").Get(ctx, "acme-inc-web-4bc7b9c8d-x2vkp", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	err = s.Stop(ctx, "kube-dns")
+	assert.EqualError(t, err, "pod kube-dns is not managed by Empire")
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:     "web",
+				Quantity: 1,
+				Exposure: &twelvefactor.Exposure{
+					Ports: []twelvefactor.Port{
+						{Host: 80, Container: 8080, Protocol: &twelvefactor.HTTP{}},
+					},
+				},
+			},
+			{Type: "scheduled", Schedule: twelvefactor.CRONSchedule("* * * * *")},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	err = s.Remove(ctx, "c9366591-ab68-4d49-a333-95ce5a23df68")
+	assert.NoError(t, err)
+
+	deployments, err := c.AppsV1().Deployments("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(deployments.Items))
+
+	services, err := c.CoreV1().Services("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(services.Items))
+
+	cronJobs, err := c.BatchV1().CronJobs("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(cronJobs.Items))
+}
+
+func TestScheduler_Restart(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	err := s.Submit(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{Type: "web", Quantity: 1},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	err = s.Restart(ctx, "c9366591-ab68-4d49-a333-95ce5a23df68", nil)
+	assert.NoError(t, err)
+
+	d, err := c.AppsV1().Deployments("default").Get(ctx, "acme-inc-web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, d.Spec.Template.Annotations[restartedAtAnnotation])
+}
+
+func TestScheduler_Run_Detached(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	s := &Scheduler{client: c}
+
+	err := s.Run(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "run",
+				Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command: []string{"rake", "db:migrate"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	jobs, err := c.BatchV1().Jobs("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(jobs.Items))
+
+	job := jobs.Items[0]
+	assert.True(t, strings.HasPrefix(job.Name, "acme-inc-run-"))
+	assert.Equal(t, Detached, job.Labels[runLabel])
+	assert.Equal(t, []string{"rake", "db:migrate"}, job.Spec.Template.Spec.Containers[0].Args)
+	assert.Equal(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+}
+
+func TestScheduler_Run_Attached(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	a := new(fakeAttacher)
+	s := &Scheduler{client: c, attacher: a}
+
+	// Simulate the pod being scheduled and started.
+	c.PrependReactor("create", "pods", func(action ktesting.Action) (bool, runtime.Object, error) {
+		pod := action.(ktesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Status.Phase = corev1.PodRunning
+		return false, nil, nil
+	})
+
+	stdout := new(bytes.Buffer)
+	err := s.Run(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "run",
+				Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command: []string{"bash"},
+				Stdin:   strings.NewReader("ls\n"),
+				Stdout:  stdout,
+				Stderr:  stdout,
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, a.pod) {
+		assert.Equal(t, Attached, a.pod.Labels[runLabel])
+		assert.True(t, a.pod.Spec.Containers[0].Stdin)
+		assert.True(t, a.pod.Spec.Containers[0].TTY)
+	}
+	assert.Contains(t, stdout.String(), "ls\n")
+
+	// The pod should be removed after we detach.
+	pods, err := c.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(pods.Items))
+}
+
+func TestScheduler_Run_AttachedExitCode(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	a := new(fakeAttacher)
+	s := &Scheduler{client: c, attacher: a}
+
+	// Simulate the pod running and exiting with a non-zero exit code.
+	c.PrependReactor("create", "pods", func(action ktesting.Action) (bool, runtime.Object, error) {
+		pod := action.(ktesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2}}},
+		}
+		return false, nil, nil
+	})
+
+	stdout := new(bytes.Buffer)
+	err := s.Run(ctx, &twelvefactor.Manifest{
+		AppID: "c9366591-ab68-4d49-a333-95ce5a23df68",
+		Name:  "acme-inc",
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "release",
+				Image:   image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
+				Command: []string{"rake", "db:migrate"},
+				Stdin:   strings.NewReader(""),
+				Stdout:  stdout,
+				Stderr:  stdout,
+			},
+		},
+	})
+	assert.Equal(t, &twelvefactor.ExitError{Code: 2}, err)
+}
+
+func TestCronSchedule(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		err bool
+	}{
+		{"0/2 * * * ? *", "0/2 * * * *", false},
+		{"0 12 * * ? *", "0 12 * * *", false},
+		{"*/5 * * * *", "*/5 * * * *", false},
+		{"* *", "", true},
+	}
+
+	for _, tt := range tests {
+		out, err := cronSchedule(twelvefactor.CRONSchedule(tt.in))
+		if tt.err {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.out, out)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		app, process string
+		out          string
+	}{
+		{"acme-inc", "web", "acme-inc-web"},
+		{"acme-inc", "web_worker", "acme-inc-web-worker"},
+		{"Acme.Inc", "Web", "acme-inc-web"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.out, resourceName(tt.app, tt.process))
+	}
+}
+
+// fakeAttacher is an attacher implementation that echoes stdin to stdout.
+type fakeAttacher struct {
+	pod *corev1.Pod
+}
+
+func (a *fakeAttacher) Attach(ctx context.Context, pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error {
+	a.pod = pod
+	_, err := io.Copy(stdout, stdin)
+	return err
+}
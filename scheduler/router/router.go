@@ -0,0 +1,335 @@
+// Package router implements the Scheduler interface by routing each app to one
+// of multiple backend schedulers.
+//
+// The backend for an app is recorded in the `scheduler_migration` table. Apps
+// without a recorded backend (or with a backend that's not configured) are
+// routed to the default backend. Apps can be moved between backends with
+// Migrate, which makes it possible to migrate apps from one scheduler to
+// another gradually.
+//
+// While an app is being migrated, Submit, Restart and Remove for the app wait
+// until the migration has finished, so that they're sent to the backend that
+// the app ends up on.
+package router
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"time"
+
+	pglock "github.com/remind101/empire/pkg/pg/lock"
+	"github.com/remind101/empire/twelvefactor"
+	"github.com/remind101/pkg/logger"
+	"golang.org/x/net/context"
+)
+
+// lockTimeout is how long we'll wait to obtain the migration lock for an app.
+const lockTimeout = 10 * time.Minute
+
+// UnknownBackendError is returned when attempting to migrate an app to a
+// backend that's not configured.
+type UnknownBackendError struct {
+	Backend string
+}
+
+// Error implements the error interface.
+func (e *UnknownBackendError) Error() string {
+	return fmt.Sprintf("unknown scheduler backend: %s", e.Backend)
+}
+
+// Scheduler is a twelvefactor.Scheduler implementation that routes each app
+// to a backend scheduler.
+type Scheduler struct {
+	// The name of the backend to use for apps that don't have a backend
+	// recorded.
+	DefaultBackend string
+
+	backends map[string]twelvefactor.Scheduler
+	db       *sql.DB
+}
+
+// NewScheduler returns a new Scheduler instance that routes apps to the given
+// backends.
+func NewScheduler(db *sql.DB, defaultBackend string, backends map[string]twelvefactor.Scheduler) *Scheduler {
+	return &Scheduler{
+		DefaultBackend: defaultBackend,
+		backends:       backends,
+		db:             db,
+	}
+}
+
+// Backends returns the names of all of the configured backends.
+func (s *Scheduler) Backends() []string {
+	var names []string
+	for name := range s.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Backend returns the name of the backend that the app is routed to.
+func (s *Scheduler) Backend(ctx context.Context, appID string) (string, error) {
+	var backend string
+	err := s.db.QueryRow(`SELECT backend FROM scheduler_migration WHERE app_id = $1`, appID).Scan(&backend)
+	if err == sql.ErrNoRows {
+		return s.DefaultBackend, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error finding scheduler backend for %s: %v", appID, err)
+	}
+
+	// Apps that were migrated to a backend that's no longer configured
+	// (for example, the legacy `ecs` backend) use the default.
+	if _, ok := s.backends[backend]; !ok {
+		return s.DefaultBackend, nil
+	}
+
+	return backend, nil
+}
+
+// Migrate moves an app to a different backend. The app is first submitted to
+// the new backend, and once it's stable, the app is routed to the new backend
+// and removed from the old one.
+//
+// The migration lock for the app is held for the whole migration, so other
+// operations on the app wait until it has finished.
+func (s *Scheduler) Migrate(ctx context.Context, app *twelvefactor.Manifest, backend string, ss twelvefactor.StatusStream) (err error) {
+	to, ok := s.backends[backend]
+	if !ok {
+		return &UnknownBackendError{Backend: backend}
+	}
+
+	l, err := s.lock(app.AppID, false)
+	if err != nil {
+		return err
+	}
+	defer unlock(l, &err)
+
+	current, err := s.Backend(ctx, app.AppID)
+	if err != nil {
+		return err
+	}
+
+	if current == backend {
+		return fmt.Errorf("%s is already using the %s backend", app.Name, backend)
+	}
+
+	from := s.backends[current]
+
+	// Schedulers only wait for the app to become stable when a
+	// StatusStream is provided.
+	if ss == nil {
+		ss = twelvefactor.NullStatusStream
+	}
+
+	publish(ctx, ss, fmt.Sprintf("Submitting %s to the %s backend", app.Name, backend))
+	if err := to.Submit(ctx, app, ss); err != nil {
+		return fmt.Errorf("error submitting to %s: %v", backend, err)
+	}
+
+	if err := s.setBackend(app.AppID, backend); err != nil {
+		return err
+	}
+
+	publish(ctx, ss, fmt.Sprintf("Removing %s from the %s backend", app.Name, current))
+	if err := from.Remove(ctx, app.AppID); err != nil {
+		return fmt.Errorf("error removing from %s: %v", current, err)
+	}
+
+	return nil
+}
+
+// setBackend records the backend that the app should be routed to.
+func (s *Scheduler) setBackend(appID, backend string) (err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM scheduler_migration WHERE app_id = $1`, appID); err != nil {
+		return fmt.Errorf("error updating scheduler backend: %v", err)
+	}
+
+	if _, err = tx.Exec(`INSERT INTO scheduler_migration (app_id, backend) VALUES ($1, $2)`, appID, backend); err != nil {
+		return fmt.Errorf("error updating scheduler backend: %v", err)
+	}
+
+	return nil
+}
+
+// Submit submits the app to the backend that the app is routed to.
+func (s *Scheduler) Submit(ctx context.Context, app *twelvefactor.Manifest, ss twelvefactor.StatusStream) (err error) {
+	l, err := s.lock(app.AppID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock(l, &err)
+
+	b, err := s.backend(ctx, app.AppID)
+	if err != nil {
+		return err
+	}
+	return b.Submit(ctx, app, ss)
+}
+
+// Run runs the processes with the backend that the app is routed to. One off
+// processes aren't removed by a migration, so Run doesn't wait for one.
+func (s *Scheduler) Run(ctx context.Context, app *twelvefactor.Manifest) error {
+	b, err := s.backend(ctx, app.AppID)
+	if err != nil {
+		return err
+	}
+	return b.Run(ctx, app)
+}
+
+// Restart restarts the app with the backend that the app is routed to.
+func (s *Scheduler) Restart(ctx context.Context, appID string, ss twelvefactor.StatusStream) (err error) {
+	l, err := s.lock(appID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock(l, &err)
+
+	b, err := s.backend(ctx, appID)
+	if err != nil {
+		return err
+	}
+	return b.Restart(ctx, appID, ss)
+}
+
+// Cancel cancels any in progress submission with the backend that the app is
+// routed to. Cancel doesn't wait for a migration, since it's used to stop
+// operations that are in progress.
+func (s *Scheduler) Cancel(ctx context.Context, appID string) error {
+	b, err := s.backend(ctx, appID)
+	if err != nil {
+		return err
+	}
+	return b.Cancel(ctx, appID)
+}
+
+// Tasks returns the tasks from the backend that the app is routed to.
+func (s *Scheduler) Tasks(ctx context.Context, appID string) ([]*twelvefactor.Task, error) {
+	b, err := s.backend(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	return b.Tasks(ctx, appID)
+}
+
+// Remove removes the app from the backend that it's routed to, and forgets
+// the recorded backend.
+func (s *Scheduler) Remove(ctx context.Context, appID string) (err error) {
+	l, err := s.lock(appID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock(l, &err)
+
+	b, err := s.backend(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	if err := b.Remove(ctx, appID); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM scheduler_migration WHERE app_id = $1`, appID); err != nil {
+		return fmt.Errorf("error removing scheduler backend: %v", err)
+	}
+
+	return nil
+}
+
+// Stop stops the given task. Since task ids aren't associated with an app,
+// each backend is tried in turn, starting with the default backend, until one
+// of them succeeds.
+func (s *Scheduler) Stop(ctx context.Context, taskID string) error {
+	var firstErr error
+
+	names := []string{s.DefaultBackend}
+	for _, name := range s.Backends() {
+		if name != s.DefaultBackend {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		b, ok := s.backends[name]
+		if !ok {
+			continue
+		}
+
+		err := b.Stop(ctx, taskID)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// backend returns the backend scheduler that the app is routed to.
+func (s *Scheduler) backend(ctx context.Context, appID string) (twelvefactor.Scheduler, error) {
+	name, err := s.Backend(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := s.backends[name]
+	if !ok {
+		return nil, &UnknownBackendError{Backend: name}
+	}
+
+	return b, nil
+}
+
+// lock obtains the migration lock for the app. Migrations obtain the lock
+// exclusively, and other operations obtain it shared, so that they don't block
+// each other.
+func (s *Scheduler) lock(appID string, shared bool) (*pglock.AdvisoryLock, error) {
+	l, err := pglock.NewAdvisoryLock(s.db, crc32.ChecksumIEEE([]byte(fmt.Sprintf("scheduler_migration_%s", appID))))
+	if err != nil {
+		return nil, err
+	}
+	l.LockTimeout = lockTimeout
+	l.Shared = shared
+	l.Context = fmt.Sprintf("scheduler migration %s", appID)
+
+	if err := l.Lock(); err != nil {
+		return nil, fmt.Errorf("error obtaining scheduler migration lock for %s: %v", appID, err)
+	}
+
+	return l, nil
+}
+
+// unlock releases the lock, setting err if releasing the lock failed and no
+// other error occurred.
+func unlock(l *pglock.AdvisoryLock, err *error) {
+	if uerr := l.Unlock(); uerr != nil && *err == nil {
+		*err = fmt.Errorf("error releasing scheduler migration lock: %v", uerr)
+	}
+}
+
+func publish(ctx context.Context, stream twelvefactor.StatusStream, msg string) {
+	if stream != nil {
+		if err := stream.Publish(twelvefactor.Status{Message: msg}); err != nil {
+			logger.Warn(ctx, fmt.Sprintf("error publishing to stream: %v", err))
+		}
+	}
+}
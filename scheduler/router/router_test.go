@@ -0,0 +1,297 @@
+package router
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	_ "github.com/lib/pq"
+	"github.com/remind101/empire/dbtest"
+	"github.com/remind101/empire/twelvefactor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var ctx = context.Background()
+
+func TestScheduler_Backend(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	s := NewScheduler(db, "cloudformation", map[string]twelvefactor.Scheduler{
+		"cloudformation": new(mockScheduler),
+		"kubernetes":     new(mockScheduler),
+	})
+
+	_, err := db.Exec(`INSERT INTO scheduler_migration (app_id, backend) VALUES ('migrated', 'kubernetes'), ('legacy', 'ecs')`)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		appID   string
+		backend string
+	}{
+		{"migrated", "kubernetes"},
+		{"legacy", "cloudformation"},
+		{"new", "cloudformation"},
+	}
+
+	for _, tt := range tests {
+		backend, err := s.Backend(ctx, tt.appID)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.backend, backend)
+	}
+}
+
+func TestScheduler_Submit(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	_, err := db.Exec(`INSERT INTO scheduler_migration (app_id, backend) VALUES ('app2', 'b')`)
+	assert.NoError(t, err)
+
+	app1 := &twelvefactor.Manifest{AppID: "app1"}
+	app2 := &twelvefactor.Manifest{AppID: "app2"}
+	a.On("Submit", app1).Return(nil)
+	b.On("Submit", app2).Return(nil)
+
+	assert.NoError(t, s.Submit(ctx, app1, nil))
+	assert.NoError(t, s.Submit(ctx, app2, nil))
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	_, err := db.Exec(`INSERT INTO scheduler_migration (app_id, backend) VALUES ('app', 'b')`)
+	assert.NoError(t, err)
+
+	b.On("Cancel", "app").Return(nil)
+
+	err = s.Cancel(ctx, "app")
+	assert.NoError(t, err)
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Migrate(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	app := &twelvefactor.Manifest{AppID: "app", Name: "acme-inc"}
+	b.On("Submit", app).Return(nil)
+	a.On("Remove", "app").Return(nil)
+
+	err := s.Migrate(ctx, app, "b", nil)
+	assert.NoError(t, err)
+
+	backend, err := s.Backend(ctx, "app")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", backend)
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Migrate_Lock(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	app := &twelvefactor.Manifest{AppID: "app", Name: "acme-inc"}
+	release := make(chan time.Time)
+	b.On("Submit", app).WaitUntil(release).Return(nil)
+	a.On("Remove", "app").Return(nil)
+
+	migrated := make(chan error)
+	go func() {
+		migrated <- s.Migrate(ctx, app, "b", nil)
+	}()
+
+	// Give the migration time to obtain the lock.
+	time.Sleep(time.Second)
+
+	submitted := make(chan error)
+	go func() {
+		submitted <- s.Submit(ctx, app, nil)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("submit should wait for the migration to finish")
+	case <-time.After(time.Second):
+	}
+
+	close(release)
+
+	assert.NoError(t, <-migrated)
+	assert.NoError(t, <-submitted)
+
+	// The submission should have been sent to the new backend.
+	a.AssertExpectations(t)
+	b.AssertNumberOfCalls(t, "Submit", 2)
+}
+
+func TestScheduler_Migrate_SubmitError(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	app := &twelvefactor.Manifest{AppID: "app", Name: "acme-inc"}
+	b.On("Submit", app).Return(errors.New("boom"))
+
+	err := s.Migrate(ctx, app, "b", nil)
+	assert.EqualError(t, err, "error submitting to b: boom")
+
+	// The app should still be routed to the old backend.
+	backend, err := s.Backend(ctx, "app")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", backend)
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Migrate_Errors(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": new(mockScheduler),
+	})
+
+	app := &twelvefactor.Manifest{AppID: "app", Name: "acme-inc"}
+
+	err := s.Migrate(ctx, app, "c", nil)
+	assert.EqualError(t, err, "unknown scheduler backend: c")
+
+	err = s.Migrate(ctx, app, "a", nil)
+	assert.EqualError(t, err, "acme-inc is already using the a backend")
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	db := newDB(t)
+	defer db.Close()
+
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(db, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	_, err := db.Exec(`INSERT INTO scheduler_migration (app_id, backend) VALUES ('app', 'b')`)
+	assert.NoError(t, err)
+
+	b.On("Remove", "app").Return(nil)
+
+	err = s.Remove(ctx, "app")
+	assert.NoError(t, err)
+
+	backend, err := s.Backend(ctx, "app")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", backend)
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(nil, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	a.On("Stop", "task").Return(errors.New("task not found"))
+	b.On("Stop", "task").Return(nil)
+
+	err := s.Stop(ctx, "task")
+	assert.NoError(t, err)
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_Stop_NotFound(t *testing.T) {
+	a, b := new(mockScheduler), new(mockScheduler)
+	s := NewScheduler(nil, "a", map[string]twelvefactor.Scheduler{
+		"a": a,
+		"b": b,
+	})
+
+	a.On("Stop", "task").Return(errors.New("task not found in a"))
+	b.On("Stop", "task").Return(errors.New("task not found in b"))
+
+	err := s.Stop(ctx, "task")
+	assert.EqualError(t, err, "task not found in a")
+
+	a.AssertExpectations(t)
+	b.AssertExpectations(t)
+}
+
+func newDB(t testing.TB) *sql.DB {
+	db := dbtest.Open(t)
+	if _, err := db.Exec(`TRUNCATE TABLE scheduler_migration`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type mockScheduler struct {
+	twelvefactor.Scheduler
+	mock.Mock
+}
+
+func (m *mockScheduler) Submit(ctx context.Context, app *twelvefactor.Manifest, ss twelvefactor.StatusStream) error {
+	args := m.Called(app)
+	return args.Error(0)
+}
+
+func (m *mockScheduler) Remove(ctx context.Context, appID string) error {
+	args := m.Called(appID)
+	return args.Error(0)
+}
+
+func (m *mockScheduler) Cancel(ctx context.Context, appID string) error {
+	args := m.Called(appID)
+	return args.Error(0)
+}
+
+func (m *mockScheduler) Stop(ctx context.Context, taskID string) error {
+	args := m.Called(taskID)
+	return args.Error(0)
+}
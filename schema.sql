@@ -74,7 +74,39 @@ CREATE TABLE apps (
     exposure text DEFAULT 'private'::text NOT NULL,
     certs json,
     maintenance boolean DEFAULT false NOT NULL,
-    deleted_at timestamp without time zone
+    deleted_at timestamp without time zone,
+    auto_rollback boolean
+);
+
+
+--
+-- Name: audit_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE audit_events (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid,
+    app_name text,
+    user_name text,
+    event text NOT NULL,
+    message text NOT NULL,
+    data json NOT NULL,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
+--
+-- Name: canaries; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE canaries (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid NOT NULL,
+    version integer NOT NULL,
+    stable_version integer NOT NULL,
+    weight integer NOT NULL,
+    promote_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now())
 );
 
 
@@ -92,6 +124,19 @@ CREATE TABLE certificates (
 );
 
 
+--
+-- Name: collaborators; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE collaborators (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid NOT NULL,
+    user_name text NOT NULL,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
 --
 -- Name: configs; Type: TABLE; Schema: public; Owner: -
 --
@@ -104,6 +149,26 @@ CREATE TABLE configs (
 );
 
 
+--
+-- Name: deployments; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE deployments (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid NOT NULL,
+    image text NOT NULL,
+    user_name text,
+    state text NOT NULL,
+    version integer,
+    error text,
+    messages json NOT NULL,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp without time zone,
+    finished_at timestamp without time zone,
+    cancelled_state text
+);
+
+
 --
 -- Name: domains; Type: TABLE; Schema: public; Owner: -
 --
@@ -126,6 +191,82 @@ CREATE TABLE ecs_environment (
 );
 
 
+--
+-- Name: log_drains; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE log_drains (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid NOT NULL,
+    url text NOT NULL,
+    token text NOT NULL,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
+--
+-- Name: notifications; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE notifications (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid NOT NULL,
+    kind text NOT NULL,
+    target text NOT NULL,
+    events json,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    secret text
+);
+
+
+--
+-- Name: outbox_deliveries; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE outbox_deliveries (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    event_id uuid NOT NULL,
+    stream text NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+    delivered_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
+--
+-- Name: outbox_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE outbox_events (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    app_id uuid,
+    name text NOT NULL,
+    message text NOT NULL,
+    data json NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+    next_attempt_at timestamp without time zone NOT NULL,
+    delivered_at timestamp without time zone,
+    dead_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
+--
+-- Name: policies; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE policies (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    name text NOT NULL,
+    document text NOT NULL,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp without time zone DEFAULT timezone('utc'::text, now())
+);
+
+
 --
 -- Name: ports; Type: TABLE; Schema: public; Owner: -
 --
@@ -150,7 +291,9 @@ CREATE TABLE releases (
     version integer NOT NULL,
     description text,
     created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
-    formation json NOT NULL
+    formation json NOT NULL,
+    failed boolean DEFAULT false NOT NULL,
+    pending boolean DEFAULT false NOT NULL
 );
 
 
@@ -173,6 +316,25 @@ CREATE TABLE schema_migrations (
 );
 
 
+--
+-- Name: service_accounts; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE service_accounts (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    scopes json NOT NULL,
+    created_by text NOT NULL,
+    expires_at timestamp without time zone,
+    last_used_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp without time zone DEFAULT timezone('utc'::text, now()),
+    groups json,
+    creator_token text
+);
+
+
 --
 -- Name: slugs; Type: TABLE; Schema: public; Owner: -
 --
@@ -202,6 +364,22 @@ ALTER TABLE ONLY apps
     ADD CONSTRAINT apps_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: audit_events audit_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: canaries canaries_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY canaries
+    ADD CONSTRAINT canaries_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: certificates certificates_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -210,6 +388,14 @@ ALTER TABLE ONLY certificates
     ADD CONSTRAINT certificates_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: collaborators collaborators_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY collaborators
+    ADD CONSTRAINT collaborators_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: configs configs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -218,6 +404,14 @@ ALTER TABLE ONLY configs
     ADD CONSTRAINT configs_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: deployments deployments_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY deployments
+    ADD CONSTRAINT deployments_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: domains domains_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -234,6 +428,46 @@ ALTER TABLE ONLY ecs_environment
     ADD CONSTRAINT ecs_environment_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: log_drains log_drains_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY log_drains
+    ADD CONSTRAINT log_drains_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: notifications notifications_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY notifications
+    ADD CONSTRAINT notifications_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: outbox_deliveries outbox_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY outbox_deliveries
+    ADD CONSTRAINT outbox_deliveries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: outbox_events outbox_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY outbox_events
+    ADD CONSTRAINT outbox_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: policies policies_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY policies
+    ADD CONSTRAINT policies_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: ports ports_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -258,6 +492,14 @@ ALTER TABLE ONLY schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
 
 
+--
+-- Name: service_accounts service_accounts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY service_accounts
+    ADD CONSTRAINT service_accounts_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: slugs slugs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -266,6 +508,27 @@ ALTER TABLE ONLY slugs
     ADD CONSTRAINT slugs_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: index_audit_events_on_app_name; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX index_audit_events_on_app_name ON audit_events USING btree (app_name);
+
+
+--
+-- Name: index_audit_events_on_created_at; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX index_audit_events_on_created_at ON audit_events USING btree (created_at);
+
+
+--
+-- Name: index_canaries_on_app_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_canaries_on_app_id ON canaries USING btree (app_id);
+
+
 --
 -- Name: index_certificates_on_app_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -273,6 +536,13 @@ ALTER TABLE ONLY slugs
 CREATE UNIQUE INDEX index_certificates_on_app_id ON certificates USING btree (app_id);
 
 
+--
+-- Name: index_collaborators_on_app_id_and_user_name; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_collaborators_on_app_id_and_user_name ON collaborators USING btree (app_id, user_name);
+
+
 --
 -- Name: index_configs_on_created_at; Type: INDEX; Schema: public; Owner: -
 --
@@ -280,6 +550,13 @@ CREATE UNIQUE INDEX index_certificates_on_app_id ON certificates USING btree (ap
 CREATE INDEX index_configs_on_created_at ON configs USING btree (created_at);
 
 
+--
+-- Name: index_deployments_on_app_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX index_deployments_on_app_id ON deployments USING btree (app_id);
+
+
 --
 -- Name: index_domains_on_app_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -294,6 +571,41 @@ CREATE INDEX index_domains_on_app_id ON domains USING btree (app_id);
 CREATE UNIQUE INDEX index_domains_on_hostname ON domains USING btree (hostname);
 
 
+--
+-- Name: index_log_drains_on_app_id_and_url; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_log_drains_on_app_id_and_url ON log_drains USING btree (app_id, url);
+
+
+--
+-- Name: index_notifications_on_app_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX index_notifications_on_app_id ON notifications USING btree (app_id);
+
+
+--
+-- Name: index_outbox_deliveries_on_event_id_and_stream; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_outbox_deliveries_on_event_id_and_stream ON outbox_deliveries USING btree (event_id, stream);
+
+
+--
+-- Name: index_outbox_events_on_next_attempt_at; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX index_outbox_events_on_next_attempt_at ON outbox_events USING btree (next_attempt_at) WHERE ((delivered_at IS NULL) AND (dead_at IS NULL));
+
+
+--
+-- Name: index_policies_on_name; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_policies_on_name ON policies USING btree (name);
+
+
 --
 -- Name: index_releases_on_app_id_and_version; Type: INDEX; Schema: public; Owner: -
 --
@@ -301,6 +613,20 @@ CREATE UNIQUE INDEX index_domains_on_hostname ON domains USING btree (hostname);
 CREATE UNIQUE INDEX index_releases_on_app_id_and_version ON releases USING btree (app_id, version);
 
 
+--
+-- Name: index_service_accounts_on_name; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_service_accounts_on_name ON service_accounts USING btree (name);
+
+
+--
+-- Name: index_service_accounts_on_token_hash; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX index_service_accounts_on_token_hash ON service_accounts USING btree (token_hash);
+
+
 --
 -- Name: index_stacks_on_app_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -322,6 +648,14 @@ CREATE UNIQUE INDEX index_stacks_on_stack_name ON stacks USING btree (stack_name
 CREATE UNIQUE INDEX unique_app_name ON apps USING btree (name) WHERE (deleted_at IS NULL);
 
 
+--
+-- Name: canaries canaries_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY canaries
+    ADD CONSTRAINT canaries_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
+
+
 --
 -- Name: certificates certificates_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -330,6 +664,14 @@ ALTER TABLE ONLY certificates
     ADD CONSTRAINT certificates_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
 
 
+--
+-- Name: collaborators collaborators_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY collaborators
+    ADD CONSTRAINT collaborators_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
+
+
 --
 -- Name: configs configs_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -338,6 +680,14 @@ ALTER TABLE ONLY configs
     ADD CONSTRAINT configs_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
 
 
+--
+-- Name: deployments deployments_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY deployments
+    ADD CONSTRAINT deployments_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
+
+
 --
 -- Name: domains domains_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -346,6 +696,30 @@ ALTER TABLE ONLY domains
     ADD CONSTRAINT domains_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
 
 
+--
+-- Name: log_drains log_drains_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY log_drains
+    ADD CONSTRAINT log_drains_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
+
+
+--
+-- Name: notifications notifications_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY notifications
+    ADD CONSTRAINT notifications_app_id_fkey FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE;
+
+
+--
+-- Name: outbox_deliveries outbox_deliveries_event_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY outbox_deliveries
+    ADD CONSTRAINT outbox_deliveries_event_id_fkey FOREIGN KEY (event_id) REFERENCES outbox_events(id) ON DELETE CASCADE;
+
+
 --
 -- Name: ports ports_app_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/remind101/empire"
@@ -43,6 +44,21 @@ func (e *UnauthorizedError) Error() string {
 type Auth struct {
 	Strategies Strategies
 	Authorizer Authorizer
+
+	// If provided, checks that the user can perform each action. The
+	// default is to allow authorized users to perform any action.
+	ActionAuthorizer ActionAuthorizer
+
+	// If provided, checks that the user can perform admin actions (e.g.
+	// "admin:policies"), instead of the ActionAuthorizer. Admin actions are
+	// always denied if this isn't provided.
+	AdminAuthorizer ActionAuthorizer
+
+	// If provided, checks that service accounts have access to Empire,
+	// instead of the Authorizer. This is for Authorizers that can't check
+	// service accounts, like GitHub organization membership, which needs
+	// the user's GitHub token.
+	ServiceAccountAuthorizer Authorizer
 }
 
 // Strategy wraps an authenticator with a name.
@@ -96,8 +112,11 @@ func (s Strategies) strategy(name string) *Strategy {
 
 func (a *Auth) copy() *Auth {
 	return &Auth{
-		Strategies: a.Strategies[:],
-		Authorizer: a.Authorizer,
+		Strategies:               a.Strategies[:],
+		Authorizer:               a.Authorizer,
+		ActionAuthorizer:         a.ActionAuthorizer,
+		AdminAuthorizer:          a.AdminAuthorizer,
+		ServiceAccountAuthorizer: a.ServiceAccountAuthorizer,
 	}
 }
 
@@ -130,15 +149,89 @@ func (a *Auth) authenticate(ctx context.Context, authenticator Authenticator, us
 
 	ctx = WithSession(ctx, session)
 
-	if a.Authorizer != nil {
-		if err := a.Authorizer.Authorize(session.User); err != nil {
-			return ctx, err
-		}
+	if err := a.Authorize(session); err != nil {
+		return ctx, err
 	}
 
 	return ctx, nil
 }
 
+// Authorize checks that the user in the session has access to Empire, using
+// the Authorizer. This is done when authenticating, but should also be done
+// when sessions are created outside of an Authenticator (e.g. SAML logins).
+func (a *Auth) Authorize(session *Session) error {
+	// Service accounts have the groups of the user that created them, so
+	// they're checked by the Authorizer, unless it can't check them.
+	authorizer := a.Authorizer
+	if session.ServiceAccount && a.ServiceAccountAuthorizer != nil {
+		authorizer = a.ServiceAccountAuthorizer
+	}
+
+	if authorizer == nil {
+		return nil
+	}
+
+	return authorizer.Authorize(session)
+}
+
+// CreatorAuthorizer returns an Authorizer for service accounts, which checks
+// that the user that created the service account is still authorized by a, so
+// that service accounts stop working once their creator loses access.
+func CreatorAuthorizer(a Authorizer) Authorizer {
+	return AuthorizerFunc(func(session *Session) error {
+		if session.Creator == nil {
+			return &UnauthorizedError{
+				Reason: fmt.Sprintf("The creator of %s can't be authorized. Create a new service account.", session.User.Name),
+			}
+		}
+
+		if err := a.Authorize(NewSession(session.Creator)); err != nil {
+			if err, ok := err.(*UnauthorizedError); ok {
+				return &UnauthorizedError{
+					Reason: fmt.Sprintf("%s was created by %s, who is no longer authorized: %s", session.User.Name, session.Creator.Name, err.Reason),
+				}
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// AuthorizeAction checks that the authenticated user in the context can perform
+// the action on the app. app should be empty for actions that don't relate to
+// an app.
+func (a *Auth) AuthorizeAction(ctx context.Context, action, app string) error {
+	session := SessionFromContext(ctx)
+
+	// The scopes of service accounts are always enforced.
+	if session.ServiceAccount {
+		if err := authorizeScopes(session, action, app); err != nil {
+			return err
+		}
+	}
+
+	if isAdminAction(action) {
+		if a.AdminAuthorizer == nil {
+			return unauthorizedAction(session, action, app)
+		}
+
+		return a.AdminAuthorizer.AuthorizeAction(session, action, app)
+	}
+
+	if a.ActionAuthorizer == nil {
+		return nil
+	}
+
+	return a.ActionAuthorizer.AuthorizeAction(session, action, app)
+}
+
+// isAdminAction returns true if the action manages Empire itself, rather than
+// apps.
+func isAdminAction(action string) bool {
+	return strings.HasPrefix(action, "admin:")
+}
+
 // Session represents an authenticated Session.
 type Session struct {
 	// The authenticated User.
@@ -146,6 +239,21 @@ type Session struct {
 
 	// When this Session will expire. The zero value means no expiration.
 	ExpiresAt *time.Time
+
+	// The groups that the user belongs to, which policies can grant access
+	// to.
+	Groups []string
+
+	// True if the session was authenticated with a service account token.
+	ServiceAccount bool
+
+	// The scopes of the service account, which limit the actions that can
+	// be performed.
+	Scopes []string
+
+	// For service accounts, the user that created the service account, with
+	// the credentials that they had when they created it, if they're known.
+	Creator *empire.User
 }
 
 // NewSession returns a new Session for the user.
@@ -171,15 +279,43 @@ func (fn AuthenticatorFunc) Authenticate(username, password, otp string) (*Sessi
 
 // Authorizer represents something that can perform an authorization check.
 type Authorizer interface {
-	// Authorize should check that the user has access to perform the
-	// action. If not, ErrUnauthorized should be returned.
-	Authorize(*empire.User) error
+	// Authorize should check that the user in the session has access to
+	// Empire. If not, an UnauthorizedError should be returned.
+	Authorize(*Session) error
+}
+
+type AuthorizerFunc func(*Session) error
+
+func (fn AuthorizerFunc) Authorize(session *Session) error {
+	return fn(session)
+}
+
+// ActionAuthorizer represents something that can check whether a user can
+// perform a specific action, like deploying or destroying an app.
+type ActionAuthorizer interface {
+	// AuthorizeAction should check that the user in the session can
+	// perform the action on the app. If not, an UnauthorizedError should
+	// be returned.
+	AuthorizeAction(session *Session, action, app string) error
 }
 
-type AuthorizerFunc func(*empire.User) error
+type ActionAuthorizerFunc func(*Session, string, string) error
+
+func (fn ActionAuthorizerFunc) AuthorizeAction(session *Session, action, app string) error {
+	return fn(session, action, app)
+}
 
-func (fn AuthorizerFunc) Authorize(user *empire.User) error {
-	return fn(user)
+// MultiActionAuthorizer returns an ActionAuthorizer that only allows an action
+// if all of the given ActionAuthorizers allow it.
+func MultiActionAuthorizer(authorizers ...ActionAuthorizer) ActionAuthorizer {
+	return ActionAuthorizerFunc(func(session *Session, action, app string) error {
+		for _, a := range authorizers {
+			if err := a.AuthorizeAction(session, action, app); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // StaticAuthenticator returns an Authenticator that returns the provided user
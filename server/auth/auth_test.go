@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -144,6 +146,86 @@ func TestWithMaxSessionDuration_WithLongerExpiresAt(t *testing.T) {
 	m.AssertExpectations(t)
 }
 
+func TestAuth_Authenticate_ServiceAccount(t *testing.T) {
+	m := new(mockAuthenticator)
+	z := new(mockAuthorizer)
+	a := &Auth{
+		Strategies: Strategies{
+			{Name: StrategyAccessToken, Authenticator: m},
+		},
+		Authorizer: z,
+	}
+
+	session := NewSession(&empire.User{Name: "service:ci"})
+	session.ServiceAccount = true
+	m.On("Authenticate", "", "emp_token", "").Return(session, nil)
+
+	// Service accounts are checked by the Authorizer.
+	z.On("Authorize", session).Return(&UnauthorizedError{Reason: "no"}).Once()
+	_, err := a.Authenticate(context.Background(), "", "emp_token", "")
+	assert.EqualError(t, err, "no")
+
+	// Unless the ServiceAccountAuthorizer is provided.
+	a.ServiceAccountAuthorizer = AuthorizerFunc(func(s *Session) error {
+		assert.Equal(t, session, s)
+		return nil
+	})
+	ctx, err := a.Authenticate(context.Background(), "", "emp_token", "")
+	assert.NoError(t, err)
+	assert.Equal(t, session, SessionFromContext(ctx))
+
+	m.AssertExpectations(t)
+	z.AssertExpectations(t)
+}
+
+func TestCreatorAuthorizer(t *testing.T) {
+	z := new(mockAuthorizer)
+	a := CreatorAuthorizer(z)
+
+	creator := &empire.User{Name: "ejholmes", GitHubToken: "abcd"}
+	session := NewSession(&empire.User{Name: "service:ci"})
+	session.ServiceAccount = true
+	session.Creator = creator
+
+	// The creator is authorized in place of the service account.
+	z.On("Authorize", NewSession(creator)).Return(nil).Once()
+	assert.NoError(t, a.Authorize(session))
+
+	z.On("Authorize", NewSession(creator)).Return(&UnauthorizedError{Reason: "ejholmes is not a member of the \"remind101\" organization."}).Once()
+	err := a.Authorize(session)
+	assert.EqualError(t, err, `service:ci was created by ejholmes, who is no longer authorized: ejholmes is not a member of the "remind101" organization.`)
+
+	// Service accounts without a known creator are rejected.
+	session.Creator = nil
+	err = a.Authorize(session)
+	assert.EqualError(t, err, "The creator of service:ci can't be authorized. Create a new service account.")
+
+	z.AssertExpectations(t)
+}
+
+func TestAuth_AuthorizeAction_Admin(t *testing.T) {
+	ctx := WithSession(context.Background(), NewSession(&empire.User{Name: "ejholmes"}))
+
+	// Without an AdminAuthorizer, admin actions are denied, even though
+	// any other action is allowed.
+	a := &Auth{}
+	assert.NoError(t, a.AuthorizeAction(ctx, "deploy", "acme-inc"))
+	assert.EqualError(t, a.AuthorizeAction(ctx, "admin:policies", ""), "ejholmes is not allowed to perform admin:policies.")
+
+	// Admin actions are only checked by the AdminAuthorizer.
+	a = &Auth{
+		ActionAuthorizer: ActionAuthorizerFunc(func(*Session, string, string) error {
+			return errors.New("no")
+		}),
+		AdminAuthorizer: ActionAuthorizerFunc(func(session *Session, action, app string) error {
+			assert.Equal(t, "admin:policies", action)
+			return nil
+		}),
+	}
+	assert.NoError(t, a.AuthorizeAction(ctx, "admin:policies", ""))
+	assert.EqualError(t, a.AuthorizeAction(ctx, "deploy", "acme-inc"), "no")
+}
+
 type mockAuthenticator struct {
 	mock.Mock
 }
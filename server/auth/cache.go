@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/pmylund/go-cache"
-	"github.com/remind101/empire"
 )
 
 // CacheAuthorization wraps an Authorizer in an in memory cache that expires
@@ -29,8 +28,8 @@ type cachedAuthorizer struct {
 	}
 }
 
-func (a *cachedAuthorizer) Authorize(user *empire.User) error {
-	_, ok := a.cache.Get(user.Name)
+func (a *cachedAuthorizer) Authorize(session *Session) error {
+	_, ok := a.cache.Get(session.User.Name)
 
 	// Authorized!
 	if ok {
@@ -38,12 +37,51 @@ func (a *cachedAuthorizer) Authorize(user *empire.User) error {
 	}
 
 	// Not in cache, call down to the wrapped Authorizer.
-	err := a.Authorizer.Authorize(user)
+	err := a.Authorizer.Authorize(session)
 
 	// Only cache positive authorizations.
 	if err == nil {
-		a.cache.Set(user.Name, true, 0)
+		a.cache.Set(session.User.Name, true, 0)
 	}
 
 	return err
 }
+
+// CacheMembership wraps a MembershipChecker in an in memory cache that expires
+// after the given expiration. Both positive and negative results are cached,
+// since membership is checked for every request.
+func CacheMembership(m MembershipChecker, expiration time.Duration) MembershipChecker {
+	cache := cache.New(expiration, 30*time.Second)
+
+	return &cachedMembershipChecker{
+		MembershipChecker: m,
+		cache:             cache,
+	}
+}
+
+// cachedMembershipChecker is a MembershipChecker middleware that caches
+// membership checks.
+type cachedMembershipChecker struct {
+	MembershipChecker
+
+	cache interface {
+		Set(k string, x interface{}, d time.Duration)
+		Get(k string) (interface{}, bool)
+	}
+}
+
+func (m *cachedMembershipChecker) IsMember(session *Session, team string) (bool, error) {
+	key := session.User.Name + "\x00" + team
+
+	if v, ok := m.cache.Get(key); ok {
+		return v.(bool), nil
+	}
+
+	ok, err := m.MembershipChecker.IsMember(session, team)
+	if err != nil {
+		return ok, err
+	}
+
+	m.cache.Set(key, ok, 0)
+	return ok, nil
+}
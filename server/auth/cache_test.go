@@ -10,7 +10,7 @@ import (
 )
 
 func TestCachedAuthorizer_Cached(t *testing.T) {
-	u := &empire.User{Name: "ejholmes"}
+	u := NewSession(&empire.User{Name: "ejholmes"})
 	c := new(mockCache)
 	a := &cachedAuthorizer{
 		cache: c,
@@ -25,7 +25,7 @@ func TestCachedAuthorizer_Cached(t *testing.T) {
 }
 
 func TestCachedAuthorizer_Positive(t *testing.T) {
-	u := &empire.User{Name: "ejholmes"}
+	u := NewSession(&empire.User{Name: "ejholmes"})
 	c := new(mockCache)
 	m := new(mockAuthorizer)
 	a := &cachedAuthorizer{
@@ -45,7 +45,7 @@ func TestCachedAuthorizer_Positive(t *testing.T) {
 }
 
 func TestCachedAuthorizer_Negative(t *testing.T) {
-	u := &empire.User{Name: "ejholmes"}
+	u := NewSession(&empire.User{Name: "ejholmes"})
 	c := new(mockCache)
 	m := new(mockAuthorizer)
 	a := &cachedAuthorizer{
@@ -64,6 +64,43 @@ func TestCachedAuthorizer_Negative(t *testing.T) {
 	m.AssertExpectations(t)
 }
 
+func TestCachedMembershipChecker_Cached(t *testing.T) {
+	s := NewSession(&empire.User{Name: "ejholmes"})
+	c := new(mockCache)
+	m := &cachedMembershipChecker{
+		cache: c,
+	}
+
+	c.On("Get", "ejholmes\x00123").Return(false, true)
+
+	ok, err := m.IsMember(s, "123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	c.AssertExpectations(t)
+}
+
+func TestCachedMembershipChecker_NotCached(t *testing.T) {
+	s := NewSession(&empire.User{Name: "ejholmes"})
+	c := new(mockCache)
+	mc := new(mockMembershipChecker)
+	m := &cachedMembershipChecker{
+		MembershipChecker: mc,
+		cache:             c,
+	}
+
+	mc.On("IsMember", s, "123").Return(true, nil)
+	c.On("Get", "ejholmes\x00123").Return(nil, false)
+	c.On("Set", "ejholmes\x00123", true, time.Duration(0))
+
+	ok, err := m.IsMember(s, "123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	c.AssertExpectations(t)
+	mc.AssertExpectations(t)
+}
+
 type mockCache struct {
 	mock.Mock
 }
@@ -81,7 +118,16 @@ type mockAuthorizer struct {
 	mock.Mock
 }
 
-func (m *mockAuthorizer) Authorize(user *empire.User) error {
-	args := m.Called(user)
+func (m *mockAuthorizer) Authorize(session *Session) error {
+	args := m.Called(session)
 	return args.Error(0)
 }
+
+type mockMembershipChecker struct {
+	mock.Mock
+}
+
+func (m *mockMembershipChecker) IsMember(session *Session, team string) (bool, error) {
+	args := m.Called(session, team)
+	return args.Bool(0), args.Error(1)
+}
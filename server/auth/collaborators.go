@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/remind101/empire"
+)
+
+// readActions are the actions that don't change an app, which any user can
+// perform on any app.
+var readActions = map[string]bool{
+	"access:list":        true,
+	"apps:info":          true,
+	"apps:list":          true,
+	"canary:info":        true,
+	"config:get":         true,
+	"deployments:info":   true,
+	"domains:list":       true,
+	"drains:list":        true,
+	"events:list":        true,
+	"logs":               true,
+	"notifications:list": true,
+	"ps:list":            true,
+	"releases:info":      true,
+	"releases:list":      true,
+	"scale:list":         true,
+	"scheduler:info":     true,
+}
+
+// CollaboratorStore provides the names of the users that collaborate on an
+// app.
+type CollaboratorStore interface {
+	Collaborators(app string) ([]string, error)
+}
+
+// CollaboratorStoreFunc is a function signature that implements the
+// CollaboratorStore interface.
+type CollaboratorStoreFunc func(string) ([]string, error)
+
+// Collaborators calls the CollaboratorStoreFunc.
+func (fn CollaboratorStoreFunc) Collaborators(app string) ([]string, error) {
+	return fn(app)
+}
+
+// DatabaseCollaboratorStore returns a CollaboratorStore that returns the
+// collaborators stored in Empire's database. Apps that don't exist yet have no
+// collaborators.
+func DatabaseCollaboratorStore(e interface {
+	Apps(empire.AppsQuery) ([]*empire.App, error)
+	Collaborators(empire.CollaboratorsQuery) ([]*empire.Collaborator, error)
+}) CollaboratorStore {
+	return CollaboratorStoreFunc(func(app string) ([]string, error) {
+		apps, err := e.Apps(empire.AppsQuery{Name: &app})
+		if err != nil {
+			return nil, fmt.Errorf("error finding app: %v", err)
+		}
+
+		var names []string
+		for _, a := range apps {
+			collaborators, err := e.Collaborators(empire.CollaboratorsQuery{App: a})
+			if err != nil {
+				return nil, fmt.Errorf("error finding collaborators: %v", err)
+			}
+
+			for _, c := range collaborators {
+				names = append(names, c.UserName)
+			}
+		}
+
+		return names, nil
+	})
+}
+
+// CollaboratorAuthorizer is an ActionAuthorizer that only allows the
+// collaborators on an app, and admins, to make changes to the app. Any user can
+// perform actions that only read an app. Apps without any collaborators can be
+// changed by anyone, but only admins can add their first collaborator. Admin
+// actions and service account authorizations can only be managed by admins.
+type CollaboratorAuthorizer struct {
+	// Provides the collaborators on an app.
+	Collaborators CollaboratorStore
+
+	// Principals that can make changes to any app, in the same format as
+	// the principals in a policy Statement (e.g. "team:1234").
+	Admins []string
+
+	// Used to check membership of "team:" admins. If not provided, team
+	// admins never match.
+	Teams MembershipChecker
+}
+
+// AuthorizeAction implements the ActionAuthorizer interface.
+func (a *CollaboratorAuthorizer) AuthorizeAction(session *Session, action, app string) error {
+	if adminOnlyAction(action) {
+		ok, err := isPrincipal(a.Teams, session, a.Admins)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return unauthorizedAction(session, action, app)
+		}
+
+		return nil
+	}
+
+	if app == "" || readActions[action] {
+		return nil
+	}
+
+	collaborators, err := a.Collaborators.Collaborators(app)
+	if err != nil {
+		return err
+	}
+
+	// Otherwise, anyone could claim an app by adding themselves as its
+	// first collaborator.
+	if len(collaborators) == 0 && action != "access:add" {
+		return nil
+	}
+
+	for _, name := range collaborators {
+		if name == session.User.Name {
+			return nil
+		}
+	}
+
+	ok, err := isPrincipal(a.Teams, session, a.Admins)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return nil
+	}
+
+	if len(collaborators) == 0 {
+		return &UnauthorizedError{
+			Reason: fmt.Sprintf("only admins can add the first collaborator on %s.", app),
+		}
+	}
+
+	return &UnauthorizedError{
+		Reason: fmt.Sprintf("%s is not a collaborator on %s.", session.User.Name, app),
+	}
+}
+
+// adminOnlyAction returns true if the action can only be performed by admins,
+// because it doesn't relate to an app.
+func adminOnlyAction(action string) bool {
+	return isAdminAction(action) || strings.HasPrefix(action, "authorizations:")
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollaboratorAuthorizer(t *testing.T) {
+	a := &CollaboratorAuthorizer{
+		Collaborators: CollaboratorStoreFunc(func(app string) ([]string, error) {
+			switch app {
+			case "acme-inc":
+				return []string{"ejholmes", "mwildehahn"}, nil
+			default:
+				return nil, nil
+			}
+		}),
+		Admins: []string{"group:sre", "team:123"},
+		Teams: MembershipCheckerFunc(func(session *Session, team string) (bool, error) {
+			return session.User.Name == "bob" && team == "123", nil
+		}),
+	}
+
+	tests := []struct {
+		session *Session
+		action  string
+		app     string
+		err     string
+	}{
+		// Collaborators can change the app.
+		{newTestSession("ejholmes"), "deploy", "acme-inc", ""},
+		{newTestSession("mwildehahn"), "config:set", "acme-inc", ""},
+
+		// Other users can only read it.
+		{newTestSession("alice"), "deploy", "acme-inc", "alice is not a collaborator on acme-inc."},
+		{newTestSession("alice"), "access:add", "acme-inc", "alice is not a collaborator on acme-inc."},
+		{newTestSession("alice"), "releases:list", "acme-inc", ""},
+
+		// Admins can change any app.
+		{newTestSession("alice", "sre"), "deploy", "acme-inc", ""},
+		{newTestSession("bob"), "apps:destroy", "acme-inc", ""},
+
+		// Apps without collaborators aren't restricted, but only
+		// admins can add their first collaborator.
+		{newTestSession("alice"), "deploy", "api", ""},
+		{newTestSession("alice"), "apps:create", "api", ""},
+		{newTestSession("alice"), "access:add", "api", "only admins can add the first collaborator on api."},
+		{newTestSession("alice", "sre"), "access:add", "api", ""},
+
+		// Actions that don't relate to an app are restricted to
+		// admins.
+		{newTestSession("alice"), "admin:outbox", "", "alice is not allowed to perform admin:outbox."},
+		{newTestSession("alice"), "authorizations:create", "", "alice is not allowed to perform authorizations:create."},
+		{newTestSession("bob"), "authorizations:list", "", ""},
+		{newTestSession("alice"), "apps:list", "", ""},
+	}
+
+	for _, tt := range tests {
+		err := a.AuthorizeAction(tt.session, tt.action, tt.app)
+		if tt.err == "" {
+			assert.NoError(t, err, "%s %s %s", tt.session.User.Name, tt.action, tt.app)
+		} else {
+			assert.IsType(t, &UnauthorizedError{}, err)
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestCollaboratorAuthorizer_Error(t *testing.T) {
+	a := &CollaboratorAuthorizer{
+		Collaborators: CollaboratorStoreFunc(func(app string) ([]string, error) {
+			return nil, errors.New("boom")
+		}),
+	}
+
+	err := a.AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme-inc")
+	assert.EqualError(t, err, "boom")
+}
+
+func TestDatabaseCollaboratorStore(t *testing.T) {
+	s := DatabaseCollaboratorStore(&fakeCollaborators{
+		apps: []*empire.App{{ID: "1", Name: "acme-inc"}},
+		collaborators: []*empire.Collaborator{
+			{AppID: "1", UserName: "ejholmes"},
+		},
+	})
+
+	names, err := s.Collaborators("acme-inc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ejholmes"}, names)
+
+	names, err = s.Collaborators("api")
+	assert.NoError(t, err)
+	assert.Nil(t, names)
+}
+
+func TestMultiActionAuthorizer(t *testing.T) {
+	allow := ActionAuthorizerFunc(func(*Session, string, string) error {
+		return nil
+	})
+	deny := ActionAuthorizerFunc(func(*Session, string, string) error {
+		return &UnauthorizedError{Reason: "no"}
+	})
+
+	assert.NoError(t, MultiActionAuthorizer(allow, allow).AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme-inc"))
+	assert.EqualError(t, MultiActionAuthorizer(allow, deny).AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme-inc"), "no")
+}
+
+type fakeCollaborators struct {
+	apps          []*empire.App
+	collaborators []*empire.Collaborator
+}
+
+func (f *fakeCollaborators) Apps(q empire.AppsQuery) ([]*empire.App, error) {
+	var apps []*empire.App
+	for _, a := range f.apps {
+		if a.Name == *q.Name {
+			apps = append(apps, a)
+		}
+	}
+	return apps, nil
+}
+
+func (f *fakeCollaborators) Collaborators(q empire.CollaboratorsQuery) ([]*empire.Collaborator, error) {
+	var collaborators []*empire.Collaborator
+	for _, c := range f.collaborators {
+		if c.AppID == q.App.ID {
+			collaborators = append(collaborators, c)
+		}
+	}
+	return collaborators, nil
+}
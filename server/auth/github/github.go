@@ -87,7 +87,9 @@ func NewOrganizationAuthorizer(c *Client) *OrganizationAuthorizer {
 	return &OrganizationAuthorizer{client: c}
 }
 
-func (a *OrganizationAuthorizer) Authorize(user *empire.User) error {
+func (a *OrganizationAuthorizer) Authorize(session *auth.Session) error {
+	user := session.User
+
 	if a.Organization == "" {
 		// Probably a configuration error
 		panic("no organization set")
@@ -121,7 +123,9 @@ func NewTeamAuthorizer(c *Client) *TeamAuthorizer {
 	return &TeamAuthorizer{client: c}
 }
 
-func (a *TeamAuthorizer) Authorize(user *empire.User) error {
+func (a *TeamAuthorizer) Authorize(session *auth.Session) error {
+	user := session.User
+
 	if a.TeamID == "" {
 		panic("no team id set")
 	}
@@ -139,3 +143,26 @@ func (a *TeamAuthorizer) Authorize(user *empire.User) error {
 
 	return nil
 }
+
+// TeamMembershipChecker is an implementation of the auth.MembershipChecker
+// interface that checks membership of GitHub teams, by team id. It's used to
+// match "team:" principals in authorization policies.
+type TeamMembershipChecker struct {
+	client interface {
+		IsTeamMember(teamID, token string) (bool, error)
+	}
+}
+
+func NewTeamMembershipChecker(c *Client) *TeamMembershipChecker {
+	return &TeamMembershipChecker{client: c}
+}
+
+func (c *TeamMembershipChecker) IsMember(session *auth.Session, team string) (bool, error) {
+	// Users that didn't authenticate with GitHub can't be members of a
+	// GitHub team.
+	if session.User.GitHubToken == "" {
+		return false, nil
+	}
+
+	return c.client.IsTeamMember(team, session.User.GitHubToken)
+}
@@ -78,7 +78,7 @@ func TestOrganizationAuthorizer(t *testing.T) {
 
 	c.On("IsOrganizationMember", "remind101", "access_token").Return(true, nil)
 
-	err := a.Authorize(&empire.User{GitHubToken: "access_token"})
+	err := a.Authorize(auth.NewSession(&empire.User{GitHubToken: "access_token"}))
 	assert.NoError(t, err)
 }
 
@@ -91,10 +91,10 @@ func TestOrganizationAuthorizer_Unauthorized(t *testing.T) {
 
 	c.On("IsOrganizationMember", "remind101", "access_token").Return(false, nil)
 
-	err := a.Authorize(&empire.User{
+	err := a.Authorize(auth.NewSession(&empire.User{
 		Name:        "ejholmes",
-		GitHubToken: "access_token"},
-	)
+		GitHubToken: "access_token",
+	}))
 	assert.IsType(t, &auth.UnauthorizedError{}, err)
 	assert.EqualError(t, err, `ejholmes is not a member of the "remind101" organization.`)
 }
@@ -108,10 +108,10 @@ func TestTeamAuthorizer(t *testing.T) {
 
 	c.On("IsTeamMember", "123", "access_token").Return(true, nil)
 
-	err := a.Authorize(&empire.User{
+	err := a.Authorize(auth.NewSession(&empire.User{
 		Name:        "ejholmes",
 		GitHubToken: "access_token",
-	})
+	}))
 	assert.NoError(t, err)
 }
 
@@ -124,14 +124,44 @@ func TestTeamAuthorizer_Unauthorized(t *testing.T) {
 
 	c.On("IsTeamMember", "123", "access_token").Return(false, nil)
 
-	err := a.Authorize(&empire.User{
+	err := a.Authorize(auth.NewSession(&empire.User{
 		Name:        "ejholmes",
 		GitHubToken: "access_token",
-	})
+	}))
 	assert.IsType(t, &auth.UnauthorizedError{}, err)
 	assert.EqualError(t, err, `ejholmes is not a member of team 123.`)
 }
 
+func TestTeamMembershipChecker(t *testing.T) {
+	c := new(mockClient)
+	m := &TeamMembershipChecker{
+		client: c,
+	}
+
+	c.On("IsTeamMember", "123", "access_token").Return(true, nil)
+	c.On("IsTeamMember", "456", "access_token").Return(false, nil)
+
+	session := auth.NewSession(&empire.User{
+		Name:        "ejholmes",
+		GitHubToken: "access_token",
+	})
+
+	ok, err := m.IsMember(session, "123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = m.IsMember(session, "456")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Users without a GitHub token are never members.
+	ok, err = m.IsMember(auth.NewSession(&empire.User{Name: "ejholmes"}), "123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	c.AssertExpectations(t)
+}
+
 type mockClient struct {
 	mock.Mock
 }
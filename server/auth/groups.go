@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GroupAuthorizer is an implementation of the Authorizer interface that checks
+// that the user is a member of at least one of the given groups, like the
+// groups from a SAML assertion.
+type GroupAuthorizer struct {
+	Groups []string
+}
+
+func (a *GroupAuthorizer) Authorize(session *Session) error {
+	if len(a.Groups) == 0 {
+		// Probably a configuration error
+		panic("no groups set")
+	}
+
+	for _, group := range session.Groups {
+		for _, g := range a.Groups {
+			if group == g {
+				return nil
+			}
+		}
+	}
+
+	return &UnauthorizedError{
+		Reason: fmt.Sprintf("%s is not a member of any of the groups %s.", session.User.Name, strings.Join(a.Groups, ", ")),
+	}
+}
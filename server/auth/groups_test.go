@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupAuthorizer(t *testing.T) {
+	a := &GroupAuthorizer{Groups: []string{"oncall", "sre"}}
+
+	err := a.Authorize(newTestSession("ejholmes", "engineering", "sre"))
+	assert.NoError(t, err)
+
+	err = a.Authorize(newTestSession("mwildehahn", "engineering"))
+	assert.Equal(t, &UnauthorizedError{
+		Reason: "mwildehahn is not a member of any of the groups oncall, sre.",
+	}, err)
+
+	err = a.Authorize(newTestSession("bob"))
+	assert.IsType(t, &UnauthorizedError{}, err)
+}
+
+func TestAuth_Authenticate_GroupAuthorizer(t *testing.T) {
+	a := &Auth{
+		Strategies: Strategies{
+			{
+				Name: StrategyUsernamePassword,
+				Authenticator: AuthenticatorFunc(func(username, password, otp string) (*Session, error) {
+					return newTestSession(username, password), nil
+				}),
+			},
+		},
+		Authorizer: &GroupAuthorizer{Groups: []string{"oncall"}},
+	}
+
+	_, err := a.Authenticate(context.Background(), "ejholmes", "oncall", "")
+	assert.NoError(t, err)
+
+	_, err = a.Authenticate(context.Background(), "mwildehahn", "engineering", "")
+	assert.IsType(t, &UnauthorizedError{}, err)
+}
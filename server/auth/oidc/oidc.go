@@ -0,0 +1,77 @@
+// Package oidc provides an auth.Authenticator implementation backed by an
+// OpenID Connect provider, like Okta or Keycloak.
+package oidc
+
+import (
+	"fmt"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/server/auth"
+)
+
+// TokenUsername is the username that's provided along with an ID token as the
+// password, which is how `emp weblogin` exchanges the ID token it gets from the
+// web flow for an access token.
+const TokenUsername = "$token$"
+
+const (
+	// DefaultUsernameClaim is the default claim that's used as the name of
+	// the user.
+	DefaultUsernameClaim = "email"
+
+	// DefaultGroupsClaim is the default claim that contains the groups that
+	// the user belongs to.
+	DefaultGroupsClaim = "groups"
+)
+
+// Authenticator is an implementation of the auth.Authenticator interface that
+// authenticates users with an ID token, obtained through the authorization code
+// flow.
+type Authenticator struct {
+	// The claim that's used as the name of the user.
+	UsernameClaim string
+
+	// The claim that contains the groups that the user belongs to.
+	GroupsClaim string
+
+	verifier interface {
+		Verify(rawIDToken string) (*IDToken, error)
+	}
+}
+
+// NewAuthenticator returns a new Authenticator instance that uses the given
+// Verifier to verify ID tokens.
+func NewAuthenticator(v *Verifier) *Authenticator {
+	return &Authenticator{
+		UsernameClaim: DefaultUsernameClaim,
+		GroupsClaim:   DefaultGroupsClaim,
+		verifier:      v,
+	}
+}
+
+func (a *Authenticator) Authenticate(username, password, otp string) (*auth.Session, error) {
+	if username != TokenUsername || password == "" {
+		return nil, auth.ErrForbidden
+	}
+
+	token, err := a.verifier.Verify(password)
+	if err != nil {
+		if _, ok := err.(*ValidationError); ok {
+			return nil, auth.ErrForbidden
+		}
+		return nil, err
+	}
+
+	name := token.String(a.UsernameClaim)
+	if name == "" {
+		return nil, fmt.Errorf("oidc: ID token for %s is missing the %q claim", token.Subject, a.UsernameClaim)
+	}
+
+	// Access tokens don't outlive the ID token, so that a user that was
+	// removed from the provider, or from one of the allowed groups, loses
+	// access once the ID token expires.
+	session := auth.NewSession(&empire.User{Name: name})
+	session.ExpiresAt = &token.Expiry
+	session.Groups = token.Strings(a.GroupsClaim)
+	return session, nil
+}
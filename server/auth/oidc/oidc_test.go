@@ -0,0 +1,100 @@
+package oidc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remind101/empire/server/auth"
+	"github.com/remind101/empire/server/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	a := newTestAuthenticator(t, i)
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	session, err := a.Authenticate(TokenUsername, i.IDToken(map[string]interface{}{
+		"sub":    "00u1",
+		"email":  "ejholmes@example.com",
+		"groups": []string{"engineering"},
+		"exp":    exp.Unix(),
+	}), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ejholmes@example.com", session.User.Name)
+	assert.Equal(t, []string{"engineering"}, session.Groups)
+	assert.Equal(t, exp, *session.ExpiresAt)
+}
+
+func TestAuthenticator_UsernameClaim(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	a := newTestAuthenticator(t, i)
+	a.UsernameClaim = "preferred_username"
+
+	session, err := a.Authenticate(TokenUsername, i.IDToken(map[string]interface{}{
+		"sub":                "00u1",
+		"preferred_username": "ejholmes",
+	}), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ejholmes", session.User.Name)
+
+	_, err = a.Authenticate(TokenUsername, i.IDToken(map[string]interface{}{
+		"sub":   "00u1",
+		"email": "ejholmes@example.com",
+	}), "")
+	assert.EqualError(t, err, `oidc: ID token for 00u1 is missing the "preferred_username" claim`)
+}
+
+func TestAuthenticator_ErrForbidden(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	a := newTestAuthenticator(t, i)
+
+	tests := []struct {
+		username, password string
+	}{
+		{"ejholmes", "password"},
+		{TokenUsername, ""},
+		{TokenUsername, "gho_1234"},
+		{TokenUsername, i.IDToken(map[string]interface{}{"sub": "00u1", "aud": "other"})},
+	}
+
+	for _, tt := range tests {
+		_, err := a.Authenticate(tt.username, tt.password, "")
+		assert.Equal(t, auth.ErrForbidden, err)
+	}
+}
+
+func TestAuthenticator_Error(t *testing.T) {
+	errBoom := errors.New("boom")
+	a := &Authenticator{
+		UsernameClaim: DefaultUsernameClaim,
+		GroupsClaim:   DefaultGroupsClaim,
+		verifier: verifierFunc(func(rawIDToken string) (*IDToken, error) {
+			return nil, errBoom
+		}),
+	}
+
+	_, err := a.Authenticate(TokenUsername, "token", "")
+	assert.Equal(t, errBoom, err)
+}
+
+func newTestAuthenticator(t testing.TB, i *oidctest.Issuer) *Authenticator {
+	p, err := Discover(i.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthenticator(NewVerifier(p, i.ClientID))
+}
+
+type verifierFunc func(string) (*IDToken, error)
+
+func (fn verifierFunc) Verify(rawIDToken string) (*IDToken, error) {
+	return fn(rawIDToken)
+}
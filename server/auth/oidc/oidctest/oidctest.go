@@ -0,0 +1,167 @@
+// Package oidctest provides a fake OpenID Connect issuer for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/remind101/empire/internal/jwt"
+)
+
+// Code is the authorization code that the fake issuer hands out, and accepts
+// at its token endpoint.
+const Code = "code"
+
+// Issuer is a fake OpenID Connect issuer, which serves its configuration, its
+// keys, and the endpoints for the authorization code flow.
+type Issuer struct {
+	*httptest.Server
+
+	// The client that ID tokens are issued to.
+	ClientID     string
+	ClientSecret string
+
+	// The key that ID tokens are signed with.
+	Key   *rsa.PrivateKey
+	KeyID string
+
+	// Claims that are included in the ID tokens returned from the token
+	// endpoint.
+	Claims map[string]interface{}
+
+	// The nonce from the last authorization request, which is included in
+	// the next ID token returned from the token endpoint.
+	nonce string
+}
+
+// NewIssuer starts a new fake issuer. Callers should call Close when done.
+func NewIssuer() *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	i := &Issuer{
+		ClientID:     "empire",
+		ClientSecret: "secret",
+		Key:          key,
+		KeyID:        "1",
+		Claims: map[string]interface{}{
+			"sub":    "00u1",
+			"email":  "ejholmes@example.com",
+			"groups": []string{"engineering"},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.serveConfiguration)
+	mux.HandleFunc("/keys", i.serveKeys)
+	mux.HandleFunc("/authorize", i.serveAuthorize)
+	mux.HandleFunc("/token", i.serveToken)
+	i.Server = httptest.NewServer(mux)
+
+	return i
+}
+
+// IDToken returns a signed ID token with the given claims. The iss, aud, iat
+// and exp claims are set unless they're provided.
+func (i *Issuer) IDToken(claims map[string]interface{}) string {
+	c := jwt.MapClaims{
+		"iss": i.URL,
+		"aud": i.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	t.Header["kid"] = i.KeyID
+
+	signed, err := t.SignedString(i.Key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (i *Issuer) serveConfiguration(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/keys",
+	})
+}
+
+func (i *Issuer) serveKeys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kid": i.KeyID,
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(i.Key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.Key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+// serveAuthorize immediately redirects back to the client with a code, as if
+// the user logged in.
+func (i *Issuer) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	u, err := url.Parse(r.FormValue("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i.nonce = r.FormValue("nonce")
+
+	q := u.Query()
+	q.Set("code", Code)
+	q.Set("state", r.FormValue("state"))
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (i *Issuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+
+	if clientID != i.ClientID || clientSecret != i.ClientSecret || r.FormValue("code") != Code {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := make(map[string]interface{})
+	for k, v := range i.Claims {
+		claims[k] = v
+	}
+	if i.nonce != "" {
+		claims["nonce"] = i.nonce
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     i.IDToken(claims),
+	})
+}
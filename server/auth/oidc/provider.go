@@ -0,0 +1,365 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/remind101/empire/internal/jwt"
+	"golang.org/x/oauth2"
+)
+
+// DiscoveryPath is the path, relative to the issuer, of the OpenID Provider
+// Configuration document.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// The signing algorithms that ID tokens can be signed with. Symmetric
+// algorithms aren't allowed, since the client secret would be the key.
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// The minimum amount of time between fetching the issuer's keys. The keys are
+// fetched when a token is signed with a key that hasn't been seen before, which
+// happens when the issuer rotates its keys.
+const keysRefreshInterval = time.Minute
+
+// Provider holds the configuration of an OpenID Connect issuer.
+type Provider struct {
+	// The issuer identifier (e.g. https://acme.okta.com).
+	Issuer string `json:"issuer"`
+
+	// The URL of the authorization endpoint, for the authorization code
+	// flow.
+	AuthURL string `json:"authorization_endpoint"`
+
+	// The URL of the token endpoint, where codes are exchanged for tokens.
+	TokenURL string `json:"token_endpoint"`
+
+	// The URL of the JSON Web Key Set that ID tokens are signed with.
+	JWKSURL string `json:"jwks_uri"`
+}
+
+// Discover fetches the OpenID Provider Configuration of an issuer.
+func Discover(issuer string) (*Provider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	resp, err := http.Get(issuer + DiscoveryPath)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: unable to fetch provider configuration: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: unable to fetch provider configuration: %s", resp.Status)
+	}
+
+	var p Provider
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("oidc: unable to decode provider configuration: %v", err)
+	}
+
+	// The issuer in the configuration has to match the issuer that was
+	// used to find it, otherwise it could be impersonating another issuer.
+	if p.Issuer != issuer {
+		return nil, fmt.Errorf("oidc: issuer %q did not match the issuer in the provider configuration (%q)", issuer, p.Issuer)
+	}
+
+	return &p, nil
+}
+
+// Endpoint returns the oauth2.Endpoint for the authorization code flow.
+func (p *Provider) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  p.AuthURL,
+		TokenURL: p.TokenURL,
+	}
+}
+
+// ValidationError is returned by Verify when an ID token is invalid.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("oidc: invalid ID token: %s", e.Reason)
+}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	// The subject, which uniquely identifies the user within the issuer.
+	Subject string
+
+	// When the ID token expires.
+	Expiry time.Time
+
+	// All of the claims in the token.
+	Claims map[string]interface{}
+}
+
+// String returns the value of a claim that's a string, or an empty string if
+// the claim isn't present.
+func (t *IDToken) String(claim string) string {
+	v, _ := t.Claims[claim].(string)
+	return v
+}
+
+// Strings returns the value of a claim that's a list of strings, like the
+// groups that the user belongs to. A claim that's a single string is returned
+// as a list with one value.
+func (t *IDToken) Strings(claim string) []string {
+	switch v := t.Claims[claim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Verifier verifies ID tokens issued by a Provider to a client.
+type Verifier struct {
+	// The issuer that the ID tokens are issued by.
+	Provider *Provider
+
+	// The client id, which ID tokens must be issued to.
+	ClientID string
+
+	mu          sync.Mutex
+	keys        map[string]interface{}
+	lastFetched time.Time
+}
+
+// NewVerifier returns a new Verifier for ID tokens issued to the given client.
+func NewVerifier(p *Provider, clientID string) *Verifier {
+	return &Verifier{
+		Provider: p,
+		ClientID: clientID,
+	}
+}
+
+// Verify checks the signature of the ID token against the issuer's keys, and
+// validates its claims. A ValidationError is returned if the ID token is
+// invalid.
+func (v *Verifier) Verify(rawIDToken string) (*IDToken, error) {
+	// Errors from looking up the key are kept aside to be returned as is,
+	// since failing to fetch the keys doesn't mean the token is invalid.
+	var keysErr error
+
+	parser := &jwt.Parser{ValidMethods: signingMethods}
+	token, err := parser.Parse(rawIDToken, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.key(kid)
+		if err != nil {
+			keysErr = err
+		}
+		return key, err
+	})
+	if keysErr != nil {
+		return nil, keysErr
+	}
+	if err != nil {
+		return nil, &ValidationError{Reason: err.Error()}
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if !claims.VerifyIssuer(v.Provider.Issuer, true) {
+		return nil, &ValidationError{Reason: "issuer did not match"}
+	}
+
+	if !hasAudience(claims, v.ClientID) {
+		return nil, &ValidationError{Reason: "audience did not match"}
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, &ValidationError{Reason: "missing expiration"}
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, &ValidationError{Reason: "missing subject"}
+	}
+
+	return &IDToken{
+		Subject: sub,
+		Expiry:  time.Unix(int64(exp), 0).UTC(),
+		Claims:  claims,
+	}, nil
+}
+
+// key returns the public key with the given key id, fetching the issuer's keys
+// if the key hasn't been seen before.
+func (v *Verifier) key(kid string) (interface{}, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key := lookupKey(v.keys, kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(v.lastFetched) < keysRefreshInterval {
+		return nil, &ValidationError{Reason: fmt.Sprintf("unknown key %q", kid)}
+	}
+
+	keys, err := fetchKeys(v.Provider.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.lastFetched = time.Now()
+
+	if key := lookupKey(v.keys, kid); key != nil {
+		return key, nil
+	}
+
+	return nil, &ValidationError{Reason: fmt.Sprintf("unknown key %q", kid)}
+}
+
+// lookupKey returns the key with the given key id. Tokens without a key id can
+// only be verified when the issuer has a single key.
+func lookupKey(keys map[string]interface{}, kid string) interface{} {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+// jsonWebKey is a single key in a JSON Web Key Set.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys fetches a JSON Web Key Set, and returns the signing keys by their
+// key id. Keys of unsupported types are ignored.
+func fetchKeys(url string) (map[string]interface{}, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: unable to fetch keys: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: unable to fetch keys: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("oidc: unable to decode keys: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("oidc: invalid key %q: %v", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey returns the *rsa.PublicKey or *ecdsa.PublicKey for the key, or nil
+// if the key type isn't supported.
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBigInt decodes a base64url encoded big endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// hasAudience returns true if the aud claim, which can be a string or a list
+// of strings, includes the client id.
+func hasAudience(claims jwt.MapClaims, clientID string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package oidc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/remind101/empire/server/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	p, err := Discover(i.URL + "/")
+	assert.NoError(t, err)
+	assert.Equal(t, &Provider{
+		Issuer:   i.URL,
+		AuthURL:  i.URL + "/authorize",
+		TokenURL: i.URL + "/token",
+		JWKSURL:  i.URL + "/keys",
+	}, p)
+}
+
+func TestDiscover_IssuerMismatch(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	_, err := Discover(i.URL + "/acme")
+	assert.Error(t, err)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	p, err := Discover(i.URL)
+	assert.NoError(t, err)
+	v := NewVerifier(p, i.ClientID)
+
+	token, err := v.Verify(i.IDToken(map[string]interface{}{
+		"sub":    "00u1",
+		"email":  "ejholmes@example.com",
+		"groups": []string{"engineering", "sre"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "00u1", token.Subject)
+	assert.Equal(t, "ejholmes@example.com", token.String("email"))
+	assert.Equal(t, []string{"engineering", "sre"}, token.Strings("groups"))
+	assert.Equal(t, []string(nil), token.Strings("roles"))
+
+	// Multiple audiences.
+	_, err = v.Verify(i.IDToken(map[string]interface{}{
+		"sub": "00u1",
+		"aud": []string{"other", i.ClientID},
+	}))
+	assert.NoError(t, err)
+}
+
+func TestVerifier_Verify_Invalid(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	p, err := Discover(i.URL)
+	assert.NoError(t, err)
+	v := NewVerifier(p, i.ClientID)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		{"wrong issuer", map[string]interface{}{"sub": "00u1", "iss": "https://evil.example.com"}},
+		{"wrong audience", map[string]interface{}{"sub": "00u1", "aud": "other"}},
+		{"expired", map[string]interface{}{"sub": "00u1", "exp": time.Now().Add(-time.Minute).Unix()}},
+		{"missing subject", map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		_, err := v.Verify(i.IDToken(tt.claims))
+		assert.IsType(t, &ValidationError{}, err, tt.name)
+	}
+
+	// Signed with an unknown key.
+	i.KeyID = "2"
+	_, err = v.Verify(i.IDToken(map[string]interface{}{"sub": "00u1"}))
+	assert.IsType(t, &ValidationError{}, err)
+
+	// Not a JWT.
+	_, err = v.Verify("gho_1234")
+	assert.IsType(t, &ValidationError{}, err)
+}
+
+func TestVerifier_Verify_Tampered(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	p, err := Discover(i.URL)
+	assert.NoError(t, err)
+	v := NewVerifier(p, i.ClientID)
+
+	token := i.IDToken(map[string]interface{}{"sub": "00u1"})
+	other := i.IDToken(map[string]interface{}{"sub": "00u2"})
+
+	// The claims of one token, with the signature of another.
+	parts := strings.Split(token, ".")
+	otherParts := strings.Split(other, ".")
+	_, err = v.Verify(parts[0] + "." + otherParts[1] + "." + parts[2])
+	assert.IsType(t, &ValidationError{}, err)
+}
+
+func TestVerifier_Verify_KeysUnavailable(t *testing.T) {
+	i := oidctest.NewIssuer()
+
+	p, err := Discover(i.URL)
+	assert.NoError(t, err)
+	v := NewVerifier(p, i.ClientID)
+
+	token := i.IDToken(map[string]interface{}{"sub": "00u1"})
+	i.Close()
+
+	// Failing to fetch the keys isn't a validation error.
+	_, err = v.Verify(token)
+	assert.Error(t, err)
+	_, ok := err.(*ValidationError)
+	assert.False(t, ok)
+}
@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/remind101/empire"
+)
+
+// Effects that a Statement can have.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Kinds of principals that a Statement can apply to. Principals are written as
+// "kind:name" (e.g. "user:ejholmes", "team:1234", "group:sre"), or "*" for
+// everyone.
+const (
+	// PrincipalUser matches the name of the user.
+	PrincipalUser = "user"
+
+	// PrincipalTeam matches members of a GitHub team, by id.
+	PrincipalTeam = "team"
+
+	// PrincipalGroup matches the groups in the user's Session (e.g. SAML
+	// groups).
+	PrincipalGroup = "group"
+)
+
+// Policy is a set of statements that allow or deny users access to perform
+// actions on apps.
+type Policy struct {
+	Statements []*Statement `json:"statements"`
+}
+
+// Statement allows or denies principals access to perform actions on apps.
+type Statement struct {
+	// Either "allow" or "deny".
+	Effect string `json:"effect"`
+
+	// The principals that this statement applies to.
+	Principals []string `json:"principals"`
+
+	// Patterns for the actions that this statement applies to (e.g.
+	// "deploy", "config:*").
+	Actions []string `json:"actions"`
+
+	// Patterns for the names of the apps that this statement applies to
+	// (e.g. "payments-*"). If not provided, the statement applies to all
+	// apps, and to actions that don't relate to an app.
+	Apps []string `json:"apps"`
+}
+
+// Validate checks that the statement is well formed.
+func (s *Statement) Validate() error {
+	if s.Effect != EffectAllow && s.Effect != EffectDeny {
+		return fmt.Errorf("effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+
+	if len(s.Principals) == 0 {
+		return fmt.Errorf("at least one principal is required")
+	}
+
+	for _, p := range s.Principals {
+		if err := ValidatePrincipal(p); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+
+	for _, pattern := range append(s.Actions, s.Apps...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the statement applies to the action on the app.
+func (s *Statement) matches(action, app string) bool {
+	if !matchAny(s.Actions, action) {
+		return false
+	}
+
+	if len(s.Apps) == 0 {
+		return true
+	}
+
+	return app != "" && matchAny(s.Apps, app)
+}
+
+// ValidatePrincipal checks that the principal is "*", or "kind:name" with a
+// known kind.
+func ValidatePrincipal(principal string) error {
+	if principal == "*" {
+		return nil
+	}
+
+	kind, name := splitPrincipal(principal)
+	switch kind {
+	case PrincipalUser, PrincipalTeam, PrincipalGroup:
+	default:
+		return fmt.Errorf("unknown principal: %s", principal)
+	}
+
+	if name == "" {
+		return fmt.Errorf("principal %s is missing a name", principal)
+	}
+
+	return nil
+}
+
+// ParsePolicy parses a JSON policy document, and validates it.
+func ParsePolicy(raw []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("error parsing policy: %v", err)
+	}
+
+	for i, s := range p.Statements {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("statement %d: %v", i, err)
+		}
+	}
+
+	return &p, nil
+}
+
+// PolicyStore provides the policies that a PolicyAuthorizer evaluates.
+type PolicyStore interface {
+	Policies() ([]*Policy, error)
+}
+
+// PolicyStoreFunc is a function signature that implements the PolicyStore
+// interface.
+type PolicyStoreFunc func() ([]*Policy, error)
+
+// Policies calls the PolicyStoreFunc.
+func (fn PolicyStoreFunc) Policies() ([]*Policy, error) {
+	return fn()
+}
+
+// StaticPolicyStore is a PolicyStore that always returns the same policies.
+type StaticPolicyStore []*Policy
+
+// Policies implements the PolicyStore interface.
+func (s StaticPolicyStore) Policies() ([]*Policy, error) {
+	return s, nil
+}
+
+// Governs returns true if a statement in the policies applies to the action
+// when it doesn't relate to an app.
+func (s StaticPolicyStore) Governs(action string) bool {
+	for _, p := range s {
+		for _, st := range p.Statements {
+			if st.matches(action, "") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PolicyFile reads and parses the policy in the file at path, and returns a
+// StaticPolicyStore with it.
+func PolicyFile(path string) (StaticPolicyStore, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := ParsePolicy(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return StaticPolicyStore{p}, nil
+}
+
+// MultiPolicyStore returns a PolicyStore that returns the policies from each
+// PolicyStore, in order.
+func MultiPolicyStore(stores ...PolicyStore) PolicyStore {
+	return PolicyStoreFunc(func() ([]*Policy, error) {
+		var policies []*Policy
+		for _, s := range stores {
+			ps, err := s.Policies()
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, ps...)
+		}
+		return policies, nil
+	})
+}
+
+// DatabasePolicyStore returns a PolicyStore that returns the policies stored
+// in Empire's database, ordered by name.
+func DatabasePolicyStore(e interface {
+	Policies(empire.PoliciesQuery) ([]*empire.Policy, error)
+}) PolicyStore {
+	return PolicyStoreFunc(func() ([]*Policy, error) {
+		stored, err := e.Policies(empire.PoliciesQuery{})
+		if err != nil {
+			return nil, fmt.Errorf("error finding policies: %v", err)
+		}
+
+		var policies []*Policy
+		for _, sp := range stored {
+			p, err := ParsePolicy([]byte(sp.Document))
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %v", sp.Name, err)
+			}
+			policies = append(policies, p)
+		}
+
+		return policies, nil
+	})
+}
+
+// MembershipChecker checks whether the user in a Session is a member of a team.
+type MembershipChecker interface {
+	IsMember(session *Session, team string) (bool, error)
+}
+
+// MembershipCheckerFunc is a function signature that implements the
+// MembershipChecker interface.
+type MembershipCheckerFunc func(*Session, string) (bool, error)
+
+// IsMember calls the MembershipCheckerFunc.
+func (fn MembershipCheckerFunc) IsMember(session *Session, team string) (bool, error) {
+	return fn(session, team)
+}
+
+// PolicyAuthorizer is an ActionAuthorizer that evaluates policies.
+//
+// The statements from all of the policies are evaluated in order, and the
+// first statement that applies to the user, action and app determines whether
+// the action is allowed or denied. If no statement applies, the action is
+// denied.
+type PolicyAuthorizer struct {
+	// The policies to evaluate.
+	Policies PolicyStore
+
+	// Used to check membership of "team:" principals. If not provided,
+	// team principals never match.
+	Teams MembershipChecker
+}
+
+// AuthorizeAction implements the ActionAuthorizer interface.
+func (a *PolicyAuthorizer) AuthorizeAction(session *Session, action, app string) error {
+	policies, err := a.Policies.Policies()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range policies {
+		for _, s := range p.Statements {
+			if !s.matches(action, app) {
+				continue
+			}
+
+			ok, err := isPrincipal(a.Teams, session, s.Principals)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				continue
+			}
+
+			if s.Effect == EffectAllow {
+				return nil
+			}
+
+			return unauthorizedAction(session, action, app)
+		}
+	}
+
+	return unauthorizedAction(session, action, app)
+}
+
+// isPrincipal returns true if the session matches any of the principals. teams
+// is used to check membership of "team:" principals, and can be nil.
+func isPrincipal(teams MembershipChecker, session *Session, principals []string) (bool, error) {
+	for _, p := range principals {
+		if p == "*" {
+			return true, nil
+		}
+
+		kind, name := splitPrincipal(p)
+		switch kind {
+		case PrincipalUser:
+			if session.User.Name == name {
+				return true, nil
+			}
+		case PrincipalGroup:
+			for _, g := range session.Groups {
+				if g == name {
+					return true, nil
+				}
+			}
+		case PrincipalTeam:
+			if teams == nil {
+				continue
+			}
+
+			ok, err := teams.IsMember(session, name)
+			if err != nil {
+				return false, fmt.Errorf("error checking membership of %s: %v", p, err)
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func unauthorizedAction(session *Session, action, app string) error {
+	reason := fmt.Sprintf("%s is not allowed to perform %s", session.User.Name, action)
+	if app != "" {
+		reason = fmt.Sprintf("%s on %s", reason, app)
+	}
+
+	return &UnauthorizedError{Reason: reason + "."}
+}
+
+// splitPrincipal splits a principal into its kind and name.
+func splitPrincipal(principal string) (kind, name string) {
+	parts := strings.SplitN(principal, ":", 2)
+	if len(parts) != 2 {
+		return principal, ""
+	}
+	return parts[0], parts[1]
+}
+
+// matchAny returns true if the value matches any of the patterns.
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
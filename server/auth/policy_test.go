@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{`{"statements": [{"effect": "allow", "principals": ["*"], "actions": ["*"]}]}`, ""},
+		{`{"statements": [{"effect": "deny", "principals": ["user:ejholmes", "team:123", "group:sre"], "actions": ["config:*"], "apps": ["acme-*"]}]}`, ""},
+		{`{`, "error parsing policy: unexpected end of JSON input"},
+		{`{"statements": [{"effect": "maybe", "principals": ["*"], "actions": ["*"]}]}`, `statement 0: effect must be "allow" or "deny"`},
+		{`{"statements": [{"effect": "allow", "actions": ["*"]}]}`, "statement 0: at least one principal is required"},
+		{`{"statements": [{"effect": "allow", "principals": ["role:admin"], "actions": ["*"]}]}`, "statement 0: unknown principal: role:admin"},
+		{`{"statements": [{"effect": "allow", "principals": ["user:"], "actions": ["*"]}]}`, "statement 0: principal user: is missing a name"},
+		{`{"statements": [{"effect": "allow", "principals": ["*"]}]}`, "statement 0: at least one action is required"},
+		{`{"statements": [{"effect": "allow", "principals": ["*"], "actions": ["["]}]}`, `statement 0: invalid pattern "[": syntax error in pattern`},
+	}
+
+	for _, tt := range tests {
+		_, err := ParsePolicy([]byte(tt.in))
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestPolicyAuthorizer(t *testing.T) {
+	policy := mustParsePolicy(t, `{
+  "statements": [
+    {"effect": "deny", "principals": ["user:intern"], "actions": ["*"], "apps": ["payments*"]},
+    {"effect": "allow", "principals": ["team:123"], "actions": ["deploy", "config:*"], "apps": ["payments*"]},
+    {"effect": "allow", "principals": ["group:sre"], "actions": ["*"]},
+    {"effect": "allow", "principals": ["*"], "actions": ["*:list", "*:info"]}
+  ]
+}`)
+
+	teams := MembershipCheckerFunc(func(session *Session, team string) (bool, error) {
+		return session.User.Name == "intern" || session.User.Name == "ejholmes", nil
+	})
+
+	a := &PolicyAuthorizer{
+		Policies: StaticPolicyStore{policy},
+		Teams:    teams,
+	}
+
+	tests := []struct {
+		session *Session
+		action  string
+		app     string
+		err     string
+	}{
+		// The first statement that matches wins.
+		{newTestSession("intern"), "deploy", "payments", "intern is not allowed to perform deploy on payments."},
+		{newTestSession("intern"), "apps:info", "payments-api", "intern is not allowed to perform apps:info on payments-api."},
+		{newTestSession("intern"), "apps:info", "acme", ""},
+
+		// Team principals.
+		{newTestSession("ejholmes"), "deploy", "payments", ""},
+		{newTestSession("ejholmes"), "config:set", "payments-api", ""},
+		{newTestSession("ejholmes"), "apps:destroy", "payments", "ejholmes is not allowed to perform apps:destroy on payments."},
+		{newTestSession("ejholmes"), "deploy", "acme", "ejholmes is not allowed to perform deploy on acme."},
+
+		// Group principals.
+		{newTestSession("bob", "sre"), "apps:destroy", "acme", ""},
+		{newTestSession("bob", "sre"), "admin:policies", "", ""},
+		{newTestSession("bob"), "admin:policies", "", "bob is not allowed to perform admin:policies."},
+
+		// Anything that isn't allowed is denied.
+		{newTestSession("bob"), "apps:list", "", ""},
+		{newTestSession("bob"), "scale", "acme", "bob is not allowed to perform scale on acme."},
+	}
+
+	for _, tt := range tests {
+		err := a.AuthorizeAction(tt.session, tt.action, tt.app)
+		if tt.err == "" {
+			assert.NoError(t, err, "%s %s %s", tt.session.User.Name, tt.action, tt.app)
+		} else {
+			assert.IsType(t, &UnauthorizedError{}, err)
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestPolicyAuthorizer_NoTeams(t *testing.T) {
+	a := &PolicyAuthorizer{
+		Policies: StaticPolicyStore{
+			mustParsePolicy(t, `{"statements": [{"effect": "allow", "principals": ["team:123"], "actions": ["*"]}]}`),
+		},
+	}
+
+	err := a.AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme")
+	assert.EqualError(t, err, "ejholmes is not allowed to perform deploy on acme.")
+}
+
+func TestPolicyAuthorizer_MembershipError(t *testing.T) {
+	a := &PolicyAuthorizer{
+		Policies: StaticPolicyStore{
+			mustParsePolicy(t, `{"statements": [{"effect": "allow", "principals": ["team:123"], "actions": ["*"]}]}`),
+		},
+		Teams: MembershipCheckerFunc(func(session *Session, team string) (bool, error) {
+			return false, errors.New("boom")
+		}),
+	}
+
+	err := a.AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme")
+	assert.EqualError(t, err, "error checking membership of team:123: boom")
+}
+
+func TestMultiPolicyStore(t *testing.T) {
+	p1 := mustParsePolicy(t, `{"statements": [{"effect": "deny", "principals": ["*"], "actions": ["apps:destroy"]}]}`)
+	p2 := mustParsePolicy(t, `{"statements": [{"effect": "allow", "principals": ["*"], "actions": ["*"]}]}`)
+
+	a := &PolicyAuthorizer{
+		Policies: MultiPolicyStore(StaticPolicyStore{p1}, StaticPolicyStore{p2}),
+	}
+
+	assert.NoError(t, a.AuthorizeAction(newTestSession("ejholmes"), "deploy", "acme"))
+	assert.Error(t, a.AuthorizeAction(newTestSession("ejholmes"), "apps:destroy", "acme"))
+}
+
+func TestStaticPolicyStore_Governs(t *testing.T) {
+	s := StaticPolicyStore{
+		mustParsePolicy(t, `{"statements": [{"effect": "allow", "principals": ["team:123"], "actions": ["admin:policies"]}]}`),
+	}
+	assert.True(t, s.Governs("admin:policies"))
+	assert.False(t, s.Governs("admin:outbox"))
+
+	// Statements that only apply to apps don't govern admin actions.
+	s = StaticPolicyStore{
+		mustParsePolicy(t, `{"statements": [{"effect": "allow", "principals": ["*"], "actions": ["*"], "apps": ["acme-*"]}]}`),
+	}
+	assert.False(t, s.Governs("admin:policies"))
+}
+
+func TestDatabasePolicyStore(t *testing.T) {
+	s := DatabasePolicyStore(fakePolicies{
+		{Name: "a", Document: `{"statements": [{"effect": "allow", "principals": ["*"], "actions": ["*"]}]}`},
+	})
+
+	policies, err := s.Policies()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(policies))
+
+	s = DatabasePolicyStore(fakePolicies{
+		{Name: "a", Document: `{"statements": [{"effect": "allow"}]}`},
+	})
+
+	_, err = s.Policies()
+	assert.EqualError(t, err, "policy a: statement 0: at least one principal is required")
+}
+
+type fakePolicies []*empire.Policy
+
+func (p fakePolicies) Policies(empire.PoliciesQuery) ([]*empire.Policy, error) {
+	return p, nil
+}
+
+func newTestSession(name string, groups ...string) *Session {
+	s := NewSession(&empire.User{Name: name})
+	s.Groups = groups
+	return s
+}
+
+func mustParsePolicy(t testing.TB, raw string) *Policy {
+	p, err := ParsePolicy([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -1,14 +1,17 @@
 package saml
 
 import (
+	"strings"
+
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/saml"
 	"github.com/remind101/empire/server/auth"
 )
 
 // SessionFromAssertion returns a new auth.Session generated from the SAML
-// assertion.
-func SessionFromAssertion(assertion *saml.Assertion) *auth.Session {
+// assertion. If groupsAttribute is provided, the values of the attribute with
+// that name (or friendly name) are used as the groups that the user belongs to.
+func SessionFromAssertion(assertion *saml.Assertion, groupsAttribute string) *auth.Session {
 	login := assertion.Subject.NameID.Value
 	user := &empire.User{
 		Name: login,
@@ -16,5 +19,30 @@ func SessionFromAssertion(assertion *saml.Assertion) *auth.Session {
 
 	session := auth.NewSession(user)
 	session.ExpiresAt = &assertion.AuthnStatement.SessionNotOnOrAfter
+	if groupsAttribute != "" {
+		session.Groups = groupsFromAssertion(assertion, groupsAttribute)
+	}
 	return session
 }
+
+// groupsFromAssertion returns the values of the named attribute. Each value is
+// a group.
+func groupsFromAssertion(assertion *saml.Assertion, name string) []string {
+	if assertion.AttributeStatement == nil {
+		return nil
+	}
+
+	var groups []string
+	for _, attr := range assertion.AttributeStatement.Attributes {
+		if attr.Name != name && attr.FriendlyName != name {
+			continue
+		}
+
+		for _, v := range attr.Values {
+			if group := strings.TrimSpace(v.Value); group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
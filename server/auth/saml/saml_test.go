@@ -0,0 +1,62 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/remind101/empire/internal/saml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionFromAssertion(t *testing.T) {
+	assertion := newTestAssertion(&saml.AttributeStatement{
+		Attributes: []saml.Attribute{
+			{
+				FriendlyName: "mail",
+				Name:         "urn:oid:0.9.2342.19200300.100.1.3",
+				Values:       []saml.AttributeValue{{Value: "ejholmes@example.com"}},
+			},
+			{
+				FriendlyName: "eduPersonAffiliation",
+				Name:         "urn:oid:1.3.6.1.4.1.5923.1.1.1.1",
+				Values: []saml.AttributeValue{
+					{Value: "engineering"},
+					{Value: " oncall "},
+					{Value: ""},
+				},
+			},
+		},
+	})
+
+	session := SessionFromAssertion(assertion, "eduPersonAffiliation")
+	assert.Equal(t, "ejholmes", session.User.Name)
+	assert.Equal(t, assertion.AuthnStatement.SessionNotOnOrAfter, *session.ExpiresAt)
+	assert.Equal(t, []string{"engineering", "oncall"}, session.Groups)
+
+	session = SessionFromAssertion(assertion, "urn:oid:1.3.6.1.4.1.5923.1.1.1.1")
+	assert.Equal(t, []string{"engineering", "oncall"}, session.Groups)
+
+	session = SessionFromAssertion(assertion, "memberOf")
+	assert.Nil(t, session.Groups)
+
+	session = SessionFromAssertion(assertion, "")
+	assert.Nil(t, session.Groups)
+}
+
+func TestSessionFromAssertion_NoAttributes(t *testing.T) {
+	session := SessionFromAssertion(newTestAssertion(nil), "groups")
+	assert.Equal(t, "ejholmes", session.User.Name)
+	assert.Nil(t, session.Groups)
+}
+
+func newTestAssertion(attributes *saml.AttributeStatement) *saml.Assertion {
+	return &saml.Assertion{
+		Subject: &saml.Subject{
+			NameID: &saml.NameID{Value: "ejholmes"},
+		},
+		AuthnStatement: &saml.AuthnStatement{
+			SessionNotOnOrAfter: time.Date(2016, 10, 26, 0, 0, 0, 0, time.UTC),
+		},
+		AttributeStatement: attributes,
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/remind101/empire"
+)
+
+// deployActions are the actions that the "deploy" scope allows.
+var deployActions = map[string]bool{
+	"deploy":           true,
+	"deploy:cancel":    true,
+	"deployments:info": true,
+}
+
+// authorizeScopes checks that the scopes of a service account's session allow
+// the action on the app.
+func authorizeScopes(session *Session, action, app string) error {
+	var (
+		allowed bool
+		apps    []string
+	)
+
+	for _, scope := range session.Scopes {
+		switch {
+		case scope == empire.ScopeGlobal:
+			allowed = true
+		case scope == empire.ScopeRead:
+			allowed = allowed || readActions[action]
+		case scope == empire.ScopeDeploy:
+			allowed = allowed || deployActions[action]
+		case strings.HasPrefix(scope, empire.ScopeAppPrefix):
+			apps = append(apps, strings.TrimPrefix(scope, empire.ScopeAppPrefix))
+		}
+	}
+
+	if !allowed {
+		return &UnauthorizedError{
+			Reason: fmt.Sprintf("%s does not have a scope that allows %s.", session.User.Name, action),
+		}
+	}
+
+	// When limited to specific apps, actions that don't relate to an app
+	// aren't allowed.
+	if len(apps) > 0 && (app == "" || !matchAny(apps, app)) {
+		return &UnauthorizedError{
+			Reason: fmt.Sprintf("%s is limited to the apps %s.", session.User.Name, strings.Join(apps, ", ")),
+		}
+	}
+
+	return nil
+}
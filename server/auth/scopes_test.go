@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth_AuthorizeAction_Scopes(t *testing.T) {
+	// Admin actions are denied without an AdminAuthorizer, so allow them
+	// to check that scopes are still enforced.
+	a := &Auth{
+		AdminAuthorizer: ActionAuthorizerFunc(func(*Session, string, string) error {
+			return nil
+		}),
+	}
+
+	tests := []struct {
+		scopes []string
+		action string
+		app    string
+		err    string
+	}{
+		{[]string{"global"}, "apps:destroy", "acme-inc", ""},
+		{[]string{"global"}, "admin:policies", "", ""},
+
+		{[]string{"read"}, "releases:list", "acme-inc", ""},
+		{[]string{"read"}, "deploy", "acme-inc", "service:ci does not have a scope that allows deploy."},
+
+		{[]string{"deploy"}, "deploy", "acme-inc", ""},
+		{[]string{"deploy"}, "config:set", "acme-inc", "service:ci does not have a scope that allows config:set."},
+		{[]string{"read", "deploy"}, "apps:info", "acme-inc", ""},
+
+		{[]string{"deploy", "app:acme-*"}, "deploy", "acme-inc", ""},
+		{[]string{"deploy", "app:acme-*"}, "deploy", "api", "service:ci is limited to the apps acme-*."},
+		{[]string{"read", "app:acme-*"}, "apps:list", "", "service:ci is limited to the apps acme-*."},
+
+		{[]string{"app:acme-*"}, "deploy", "acme-inc", "service:ci does not have a scope that allows deploy."},
+		{nil, "apps:list", "", "service:ci does not have a scope that allows apps:list."},
+	}
+
+	for _, tt := range tests {
+		session := NewSession(&empire.User{Name: "service:ci"})
+		session.ServiceAccount = true
+		session.Scopes = tt.scopes
+
+		err := a.AuthorizeAction(WithSession(context.Background(), session), tt.action, tt.app)
+		if tt.err == "" {
+			assert.NoError(t, err, "%v %s %s", tt.scopes, tt.action, tt.app)
+		} else {
+			assert.IsType(t, &UnauthorizedError{}, err)
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestAuth_AuthorizeAction_NotServiceAccount(t *testing.T) {
+	a := &Auth{}
+
+	// Scopes only apply to service accounts.
+	err := a.AuthorizeAction(WithSession(context.Background(), NewSession(&empire.User{Name: "ejholmes"})), "deploy", "acme-inc")
+	assert.NoError(t, err)
+}
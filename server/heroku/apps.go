@@ -13,12 +13,13 @@ type App heroku.App
 
 func newApp(a *empire.App) *App {
 	return &App{
-		Id:          a.ID,
-		Name:        a.Name,
-		Maintenance: a.Maintenance,
-		CreatedAt:   *a.CreatedAt,
-		Cert:        a.Certs["web"], // For backwards compatibility.
-		Certs:       a.Certs,
+		Id:           a.ID,
+		Name:         a.Name,
+		Maintenance:  a.Maintenance,
+		AutoRollback: a.AutoRollback,
+		CreatedAt:    *a.CreatedAt,
+		Cert:         a.Certs["web"], // For backwards compatibility.
+		Certs:        a.Certs,
 	}
 }
 
@@ -84,12 +85,25 @@ func (h *Server) DeployApp(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	opts, err := newDeployOpts(w, r)
-	opts.App = a
+	opts, detach, err := newDeployOpts(w, r)
 	if err != nil {
 		return err
 	}
-	h.Deploy(ctx, *opts)
+	opts.App = a
+
+	if detach {
+		return h.deployDetached(w, r, *opts)
+	}
+
+	_, err = h.Deploy(ctx, *opts)
+
+	// Validation errors are returned before anything is written to the
+	// stream. All other errors are written to the stream.
+	switch err := err.(type) {
+	case *empire.ValidationError:
+		return err
+	}
+
 	return nil
 }
 
@@ -106,6 +120,10 @@ func (h *Server) PostApps(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := h.AuthorizeAction(ctx, "apps:create", form.Name); err != nil {
+		return err
+	}
+
 	m, err := findMessage(r)
 	if err != nil {
 		return err
@@ -164,6 +182,17 @@ func (h *Server) PatchApp(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	if form.AutoRollback != nil {
+		if err := h.SetAutoRollback(ctx, empire.SetAutoRollbackOpts{
+			User:         auth.UserFromContext(ctx),
+			App:          a,
+			AutoRollback: *form.AutoRollback,
+			Message:      m,
+		}); err != nil {
+			return err
+		}
+	}
+
 	return Encode(w, newApp(a))
 }
 
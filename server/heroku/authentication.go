@@ -1,12 +1,20 @@
 package heroku
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/realip"
 	"github.com/remind101/empire/server/auth"
@@ -26,6 +34,10 @@ type AccessToken struct {
 
 	// The user that this AccessToken belongs to.
 	User *empire.User
+
+	// The groups that the user belonged to when the AccessToken was
+	// created.
+	Groups []string
 }
 
 // Returns the amount of time before the token expires.
@@ -51,7 +63,9 @@ func (s *Server) Authenticate(r *http.Request, strategies ...string) (context.Co
 
 	// Add an auth strategy for authenticating with an access token.
 	auther := s.Auth.PrependAuthenticator(auth.StrategyAccessToken, &accessTokenAuthenticator{
-		findAccessToken: s.AccessTokensFind,
+		findAccessToken:    s.AccessTokensFind,
+		findServiceAccount: s.findServiceAccount,
+		secret:             s.Secret,
 	})
 
 	unauthorized := s.Unauthorized
@@ -100,6 +114,30 @@ func (s *Server) Authenticate(r *http.Request, strategies ...string) (context.Co
 	return ctx, nil
 }
 
+// AuthorizeAction checks that the authenticated user can perform the action on
+// the app. app should be empty for actions that don't relate to an app.
+func (s *Server) AuthorizeAction(ctx context.Context, action, app string) error {
+	err := s.Auth.AuthorizeAction(ctx, action, app)
+	if err != nil {
+		user := auth.UserFromContext(ctx)
+
+		logger.Info(ctx,
+			"authorization.failure",
+			"user", user.Name,
+			"action", action,
+			"app", app,
+			"err", err,
+		)
+
+		tags := []string{
+			fmt.Sprintf("user:%s", user.Name),
+			fmt.Sprintf("action:%s", action),
+		}
+		stats.Inc(ctx, "authorization.failure", 1, 1.0, tags)
+	}
+	return err
+}
+
 // Called when a request fails authentication/authorization checks, which logs
 // and increments metrics. Can be useful for alerting on.
 func instrumentAuthenticationError(r *http.Request, username string, err error) {
@@ -133,14 +171,22 @@ func instrumentAuthenticationError(r *http.Request, username string, err error)
 }
 
 // accessTokenAuthenticator is an Authenticator that uses empire JWT access tokens to
-// authenticate.
+// authenticate, or the tokens of service accounts.
 type accessTokenAuthenticator struct {
-	findAccessToken func(string) (*AccessToken, error)
+	findAccessToken    func(string) (*AccessToken, error)
+	findServiceAccount func(string) (*empire.ServiceAccount, error)
+
+	// Used to decrypt the creator of service accounts.
+	secret []byte
 }
 
 // Authenticate authenticates the access token, which should be provided as the
 // password parameter. Username and otp are ignored.
 func (a *accessTokenAuthenticator) Authenticate(_ string, token string, _ string) (*auth.Session, error) {
+	if strings.HasPrefix(token, empire.ServiceAccountTokenPrefix) {
+		return a.authenticateServiceAccount(token)
+	}
+
 	at, err := a.findAccessToken(token)
 	if err != nil {
 		return nil, err
@@ -153,11 +199,58 @@ func (a *accessTokenAuthenticator) Authenticate(_ string, token string, _ string
 	session := &auth.Session{
 		User:      at.User,
 		ExpiresAt: at.ExpiresAt,
+		Groups:    at.Groups,
 	}
 
 	return session, nil
 }
 
+// authenticateServiceAccount authenticates a service account token. Tokens of
+// service accounts that have been removed are rejected.
+func (a *accessTokenAuthenticator) authenticateServiceAccount(token string) (*auth.Session, error) {
+	s, err := a.findServiceAccount(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		return nil, auth.ErrForbidden
+	}
+
+	session := &auth.Session{
+		User:           s.User(),
+		ExpiresAt:      s.ExpiresAt,
+		Groups:         s.Groups,
+		ServiceAccount: true,
+		Scopes:         s.Scopes,
+	}
+
+	// If the creator can't be decrypted (e.g. because the secret changed),
+	// the creator is unknown, and authorizers that check the creator
+	// reject the service account.
+	if s.CreatorToken != "" {
+		if creator, err := decryptUser(a.secret, s.CreatorToken); err == nil {
+			session.Creator = creator
+		}
+	}
+
+	return session, nil
+}
+
+// findServiceAccount returns the service account with the given token, or nil
+// if the token doesn't exist or has expired.
+func (s *Server) findServiceAccount(token string) (*empire.ServiceAccount, error) {
+	sa, err := s.AuthenticateServiceAccount(token)
+	switch err {
+	case nil:
+		return sa, nil
+	case gorm.RecordNotFound, empire.ErrServiceAccountExpired:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
 // AccessTokensCreate "creates" the token by jwt signing it and setting the
 // Token value.
 func (s *Server) AccessTokensCreate(token *AccessToken) (*AccessToken, error) {
@@ -223,6 +316,9 @@ func accessTokenToJwt(token *AccessToken) *jwt.Token {
 		Name:        token.User.Name,
 		GitHubToken: token.User.GitHubToken,
 	}
+	if len(token.Groups) > 0 {
+		t.Claims["Groups"] = token.Groups
+	}
 
 	return t
 }
@@ -256,6 +352,15 @@ func jwtToAccessToken(t *jwt.Token) (*AccessToken, error) {
 		return &token, errors.New("missing user")
 	}
 
+	// Tokens created before groups were added don't have any.
+	if groups, ok := t.Claims["Groups"].([]interface{}); ok {
+		for _, g := range groups {
+			if g, ok := g.(string); ok {
+				token.Groups = append(token.Groups, g)
+			}
+		}
+	}
+
 	return &token, nil
 }
 
@@ -264,3 +369,74 @@ func jwtParse(secret []byte, token string) (*jwt.Token, error) {
 		return secret, nil
 	})
 }
+
+// encryptUser encrypts the user, including their GitHub token, with the secret,
+// so that it can be stored with a service account that they create.
+func encryptUser(secret []byte, user *empire.User) (string, error) {
+	raw, err := json.Marshal(encryptedUser{
+		Name:        user.Name,
+		GitHubToken: user.GitHubToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, raw, nil)), nil
+}
+
+// decryptUser decrypts a user that was encrypted with encryptUser.
+func decryptUser(secret []byte, encrypted string) (*empire.User, error) {
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < gcm.NonceSize() {
+		return nil, errors.New("encrypted user is too short")
+	}
+
+	nonce, ciphertext := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u encryptedUser
+	if err := json.Unmarshal(plaintext, &u); err != nil {
+		return nil, err
+	}
+
+	return &empire.User{Name: u.Name, GitHubToken: u.GitHubToken}, nil
+}
+
+// encryptedUser is the representation of a user that's encrypted by
+// encryptUser. empire.User doesn't include the GitHub token when it's encoded.
+type encryptedUser struct {
+	Name        string
+	GitHubToken string
+}
+
+// newGCM returns an AES-GCM cipher with a key derived from the secret.
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,92 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
+)
+
+type Canary heroku.Canary
+
+func newCanary(c *empire.Canary) *Canary {
+	return &Canary{
+		Version:       c.Version,
+		StableVersion: c.StableVersion,
+		Weight:        c.Weight,
+		PromoteAt:     c.PromoteAt,
+		CreatedAt:     *c.CreatedAt,
+	}
+}
+
+// GetCanary returns the canary that's in progress for the app.
+func (h *Server) GetCanary(w http.ResponseWriter, r *http.Request) error {
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	c, err := h.CanariesFind(empire.CanariesQuery{App: a})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newCanary(c))
+}
+
+// PostCanaryPromote promotes the canary that's in progress for the app.
+func (h *Server) PostCanaryPromote(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	m, err := findMessage(r)
+	if err != nil {
+		return err
+	}
+
+	release, err := h.PromoteCanary(ctx, empire.PromoteCanaryOpts{
+		User:    auth.UserFromContext(ctx),
+		App:     a,
+		Message: m,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newRelease(release))
+}
+
+// PostCanaryAbort aborts the canary that's in progress for the app, and rolls
+// back to the stable release.
+func (h *Server) PostCanaryAbort(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	m, err := findMessage(r)
+	if err != nil {
+		return err
+	}
+
+	release, err := h.AbortCanary(ctx, empire.AbortCanaryOpts{
+		User:    auth.UserFromContext(ctx),
+		App:     a,
+		Message: m,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newRelease(release))
+}
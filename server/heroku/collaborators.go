@@ -0,0 +1,122 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
+)
+
+type Collaborator heroku.Collaborator
+
+func newCollaborator(c *empire.Collaborator) *Collaborator {
+	collaborator := &Collaborator{
+		Id:        c.ID,
+		CreatedAt: *c.CreatedAt,
+		UpdatedAt: *c.UpdatedAt,
+	}
+
+	// Empire identifies users by their name, so it's used as both the id
+	// and email of the user.
+	collaborator.User.Id = c.UserName
+	collaborator.User.Email = c.UserName
+
+	return collaborator
+}
+
+func newCollaborators(cs []*empire.Collaborator) []*Collaborator {
+	collaborators := make([]*Collaborator, len(cs))
+
+	for i := 0; i < len(cs); i++ {
+		collaborators[i] = newCollaborator(cs[i])
+	}
+
+	return collaborators
+}
+
+// GetCollaborators returns the collaborators on an app.
+func (h *Server) GetCollaborators(w http.ResponseWriter, r *http.Request) error {
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	cs, err := h.Collaborators(empire.CollaboratorsQuery{App: a})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newCollaborators(cs))
+}
+
+type PostCollaboratorsForm struct {
+	User string `json:"user"`
+}
+
+// PostCollaborators gives a user access to an app.
+func (h *Server) PostCollaborators(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	var form PostCollaboratorsForm
+
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	c, err := h.CreateCollaborator(ctx, empire.CreateCollaboratorOpts{
+		User:     auth.UserFromContext(ctx),
+		App:      a,
+		UserName: form.User,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(201)
+	return Encode(w, newCollaborator(c))
+}
+
+// DeleteCollaborator removes a user's access to an app. The collaborator can be
+// identified by its id, or the name of the user.
+func (h *Server) DeleteCollaborator(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	cs, err := h.Collaborators(empire.CollaboratorsQuery{App: a})
+	if err != nil {
+		return err
+	}
+
+	identity := Vars(r)["collaborator"]
+
+	var collaborator *empire.Collaborator
+	for _, c := range cs {
+		if c.ID == identity || c.UserName == identity {
+			collaborator = c
+		}
+	}
+
+	if collaborator == nil {
+		return ErrNotFound
+	}
+
+	if err := h.DestroyCollaborator(ctx, empire.DestroyCollaboratorOpts{
+		User:         auth.UserFromContext(ctx),
+		Collaborator: collaborator,
+	}); err != nil {
+		return err
+	}
+
+	return NoContent(w)
+}
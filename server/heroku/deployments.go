@@ -1,8 +1,11 @@
 package heroku
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/remind101/empire/pkg/heroku"
 	"github.com/remind101/empire/pkg/image"
 	streamhttp "github.com/remind101/empire/pkg/stream/http"
 	"github.com/remind101/empire/server/auth"
@@ -10,59 +13,199 @@ import (
 	"github.com/remind101/empire"
 )
 
+type Deployment heroku.Deployment
+
+func newDeployment(d *empire.Deployment) *Deployment {
+	var messages []heroku.DeploymentMessage
+	for _, m := range d.Messages {
+		messages = append(messages, heroku.DeploymentMessage{
+			Message:   m.Message,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	deployment := &Deployment{
+		Id:         d.ID,
+		Image:      d.Image,
+		State:      string(d.State),
+		Version:    d.Version,
+		Error:      d.Error,
+		Messages:   messages,
+		CreatedAt:  *d.CreatedAt,
+		UpdatedAt:  *d.UpdatedAt,
+		FinishedAt: d.FinishedAt,
+	}
+	if d.App != nil {
+		deployment.App.Name = d.App.Name
+	}
+	return deployment
+}
+
 // PostDeployForm is the form object that represents the POST body.
 type PostDeployForm struct {
 	Image  image.Image
 	Stream bool
+
+	// If provided, the release is rolled out as a canary, receiving this
+	// percentage of traffic.
+	Canary int
+
+	// If provided, the canary is automatically promoted after this
+	// duration (e.g. "30m").
+	Bake string
+
+	// If true, the deployment is performed in the background, and the
+	// Deployment is returned immediately.
+	Detach bool
 }
 
 // ServeHTTPContext implements the Handler interface.
 func (h *Server) PostDeploys(w http.ResponseWriter, req *http.Request) error {
 	ctx := req.Context()
 
-	opts, err := newDeployOpts(w, req)
+	opts, detach, err := newDeployOpts(w, req)
 	if err != nil {
 		return err
 	}
 
+	// The app is determined by the image that's being deployed.
+	if err := h.AuthorizeAction(ctx, "deploy", empire.AppNameFromRepo(opts.Image.Repository)); err != nil {
+		return err
+	}
+
+	if detach {
+		return h.deployDetached(w, req, *opts)
+	}
+
 	_, err = h.Deploy(ctx, *opts)
 
 	// We only return the MessageRequiredError since all other errors are
 	// written to the stream.
 	switch err := err.(type) {
-	case *empire.MessageRequiredError:
+	case *empire.MessageRequiredError, *empire.ValidationError:
 		return err
 	}
 
 	return nil
 }
 
-func newDeployOpts(w http.ResponseWriter, req *http.Request) (*empire.DeployOpts, error) {
+// GetDeployment returns a single deployment of an app.
+func (h *Server) GetDeployment(w http.ResponseWriter, r *http.Request) error {
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	vars := Vars(r)
+	id := vars["id"]
+
+	d, err := h.DeploymentsFind(empire.DeploymentsQuery{ID: &id, App: a})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newDeployment(d))
+}
+
+// PostDeploymentCancelForm is the form object that represents the POST body
+// when cancelling a deployment.
+type PostDeploymentCancelForm struct {
+	// The id of the deployment to cancel. If not provided, the most recent
+	// deployment that's in progress is cancelled.
+	ID string `json:"id"`
+}
+
+// PostDeploymentCancel cancels a deployment that's in progress.
+func (h *Server) PostDeploymentCancel(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	var form PostDeploymentCancelForm
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	m, err := findMessage(r)
+	if err != nil {
+		return err
+	}
+
+	opts := empire.CancelDeploymentOpts{
+		User:    auth.UserFromContext(ctx),
+		App:     a,
+		Message: m,
+	}
+	if form.ID != "" {
+		opts.ID = &form.ID
+	}
+
+	d, err := h.CancelDeployment(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newDeployment(d))
+}
+
+// deployDetached queues the deployment, and responds with the Deployment,
+// without waiting for it to finish.
+func (h *Server) deployDetached(w http.ResponseWriter, req *http.Request, opts empire.DeployOpts) error {
+	d, err := h.DeployAsync(req.Context(), opts)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(201)
+	return Encode(w, newDeployment(d))
+}
+
+func newDeployOpts(w http.ResponseWriter, req *http.Request) (*empire.DeployOpts, bool, error) {
 	ctx := req.Context()
 
 	var form PostDeployForm
 
 	if err := Decode(req, &form); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	m, err := findMessage(req)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	w.Header().Set("Content-Type", "application/json; boundary=NL")
-
 	if form.Image.Tag == "" && form.Image.Digest == "" {
 		form.Image.Tag = "latest"
 	}
 
+	var bake time.Duration
+	if form.Bake != "" {
+		bake, err = time.ParseDuration(form.Bake)
+		if err != nil {
+			return nil, false, &empire.ValidationError{Err: fmt.Errorf("invalid bake time: %v", err)}
+		}
+	}
+
 	opts := empire.DeployOpts{
-		User:    auth.UserFromContext(ctx),
-		Image:   form.Image,
-		Output:  empire.NewDeploymentStream(streamhttp.StreamingResponseWriter(w)),
-		Message: m,
-		Stream:  form.Stream,
+		User:           auth.UserFromContext(ctx),
+		Image:          form.Image,
+		Message:        m,
+		Stream:         form.Stream,
+		CanaryWeight:   form.Canary,
+		CanaryBakeTime: bake,
+	}
+
+	// Detached deployments record their output, rather than streaming it
+	// in the response.
+	if !form.Detach {
+		w.Header().Set("Content-Type", "application/json; boundary=NL")
+		opts.Output = empire.NewDeploymentStream(streamhttp.StreamingResponseWriter(w))
 	}
-	return &opts, nil
+
+	return &opts, form.Detach, nil
 }
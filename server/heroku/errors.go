@@ -8,6 +8,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
 )
 
 // Named matching heroku's error codes. See
@@ -71,6 +72,12 @@ func newError(err error) *ErrorResource {
 		return ErrMessageRequired
 	case *empire.ValidationError:
 		return ErrBadRequest
+	case *auth.UnauthorizedError:
+		return &ErrorResource{
+			Status:  http.StatusForbidden,
+			ID:      "forbidden",
+			Message: err.Reason,
+		}
 	default:
 		return &ErrorResource{
 			Message: err.Error(),
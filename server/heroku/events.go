@@ -0,0 +1,167 @@
+package heroku
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	streamhttp "github.com/remind101/empire/pkg/stream/http"
+)
+
+// The interval that a comment is sent on an event stream, to prevent the ELB
+// idle connection timeout from closing the connection.
+const eventStreamHeartbeatInterval = 10 * time.Second
+
+type Event heroku.Event
+
+func newEvent(e *empire.AuditEvent) *Event {
+	return &Event{
+		Id:        e.ID,
+		App:       e.AppName,
+		User:      e.UserName,
+		Event:     e.Event,
+		Message:   e.Message,
+		Data:      json.RawMessage(e.Data),
+		CreatedAt: *e.CreatedAt,
+	}
+}
+
+func newEvents(es []*empire.AuditEvent) []*Event {
+	events := make([]*Event, len(es))
+
+	for i := 0; i < len(es); i++ {
+		events[i] = newEvent(es[i])
+	}
+
+	return events
+}
+
+// GetEvents returns events from the audit log. If the request is for an app,
+// only events for that app are returned.
+func (h *Server) GetEvents(w http.ResponseWriter, r *http.Request) error {
+	q, err := newAuditEventsQuery(r)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := Vars(r)["app"]; ok {
+		a, err := h.findApp(r)
+		if err != nil {
+			return err
+		}
+		q.App = &a.Name
+	}
+
+	events, err := h.AuditEvents(q)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newEvents(events))
+}
+
+// GetEventsStream streams events as they happen, as Server-Sent Events. If the
+// request is for an app, only events for that app are streamed.
+func (h *Server) GetEventsStream(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var app *string
+	if _, ok := Vars(r)["app"]; ok {
+		a, err := h.findApp(r)
+		if err != nil {
+			return err
+		}
+		app = &a.Name
+	}
+
+	events, err := h.SubscribeEvents(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	ew := streamhttp.NewEventWriter(w)
+	w.WriteHeader(200)
+
+	// Flush the headers, so the client knows that the stream is open.
+	if err := ew.WriteComment("connected"); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-heartbeat.C:
+			if err := ew.WriteComment("keepalive"); err != nil {
+				return err
+			}
+		case event, ok := <-events:
+			// The channel is closed when the client disconnects.
+			if !ok {
+				return nil
+			}
+
+			raw, err := json.Marshal(newEvent(event))
+			if err != nil {
+				return err
+			}
+
+			if err := ew.WriteEvent(event.ID, event.Event, raw); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newAuditEventsQuery builds an empire.AuditEventsQuery from the query string
+// and Range header of the request.
+func newAuditEventsQuery(r *http.Request) (empire.AuditEventsQuery, error) {
+	var q empire.AuditEventsQuery
+
+	rangeHeader, err := RangeHeader(r)
+	if err != nil {
+		return q, err
+	}
+	q.Range = rangeHeader
+
+	params := r.URL.Query()
+
+	if user := params.Get("user"); user != "" {
+		q.User = &user
+	}
+
+	if event := params.Get("event"); event != "" {
+		q.Event = &event
+	}
+
+	if q.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		return q, err
+	}
+
+	if q.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// parseTimeParam parses an RFC3339 timestamp from a query parameter. If the
+// parameter is empty, nil is returned.
+func parseTimeParam(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, &empire.ValidationError{Err: fmt.Errorf("invalid time %q: %v", v, err)}
+	}
+
+	t = t.UTC()
+	return &t, nil
+}
@@ -56,65 +56,114 @@ func New(e *empire.Empire) *Server {
 	}
 
 	// Apps
-	r.handle("GET", "/apps", r.GetApps)                  // hk apps
-	r.handle("GET", "/apps/{app}", r.GetAppInfo)         // hk info
-	r.handle("DELETE", "/apps/{app}", r.DeleteApp)       // hk destroy
-	r.handle("PATCH", "/apps/{app}", r.PatchApp)         // hk destroy
-	r.handle("POST", "/apps/{app}/deploys", r.DeployApp) // Deploy an image to an app
-	r.handle("POST", "/apps", r.PostApps)                // hk create
-	r.handle("POST", "/organizations/apps", r.PostApps)  // hk create
+	r.handle("GET", "/apps", r.GetApps, "apps:list")                         // hk apps
+	r.handle("GET", "/apps/{app}", r.GetAppInfo, "apps:info")                // hk info
+	r.handle("DELETE", "/apps/{app}", r.DeleteApp, "apps:destroy")           // hk destroy
+	r.handle("PATCH", "/apps/{app}", r.PatchApp, "apps:update")              // hk destroy
+	r.handle("POST", "/apps/{app}/deploys", r.DeployApp, "deploy")           // Deploy an image to an app
+	r.handle("POST", "/apps", r.PostApps, authorizedInHandler)               // hk create
+	r.handle("POST", "/organizations/apps", r.PostApps, authorizedInHandler) // hk create
 
 	// Domains
-	r.handle("GET", "/apps/{app}/domains", r.GetDomains)                 // hk domains
-	r.handle("POST", "/apps/{app}/domains", r.PostDomains)               // hk domain-add
-	r.handle("DELETE", "/apps/{app}/domains/{hostname}", r.DeleteDomain) // hk domain-remove
+	r.handle("GET", "/apps/{app}/domains", r.GetDomains, "domains:list")                   // hk domains
+	r.handle("POST", "/apps/{app}/domains", r.PostDomains, "domains:add")                  // hk domain-add
+	r.handle("DELETE", "/apps/{app}/domains/{hostname}", r.DeleteDomain, "domains:remove") // hk domain-remove
 
 	// Deploys
-	r.handle("POST", "/deploys", r.PostDeploys) // Deploy an app
+	r.handle("POST", "/deploys", r.PostDeploys, authorizedInHandler)                            // Deploy an app
+	r.handle("GET", "/apps/{app}/deployments/{id}", r.GetDeployment, "deployments:info")        // emp deployments-watch
+	r.handle("POST", "/apps/{app}/deployments/cancel", r.PostDeploymentCancel, "deploy:cancel") // emp deploy-cancel
 
 	// Releases
-	r.handle("GET", "/apps/{app}/releases", r.GetReleases)          // hk releases
-	r.handle("GET", "/apps/{app}/releases/{version}", r.GetRelease) // hk release-info
-	r.handle("POST", "/apps/{app}/releases", r.PostReleases)        // hk rollback
+	r.handle("GET", "/apps/{app}/releases", r.GetReleases, "releases:list")          // hk releases
+	r.handle("GET", "/apps/{app}/releases/{version}", r.GetRelease, "releases:info") // hk release-info
+	r.handle("POST", "/apps/{app}/releases", r.PostReleases, "rollback")             // hk rollback
 
 	// Configs
-	r.handle("GET", "/apps/{app}/config-vars", r.GetConfigs)                    // hk env, hk get
-	r.handle("GET", "/apps/{app}/config-vars/{version}", r.GetConfigsByRelease) // hk env v1, hk get v1
-	r.handle("PATCH", "/apps/{app}/config-vars", r.PatchConfigs)                // hk set, hk unset
+	r.handle("GET", "/apps/{app}/config-vars", r.GetConfigs, "config:get")                    // hk env, hk get
+	r.handle("GET", "/apps/{app}/config-vars/{version}", r.GetConfigsByRelease, "config:get") // hk env v1, hk get v1
+	r.handle("PATCH", "/apps/{app}/config-vars", r.PatchConfigs, "config:set")                // hk set, hk unset
 
 	// Processes
-	r.handle("GET", "/apps/{app}/dynos", r.GetProcesses)                     // hk dynos
-	r.handle("POST", "/apps/{app}/dynos", r.PostProcess)                     // hk run
-	r.handle("DELETE", "/apps/{app}/dynos", r.DeleteProcesses)               // hk restart
-	r.handle("DELETE", "/apps/{app}/dynos/{ptype}.{pid}", r.DeleteProcesses) // hk restart web.1
-	r.handle("DELETE", "/apps/{app}/dynos/{pid}", r.DeleteProcesses)         // hk restart web
+	r.handle("GET", "/apps/{app}/dynos", r.GetProcesses, "ps:list")                     // hk dynos
+	r.handle("POST", "/apps/{app}/dynos", r.PostProcess, "run")                         // hk run
+	r.handle("DELETE", "/apps/{app}/dynos", r.DeleteProcesses, "restart")               // hk restart
+	r.handle("DELETE", "/apps/{app}/dynos/{ptype}.{pid}", r.DeleteProcesses, "restart") // hk restart web.1
+	r.handle("DELETE", "/apps/{app}/dynos/{pid}", r.DeleteProcesses, "restart")         // hk restart web
 
 	// Formations
-	r.handle("GET", "/apps/{app}/formation", r.GetFormation)     // hk scale -l
-	r.handle("PATCH", "/apps/{app}/formation", r.PatchFormation) // hk scale
+	r.handle("GET", "/apps/{app}/formation", r.GetFormation, "scale:list") // hk scale -l
+	r.handle("PATCH", "/apps/{app}/formation", r.PatchFormation, "scale")  // hk scale
 
 	// OAuth
-	r.handle("POST", "/oauth/authorizations", r.PostAuthorizations).
-		// Authentication for this endpoint is handled directly in the
-		// handler.
-		AuthWith(auth.StrategyUsernamePassword)
+	r.handle("POST", "/oauth/authorizations", r.PostAuthorizations, authorizedInHandler).
+		// Users log in with a username and password, and can create
+		// service accounts with an access token.
+		AuthWith(auth.StrategyAccessToken, auth.StrategyUsernamePassword)
+	r.handle("GET", "/oauth/authorizations", r.GetAuthorizations, "authorizations:list")             // emp authorizations
+	r.handle("DELETE", "/oauth/authorizations/{id}", r.DeleteAuthorization, "authorizations:revoke") // emp authorizations-revoke
 
 	// Certs
-	r.handle("POST", "/apps/{app}/certs", r.PostCerts)
+	r.handle("POST", "/apps/{app}/certs", r.PostCerts, "certs:attach")
+
+	// Scheduler
+	r.handle("GET", "/apps/{app}/scheduler", r.GetScheduler, "scheduler:info")                           // emp scheduler
+	r.handle("POST", "/apps/{app}/scheduler/migrations", r.PostSchedulerMigrations, "scheduler:migrate") // emp scheduler-migrate
+
+	// Canaries
+	r.handle("GET", "/apps/{app}/canary", r.GetCanary, "canary:info")                     // emp canary
+	r.handle("POST", "/apps/{app}/canary/promote", r.PostCanaryPromote, "canary:promote") // emp canary-promote
+	r.handle("POST", "/apps/{app}/canary/abort", r.PostCanaryAbort, "canary:abort")       // emp canary-abort
+
+	// Notifications
+	r.handle("GET", "/apps/{app}/notifications", r.GetNotifications, "notifications:list")             // emp notifications
+	r.handle("POST", "/apps/{app}/notifications", r.PostNotifications, "notifications:add")            // emp notifications-add
+	r.handle("DELETE", "/apps/{app}/notifications/{id}", r.DeleteNotification, "notifications:remove") // emp notifications-remove
+
+	// Log drains
+	r.handle("GET", "/apps/{app}/log-drains", r.GetLogDrains, "drains:list")             // emp drains
+	r.handle("POST", "/apps/{app}/log-drains", r.PostLogDrains, "drains:add")            // emp drains-add
+	r.handle("DELETE", "/apps/{app}/log-drains/{id}", r.DeleteLogDrain, "drains:remove") // emp drains-remove
+
+	// Collaborators
+	r.handle("GET", "/apps/{app}/collaborators", r.GetCollaborators, "access:list")                       // emp access
+	r.handle("POST", "/apps/{app}/collaborators", r.PostCollaborators, "access:add")                      // emp access-add
+	r.handle("DELETE", "/apps/{app}/collaborators/{collaborator}", r.DeleteCollaborator, "access:remove") // emp access-remove
+
+	// Events
+	r.handle("GET", "/events", r.GetEvents, "events:list")                                      // emp events
+	r.handle("GET", "/apps/{app}/events", r.GetEvents, "events:list")                           // emp events -a <app>
+	r.handle("GET", "/events/stream", r.GetEventsStream, "events:list")                         // emp events --follow
+	r.handle("GET", "/apps/{app}/events/stream", r.GetEventsStream, "events:list")              // emp events --follow -a <app>
+	r.handle("GET", "/admin/events/outbox", r.GetOutboxEvents, "admin:outbox")                  // List undelivered events
+	r.handle("POST", "/admin/events/outbox/{id}/retry", r.PostOutboxEventRetry, "admin:outbox") // Requeue a dead-lettered event
+
+	// Policies
+	r.handle("GET", "/admin/policies", r.GetPolicies, "admin:policies")            // List policies
+	r.handle("PUT", "/admin/policies/{name}", r.PutPolicy, "admin:policies")       // Create or replace a policy
+	r.handle("DELETE", "/admin/policies/{name}", r.DeletePolicy, "admin:policies") // Remove a policy
 
 	// SSL
 	sslRemoved := errHandler(ErrSSLRemoved)
-	r.handle("GET", "/apps/{app}/ssl-endpoints", sslRemoved)           // hk ssl
-	r.handle("POST", "/apps/{app}/ssl-endpoints", sslRemoved)          // hk ssl-cert-add
-	r.handle("PATCH", "/apps/{app}/ssl-endpoints/{cert}", sslRemoved)  // hk ssl-cert-add, hk ssl-cert-rollback
-	r.handle("DELETE", "/apps/{app}/ssl-endpoints/{cert}", sslRemoved) // hk ssl-destroy
+	r.handle("GET", "/apps/{app}/ssl-endpoints", sslRemoved, "ssl")           // hk ssl
+	r.handle("POST", "/apps/{app}/ssl-endpoints", sslRemoved, "ssl")          // hk ssl-cert-add
+	r.handle("PATCH", "/apps/{app}/ssl-endpoints/{cert}", sslRemoved, "ssl")  // hk ssl-cert-add, hk ssl-cert-rollback
+	r.handle("DELETE", "/apps/{app}/ssl-endpoints/{cert}", sslRemoved, "ssl") // hk ssl-destroy
 
 	// Logs
-	r.handle("POST", "/apps/{app}/log-sessions", r.PostLogs) // hk log
+	r.handle("POST", "/apps/{app}/log-sessions", r.PostLogs, "logs") // hk log
 
 	return r
 }
 
+// Special actions for routes that aren't authorized with the action and app
+// from the URL.
+const (
+	// The route is authorized by its handler, since the action or app
+	// isn't known until the request body is decoded.
+	authorizedInHandler = "<authorized in handler>"
+)
+
 // handlerFunc is a function that an endpoint will be routed to.
 type handlerFunc func(http.ResponseWriter, *http.Request) error
 
@@ -129,6 +178,10 @@ type route struct {
 	// When true, disables the authentication check.
 	authStrategies []string
 
+	// The action that the user must be authorized to perform on the app
+	// to use this route.
+	action string
+
 	s *Server
 }
 
@@ -153,6 +206,13 @@ func (r *route) handle(w http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
+	// Authorize the action.
+	if r.action != authorizedInHandler {
+		if err := r.s.AuthorizeAction(ctx, r.action, Vars(req)["app"]); err != nil {
+			return err
+		}
+	}
+
 	// Track metrics for this endpoint.
 	m := withMetrics(r.Name, r.handler)
 
@@ -164,8 +224,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // handle adds a new handler to the router, which also increments a counter.
-func (s *Server) handle(method, path string, h handlerFunc, authStrategy ...string) *route {
+// Users must be authorized to perform the action on the app in the path to use
+// the route.
+func (s *Server) handle(method, path string, h handlerFunc, action string) *route {
 	r := s.route(h)
+	r.action = action
 	s.mux.Handle(path, r).Methods(method)
 	return r
 }
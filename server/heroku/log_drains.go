@@ -0,0 +1,104 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
+)
+
+type LogDrain heroku.LogDrain
+
+func newLogDrain(d *empire.LogDrain) *LogDrain {
+	return &LogDrain{
+		Id:        d.ID,
+		URL:       d.URL,
+		Token:     d.Token,
+		CreatedAt: *d.CreatedAt,
+		UpdatedAt: *d.UpdatedAt,
+	}
+}
+
+func newLogDrains(ds []*empire.LogDrain) []*LogDrain {
+	drains := make([]*LogDrain, len(ds))
+
+	for i := 0; i < len(ds); i++ {
+		drains[i] = newLogDrain(ds[i])
+	}
+
+	return drains
+}
+
+// GetLogDrains returns the log drains for an app.
+func (h *Server) GetLogDrains(w http.ResponseWriter, r *http.Request) error {
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	ds, err := h.LogDrains(empire.LogDrainsQuery{App: a})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newLogDrains(ds))
+}
+
+type PostLogDrainsForm struct {
+	URL string `json:"url"`
+}
+
+// PostLogDrains adds a log drain to an app.
+func (h *Server) PostLogDrains(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	var form PostLogDrainsForm
+
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	d, err := h.CreateLogDrain(ctx, empire.CreateLogDrainOpts{
+		User: auth.UserFromContext(ctx),
+		App:  a,
+		URL:  form.URL,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(201)
+	return Encode(w, newLogDrain(d))
+}
+
+// DeleteLogDrain removes a log drain from an app.
+func (h *Server) DeleteLogDrain(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	id := Vars(r)["id"]
+	d, err := h.LogDrainsFind(empire.LogDrainsQuery{ID: &id, App: a})
+	if err != nil {
+		return err
+	}
+
+	if err := h.DestroyLogDrain(ctx, empire.DestroyLogDrainOpts{
+		User:     auth.UserFromContext(ctx),
+		LogDrain: d,
+	}); err != nil {
+		return err
+	}
+
+	return NoContent(w)
+}
@@ -1,14 +1,38 @@
 package heroku
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
 	"time"
 
+	"github.com/remind101/empire"
 	streamhttp "github.com/remind101/empire/pkg/stream/http"
 )
 
 type PostLogsForm struct {
+	// How far back to start streaming logs from, in nanoseconds. Since
+	// takes precedence over this.
 	Duration int64
+
+	// If provided, only logs from this process type are streamed.
+	Process string `json:"process"`
+
+	// If provided, only logs from this task are streamed.
+	TaskID string `json:"task_id"`
+
+	// If provided, logs are streamed between these times.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+
+	// If provided, only lines containing this text are streamed. If Regexp
+	// is true, this is a regular expression.
+	Filter string `json:"filter"`
+	Regexp bool   `json:"regexp"`
+
+	// If true, the logs that already exist are streamed, and the stream is
+	// closed, instead of following new logs.
+	NoFollow bool `json:"no_follow"`
 }
 
 func (h *Server) PostLogs(w http.ResponseWriter, r *http.Request) error {
@@ -25,15 +49,51 @@ func (h *Server) PostLogs(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	opts, err := newStreamLogsOpts(form)
+	if err != nil {
+		return err
+	}
+
 	rw := streamhttp.StreamingResponseWriter(w)
 
 	// Prevent the ELB idle connection timeout to close the connection.
 	defer close(streamhttp.Heartbeat(rw, 10*time.Second))
 
-	err = h.StreamLogs(a, rw, time.Duration(form.Duration))
+	err = h.StreamLogs(a, rw, opts)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// newStreamLogsOpts builds an empire.StreamLogsOpts from the form.
+func newStreamLogsOpts(form PostLogsForm) (empire.StreamLogsOpts, error) {
+	opts := empire.StreamLogsOpts{
+		Process: form.Process,
+		TaskID:  form.TaskID,
+		Since:   form.Since,
+		Until:   form.Until,
+		Follow:  !form.NoFollow,
+	}
+
+	if opts.Since == nil && form.Duration != 0 {
+		since := time.Now().Add(-time.Duration(form.Duration))
+		opts.Since = &since
+	}
+
+	if form.Filter != "" {
+		expr := form.Filter
+		if !form.Regexp {
+			expr = regexp.QuoteMeta(expr)
+		}
+
+		filter, err := regexp.Compile(expr)
+		if err != nil {
+			return opts, &empire.ValidationError{Err: fmt.Errorf("invalid filter: %v", err)}
+		}
+		opts.Filter = filter
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,25 @@
+package heroku
+
+import (
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStreamLogsOpts(t *testing.T) {
+	opts, err := newStreamLogsOpts(PostLogsForm{Filter: "GET /apps/*"})
+	assert.NoError(t, err)
+	assert.True(t, opts.Follow)
+	assert.True(t, opts.Filter.MatchString("Started GET /apps/* for 127.0.0.1"))
+	assert.False(t, opts.Filter.MatchString("Started GET /apps/acme-inc for 127.0.0.1"))
+
+	opts, err = newStreamLogsOpts(PostLogsForm{Filter: "GET /apps/.*", Regexp: true, NoFollow: true, Duration: 60000000000})
+	assert.NoError(t, err)
+	assert.False(t, opts.Follow)
+	assert.NotNil(t, opts.Since)
+	assert.True(t, opts.Filter.MatchString("Started GET /apps/acme-inc for 127.0.0.1"))
+
+	_, err = newStreamLogsOpts(PostLogsForm{Filter: "(", Regexp: true})
+	assert.IsType(t, &empire.ValidationError{}, err)
+}
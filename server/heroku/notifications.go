@@ -0,0 +1,107 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
+)
+
+type Notification heroku.Notification
+
+func newNotification(n *empire.Notification) *Notification {
+	return &Notification{
+		Id:        n.ID,
+		Kind:      n.Kind,
+		Target:    n.Target,
+		Events:    []string(n.Events),
+		CreatedAt: *n.CreatedAt,
+	}
+}
+
+func newNotifications(ns []*empire.Notification) []*Notification {
+	notifications := make([]*Notification, len(ns))
+
+	for i := 0; i < len(ns); i++ {
+		notifications[i] = newNotification(ns[i])
+	}
+
+	return notifications
+}
+
+// GetNotifications returns the notifications for an app.
+func (h *Server) GetNotifications(w http.ResponseWriter, r *http.Request) error {
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	ns, err := h.Notifications(empire.NotificationsQuery{App: a})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newNotifications(ns))
+}
+
+type PostNotificationsForm heroku.NotificationCreateOpts
+
+// PostNotifications adds a notification to an app.
+func (h *Server) PostNotifications(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	var form PostNotificationsForm
+
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	n, err := h.CreateNotification(ctx, empire.CreateNotificationOpts{
+		User:   auth.UserFromContext(ctx),
+		App:    a,
+		Kind:   form.Kind,
+		Target: form.Target,
+		Events: form.Events,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp := newNotification(n)
+	resp.Secret = n.Secret
+
+	w.WriteHeader(201)
+	return Encode(w, resp)
+}
+
+// DeleteNotification removes a notification from an app.
+func (h *Server) DeleteNotification(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	id := Vars(r)["id"]
+	n, err := h.NotificationsFind(empire.NotificationsQuery{ID: &id, App: a})
+	if err != nil {
+		return err
+	}
+
+	if err := h.DestroyNotification(ctx, empire.DestroyNotificationOpts{
+		User:         auth.UserFromContext(ctx),
+		Notification: n,
+	}); err != nil {
+		return err
+	}
+
+	return NoContent(w)
+}
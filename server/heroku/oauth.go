@@ -2,7 +2,10 @@ package heroku
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/internal/uuid"
 	"github.com/remind101/empire/pkg/heroku"
 	"github.com/remind101/empire/server/auth"
 )
@@ -31,14 +34,88 @@ func newAuthorization(token *AccessToken) *Authorization {
 	}
 }
 
+// newServiceAccountAuthorization returns an Authorization for a service
+// account. The token is only included when the service account was just
+// created.
+func newServiceAccountAuthorization(s *empire.ServiceAccount) *Authorization {
+	var expIn *int
+	if t := s.ExpiresAt; t != nil {
+		exp := int(t.Sub(time.Now()).Seconds())
+		expIn = &exp
+	}
+	return &Authorization{
+		Id:          s.ID,
+		Description: s.Name,
+		CreatedBy:   s.CreatedBy,
+		Scope:       s.Scopes,
+		LastUsedAt:  s.LastUsedAt,
+		CreatedAt:   *s.CreatedAt,
+		UpdatedAt:   *s.UpdatedAt,
+		AccessToken: &struct {
+			ExpiresIn *int   `json:"expires_in"`
+			Id        string `json:"id"`
+			Token     string `json:"token"`
+		}{
+			ExpiresIn: expIn,
+			Id:        s.ID,
+			Token:     s.Token,
+		},
+	}
+}
+
+func newServiceAccountAuthorizations(ss []*empire.ServiceAccount) []*Authorization {
+	authorizations := make([]*Authorization, len(ss))
+
+	for i := 0; i < len(ss); i++ {
+		authorizations[i] = newServiceAccountAuthorization(ss[i])
+	}
+
+	return authorizations
+}
+
+type PostAuthorizationsForm struct {
+	// The name of the service account, when creating one.
+	Description string `json:"description"`
+
+	// Seconds until the token expires.
+	ExpiresIn *int `json:"expires_in"`
+
+	// When provided, a service account with these scopes is created,
+	// instead of an access token for the user.
+	Scope []string `json:"scope"`
+}
+
+// PostAuthorizations creates an access token for the authenticated user (which
+// is how `emp login` works), or creates a service account when scopes are
+// provided.
 func (h *Server) PostAuthorizations(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
+	var form PostAuthorizationsForm
+
+	if err := DecodeRequest(r, &form, true); err != nil {
+		return err
+	}
+
 	session := auth.SessionFromContext(ctx)
 
+	if len(form.Scope) > 0 {
+		return h.createServiceAccount(w, r, form)
+	}
+
+	// Access tokens aren't scoped, so a service account can't create one.
+	if session.ServiceAccount {
+		return &ErrorResource{
+			Status:  http.StatusForbidden,
+			ID:      "forbidden",
+			Message: "Service accounts can't create access tokens.",
+		}
+	}
+
 	at, err := h.AccessTokensCreate(&AccessToken{
 		User:      session.User,
 		ExpiresAt: session.ExpiresAt,
+		Groups:    session.Groups,
 	})
 	if err != nil {
 		return err
@@ -46,3 +123,91 @@ func (h *Server) PostAuthorizations(w http.ResponseWriter, r *http.Request) erro
 
 	return Encode(w, newAuthorization(at))
 }
+
+func (h *Server) createServiceAccount(w http.ResponseWriter, r *http.Request, form PostAuthorizationsForm) error {
+	ctx := r.Context()
+
+	if err := h.AuthorizeAction(ctx, "authorizations:create", ""); err != nil {
+		return err
+	}
+
+	var expiresIn time.Duration
+	if form.ExpiresIn != nil {
+		expiresIn = time.Duration(*form.ExpiresIn) * time.Second
+	}
+
+	session := auth.SessionFromContext(ctx)
+
+	opts := empire.CreateServiceAccountOpts{
+		User:      session.User,
+		Name:      form.Description,
+		Scopes:    form.Scope,
+		ExpiresIn: expiresIn,
+		Groups:    session.Groups,
+	}
+
+	// A service account can't create one that can do more than it can.
+	creator := session.User
+	if session.ServiceAccount {
+		opts.CreatorScopes = session.Scopes
+		opts.CreatorExpiresAt = session.ExpiresAt
+		creator = session.Creator
+	}
+
+	// The user that created the service account (or the service account
+	// that created it) is stored with their credentials, so that the
+	// service account can be authorized as them.
+	if creator != nil && creator.GitHubToken != "" {
+		token, err := encryptUser(h.Secret, creator)
+		if err != nil {
+			return err
+		}
+		opts.CreatorToken = token
+	}
+
+	s, err := h.CreateServiceAccount(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(201)
+	return Encode(w, newServiceAccountAuthorization(s))
+}
+
+// GetAuthorizations lists the service accounts.
+func (h *Server) GetAuthorizations(w http.ResponseWriter, r *http.Request) error {
+	ss, err := h.ServiceAccounts(empire.ServiceAccountsQuery{})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newServiceAccountAuthorizations(ss))
+}
+
+// DeleteAuthorization removes a service account, which revokes its token. The
+// service account can be identified by its id, or its name.
+func (h *Server) DeleteAuthorization(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	identity := Vars(r)["id"]
+
+	q := empire.ServiceAccountsQuery{Name: &identity}
+	if uuid.Parse(identity) != nil {
+		q = empire.ServiceAccountsQuery{ID: &identity}
+	}
+
+	account, err := h.ServiceAccountsFind(q)
+	if err != nil {
+		return err
+	}
+
+	if err := h.DestroyServiceAccount(ctx, empire.DestroyServiceAccountOpts{
+		User:           auth.UserFromContext(ctx),
+		ServiceAccount: account,
+	}); err != nil {
+		return err
+	}
+
+	return NoContent(w)
+}
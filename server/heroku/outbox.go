@@ -0,0 +1,58 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+)
+
+type OutboxEvent heroku.OutboxEvent
+
+func newOutboxEvent(e *empire.OutboxEvent) *OutboxEvent {
+	return &OutboxEvent{
+		Id:            e.ID,
+		Event:         e.Name,
+		Message:       e.Message,
+		Attempts:      e.Attempts,
+		LastError:     e.LastError,
+		NextAttemptAt: e.NextAttemptAt,
+		DeadAt:        e.DeadAt,
+		CreatedAt:     *e.CreatedAt,
+	}
+}
+
+func newOutboxEvents(es []*empire.OutboxEvent) []*OutboxEvent {
+	events := make([]*OutboxEvent, len(es))
+
+	for i := 0; i < len(es); i++ {
+		events[i] = newOutboxEvent(es[i])
+	}
+
+	return events
+}
+
+// GetOutboxEvents returns the events that haven't been delivered, including
+// events that have been dead-lettered.
+func (h *Server) GetOutboxEvents(w http.ResponseWriter, r *http.Request) error {
+	events, err := h.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newOutboxEvents(events))
+}
+
+// PostOutboxEventRetry requeues an event that was dead-lettered.
+func (h *Server) PostOutboxEventRetry(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	event, err := h.RetryOutboxEvent(ctx, Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newOutboxEvent(event))
+}
@@ -0,0 +1,96 @@
+package heroku
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	"github.com/remind101/empire/server/auth"
+)
+
+type Policy heroku.Policy
+
+func newPolicy(p *empire.Policy) *Policy {
+	return &Policy{
+		Id:        p.ID,
+		Name:      p.Name,
+		Document:  json.RawMessage(p.Document),
+		CreatedAt: *p.CreatedAt,
+		UpdatedAt: *p.UpdatedAt,
+	}
+}
+
+func newPolicies(ps []*empire.Policy) []*Policy {
+	policies := make([]*Policy, len(ps))
+
+	for i := 0; i < len(ps); i++ {
+		policies[i] = newPolicy(ps[i])
+	}
+
+	return policies
+}
+
+// GetPolicies returns the policies that are stored in the database.
+func (h *Server) GetPolicies(w http.ResponseWriter, r *http.Request) error {
+	ps, err := h.Policies(empire.PoliciesQuery{})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newPolicies(ps))
+}
+
+type PutPolicyForm struct {
+	Document json.RawMessage `json:"document"`
+}
+
+// PutPolicy creates or replaces a policy.
+func (h *Server) PutPolicy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var form PutPolicyForm
+
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	// Make sure that the policy is valid before storing it, since an
+	// invalid policy would cause every request to fail.
+	if _, err := auth.ParsePolicy(form.Document); err != nil {
+		return err
+	}
+
+	p, err := h.SetPolicy(ctx, empire.SetPolicyOpts{
+		User:     auth.UserFromContext(ctx),
+		Name:     Vars(r)["name"],
+		Document: string(form.Document),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, newPolicy(p))
+}
+
+// DeletePolicy removes a policy.
+func (h *Server) DeletePolicy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	name := Vars(r)["name"]
+	p, err := h.PoliciesFind(empire.PoliciesQuery{Name: &name})
+	if err != nil {
+		return err
+	}
+
+	if err := h.DestroyPolicy(ctx, empire.DestroyPolicyOpts{
+		User:   auth.UserFromContext(ctx),
+		Policy: p,
+	}); err != nil {
+		return err
+	}
+
+	return NoContent(w)
+}
@@ -15,7 +15,7 @@ import (
 type Dyno heroku.Dyno
 
 func newDyno(task *empire.Task) *Dyno {
-	return &Dyno{
+	d := &Dyno{
 		Command:   task.Command.String(),
 		Type:      task.Type,
 		Name:      task.Name,
@@ -24,6 +24,14 @@ func newDyno(task *empire.Task) *Dyno {
 		Size:      task.Constraints.String(),
 		UpdatedAt: task.UpdatedAt,
 	}
+	for _, s := range task.Sidecars {
+		d.Sidecars = append(d.Sidecars, heroku.DynoSidecar{
+			Name:    s.Name,
+			Command: s.Command.String(),
+			State:   s.State,
+		})
+	}
+	return d
 }
 
 func newDynos(tasks []*empire.Task) []*Dyno {
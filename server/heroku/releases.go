@@ -21,10 +21,21 @@ func newRelease(r *empire.Release) *Release {
 			Id: r.SlugID,
 		},
 		Description: r.Description,
+		Status:      releaseStatus(r),
 		CreatedAt:   *r.CreatedAt,
 	}
 }
 
+func releaseStatus(r *empire.Release) string {
+	if r.Failed {
+		return "failed"
+	}
+	if r.Pending {
+		return "pending"
+	}
+	return "succeeded"
+}
+
 func newReleases(rs []*empire.Release) []*Release {
 	releases := make([]*Release, len(rs))
 
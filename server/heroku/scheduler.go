@@ -0,0 +1,76 @@
+package heroku
+
+import (
+	"net/http"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/heroku"
+	streamhttp "github.com/remind101/empire/pkg/stream/http"
+	"github.com/remind101/empire/server/auth"
+)
+
+type Scheduler heroku.Scheduler
+
+// GetScheduler returns the scheduler backend that the app is running on.
+func (h *Server) GetScheduler(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	backend, err := h.SchedulerBackend(ctx, a)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(200)
+	return Encode(w, &Scheduler{Backend: backend})
+}
+
+// PostSchedulerMigrationsForm is the form object that represents the POST
+// body.
+type PostSchedulerMigrationsForm struct {
+	Backend string `json:"backend"`
+}
+
+// PostSchedulerMigrations migrates an app to a different scheduler backend,
+// streaming the status of the migration.
+func (h *Server) PostSchedulerMigrations(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	a, err := h.findApp(r)
+	if err != nil {
+		return err
+	}
+
+	var form PostSchedulerMigrationsForm
+	if err := Decode(r, &form); err != nil {
+		return err
+	}
+
+	m, err := findMessage(r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; boundary=NL")
+
+	err = h.Migrate(ctx, empire.MigrateOpts{
+		User:    auth.UserFromContext(ctx),
+		App:     a,
+		Backend: form.Backend,
+		Output:  empire.NewDeploymentStream(streamhttp.StreamingResponseWriter(w)),
+		Message: m,
+	})
+
+	// Validation errors are returned before anything is written to the
+	// stream. All other errors are written to the stream.
+	switch err := err.(type) {
+	case *empire.MessageRequiredError, *empire.ValidationError:
+		return err
+	}
+
+	return nil
+}
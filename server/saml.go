@@ -43,12 +43,20 @@ func (s *Server) SAMLACS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session := samlauth.SessionFromAssertion(assertion)
+	session := samlauth.SessionFromAssertion(assertion, s.SAMLGroupsAttribute)
+
+	// Check that the user can access Empire (e.g. that they're a member of
+	// an allowed group) before giving them an Access Token.
+	if err := s.Heroku.Auth.Authorize(session); err != nil {
+		http.Error(w, err.Error(), 403)
+		return
+	}
 
 	// Create an Access Token for the API.
 	at, err := s.Heroku.AccessTokensCreate(&heroku.AccessToken{
 		ExpiresAt: session.ExpiresAt,
 		User:      session.User,
+		Groups:    session.Groups,
 	})
 	if err != nil {
 		http.Error(w, err.Error(), 403)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/internal/saml"
+	"github.com/remind101/empire/server/auth/oidc"
 	"github.com/remind101/empire/server/github"
 	"github.com/remind101/empire/server/heroku"
 )
@@ -38,6 +39,16 @@ type Options struct {
 			Scopes []string
 		}
 	}
+
+	// If provided, the web flow authenticates users with an OpenID
+	// Connect provider, instead of GitHub.
+	OIDC struct {
+		Verifier     *oidc.Verifier
+		ClientID     string
+		ClientSecret string
+		RedirectURL  string
+		Scopes       []string
+	}
 }
 
 // Server composes the Heroku API compatibility layer, the GitHub Webhooks
@@ -56,7 +67,22 @@ type Server struct {
 	// If provided, enables the SAML integration.
 	ServiceProvider *saml.ServiceProvider
 
+	// If provided, the values of this SAML attribute are used as the
+	// groups that the user belongs to.
+	SAMLGroupsAttribute string
+
 	AuthConfig *oauth2.Config
+
+	// When true, the web flow returns the OpenID Connect ID token to the
+	// client, instead of the OAuth access token.
+	IDToken bool
+
+	// Verifies the ID token returned from the web flow, when IDToken is
+	// true.
+	Verifier *oidc.Verifier
+
+	// The secret used to sign the state of the web flow.
+	Secret []byte
 }
 
 func New(e *empire.Empire, options Options) *Server {
@@ -84,6 +110,18 @@ func New(e *empire.Empire, options Options) *Server {
 		}
 	}
 
+	if v := options.OIDC.Verifier; v != nil {
+		s.AuthConfig = &oauth2.Config{
+			ClientID:     options.OIDC.ClientID,
+			ClientSecret: options.OIDC.ClientSecret,
+			Scopes:       append([]string{"openid"}, options.OIDC.Scopes...),
+			Endpoint:     v.Provider.Endpoint(),
+			RedirectURL:  options.OIDC.RedirectURL + "/oauth/exchange",
+		}
+		s.IDToken = true
+		s.Verifier = v
+	}
+
 	s.Heroku = heroku.New(e)
 	s.Health = NewHealthHandler(e)
 
@@ -91,8 +129,19 @@ func New(e *empire.Empire, options Options) *Server {
 }
 
 func (s *Server) StartWebFlow(w http.ResponseWriter, r *http.Request) {
+	state, nonce, err := newWebFlowState(s.Secret, r.FormValue("port"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if s.IDToken {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+
 	// Construct a URL for the initial webflow request
-	url := s.AuthConfig.AuthCodeURL(fmt.Sprintf(r.FormValue("port")))
+	url := s.AuthConfig.AuthCodeURL(state, opts...)
 	// Issue a redirect
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
@@ -109,8 +158,14 @@ func reportOAuthFailure(w http.ResponseWriter, r *http.Request, port string, err
 }
 
 func (s *Server) PerformCodeExchange(w http.ResponseWriter, r *http.Request) {
+	// Without a valid state, there's no port to report the failure to.
+	port, nonce, err := parseWebFlowState(s.Secret, r.FormValue("state"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	token, err := s.AuthConfig.Exchange(oauth2.NoContext, r.FormValue("code"))
-	port := r.FormValue("state")
 	if err != nil {
 		reportOAuthFailure(w, r, port, err)
 		return
@@ -121,6 +176,30 @@ func (s *Server) PerformCodeExchange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With OpenID Connect, the client exchanges the ID token for an access
+	// token, which authenticates the user without calling the provider.
+	t := token.AccessToken
+	if s.IDToken {
+		t, _ = token.Extra("id_token").(string)
+		if t == "" {
+			reportOAuthFailure(w, r, port, "Could not retrieve ID token")
+			return
+		}
+
+		// The nonce in the ID token has to match the one that was
+		// sent when the web flow was started, so that an ID token
+		// from another login can't be replayed.
+		idToken, err := s.Verifier.Verify(t)
+		if err != nil {
+			reportOAuthFailure(w, r, port, err)
+			return
+		}
+		if idToken.String("nonce") != nonce {
+			reportOAuthFailure(w, r, port, "ID token nonce did not match")
+			return
+		}
+	}
+
 	// We COULD directly generate and return the Signed JWT to the client here and return it to the client, but it kind of feels out
 	// of place.  We've opted instead for re-using as much of the existing authentication path as possible
 	// It's worth thinking about the security implications here.  The JWT is signed and can't be modified, and has an expiration date in
@@ -128,7 +207,7 @@ func (s *Server) PerformCodeExchange(w http.ResponseWriter, r *http.Request) {
 	// that the Github token we're sending back is being sent to the browser is over https as a redirect response.  The browser then makes
 	// an unencrypted http request, but only to localhost, so an attacker would have to be able to capture packets on the loopback interface
 	// to get it, which in turn requires root access, so the entire client machine would already be compromised at that point.
-	redirectUrl := fmt.Sprintf("http://localhost:%s/oauth/token?token=%s", r.FormValue("state"), url.QueryEscape(token.AccessToken))
+	redirectUrl := fmt.Sprintf("http://localhost:%s/oauth/token?token=%s", port, url.QueryEscape(t))
 	http.Redirect(w, r, redirectUrl, http.StatusTemporaryRedirect)
 }
 
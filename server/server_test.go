@@ -0,0 +1,163 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/server/auth/oidc"
+	"github.com/remind101/empire/server/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_OIDCWebFlow(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	s := newTestOIDCServer(t, i)
+
+	// Starting the web flow redirects to the issuer.
+	location := startWebFlow(t, s, "1234")
+	assert.Equal(t, i.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.NotEqual(t, "", location.Query().Get("state"))
+	assert.NotEqual(t, "", location.Query().Get("nonce"))
+	assert.Equal(t, "openid email groups", location.Query().Get("scope"))
+	assert.Equal(t, "https://empire.example.com/oauth/exchange", location.Query().Get("redirect_uri"))
+
+	// After the user logs in, the code is exchanged and the ID token is
+	// handed to the client.
+	callback := authorize(t, location)
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", callback.RequestURI(), nil)
+	s.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:1234", location.Host)
+	assert.Equal(t, "/oauth/token", location.Path)
+
+	token, err := s.Verifier.Verify(location.Query().Get("token"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ejholmes@example.com", token.String("email"))
+}
+
+func TestServer_OIDCWebFlow_InvalidCode(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	s := newTestOIDCServer(t, i)
+
+	location := startWebFlow(t, s, "1234")
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth/exchange?code=bad&state="+url.QueryEscape(location.Query().Get("state")), nil)
+	s.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:1234", location.Host)
+	assert.Equal(t, "/oauth/failure", location.Path)
+	assert.Equal(t, "", location.Query().Get("token"))
+}
+
+func TestServer_OIDCWebFlow_InvalidState(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	s := newTestOIDCServer(t, i)
+
+	// The port isn't accepted as the state.
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth/exchange?code="+oidctest.Code+"&state=1234", nil)
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Neither is a state that was signed with another secret.
+	other := newTestOIDCServer(t, i)
+	other.Secret = []byte("other")
+	location := startWebFlow(t, other, "1234")
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", authorize(t, location).RequestURI(), nil)
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// The port has to be a port.
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/oauth/start?port=1234@example.com", nil)
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestServer_OIDCWebFlow_InvalidNonce(t *testing.T) {
+	i := oidctest.NewIssuer()
+	defer i.Close()
+
+	s := newTestOIDCServer(t, i)
+
+	// The ID token is issued for the second login, but exchanged with the
+	// state of the first.
+	first := startWebFlow(t, s, "1234")
+	second := startWebFlow(t, s, "1234")
+	authorize(t, second)
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth/exchange?code="+oidctest.Code+"&state="+url.QueryEscape(first.Query().Get("state")), nil)
+	s.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/oauth/failure", location.Path)
+	assert.Equal(t, "ID token nonce did not match", location.Query().Get("message"))
+}
+
+func newTestOIDCServer(t testing.TB, i *oidctest.Issuer) *Server {
+	p, err := oidc.Discover(i.URL)
+	assert.NoError(t, err)
+
+	var opts Options
+	opts.OIDC.Verifier = oidc.NewVerifier(p, i.ClientID)
+	opts.OIDC.ClientID = i.ClientID
+	opts.OIDC.ClientSecret = i.ClientSecret
+	opts.OIDC.RedirectURL = "https://empire.example.com"
+	opts.OIDC.Scopes = []string{"email", "groups"}
+	s := New(&empire.Empire{}, opts)
+	s.Secret = []byte("secret")
+	return s
+}
+
+// startWebFlow starts the web flow, and returns the URL of the issuer that the
+// user is redirected to.
+func startWebFlow(t testing.TB, s *Server, port string) *url.URL {
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth/start?port="+port, nil)
+	s.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+	location, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	return location
+}
+
+// authorize logs in with the issuer, and returns the URL that the issuer
+// redirects back to.
+func authorize(t testing.TB, location *url.URL) *url.URL {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(location.String())
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return callback
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/remind101/empire/internal/jwt"
+)
+
+// webFlowStateExpiration is how long the user has to log in with the provider
+// after starting the web flow.
+const webFlowStateExpiration = 10 * time.Minute
+
+// newWebFlowState returns the state that's passed through the web flow, which
+// is signed with the secret so that it can't be forged. The state binds the
+// port that `emp` is listening on to a random nonce, which is sent to OpenID
+// Connect providers, and has to match the nonce in the ID token.
+func newWebFlowState(secret []byte, port string) (state, nonce string, err error) {
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", "", fmt.Errorf("invalid port: %q", port)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("error generating nonce: %v", err)
+	}
+	nonce = hex.EncodeToString(b)
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"port":  port,
+		"nonce": nonce,
+		"exp":   time.Now().Add(webFlowStateExpiration).Unix(),
+	})
+	state, err = t.SignedString(secret)
+	return state, nonce, err
+}
+
+// parseWebFlowState verifies the state returned from the provider, and returns
+// the port and nonce from it.
+func parseWebFlowState(secret []byte, state string) (port, nonce string, err error) {
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}}
+	t, err := parser.Parse(state, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("invalid state: %v", err)
+	}
+
+	claims := t.Claims.(jwt.MapClaims)
+	port, _ = claims["port"].(string)
+	nonce, _ = claims["nonce"].(string)
+	if port == "" || nonce == "" {
+		return "", "", errors.New("invalid state")
+	}
+
+	return port, nonce, nil
+}
@@ -0,0 +1,512 @@
+package empire
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/remind101/empire/pkg/timex"
+	"golang.org/x/net/context"
+)
+
+// ServiceAccountTokenPrefix is the prefix of all service account tokens, which
+// distinguishes them from the JWT access tokens that users get when they log
+// in.
+const ServiceAccountTokenPrefix = "emp_"
+
+// ServiceAccountUserPrefix is prefixed to the name of a service account to get
+// the name of the user that it acts as (e.g. "service:ci"), so that service
+// accounts can't impersonate people.
+const ServiceAccountUserPrefix = "service:"
+
+// The scopes that a service account can have.
+const (
+	// ScopeGlobal allows any action.
+	ScopeGlobal = "global"
+
+	// ScopeRead allows actions that only read apps (e.g. `emp releases`).
+	ScopeRead = "read"
+
+	// ScopeDeploy allows deploying apps, and following the deployment.
+	ScopeDeploy = "deploy"
+
+	// ScopeAppPrefix limits the other scopes to apps matching a pattern
+	// (e.g. "app:acme-*").
+	ScopeAppPrefix = "app:"
+)
+
+// DefaultServiceAccountExpiration is the default maximum lifetime of service
+// account tokens.
+const DefaultServiceAccountExpiration = 90 * 24 * time.Hour
+
+// ServiceAccountNamePattern is a regex pattern that service account names must
+// conform to.
+var ServiceAccountNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{2,30}$`)
+
+// ErrServiceAccountExpired is returned when authenticating with the token of a
+// service account that has expired.
+var ErrServiceAccountExpired = errors.New("service account token has expired")
+
+// ServiceAccount is a named, non-human user (e.g. a CI system), which
+// authenticates with a long lived API token. The token is only stored as a
+// hash, and the scopes limit what the service account can do.
+type ServiceAccount struct {
+	// A unique uuid that identifies this service account.
+	ID string
+
+	// A unique name for the service account (e.g. "ci").
+	Name string
+
+	// The sha256 hash of the token.
+	TokenHash string
+
+	// The scopes that limit what the service account can do (e.g.
+	// "deploy", "app:acme-*").
+	Scopes Scopes
+
+	// The name of the user that created the service account.
+	CreatedBy string
+
+	// The groups of the user that created the service account, when it was
+	// created. The service account is authorized with these groups.
+	Groups Groups
+
+	// An encrypted credential of the user that created the service account
+	// (e.g. their GitHub token), which is used to check that the creator
+	// still has access. It's opaque to Empire.
+	CreatorToken string
+
+	// When the token expires. The zero value means no expiration.
+	ExpiresAt *time.Time
+
+	// The last time that the token was used.
+	LastUsedAt *time.Time
+
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+
+	// The token, which is only available when the service account is
+	// created.
+	Token string `sql:"-"`
+}
+
+// BeforeCreate sets created_at and updated_at before inserting.
+func (s *ServiceAccount) BeforeCreate() error {
+	t := timex.Now()
+	s.CreatedAt = &t
+	s.UpdatedAt = &t
+	return nil
+}
+
+// User returns the User that the service account acts as.
+func (s *ServiceAccount) User() *User {
+	return &User{Name: ServiceAccountUserPrefix + s.Name}
+}
+
+// IsExpired returns true if the token has expired.
+func (s *ServiceAccount) IsExpired() bool {
+	return s.ExpiresAt != nil && !timex.Now().Before(*s.ExpiresAt)
+}
+
+// Scopes is a list of scopes.
+type Scopes []string
+
+// Validate checks that the scopes are known, and that at least one scope
+// allows actions.
+func (s Scopes) Validate() error {
+	var actions bool
+	for _, scope := range s {
+		switch {
+		case scope == ScopeGlobal, scope == ScopeRead, scope == ScopeDeploy:
+			actions = true
+		case strings.HasPrefix(scope, ScopeAppPrefix):
+			pattern := strings.TrimPrefix(scope, ScopeAppPrefix)
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("invalid app pattern in scope %q", scope)
+			}
+		default:
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	if !actions {
+		return fmt.Errorf("at least one of the %q, %q or %q scopes is required", ScopeGlobal, ScopeRead, ScopeDeploy)
+	}
+
+	return nil
+}
+
+// Includes returns true if every scope in other is allowed by s, so that a
+// service account with the scopes in s can't create one that can do more.
+func (s Scopes) Includes(other Scopes) bool {
+	var (
+		global  bool
+		actions = make(map[string]bool)
+		apps    = make(map[string]bool)
+	)
+	for _, scope := range s {
+		switch {
+		case scope == ScopeGlobal:
+			global = true
+		case strings.HasPrefix(scope, ScopeAppPrefix):
+			apps[scope] = true
+		default:
+			actions[scope] = true
+		}
+	}
+
+	var limited bool
+	for _, scope := range other {
+		switch {
+		case strings.HasPrefix(scope, ScopeAppPrefix):
+			if len(apps) > 0 && !apps[scope] {
+				return false
+			}
+			limited = true
+		case !global && !actions[scope]:
+			return false
+		}
+	}
+
+	// When s is limited to some apps, other has to be too.
+	if len(apps) > 0 && !limited {
+		return false
+	}
+
+	return true
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *Scopes) Scan(src interface{}) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return error(errors.New("Scan source was not []bytes"))
+	}
+
+	var scopes Scopes
+	if err := json.Unmarshal(bytes, &scopes); err != nil {
+		return err
+	}
+	*s = scopes
+
+	return nil
+}
+
+// Value implements the driver.Value interface.
+func (s Scopes) Value() (driver.Value, error) {
+	raw, err := json.Marshal([]string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(raw), nil
+}
+
+// Groups is a list of groups.
+type Groups []string
+
+// Scan implements the sql.Scanner interface.
+func (g *Groups) Scan(src interface{}) error {
+	if src == nil {
+		*g = nil
+		return nil
+	}
+
+	bytes, ok := src.([]byte)
+	if !ok {
+		return error(errors.New("Scan source was not []bytes"))
+	}
+
+	var groups Groups
+	if err := json.Unmarshal(bytes, &groups); err != nil {
+		return err
+	}
+	*g = groups
+
+	return nil
+}
+
+// Value implements the driver.Value interface.
+func (g Groups) Value() (driver.Value, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal([]string(g))
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(raw), nil
+}
+
+// ServiceAccountsQuery is a scope implementation for common things to filter
+// service accounts by.
+type ServiceAccountsQuery struct {
+	// If provided, finds the service account with the given id.
+	ID *string
+
+	// If provided, finds the service account with the given name.
+	Name *string
+
+	// If provided, finds the service account with the given token.
+	Token *string
+}
+
+// scope implements the scope interface.
+func (q ServiceAccountsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.ID != nil {
+		scope = append(scope, idEquals(*q.ID))
+	}
+
+	if q.Name != nil {
+		scope = append(scope, fieldEquals("name", *q.Name))
+	}
+
+	if q.Token != nil {
+		scope = append(scope, fieldEquals("token_hash", hashToken(*q.Token)))
+	}
+
+	scope = append(scope, order("name"))
+
+	return scope.scope(db)
+}
+
+// serviceAccountsFind returns the first matching service account.
+func serviceAccountsFind(db *gorm.DB, scope scope) (*ServiceAccount, error) {
+	var account ServiceAccount
+	return &account, first(db, scope, &account)
+}
+
+// serviceAccounts returns all service accounts matching the scope.
+func serviceAccounts(db *gorm.DB, scope scope) ([]*ServiceAccount, error) {
+	var accounts []*ServiceAccount
+	return accounts, find(db, scope, &accounts)
+}
+
+// serviceAccountsCreate inserts a new service account.
+func serviceAccountsCreate(db *gorm.DB, s *ServiceAccount) (*ServiceAccount, error) {
+	return s, db.Create(s).Error
+}
+
+// serviceAccountsDestroy deletes a service account.
+func serviceAccountsDestroy(db *gorm.DB, s *ServiceAccount) error {
+	return db.Delete(s).Error
+}
+
+// CreateServiceAccountOpts are options provided when creating a service
+// account.
+type CreateServiceAccountOpts struct {
+	// User performing the action.
+	User *User
+
+	// The name of the service account.
+	Name string
+
+	// The scopes that limit what the service account can do.
+	Scopes Scopes
+
+	// If provided, the token expires after this duration. Otherwise, it
+	// expires after the ServiceAccountExpiration.
+	ExpiresIn time.Duration
+
+	// The groups of the user creating the service account.
+	Groups []string
+
+	// An encrypted credential of the user creating the service account.
+	CreatorToken string
+
+	// When a service account is created by another service account, the
+	// scopes and expiration of that service account. The new service
+	// account can't have scopes that the creator doesn't have, or outlive
+	// it.
+	CreatorScopes    Scopes
+	CreatorExpiresAt *time.Time
+}
+
+func (opts CreateServiceAccountOpts) Validate(e *Empire) error {
+	if !ServiceAccountNamePattern.MatchString(opts.Name) {
+		return &ValidationError{Err: fmt.Errorf("service account names must match %s", ServiceAccountNamePattern)}
+	}
+
+	if err := opts.Scopes.Validate(); err != nil {
+		return &ValidationError{Err: err}
+	}
+
+	if opts.ExpiresIn < 0 {
+		return &ValidationError{Err: fmt.Errorf("expiration must be in the future")}
+	}
+
+	if max := e.ServiceAccountExpiration; max != 0 && opts.ExpiresIn > max {
+		return &ValidationError{Err: fmt.Errorf("service accounts can't expire more than %v from now", max)}
+	}
+
+	if len(opts.CreatorScopes) > 0 && !opts.CreatorScopes.Includes(opts.Scopes) {
+		return &ValidationError{Err: fmt.Errorf("service accounts can only be given scopes that are included in %s", strings.Join(opts.CreatorScopes, ","))}
+	}
+
+	return nil
+}
+
+// CreateServiceAccount creates a new service account, and generates its token.
+// The token is only available on the returned ServiceAccount.
+func (e *Empire) CreateServiceAccount(ctx context.Context, opts CreateServiceAccountOpts) (*ServiceAccount, error) {
+	if err := opts.Validate(e); err != nil {
+		return nil, err
+	}
+
+	_, err := serviceAccountsFind(e.db, ServiceAccountsQuery{Name: &opts.Name})
+	switch err {
+	case nil:
+		return nil, &ValidationError{Err: fmt.Errorf("a service account named %s already exists", opts.Name)}
+	case gorm.RecordNotFound:
+	default:
+		return nil, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ServiceAccount{
+		Name:         opts.Name,
+		TokenHash:    hashToken(token),
+		Scopes:       opts.Scopes,
+		CreatedBy:    opts.User.Name,
+		Groups:       opts.Groups,
+		CreatorToken: opts.CreatorToken,
+	}
+
+	expiresIn := opts.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = e.ServiceAccountExpiration
+	}
+
+	if expiresIn != 0 {
+		t := timex.Now().Add(expiresIn)
+		s.ExpiresAt = &t
+	}
+
+	if t := opts.CreatorExpiresAt; t != nil && (s.ExpiresAt == nil || t.Before(*s.ExpiresAt)) {
+		s.ExpiresAt = t
+	}
+
+	tx := e.db.Begin()
+
+	if _, err := serviceAccountsCreate(tx, s); err != nil {
+		tx.Rollback()
+		return s, err
+	}
+
+	if err := e.publishEvent(tx, ServiceAccountCreateEvent{
+		User:      opts.User.Name,
+		Name:      s.Name,
+		Scopes:    s.Scopes,
+		ExpiresAt: s.ExpiresAt,
+	}); err != nil {
+		tx.Rollback()
+		return s, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return s, err
+	}
+	s.Token = token
+
+	return s, nil
+}
+
+// DestroyServiceAccountOpts are options provided when removing a service
+// account.
+type DestroyServiceAccountOpts struct {
+	// User performing the action.
+	User *User
+
+	// The service account to remove.
+	ServiceAccount *ServiceAccount
+}
+
+// DestroyServiceAccount removes a service account, which revokes its token.
+func (e *Empire) DestroyServiceAccount(ctx context.Context, opts DestroyServiceAccountOpts) error {
+	tx := e.db.Begin()
+
+	if err := serviceAccountsDestroy(tx, opts.ServiceAccount); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := e.publishEvent(tx, ServiceAccountRevokeEvent{
+		User:      opts.User.Name,
+		Name:      opts.ServiceAccount.Name,
+		CreatedBy: opts.ServiceAccount.CreatedBy,
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// ServiceAccountsFind returns the first service account matching the query.
+func (e *Empire) ServiceAccountsFind(q ServiceAccountsQuery) (*ServiceAccount, error) {
+	return serviceAccountsFind(e.db, q)
+}
+
+// ServiceAccounts returns all service accounts matching the query.
+func (e *Empire) ServiceAccounts(q ServiceAccountsQuery) ([]*ServiceAccount, error) {
+	return serviceAccounts(e.db, q)
+}
+
+// AuthenticateServiceAccount finds the service account with the given token,
+// and records that the token was used. gorm.RecordNotFound is returned if the
+// token doesn't exist, or has been revoked, and ErrServiceAccountExpired is
+// returned if it has expired.
+func (e *Empire) AuthenticateServiceAccount(token string) (*ServiceAccount, error) {
+	s, err := serviceAccountsFind(e.db, ServiceAccountsQuery{Token: &token})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.IsExpired() {
+		return nil, ErrServiceAccountExpired
+	}
+
+	// Tokens can be used for many requests in a row, so last_used_at is
+	// only updated about once a minute.
+	t := timex.Now()
+	if s.LastUsedAt == nil || t.Sub(*s.LastUsedAt) > time.Minute {
+		if err := e.db.Model(&ServiceAccount{}).Where("id = ?", s.ID).UpdateColumn("last_used_at", t).Error; err != nil {
+			return nil, err
+		}
+		s.LastUsedAt = &t
+	}
+
+	return s, nil
+}
+
+// generateToken generates a new random service account token.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %v", err)
+	}
+	return ServiceAccountTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashToken returns the hash of a token, which is what's stored.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
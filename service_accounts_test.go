@@ -0,0 +1,102 @@
+package empire
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceAccountsQuery(t *testing.T) {
+	name := "ci"
+	token := "emp_token"
+
+	tests := scopeTests{
+		{ServiceAccountsQuery{}, "ORDER BY name", []interface{}{}},
+		{ServiceAccountsQuery{Name: &name}, "WHERE (name = $1) ORDER BY name", []interface{}{name}},
+		{ServiceAccountsQuery{Token: &token}, "WHERE (token_hash = $1) ORDER BY name", []interface{}{hashToken(token)}},
+	}
+
+	tests.Run(t)
+}
+
+func TestScopes_Validate(t *testing.T) {
+	tests := []struct {
+		scopes Scopes
+		err    string
+	}{
+		{Scopes{"global"}, ""},
+		{Scopes{"read", "deploy", "app:acme-*"}, ""},
+		{Scopes{"app:acme-*"}, `at least one of the "global", "read" or "deploy" scopes is required`},
+		{nil, `at least one of the "global", "read" or "deploy" scopes is required`},
+		{Scopes{"write"}, "unknown scope: write"},
+		{Scopes{"deploy", "app:"}, `invalid app pattern in scope "app:"`},
+		{Scopes{"deploy", "app:["}, `invalid app pattern in scope "app:["`},
+	}
+
+	for _, tt := range tests {
+		err := tt.scopes.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestScopes_Includes(t *testing.T) {
+	tests := []struct {
+		scopes, other Scopes
+		included      bool
+	}{
+		{Scopes{"global"}, Scopes{"global"}, true},
+		{Scopes{"global"}, Scopes{"read", "deploy", "app:acme-*"}, true},
+		{Scopes{"read"}, Scopes{"read"}, true},
+		{Scopes{"read"}, Scopes{"deploy"}, false},
+		{Scopes{"read"}, Scopes{"global"}, false},
+		{Scopes{"deploy", "app:acme-*"}, Scopes{"deploy", "app:acme-*"}, true},
+		{Scopes{"deploy", "app:acme-*"}, Scopes{"deploy"}, false},
+		{Scopes{"deploy", "app:acme-*"}, Scopes{"deploy", "app:*"}, false},
+		{Scopes{"global", "app:acme-*"}, Scopes{"read", "app:acme-*"}, true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.included, tt.scopes.Includes(tt.other), "%v includes %v", tt.scopes, tt.other)
+	}
+}
+
+func TestCreateServiceAccountOpts_Validate(t *testing.T) {
+	e := &Empire{ServiceAccountExpiration: DefaultServiceAccountExpiration}
+
+	tests := []struct {
+		opts CreateServiceAccountOpts
+		err  string
+	}{
+		{CreateServiceAccountOpts{Name: "ci-deploy", Scopes: Scopes{"deploy"}}, ""},
+		{CreateServiceAccountOpts{Name: "ci-deploy", Scopes: Scopes{"deploy"}, ExpiresIn: DefaultServiceAccountExpiration}, ""},
+		{CreateServiceAccountOpts{Name: "ci-deploy", Scopes: Scopes{"deploy"}, ExpiresIn: DefaultServiceAccountExpiration + 1}, "service accounts can't expire more than 2160h0m0s from now"},
+		{CreateServiceAccountOpts{Name: "ci-deploy", Scopes: Scopes{"deploy"}, CreatorScopes: Scopes{"global"}}, ""},
+		{CreateServiceAccountOpts{Name: "ci-deploy", Scopes: Scopes{"global"}, CreatorScopes: Scopes{"deploy"}}, "service accounts can only be given scopes that are included in deploy"},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate(e)
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	t1, err := generateToken()
+	assert.NoError(t, err)
+	t2, err := generateToken()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(t1, ServiceAccountTokenPrefix))
+	assert.NotEqual(t, t1, t2)
+	assert.Equal(t, hashToken(t1), hashToken(t1))
+	assert.NotEqual(t, hashToken(t1), hashToken(t2))
+}
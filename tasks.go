@@ -40,6 +40,21 @@ type Task struct {
 
 	// The constraints of the Process.
 	Constraints Constraints
+
+	// The sidecar containers running alongside this task.
+	Sidecars []*SidecarTask
+}
+
+// SidecarTask represents a sidecar container running alongside a task.
+type SidecarTask struct {
+	// The name of the sidecar.
+	Name string
+
+	// The command that the sidecar is running.
+	Command Command
+
+	// The state of the sidecar.
+	State string
 }
 
 type tasksService struct {
@@ -70,6 +85,15 @@ func taskFromInstance(i *twelvefactor.Task) *Task {
 		version = "v0"
 	}
 
+	var sidecars []*SidecarTask
+	for _, s := range i.Sidecars {
+		sidecars = append(sidecars, &SidecarTask{
+			Name:    s.Name,
+			Command: Command(s.Command),
+			State:   s.State,
+		})
+	}
+
 	return &Task{
 		Name:    fmt.Sprintf("%s.%s.%s", version, i.Process.Type, i.ID),
 		Type:    string(i.Process.Type),
@@ -82,5 +106,6 @@ func taskFromInstance(i *twelvefactor.Task) *Task {
 		},
 		State:     i.State,
 		UpdatedAt: i.UpdatedAt,
+		Sidecars:  sidecars,
 	}
 }
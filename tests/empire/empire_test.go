@@ -1,9 +1,13 @@
 package empire_test
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,9 +15,12 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/jinzhu/gorm"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/empiretest"
+	"github.com/remind101/empire/events/webhook"
 	"github.com/remind101/empire/pkg/image"
+	"github.com/remind101/empire/pkg/jsonmessage"
 	"github.com/remind101/empire/pkg/timex"
 	"github.com/remind101/empire/procfile"
 	"github.com/remind101/empire/twelvefactor"
@@ -180,32 +187,64 @@ func TestEmpire_Deploy(t *testing.T) {
 	s.AssertExpectations(t)
 }
 
-func TestEmpire_Deploy_ImageNotFound(t *testing.T) {
+func TestEmpire_Deploy_ReleaseCommandFailed(t *testing.T) {
 	e := empiretest.NewEmpire(t)
 	s := new(mockScheduler)
 	e.Scheduler = s
-	e.ImageRegistry = empiretest.ExtractProcfile(nil, errors.New("image not found"))
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
+		},
+		"release": procfile.Process{
+			Command: []string{"./bin/migrate"},
+		},
+	}, nil)
 
-	// Deploying an image to an app that doesn't exist will create a new
-	// app.
-	_, err := e.Deploy(context.Background(), empire.DeployOpts{
-		User:   &empire.User{Name: "ejholmes"},
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	// The release command should be run, but the release should never be
+	// submitted.
+	s.On("Run", mock.Anything).Return(&twelvefactor.ExitError{Code: 1})
+
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
 		Output: empire.NewDeploymentStream(ioutil.Discard),
 		Image:  image.Image{Repository: "remind101/acme-inc"},
 	})
-	assert.Error(t, err)
+	assert.EqualError(t, err, "deployment failed: release command failed: exit status 1")
 
-	// If there's an error deploying, then the transaction should be rolled
-	// backed and no apps should exist.
-	apps, err := e.Apps(empire.AppsQuery{})
+	if assert.Equal(t, 1, len(s.Calls)) {
+		m := s.Calls[0].Arguments.Get(0).(*twelvefactor.Manifest)
+		assert.Equal(t, 1, len(m.Processes))
+		assert.Equal(t, "release", m.Processes[0].Type)
+		assert.Equal(t, []string{"./bin/migrate"}, m.Processes[0].Command)
+	}
+
+	releases, err := e.Releases(empire.ReleasesQuery{App: app})
 	assert.NoError(t, err)
-	assert.Equal(t, 0, len(apps))
+	if assert.Equal(t, 1, len(releases)) {
+		assert.True(t, releases[0].Failed)
+	}
 
 	s.AssertExpectations(t)
 }
 
-func TestEmpire_Run(t *testing.T) {
+func TestEmpire_Deploy_ReleaseCommandFailed_PreviousRelease(t *testing.T) {
 	e := empiretest.NewEmpire(t)
+	s := new(mockScheduler)
+	e.Scheduler = s
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
+		},
+	}, nil)
 
 	user := &empire.User{Name: "ejholmes"}
 
@@ -215,76 +254,77 @@ func TestEmpire_Run(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	img := image.Image{Repository: "remind101/acme-inc"}
+	v1 := image.Image{Repository: "remind101/acme-inc", Tag: "v1"}
+	s.On("Submit", mock.Anything).Return(nil)
+
 	_, err = e.Deploy(context.Background(), empire.DeployOpts{
 		App:    app,
 		User:   user,
 		Output: empire.NewDeploymentStream(ioutil.Discard),
-		Image:  img,
+		Image:  v1,
 	})
 	assert.NoError(t, err)
 
-	s := new(mockScheduler)
-	e.Scheduler = s
-
-	s.On("Run", &twelvefactor.Manifest{
-		AppID:   app.ID,
-		Name:    "acme-inc",
-		Release: "v1",
-		Env: map[string]string{
-			"EMPIRE_APPID":   app.ID,
-			"EMPIRE_APPNAME": "acme-inc",
-			"EMPIRE_RELEASE": "v1",
-		},
-		Labels: map[string]string{
-			"empire.app.name":    "acme-inc",
-			"empire.app.id":      app.ID,
-			"empire.app.release": "v1",
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
 		},
-		Processes: []*twelvefactor.Process{
-			&twelvefactor.Process{
-				Type:      "run",
-				Image:     img,
-				Command:   []string{"bundle", "exec", "rake", "db:migrate"},
-				Quantity:  1,
-				Memory:    536870912,
-				CPUShares: 256,
-				Nproc:     256,
-				Env: map[string]string{
-					"EMPIRE_PROCESS":       "run",
-					"EMPIRE_PROCESS_SCALE": "1",
-					"SOURCE":               "acme-inc.run.v1",
-					"TERM":                 "xterm",
-				},
-				Labels: map[string]string{
-					"empire.app.process": "run",
-					"empire.user":        "ejholmes",
-				},
-			},
+		"release": procfile.Process{
+			Command: []string{"./bin/migrate"},
 		},
-	}).Return(nil)
+	}, nil)
 
-	err = e.Run(context.Background(), empire.RunOpts{
-		User:    user,
-		App:     app,
-		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
+	// v2 should never be submitted, and no rollback release should be
+	// created, since v1 is still running.
+	s.On("Run", mock.Anything).Return(&twelvefactor.ExitError{Code: 1})
 
-		// Detached Process
-		Stdin:  nil,
-		Stdout: nil,
-		Stderr: nil,
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+	})
+	assert.EqualError(t, err, "deployment failed: release command failed: exit status 1")
 
-		Env: map[string]string{
-			"TERM": "xterm",
+	releases, err := e.Releases(empire.ReleasesQuery{App: app})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(releases)) {
+		assert.Equal(t, 2, releases[0].Version)
+		assert.True(t, releases[0].Failed)
+		assert.Equal(t, 1, releases[1].Version)
+		assert.False(t, releases[1].Failed)
+	}
+
+	// Changes to the app should be based on v1, which is still running.
+	prod := "production"
+	_, err = e.Set(context.Background(), empire.SetOpts{
+		User: user,
+		App:  app,
+		Vars: empire.Vars{
+			"RAILS_ENV": &prod,
 		},
 	})
 	assert.NoError(t, err)
 
+	var submitted []*twelvefactor.Manifest
+	for _, call := range s.Calls {
+		if call.Method == "Submit" {
+			submitted = append(submitted, call.Arguments.Get(0).(*twelvefactor.Manifest))
+		}
+	}
+	if assert.Equal(t, 2, len(submitted)) {
+		assert.Equal(t, "v1", submitted[0].Release)
+		assert.Equal(t, "v3", submitted[1].Release)
+		assert.Equal(t, v1, submitted[1].Processes[0].Image)
+	}
+
 	s.AssertExpectations(t)
 }
 
-func TestEmpire_Run_WithConstraints(t *testing.T) {
+func TestEmpire_Deploy_Canary_AbortedWithoutUser(t *testing.T) {
 	e := empiretest.NewEmpire(t)
+	s := new(mockScheduler)
+	e.Scheduler = s
 
 	user := &empire.User{Name: "ejholmes"}
 
@@ -294,80 +334,160 @@ func TestEmpire_Run_WithConstraints(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	img := image.Image{Repository: "remind101/acme-inc"}
+	s.On("Submit", mock.Anything).Return(nil).Once()
+
 	_, err = e.Deploy(context.Background(), empire.DeployOpts{
 		App:    app,
 		User:   user,
 		Output: empire.NewDeploymentStream(ioutil.Discard),
-		Image:  img,
+		Image:  image.Image{Repository: "remind101/acme-inc", Tag: "v1"},
 	})
 	assert.NoError(t, err)
 
+	// The canary fails to stabilize, and is aborted by rolling back to v1.
+	s.On("Submit", mock.Anything).Return(&twelvefactor.DeploymentError{Reason: "unstable"}).Once()
+	s.On("Submit", mock.Anything).Return(nil).Once()
+
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:          app,
+		Output:       empire.NewDeploymentStream(ioutil.Discard),
+		Image:        image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+		CanaryWeight: 10,
+	})
+	assert.EqualError(t, err, "deployment failed: unstable")
+
+	_, err = e.CanariesFind(empire.CanariesQuery{App: app})
+	assert.Equal(t, gorm.RecordNotFound, err)
+
+	s.AssertExpectations(t)
+}
+
+func TestEmpire_Deploy_ReleaseCommand_Pending(t *testing.T) {
+	e := empiretest.NewEmpire(t)
 	s := new(mockScheduler)
 	e.Scheduler = s
-
-	s.On("Run", &twelvefactor.Manifest{
-		AppID:   app.ID,
-		Name:    "acme-inc",
-		Release: "v1",
-		Env: map[string]string{
-			"EMPIRE_APPID":   app.ID,
-			"EMPIRE_APPNAME": "acme-inc",
-			"EMPIRE_RELEASE": "v1",
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
 		},
-		Labels: map[string]string{
-			"empire.app.name":    "acme-inc",
-			"empire.app.id":      app.ID,
-			"empire.app.release": "v1",
+	}, nil)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	s.On("Submit", mock.Anything).Return(nil)
+
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  image.Image{Repository: "remind101/acme-inc", Tag: "v1"},
+	})
+	assert.NoError(t, err)
+
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
 		},
-		Processes: []*twelvefactor.Process{
-			&twelvefactor.Process{
-				Type:      "run",
-				Image:     img,
-				Command:   []string{"bundle", "exec", "rake", "db:migrate"},
-				Quantity:  1,
-				Memory:    1073741824,
-				CPUShares: 512,
-				Nproc:     512,
-				Env: map[string]string{
-					"EMPIRE_PROCESS":       "run",
-					"EMPIRE_PROCESS_SCALE": "1",
-					"SOURCE":               "acme-inc.run.v1",
-					"TERM":                 "xterm",
-				},
-				Labels: map[string]string{
-					"empire.app.process": "run",
-					"empire.user":        "ejholmes",
-				},
-			},
+		"release": procfile.Process{
+			Command: []string{"./bin/migrate"},
 		},
+	}, nil)
+
+	// While the release command runs, v2 is pending, and v1 is still the
+	// current release.
+	s.On("Run", mock.Anything).Run(func(mock.Arguments) {
+		releases, err := e.Releases(empire.ReleasesQuery{App: app})
+		assert.NoError(t, err)
+		if assert.Equal(t, 2, len(releases)) {
+			assert.True(t, releases[0].Pending)
+		}
+
+		f, err := e.ListScale(context.Background(), app)
+		assert.NoError(t, err)
+		_, ok := f["release"]
+		assert.False(t, ok)
 	}).Return(nil)
 
-	constraints := empire.NamedConstraints["2X"]
-	err = e.Run(context.Background(), empire.RunOpts{
-		User:    user,
-		App:     app,
-		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
+	r, err := e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, r.Version)
+	assert.False(t, r.Pending)
 
-		// Detached Process
-		Stdin:  nil,
-		Stdout: nil,
-		Stderr: nil,
+	f, err := e.ListScale(context.Background(), app)
+	assert.NoError(t, err)
+	_, ok := f["release"]
+	assert.True(t, ok)
 
-		Env: map[string]string{
-			"TERM": "xterm",
-		},
+	s.AssertExpectations(t)
+}
 
-		Constraints: &constraints,
+func TestEmpire_DeployAsync(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	s := new(mockScheduler)
+	e.Scheduler = s
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	s.On("Submit", mock.Anything).Return(nil)
+
+	d, err := e.DeployAsync(context.Background(), empire.DeployOpts{
+		App:   app,
+		User:  user,
+		Image: image.Image{Repository: "remind101/acme-inc", Tag: "latest"},
 	})
 	assert.NoError(t, err)
+	assert.Equal(t, empire.DeploymentQueued, d.State)
+	assert.Equal(t, "remind101/acme-inc:latest", d.Image)
+
+	// Wait for the deployment to finish in the background.
+	timeout := time.After(5 * time.Second)
+	for !d.State.Finished() {
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for deployment to finish, state: %s", d.State)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		d, err = e.DeploymentsFind(empire.DeploymentsQuery{ID: &d.ID, App: app})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, empire.DeploymentSucceeded, d.State)
+	assert.Equal(t, "", d.Error)
+	if assert.NotNil(t, d.Version) {
+		assert.Equal(t, 1, *d.Version)
+	}
+	assert.NotNil(t, d.FinishedAt)
+	assert.NotEmpty(t, d.Messages)
 
 	s.AssertExpectations(t)
 }
 
-func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
+func TestEmpire_CancelDeployment(t *testing.T) {
 	e := empiretest.NewEmpire(t)
-	e.AllowedCommands = empire.AllowCommandProcfile
+	s := &blockingScheduler{
+		Scheduler: empire.NewFakeScheduler(),
+		Release:   "v2",
+		submitted: make(chan struct{}),
+	}
+	e.Scheduler = s
 
 	user := &empire.User{Name: "ejholmes"}
 
@@ -377,7 +497,8 @@ func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	img := image.Image{Repository: "remind101/acme-inc"}
+	img := image.Image{Repository: "remind101/acme-inc", Tag: "latest"}
+
 	_, err = e.Deploy(context.Background(), empire.DeployOpts{
 		App:    app,
 		User:   user,
@@ -386,42 +507,248 @@ func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	s := new(mockScheduler)
-	e.Scheduler = s
+	d, err := e.DeployAsync(context.Background(), empire.DeployOpts{
+		App:   app,
+		User:  user,
+		Image: img,
+	})
+	assert.NoError(t, err)
 
-	err = e.Run(context.Background(), empire.RunOpts{
+	select {
+	case <-s.submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for v2 to be submitted")
+	}
+
+	cancelled, err := e.CancelDeployment(context.Background(), empire.CancelDeploymentOpts{
 		User:    user,
 		App:     app,
-		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
+		Message: "wrong tag",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, d.ID, cancelled.ID)
+	assert.Equal(t, empire.DeploymentCancelled, cancelled.State)
+	assert.Equal(t, "cancelled by ejholmes: wrong tag", cancelled.Error)
+	assert.True(t, s.cancelled)
+
+	// Wait for the deployment to stop in the background.
+	timeout := time.After(5 * time.Second)
+	for d.FinishedAt == nil {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for deployment to stop")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		d, err = e.DeploymentsFind(empire.DeploymentsQuery{ID: &d.ID, App: app})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, empire.DeploymentCancelled, d.State)
 
-		// Detached Process
-		Stdin:  nil,
-		Stdout: nil,
-		Stderr: nil,
+	// The cancelled release should be marked as failed, and the app rolled
+	// back to v1.
+	releases, err := e.Releases(empire.ReleasesQuery{App: app})
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(releases)) {
+		assert.Equal(t, 3, releases[0].Version)
+		assert.Equal(t, "Rollback to v1 (ejholmes: 'v2 failed: deployment was cancelled')", releases[0].Description)
+		assert.True(t, releases[1].Failed)
+	}
 
-		Env: map[string]string{
-			"TERM": "xterm",
-		},
+	// Deployments that have finished can't be cancelled.
+	_, err = e.CancelDeployment(context.Background(), empire.CancelDeploymentOpts{
+		User: user,
+		App:  app,
+		ID:   &d.ID,
 	})
-	assert.IsType(t, &empire.CommandNotAllowedError{}, err)
+	assert.IsType(t, &empire.ValidationError{}, err)
+}
 
-	s.On("Run", &twelvefactor.Manifest{
-		AppID:   app.ID,
-		Name:    "acme-inc",
-		Release: "v1",
+func TestEmpire_CancelDeployment_BeforeSubmit(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	s := &blockingScheduler{
+		Scheduler: empire.NewFakeScheduler(),
+		Release:   "v2",
+		submitted: make(chan struct{}),
+	}
+	e.Scheduler = s
+	r := &blockingRegistry{
+		ImageRegistry: e.ImageRegistry,
+		Tag:           "v2",
+		extracting:    make(chan struct{}),
+	}
+	e.ImageRegistry = r
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  image.Image{Repository: "remind101/acme-inc", Tag: "v1"},
+	})
+	assert.NoError(t, err)
+
+	d, err := e.DeployAsync(context.Background(), empire.DeployOpts{
+		App:   app,
+		User:  user,
+		Image: image.Image{Repository: "remind101/acme-inc", Tag: "v2"},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-r.extracting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the image to be pulled")
+	}
+
+	cancelled, err := e.CancelDeployment(context.Background(), empire.CancelDeploymentOpts{
+		User: user,
+		App:  app,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, d.ID, cancelled.ID)
+	if assert.NotNil(t, cancelled.CancelledState) {
+		assert.Equal(t, empire.DeploymentBuilding, *cancelled.CancelledState)
+	}
+
+	// Nothing was submitted, so the scheduler isn't asked to cancel.
+	assert.False(t, s.cancelled)
+
+	// Wait for the deployment to stop in the background.
+	timeout := time.After(5 * time.Second)
+	for d.FinishedAt == nil {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for deployment to stop")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		d, err = e.DeploymentsFind(empire.DeploymentsQuery{ID: &d.ID, App: app})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, empire.DeploymentCancelled, d.State)
+
+	// No release was created, so there's nothing to roll back.
+	releases, err := e.Releases(empire.ReleasesQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(releases))
+}
+
+func TestEmpire_FailOrphanedDeployments(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	defer func() {
+		timex.Now = func() time.Time {
+			return fakeNow
+		}
+	}()
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	orphaned := &empire.Deployment{AppID: app.ID, Image: "remind101/acme-inc:v1", State: empire.DeploymentStabilizing}
+	assert.NoError(t, e.DB.Create(orphaned).Error)
+
+	timex.Now = func() time.Time {
+		return fakeNow.Add(4 * time.Minute)
+	}
+	running := &empire.Deployment{AppID: app.ID, Image: "remind101/acme-inc:v2", State: empire.DeploymentBuilding}
+	assert.NoError(t, e.DB.Create(running).Error)
+
+	// Deployments that haven't been saved for 5 minutes are orphaned.
+	timex.Now = func() time.Time {
+		return fakeNow.Add(6 * time.Minute)
+	}
+	n, err := e.FailOrphanedDeployments(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	d, err := e.DeploymentsFind(empire.DeploymentsQuery{ID: &orphaned.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, empire.DeploymentFailed, d.State)
+	assert.Equal(t, empire.ErrDeploymentOrphaned.Error(), d.Error)
+	assert.NotNil(t, d.FinishedAt)
+
+	d, err = e.DeploymentsFind(empire.DeploymentsQuery{ID: &running.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, empire.DeploymentBuilding, d.State)
+}
+
+func TestEmpire_Deploy_ImageNotFound(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	s := new(mockScheduler)
+	e.Scheduler = s
+	e.ImageRegistry = empiretest.ExtractProcfile(nil, errors.New("image not found"))
+
+	// Deploying an image to an app that doesn't exist will create a new
+	// app.
+	_, err := e.Deploy(context.Background(), empire.DeployOpts{
+		User:   &empire.User{Name: "ejholmes"},
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  image.Image{Repository: "remind101/acme-inc"},
+	})
+	assert.Error(t, err)
+
+	// If there's an error deploying, then the transaction should be rolled
+	// backed and no apps should exist.
+	apps, err := e.Apps(empire.AppsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(apps))
+
+	s.AssertExpectations(t)
+}
+
+func TestEmpire_Run(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	img := image.Image{Repository: "remind101/acme-inc"}
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  img,
+	})
+	assert.NoError(t, err)
+
+	s := new(mockScheduler)
+	e.Scheduler = s
+
+	s.On("Run", &twelvefactor.Manifest{
+		AppID:   app.ID,
+		Name:    "acme-inc",
+		Release: "v1",
 		Env: map[string]string{
 			"EMPIRE_APPID":   app.ID,
 			"EMPIRE_APPNAME": "acme-inc",
 			"EMPIRE_RELEASE": "v1",
 		},
 		Labels: map[string]string{
-			"empire.app.id":      app.ID,
 			"empire.app.name":    "acme-inc",
+			"empire.app.id":      app.ID,
 			"empire.app.release": "v1",
 		},
 		Processes: []*twelvefactor.Process{
 			&twelvefactor.Process{
-				Type:      "rake",
+				Type:      "run",
 				Image:     img,
 				Command:   []string{"bundle", "exec", "rake", "db:migrate"},
 				Quantity:  1,
@@ -429,24 +756,15 @@ func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
 				CPUShares: 256,
 				Nproc:     256,
 				Env: map[string]string{
-					"EMPIRE_PROCESS":       "rake",
+					"EMPIRE_PROCESS":       "run",
 					"EMPIRE_PROCESS_SCALE": "1",
-					"SOURCE":               "acme-inc.rake.v1",
+					"SOURCE":               "acme-inc.run.v1",
 					"TERM":                 "xterm",
 				},
 				Labels: map[string]string{
-					"empire.app.process": "rake",
+					"empire.app.process": "run",
 					"empire.user":        "ejholmes",
 				},
-				ECS: &procfile.ECS{
-					PlacementConstraints: []*ecs.PlacementConstraint{
-						&ecs.PlacementConstraint{
-							Type:       aws.String("memberOf"),
-							Expression: aws.String("attribute:profile = background"),
-						},
-					},
-					PlacementStrategy: []*ecs.PlacementStrategy{},
-				},
 			},
 		},
 	}).Return(nil)
@@ -454,7 +772,7 @@ func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
 	err = e.Run(context.Background(), empire.RunOpts{
 		User:    user,
 		App:     app,
-		Command: empire.MustParseCommand("rake db:migrate"),
+		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
 
 		// Detached Process
 		Stdin:  nil,
@@ -470,39 +788,30 @@ func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
 	s.AssertExpectations(t)
 }
 
-func TestEmpire_Set(t *testing.T) {
+func TestEmpire_Run_WithConstraints(t *testing.T) {
 	e := empiretest.NewEmpire(t)
-	s := new(mockScheduler)
-	e.Scheduler = s
-	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
-		"web": procfile.Process{
-			Command: []string{"./bin/web"},
-		},
-	}, nil)
 
 	user := &empire.User{Name: "ejholmes"}
 
-	// Create an app
 	app, err := e.Create(context.Background(), empire.CreateOpts{
 		User: user,
 		Name: "acme-inc",
 	})
 	assert.NoError(t, err)
 
-	// Add some environment variables to it.
-	prod := "production"
-	_, err = e.Set(context.Background(), empire.SetOpts{
-		User: user,
-		App:  app,
-		Vars: empire.Vars{
-			"RAILS_ENV": &prod,
-		},
+	img := image.Image{Repository: "remind101/acme-inc"}
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  img,
 	})
 	assert.NoError(t, err)
 
-	// Deploy a new image to the app.
-	img := image.Image{Repository: "remind101/acme-inc"}
-	s.On("Submit", &twelvefactor.Manifest{
+	s := new(mockScheduler)
+	e.Scheduler = s
+
+	s.On("Run", &twelvefactor.Manifest{
 		AppID:   app.ID,
 		Name:    "acme-inc",
 		Release: "v1",
@@ -510,7 +819,6 @@ func TestEmpire_Set(t *testing.T) {
 			"EMPIRE_APPID":   app.ID,
 			"EMPIRE_APPNAME": "acme-inc",
 			"EMPIRE_RELEASE": "v1",
-			"RAILS_ENV":      "production",
 		},
 		Labels: map[string]string{
 			"empire.app.name":    "acme-inc",
@@ -518,36 +826,63 @@ func TestEmpire_Set(t *testing.T) {
 			"empire.app.release": "v1",
 		},
 		Processes: []*twelvefactor.Process{
-			{
-				Type:    "web",
-				Image:   img,
-				Command: []string{"./bin/web"},
-				Exposure: &twelvefactor.Exposure{
-					Ports: []twelvefactor.Port{
-						{
-							Container: 8080,
-							Host:      80,
-							Protocol:  &twelvefactor.HTTP{},
-						},
-					},
-				},
+			&twelvefactor.Process{
+				Type:      "run",
+				Image:     img,
+				Command:   []string{"bundle", "exec", "rake", "db:migrate"},
 				Quantity:  1,
-				Memory:    536870912,
-				CPUShares: 256,
-				Nproc:     256,
+				Memory:    1073741824,
+				CPUShares: 512,
+				Nproc:     512,
 				Env: map[string]string{
-					"EMPIRE_PROCESS":       "web",
+					"EMPIRE_PROCESS":       "run",
 					"EMPIRE_PROCESS_SCALE": "1",
-					"SOURCE":               "acme-inc.web.v1",
-					"PORT":                 "8080",
+					"SOURCE":               "acme-inc.run.v1",
+					"TERM":                 "xterm",
 				},
 				Labels: map[string]string{
-					"empire.app.process": "web",
+					"empire.app.process": "run",
+					"empire.user":        "ejholmes",
 				},
 			},
 		},
-	}).Once().Return(nil)
+	}).Return(nil)
+
+	constraints := empire.NamedConstraints["2X"]
+	err = e.Run(context.Background(), empire.RunOpts{
+		User:    user,
+		App:     app,
+		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
+
+		// Detached Process
+		Stdin:  nil,
+		Stdout: nil,
+		Stderr: nil,
+
+		Env: map[string]string{
+			"TERM": "xterm",
+		},
+
+		Constraints: &constraints,
+	})
+	assert.NoError(t, err)
+
+	s.AssertExpectations(t)
+}
+
+func TestEmpire_Run_WithAllowCommandProcfile(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	e.AllowedCommands = empire.AllowCommandProcfile
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
 
+	img := image.Image{Repository: "remind101/acme-inc"}
 	_, err = e.Deploy(context.Background(), empire.DeployOpts{
 		App:    app,
 		User:   user,
@@ -556,15 +891,185 @@ func TestEmpire_Set(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	// Remove the environment variable
-	s.On("Submit", &twelvefactor.Manifest{
-		AppID:   app.ID,
-		Name:    "acme-inc",
-		Release: "v2",
+	s := new(mockScheduler)
+	e.Scheduler = s
+
+	err = e.Run(context.Background(), empire.RunOpts{
+		User:    user,
+		App:     app,
+		Command: empire.MustParseCommand("bundle exec rake db:migrate"),
+
+		// Detached Process
+		Stdin:  nil,
+		Stdout: nil,
+		Stderr: nil,
+
 		Env: map[string]string{
-			"EMPIRE_APPID":   app.ID,
-			"EMPIRE_APPNAME": "acme-inc",
-			"EMPIRE_RELEASE": "v2",
+			"TERM": "xterm",
+		},
+	})
+	assert.IsType(t, &empire.CommandNotAllowedError{}, err)
+
+	s.On("Run", &twelvefactor.Manifest{
+		AppID:   app.ID,
+		Name:    "acme-inc",
+		Release: "v1",
+		Env: map[string]string{
+			"EMPIRE_APPID":   app.ID,
+			"EMPIRE_APPNAME": "acme-inc",
+			"EMPIRE_RELEASE": "v1",
+		},
+		Labels: map[string]string{
+			"empire.app.id":      app.ID,
+			"empire.app.name":    "acme-inc",
+			"empire.app.release": "v1",
+		},
+		Processes: []*twelvefactor.Process{
+			&twelvefactor.Process{
+				Type:      "rake",
+				Image:     img,
+				Command:   []string{"bundle", "exec", "rake", "db:migrate"},
+				Quantity:  1,
+				Memory:    536870912,
+				CPUShares: 256,
+				Nproc:     256,
+				Env: map[string]string{
+					"EMPIRE_PROCESS":       "rake",
+					"EMPIRE_PROCESS_SCALE": "1",
+					"SOURCE":               "acme-inc.rake.v1",
+					"TERM":                 "xterm",
+				},
+				Labels: map[string]string{
+					"empire.app.process": "rake",
+					"empire.user":        "ejholmes",
+				},
+				ECS: &procfile.ECS{
+					PlacementConstraints: []*ecs.PlacementConstraint{
+						&ecs.PlacementConstraint{
+							Type:       aws.String("memberOf"),
+							Expression: aws.String("attribute:profile = background"),
+						},
+					},
+					PlacementStrategy: []*ecs.PlacementStrategy{},
+				},
+			},
+		},
+	}).Return(nil)
+
+	err = e.Run(context.Background(), empire.RunOpts{
+		User:    user,
+		App:     app,
+		Command: empire.MustParseCommand("rake db:migrate"),
+
+		// Detached Process
+		Stdin:  nil,
+		Stdout: nil,
+		Stderr: nil,
+
+		Env: map[string]string{
+			"TERM": "xterm",
+		},
+	})
+	assert.NoError(t, err)
+
+	s.AssertExpectations(t)
+}
+
+func TestEmpire_Set(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	s := new(mockScheduler)
+	e.Scheduler = s
+	e.ImageRegistry = empiretest.ExtractProcfile(procfile.ExtendedProcfile{
+		"web": procfile.Process{
+			Command: []string{"./bin/web"},
+		},
+	}, nil)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	// Create an app
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	// Add some environment variables to it.
+	prod := "production"
+	_, err = e.Set(context.Background(), empire.SetOpts{
+		User: user,
+		App:  app,
+		Vars: empire.Vars{
+			"RAILS_ENV": &prod,
+		},
+	})
+	assert.NoError(t, err)
+
+	// Deploy a new image to the app.
+	img := image.Image{Repository: "remind101/acme-inc"}
+	s.On("Submit", &twelvefactor.Manifest{
+		AppID:   app.ID,
+		Name:    "acme-inc",
+		Release: "v1",
+		Env: map[string]string{
+			"EMPIRE_APPID":   app.ID,
+			"EMPIRE_APPNAME": "acme-inc",
+			"EMPIRE_RELEASE": "v1",
+			"RAILS_ENV":      "production",
+		},
+		Labels: map[string]string{
+			"empire.app.name":    "acme-inc",
+			"empire.app.id":      app.ID,
+			"empire.app.release": "v1",
+		},
+		Processes: []*twelvefactor.Process{
+			{
+				Type:    "web",
+				Image:   img,
+				Command: []string{"./bin/web"},
+				Exposure: &twelvefactor.Exposure{
+					Ports: []twelvefactor.Port{
+						{
+							Container: 8080,
+							Host:      80,
+							Protocol:  &twelvefactor.HTTP{},
+						},
+					},
+				},
+				Quantity:  1,
+				Memory:    536870912,
+				CPUShares: 256,
+				Nproc:     256,
+				Env: map[string]string{
+					"EMPIRE_PROCESS":       "web",
+					"EMPIRE_PROCESS_SCALE": "1",
+					"SOURCE":               "acme-inc.web.v1",
+					"PORT":                 "8080",
+				},
+				Labels: map[string]string{
+					"empire.app.process": "web",
+				},
+			},
+		},
+	}).Once().Return(nil)
+
+	_, err = e.Deploy(context.Background(), empire.DeployOpts{
+		App:    app,
+		User:   user,
+		Output: empire.NewDeploymentStream(ioutil.Discard),
+		Image:  img,
+	})
+	assert.NoError(t, err)
+
+	// Remove the environment variable
+	s.On("Submit", &twelvefactor.Manifest{
+		AppID:   app.ID,
+		Name:    "acme-inc",
+		Release: "v2",
+		Env: map[string]string{
+			"EMPIRE_APPID":   app.ID,
+			"EMPIRE_APPNAME": "acme-inc",
+			"EMPIRE_RELEASE": "v2",
 		},
 		Labels: map[string]string{
 			"empire.app.name":    "acme-inc",
@@ -614,11 +1119,665 @@ func TestEmpire_Set(t *testing.T) {
 	s.AssertExpectations(t)
 }
 
+func TestEmpire_DeliverEvents(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	defer func() {
+		timex.Now = func() time.Time {
+			return fakeNow
+		}
+	}()
+
+	var (
+		published []empire.Event
+		streamErr = errors.New("stream unavailable")
+	)
+	e.EventStream = empire.EventStreamFunc(func(event empire.Event) error {
+		if streamErr != nil {
+			return streamErr
+		}
+		published = append(published, event)
+		return nil
+	})
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	// The first attempt fails, so the event is retried later.
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "create", events[0].Name)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.Equal(t, "stream unavailable", *events[0].LastError)
+	assert.Equal(t, fakeNow.Add(5*time.Second), events[0].NextAttemptAt.UTC())
+
+	// The event isn't due yet.
+	streamErr = nil
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(published))
+
+	timex.Now = func() time.Time {
+		return fakeNow.Add(5 * time.Second)
+	}
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(published))
+	assert.Equal(t, "create", published[0].Event())
+	assert.Equal(t, "ejholmes created acme-inc", published[0].String())
+
+	events, err = e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(events))
+}
+
+func TestEmpire_DeliverEvents_DeadLetter(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	now := fakeNow
+	timex.Now = func() time.Time {
+		return now
+	}
+	defer func() {
+		timex.Now = func() time.Time {
+			return fakeNow
+		}
+	}()
+
+	e.EventStream = empire.EventStreamFunc(func(event empire.Event) error {
+		return errors.New("stream unavailable")
+	})
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 15; i++ {
+		err = e.DeliverEvents(context.Background())
+		assert.NoError(t, err)
+		now = now.Add(time.Hour)
+	}
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 15, events[0].Attempts)
+	assert.True(t, events[0].Dead())
+
+	// Dead-lettered events aren't retried.
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+
+	event, err := e.OutboxEventsFind(empire.OutboxEventsQuery{ID: &events[0].ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 15, event.Attempts)
+
+	// Until they're requeued.
+	event, err = e.RetryOutboxEvent(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.False(t, event.Dead())
+	assert.Equal(t, 0, event.Attempts)
+
+	_, err = e.RetryOutboxEvent(context.Background(), event.ID)
+	assert.IsType(t, &empire.ValidationError{}, err)
+}
+
+func TestEmpire_DeliverEvents_PerStream(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	defer func() {
+		timex.Now = func() time.Time {
+			return fakeNow
+		}
+	}()
+
+	var (
+		a, b      int
+		streamErr = errors.New("stream unavailable")
+	)
+	e.EventStream = empire.MultiEventStream{
+		empire.NamedEventStream{Name: "a", EventStream: empire.EventStreamFunc(func(event empire.Event) error {
+			a++
+			return nil
+		})},
+		empire.NamedEventStream{Name: "b", EventStream: empire.EventStreamFunc(func(event empire.Event) error {
+			if streamErr != nil {
+				return streamErr
+			}
+			b++
+			return nil
+		})},
+	}
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, 0, b)
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+
+	deliveries, err := e.OutboxDeliveries(empire.OutboxDeliveriesQuery{EventID: &events[0].ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(deliveries))
+	assert.Equal(t, "a", deliveries[0].Stream)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+	assert.Equal(t, "b", deliveries[1].Stream)
+	assert.Nil(t, deliveries[1].DeliveredAt)
+	assert.Equal(t, 1, deliveries[1].Attempts)
+	assert.Equal(t, "stream unavailable", *deliveries[1].LastError)
+
+	// The retry is only delivered to the stream that failed.
+	streamErr = nil
+	timex.Now = func() time.Time {
+		return fakeNow.Add(5 * time.Second)
+	}
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, 1, b)
+
+	events, err = e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(events))
+}
+
+func TestEmpire_DeliverEvents_Webhook(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	var (
+		id   string
+		body []byte
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = r.Header.Get(webhook.EventIDHeader)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer s.Close()
+
+	e.EventStream = webhook.NewEventStream([]webhook.Endpoint{{URL: s.URL}})
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+
+	// Receivers can use the id of the outbox event to ignore events that
+	// are delivered more than once.
+	assert.Equal(t, events[0].ID, id)
+
+	var payload webhook.Event
+	assert.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, events[0].ID, payload.ID)
+	assert.Equal(t, "create", payload.Event)
+}
+
+func TestEmpire_AuditEvents(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+	defer func() {
+		timex.Now = func() time.Time {
+			return fakeNow
+		}
+	}()
+
+	// The second stream fails the first time, so delivery of the event is
+	// retried. The audit log has already recorded it, so it's not recorded
+	// again.
+	failed := false
+	e.EventStream = empire.MultiEventStream{
+		empire.NewAuditLog(e.DB),
+		empire.EventStreamFunc(func(event empire.Event) error {
+			if !failed {
+				failed = true
+				return errors.New("stream unavailable")
+			}
+			return nil
+		}),
+	}
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	err = e.SetAutoRollback(context.Background(), empire.SetAutoRollbackOpts{
+		User:         &empire.User{Name: "bob"},
+		App:          app,
+		AutoRollback: true,
+	})
+	assert.NoError(t, err)
+
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+
+	timex.Now = func() time.Time {
+		return fakeNow.Add(time.Minute)
+	}
+	err = e.DeliverEvents(context.Background())
+	assert.NoError(t, err)
+
+	events, err := e.AuditEvents(empire.AuditEventsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+
+	appName := "acme-inc"
+	events, err = e.AuditEvents(empire.AuditEventsQuery{App: &appName, Event: aws.String("create")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "ejholmes created acme-inc", events[0].Message)
+	assert.Equal(t, "ejholmes", *events[0].UserName)
+	assert.Equal(t, app.ID, *events[0].AppID)
+
+	events, err = e.AuditEvents(empire.AuditEventsQuery{User: aws.String("bob")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "auto_rollback", events[0].Event)
+
+	until := fakeNow
+	events, err = e.AuditEvents(empire.AuditEventsQuery{Until: &until})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(events))
+}
+
+func TestEmpire_Notifications(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	_, err = e.CreateNotification(context.Background(), empire.CreateNotificationOpts{
+		User:   user,
+		App:    app,
+		Kind:   "webhook",
+		Target: "ftp://example.com",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	n, err := e.CreateNotification(context.Background(), empire.CreateNotificationOpts{
+		User:   user,
+		App:    app,
+		Kind:   "webhook",
+		Target: "https://example.com/hooks/empire",
+		Events: []string{"deploy"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 64, len(n.Secret))
+
+	_, err = e.CreateNotification(context.Background(), empire.CreateNotificationOpts{
+		User:   user,
+		App:    app,
+		Kind:   "webhook",
+		Target: "http://169.254.169.254/latest/meta-data",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	_, err = e.CreateNotification(context.Background(), empire.CreateNotificationOpts{
+		User:   user,
+		App:    app,
+		Kind:   "sns",
+		Target: "arn:aws:sns:us-east-1:123456789012:acme-inc",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	ns, err := e.Notifications(empire.NotificationsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ns))
+	assert.Equal(t, "https://example.com/hooks/empire", ns[0].Target)
+	assert.Equal(t, empire.EventNames{"deploy"}, ns[0].Events)
+	assert.Equal(t, n.Secret, ns[0].Secret)
+
+	err = e.DestroyNotification(context.Background(), empire.DestroyNotificationOpts{
+		User:         user,
+		Notification: n,
+	})
+	assert.NoError(t, err)
+
+	ns, err = e.Notifications(empire.NotificationsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ns))
+}
+
+func TestEmpire_LogDrains(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	_, err = e.CreateLogDrain(context.Background(), empire.CreateLogDrainOpts{
+		User: user,
+		App:  app,
+		URL:  "syslog://logs.example.com",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	d, err := e.CreateLogDrain(context.Background(), empire.CreateLogDrainOpts{
+		User: user,
+		App:  app,
+		URL:  "syslog+tls://logs.example.com:6514",
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", d.Token)
+
+	_, err = e.CreateLogDrain(context.Background(), empire.CreateLogDrainOpts{
+		User: user,
+		App:  app,
+		URL:  "syslog+tls://logs.example.com:6514",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	ds, err := e.LogDrains(empire.LogDrainsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ds))
+	assert.Equal(t, "syslog+tls://logs.example.com:6514", ds[0].URL)
+	assert.Equal(t, "acme-inc", ds[0].App.Name)
+
+	err = e.DestroyLogDrain(context.Background(), empire.DestroyLogDrainOpts{
+		User:     user,
+		LogDrain: d,
+	})
+	assert.NoError(t, err)
+
+	ds, err = e.LogDrains(empire.LogDrainsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ds))
+}
+
+func TestEmpire_Collaborators(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	app, err := e.Create(context.Background(), empire.CreateOpts{
+		User: user,
+		Name: "acme-inc",
+	})
+	assert.NoError(t, err)
+
+	// The user that created the app is its first collaborator.
+	cs, err := e.Collaborators(empire.CollaboratorsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cs))
+	assert.Equal(t, "ejholmes", cs[0].UserName)
+
+	c, err := e.CreateCollaborator(context.Background(), empire.CreateCollaboratorOpts{
+		User:     user,
+		App:      app,
+		UserName: "mwildehahn",
+	})
+	assert.NoError(t, err)
+
+	_, err = e.CreateCollaborator(context.Background(), empire.CreateCollaboratorOpts{
+		User:     user,
+		App:      app,
+		UserName: "mwildehahn",
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	cs, err = e.Collaborators(empire.CollaboratorsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cs))
+	assert.Equal(t, "mwildehahn", cs[1].UserName)
+	assert.Equal(t, "acme-inc", cs[1].App.Name)
+
+	err = e.DestroyCollaborator(context.Background(), empire.DestroyCollaboratorOpts{
+		User:         user,
+		Collaborator: c,
+	})
+	assert.NoError(t, err)
+
+	cs, err = e.Collaborators(empire.CollaboratorsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cs))
+
+	// The last collaborator can't be removed.
+	err = e.DestroyCollaborator(context.Background(), empire.DestroyCollaboratorOpts{
+		User:         user,
+		Collaborator: cs[0],
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	cs, err = e.Collaborators(empire.CollaboratorsQuery{App: app})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cs))
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	var messages []string
+	for _, event := range events {
+		messages = append(messages, event.Message)
+	}
+	assert.Equal(t, []string{
+		"ejholmes created acme-inc",
+		"ejholmes added mwildehahn as a collaborator on acme-inc",
+		"ejholmes removed mwildehahn as a collaborator on acme-inc",
+	}, messages)
+}
+
+func TestEmpire_Policies(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.SetPolicy(context.Background(), empire.SetPolicyOpts{
+		User:     user,
+		Name:     "developers",
+		Document: `{`,
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	p, err := e.SetPolicy(context.Background(), empire.SetPolicyOpts{
+		User:     user,
+		Name:     "developers",
+		Document: `{"statements":[]}`,
+	})
+	assert.NoError(t, err)
+
+	_, err = e.SetPolicy(context.Background(), empire.SetPolicyOpts{
+		User:     user,
+		Name:     "developers",
+		Document: `{"statements":[{"effect":"allow","principals":["*"],"actions":["*"]}]}`,
+	})
+	assert.NoError(t, err)
+
+	ps, err := e.Policies(empire.PoliciesQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ps))
+	assert.Equal(t, p.ID, ps[0].ID)
+	assert.Equal(t, `{"statements":[{"effect":"allow","principals":["*"],"actions":["*"]}]}`, ps[0].Document)
+
+	err = e.DestroyPolicy(context.Background(), empire.DestroyPolicyOpts{
+		User:   user,
+		Policy: ps[0],
+	})
+	assert.NoError(t, err)
+
+	ps, err = e.Policies(empire.PoliciesQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ps))
+}
+
+func TestEmpire_ServiceAccounts(t *testing.T) {
+	e := empiretest.NewEmpire(t)
+
+	user := &empire.User{Name: "ejholmes"}
+
+	_, err := e.CreateServiceAccount(context.Background(), empire.CreateServiceAccountOpts{
+		User:   user,
+		Name:   "ci",
+		Scopes: empire.Scopes{"app:acme-*"},
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	s, err := e.CreateServiceAccount(context.Background(), empire.CreateServiceAccountOpts{
+		User:   user,
+		Name:   "ci",
+		Scopes: empire.Scopes{"deploy", "app:acme-*"},
+		Groups: []string{"engineering"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(s.Token, empire.ServiceAccountTokenPrefix))
+
+	// Without an expiration, the token expires after the maximum.
+	assert.NotNil(t, s.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(empire.DefaultServiceAccountExpiration), *s.ExpiresAt, time.Minute)
+
+	// A service account can't create one with more scopes than it has, or
+	// that outlives it.
+	_, err = e.CreateServiceAccount(context.Background(), empire.CreateServiceAccountOpts{
+		User:          s.User(),
+		Name:          "ci-global",
+		Scopes:        empire.Scopes{"global"},
+		CreatorScopes: s.Scopes,
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	exp := time.Now().Add(time.Hour)
+	s2, err := e.CreateServiceAccount(context.Background(), empire.CreateServiceAccountOpts{
+		User:             s.User(),
+		Name:             "ci-deploy",
+		Scopes:           empire.Scopes{"deploy", "app:acme-*"},
+		CreatorScopes:    s.Scopes,
+		CreatorExpiresAt: &exp,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, exp, *s2.ExpiresAt)
+
+	err = e.DestroyServiceAccount(context.Background(), empire.DestroyServiceAccountOpts{
+		User:           user,
+		ServiceAccount: s2,
+	})
+	assert.NoError(t, err)
+
+	_, err = e.CreateServiceAccount(context.Background(), empire.CreateServiceAccountOpts{
+		User:   user,
+		Name:   "ci",
+		Scopes: empire.Scopes{"read"},
+	})
+	assert.IsType(t, &empire.ValidationError{}, err)
+
+	a, err := e.AuthenticateServiceAccount(s.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, a.ID)
+	assert.Equal(t, empire.Scopes{"deploy", "app:acme-*"}, a.Scopes)
+	assert.Equal(t, empire.Groups{"engineering"}, a.Groups)
+	assert.NotNil(t, a.LastUsedAt)
+
+	ss, err := e.ServiceAccounts(empire.ServiceAccountsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ss))
+	assert.Equal(t, "", ss[0].Token)
+	assert.Equal(t, "ejholmes", ss[0].CreatedBy)
+	assert.NotNil(t, ss[0].LastUsedAt)
+
+	err = e.DestroyServiceAccount(context.Background(), empire.DestroyServiceAccountOpts{
+		User:           user,
+		ServiceAccount: ss[0],
+	})
+	assert.NoError(t, err)
+
+	_, err = e.AuthenticateServiceAccount(s.Token)
+	assert.Equal(t, gorm.RecordNotFound, err)
+
+	events, err := e.OutboxEvents(empire.OutboxEventsQuery{Undelivered: true})
+	assert.NoError(t, err)
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Name)
+	}
+	assert.Equal(t, []string{"service_account_create", "service_account_create", "service_account_revoke", "service_account_revoke"}, names)
+}
+
 type mockScheduler struct {
 	empire.Scheduler
 	mock.Mock
 }
 
+// blockingScheduler is a Scheduler that blocks when submitting the given
+// release, until the submission is canceled.
+type blockingScheduler struct {
+	empire.Scheduler
+	Release string
+
+	submitted chan struct{}
+	cancelled bool
+}
+
+func (s *blockingScheduler) Submit(ctx context.Context, app *twelvefactor.Manifest, ss twelvefactor.StatusStream) error {
+	if app.Release != s.Release {
+		return s.Scheduler.Submit(ctx, app, ss)
+	}
+
+	close(s.submitted)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *blockingScheduler) Cancel(ctx context.Context, appID string) error {
+	s.cancelled = true
+	return nil
+}
+
+// blockingRegistry is an ImageRegistry that blocks when pulling the image with
+// the given tag, until the deployment is canceled.
+type blockingRegistry struct {
+	empire.ImageRegistry
+	Tag string
+
+	extracting chan struct{}
+}
+
+func (r *blockingRegistry) ExtractProcfile(ctx context.Context, img image.Image, w *jsonmessage.Stream) ([]byte, error) {
+	if img.Tag != r.Tag {
+		return r.ImageRegistry.ExtractProcfile(ctx, img, w)
+	}
+
+	close(r.extracting)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
 type processesByType []*twelvefactor.Process
 
 func (e processesByType) Len() int           { return len(e) }
@@ -3,6 +3,7 @@
 package twelvefactor
 
 import (
+	"fmt"
 	"io"
 	"time"
 
@@ -30,6 +31,37 @@ type Manifest struct {
 
 	// Process that belong to this app.
 	Processes []*Process
+
+	// If provided, a new version of the app that should be gradually rolled
+	// out alongside this version.
+	Canary *Canary
+
+	// If true, Submit should return a DeploymentError when the new version
+	// of the app fails to become healthy, so that it can be rolled back.
+	// Otherwise, the failure is only reported to the StatusStream.
+	DetectFailures bool
+}
+
+// Canary represents a new version of an app that receives a percentage of
+// traffic, alongside the current version.
+type Canary struct {
+	// The new version of the app.
+	Manifest *Manifest
+
+	// The percentage of traffic (1-99) that should be sent to the new
+	// version.
+	Weight int
+}
+
+// Process returns the process of the given type, or nil if the app doesn't
+// have a process of that type.
+func (m *Manifest) Process(name string) *Process {
+	for _, p := range m.Processes {
+		if p.Type == name {
+			return p
+		}
+	}
+	return nil
 }
 
 type Process struct {
@@ -70,6 +102,18 @@ type Process struct {
 	// Any ECS specific configuration.
 	ECS *procfile.ECS
 
+	// If provided, the scheduler should automatically scale the process
+	// within these bounds, treating Quantity as the baseline.
+	Autoscaling *procfile.Autoscaling
+
+	// If provided, configures the load balancer and container health
+	// checks for the process.
+	HealthCheck *procfile.HealthCheck
+
+	// Additional containers to run alongside each instance of the
+	// process.
+	Sidecars []*Sidecar
+
 	// Input/Output streams.
 	Stdin          io.Reader
 	Stdout, Stderr io.Writer
@@ -145,6 +189,32 @@ type Host struct {
 	ID string
 }
 
+// Sidecar represents an additional container that runs alongside each
+// instance of a process, and shares its lifecycle.
+type Sidecar struct {
+	// The name of the container, which is unique within the process.
+	Name string
+
+	// The Image to run.
+	Image image.Image
+
+	// The Command to run. If empty, the default command for the image is
+	// used.
+	Command []string
+
+	// Environment variables to set.
+	Env map[string]string
+
+	// Container ports that the sidecar listens on.
+	Ports []int
+
+	// When true, the instance is stopped if the sidecar exits.
+	Essential bool
+
+	// The amount of RAM to allocate to this sidecar in bytes.
+	Memory uint
+}
+
 // Task represents an Task of a Process.
 type Task struct {
 	Process *Process
@@ -160,6 +230,22 @@ type Task struct {
 
 	// The time that this instance was last updated.
 	UpdatedAt time.Time
+
+	// The state of the sidecar containers running alongside this
+	// instance.
+	Sidecars []*SidecarTask
+}
+
+// SidecarTask represents a sidecar container within a Task.
+type SidecarTask struct {
+	// The name of the sidecar.
+	Name string
+
+	// The command that the sidecar is running.
+	Command []string
+
+	// The State that this sidecar is in.
+	State string
 }
 
 // Scheduler is an interface for interfacing with Services.
@@ -186,6 +272,35 @@ type Scheduler interface {
 
 	// Restart restarts the processes within the App.
 	Restart(context.Context, string, StatusStream) error
+
+	// Cancel cancels any in progress, or pending, Submit for the App.
+	// Schedulers that can't cancel a submission themselves should return
+	// nil, and stop when the context.Context passed to Submit is canceled.
+	Cancel(ctx context.Context, app string) error
+}
+
+// DeploymentError can be returned by Submit when a StatusStream is provided, and
+// the new version of the app failed to become healthy (e.g. the processes
+// never stabilized, or the underlying infrastructure rolled back the change).
+type DeploymentError struct {
+	// A human readable reason for why the deployment failed.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *DeploymentError) Error() string {
+	return fmt.Sprintf("deployment failed: %s", e.Reason)
+}
+
+// ExitError is returned from Run when an attached process exits with a non-zero
+// exit code.
+type ExitError struct {
+	Code int
+}
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
 }
 
 // Trasnform wraps a Scheduler to perform transformations on the Manifest. This
@@ -238,6 +353,10 @@ func merge(envs ...map[string]string) map[string]string {
 type Status struct {
 	// A friendly human readable message about the status change.
 	Message string
+
+	// True when the changes have been submitted, and the scheduler is
+	// waiting for the app to become stable.
+	Stabilizing bool
 }
 
 // String implements the fmt.Stringer interface.